@@ -0,0 +1,379 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: rokocr/v1/rokocr.proto
+
+// OCR-as-a-service API of rok-server, alongside the HTTP API. Generate clients of other languages from this file,
+// e.g. python -m grpc_tools.protoc -I api --python_out=. --grpc_python_out=. api/rokocr/v1/rokocr.proto
+
+package rokocrv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	OCR_ListTemplates_FullMethodName = "/rokocr.v1.OCR/ListTemplates"
+	OCR_Recognize_FullMethodName     = "/rokocr.v1.OCR/Recognize"
+	OCR_CreateJob_FullMethodName     = "/rokocr.v1.OCR/CreateJob"
+	OCR_GetJob_FullMethodName        = "/rokocr.v1.OCR/GetJob"
+	OCR_WatchJob_FullMethodName      = "/rokocr.v1.OCR/WatchJob"
+)
+
+// OCRClient is the client API for OCR service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// OCR - calls are authenticated like the HTTP API: "authorization: Basic <base64 name:password>" metadata of a local
+// account (not needed when the server has no sign in set up)
+type OCRClient interface {
+	// ListTemplates - templates loaded by the server
+	ListTemplates(ctx context.Context, in *ListTemplatesRequest, opts ...grpc.CallOption) (*ListTemplatesResponse, error)
+	// Recognize - recognizes streamed images right away, without a job. First message may set the options, results
+	// come back in the order images were sent (a ranking list produces a result per row). The server reads the next
+	// image only when a worker is free, so fast clients are slowed down by flow control instead of piling up images.
+	Recognize(ctx context.Context, opts ...grpc.CallOption) (OCR_RecognizeClient, error)
+	// CreateJob - queues a job of streamed images (first message may set job options), like POST /api/jobs
+	CreateJob(ctx context.Context, opts ...grpc.CallOption) (OCR_CreateJobClient, error)
+	// GetJob - job state and results so far, like GET /api/jobs/:id
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// WatchJob - progress of the job, a message per processed file, until it completes or fails
+	WatchJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (OCR_WatchJobClient, error)
+}
+
+type oCRClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOCRClient(cc grpc.ClientConnInterface) OCRClient {
+	return &oCRClient{cc}
+}
+
+func (c *oCRClient) ListTemplates(ctx context.Context, in *ListTemplatesRequest, opts ...grpc.CallOption) (*ListTemplatesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTemplatesResponse)
+	err := c.cc.Invoke(ctx, OCR_ListTemplates_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oCRClient) Recognize(ctx context.Context, opts ...grpc.CallOption) (OCR_RecognizeClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OCR_ServiceDesc.Streams[0], OCR_Recognize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &oCRRecognizeClient{ClientStream: stream}
+	return x, nil
+}
+
+type OCR_RecognizeClient interface {
+	Send(*RecognizeRequest) error
+	Recv() (*Result, error)
+	grpc.ClientStream
+}
+
+type oCRRecognizeClient struct {
+	grpc.ClientStream
+}
+
+func (x *oCRRecognizeClient) Send(m *RecognizeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *oCRRecognizeClient) Recv() (*Result, error) {
+	m := new(Result)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *oCRClient) CreateJob(ctx context.Context, opts ...grpc.CallOption) (OCR_CreateJobClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OCR_ServiceDesc.Streams[1], OCR_CreateJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &oCRCreateJobClient{ClientStream: stream}
+	return x, nil
+}
+
+type OCR_CreateJobClient interface {
+	Send(*CreateJobRequest) error
+	CloseAndRecv() (*Job, error)
+	grpc.ClientStream
+}
+
+type oCRCreateJobClient struct {
+	grpc.ClientStream
+}
+
+func (x *oCRCreateJobClient) Send(m *CreateJobRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *oCRCreateJobClient) CloseAndRecv() (*Job, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Job)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *oCRClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, OCR_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *oCRClient) WatchJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (OCR_WatchJobClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OCR_ServiceDesc.Streams[2], OCR_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &oCRWatchJobClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type OCR_WatchJobClient interface {
+	Recv() (*JobProgress, error)
+	grpc.ClientStream
+}
+
+type oCRWatchJobClient struct {
+	grpc.ClientStream
+}
+
+func (x *oCRWatchJobClient) Recv() (*JobProgress, error) {
+	m := new(JobProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OCRServer is the server API for OCR service.
+// All implementations must embed UnimplementedOCRServer
+// for forward compatibility
+//
+// OCR - calls are authenticated like the HTTP API: "authorization: Basic <base64 name:password>" metadata of a local
+// account (not needed when the server has no sign in set up)
+type OCRServer interface {
+	// ListTemplates - templates loaded by the server
+	ListTemplates(context.Context, *ListTemplatesRequest) (*ListTemplatesResponse, error)
+	// Recognize - recognizes streamed images right away, without a job. First message may set the options, results
+	// come back in the order images were sent (a ranking list produces a result per row). The server reads the next
+	// image only when a worker is free, so fast clients are slowed down by flow control instead of piling up images.
+	Recognize(OCR_RecognizeServer) error
+	// CreateJob - queues a job of streamed images (first message may set job options), like POST /api/jobs
+	CreateJob(OCR_CreateJobServer) error
+	// GetJob - job state and results so far, like GET /api/jobs/:id
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// WatchJob - progress of the job, a message per processed file, until it completes or fails
+	WatchJob(*GetJobRequest, OCR_WatchJobServer) error
+	mustEmbedUnimplementedOCRServer()
+}
+
+// UnimplementedOCRServer must be embedded to have forward compatible implementations.
+type UnimplementedOCRServer struct {
+}
+
+func (UnimplementedOCRServer) ListTemplates(context.Context, *ListTemplatesRequest) (*ListTemplatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTemplates not implemented")
+}
+func (UnimplementedOCRServer) Recognize(OCR_RecognizeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Recognize not implemented")
+}
+func (UnimplementedOCRServer) CreateJob(OCR_CreateJobServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateJob not implemented")
+}
+func (UnimplementedOCRServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedOCRServer) WatchJob(*GetJobRequest, OCR_WatchJobServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedOCRServer) mustEmbedUnimplementedOCRServer() {}
+
+// UnsafeOCRServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OCRServer will
+// result in compilation errors.
+type UnsafeOCRServer interface {
+	mustEmbedUnimplementedOCRServer()
+}
+
+func RegisterOCRServer(s grpc.ServiceRegistrar, srv OCRServer) {
+	s.RegisterService(&OCR_ServiceDesc, srv)
+}
+
+func _OCR_ListTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTemplatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OCRServer).ListTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OCR_ListTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OCRServer).ListTemplates(ctx, req.(*ListTemplatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OCR_Recognize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OCRServer).Recognize(&oCRRecognizeServer{ServerStream: stream})
+}
+
+type OCR_RecognizeServer interface {
+	Send(*Result) error
+	Recv() (*RecognizeRequest, error)
+	grpc.ServerStream
+}
+
+type oCRRecognizeServer struct {
+	grpc.ServerStream
+}
+
+func (x *oCRRecognizeServer) Send(m *Result) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *oCRRecognizeServer) Recv() (*RecognizeRequest, error) {
+	m := new(RecognizeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _OCR_CreateJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OCRServer).CreateJob(&oCRCreateJobServer{ServerStream: stream})
+}
+
+type OCR_CreateJobServer interface {
+	SendAndClose(*Job) error
+	Recv() (*CreateJobRequest, error)
+	grpc.ServerStream
+}
+
+type oCRCreateJobServer struct {
+	grpc.ServerStream
+}
+
+func (x *oCRCreateJobServer) SendAndClose(m *Job) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *oCRCreateJobServer) Recv() (*CreateJobRequest, error) {
+	m := new(CreateJobRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _OCR_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OCRServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OCR_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OCRServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OCR_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OCRServer).WatchJob(m, &oCRWatchJobServer{ServerStream: stream})
+}
+
+type OCR_WatchJobServer interface {
+	Send(*JobProgress) error
+	grpc.ServerStream
+}
+
+type oCRWatchJobServer struct {
+	grpc.ServerStream
+}
+
+func (x *oCRWatchJobServer) Send(m *JobProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// OCR_ServiceDesc is the grpc.ServiceDesc for OCR service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OCR_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rokocr.v1.OCR",
+	HandlerType: (*OCRServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListTemplates",
+			Handler:    _OCR_ListTemplates_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _OCR_GetJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Recognize",
+			Handler:       _OCR_Recognize_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "CreateJob",
+			Handler:       _OCR_CreateJob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchJob",
+			Handler:       _OCR_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rokocr/v1/rokocr.proto",
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	config "github.com/rokmonster/ocr/internal/pkg/config/compareconfig"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	log "github.com/sirupsen/logrus"
+)
+
+var flags = config.Parse()
+
+func readCSV(name string, template schema.OCRTemplate) []schema.OCRResult {
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatalf("Failed to open scan: %v", err)
+	}
+	defer f.Close()
+
+	results, err := rokocr.ReadCSV(f, template)
+	if err != nil {
+		log.Fatalf("Failed to read scan %v: %v", name, err)
+	}
+	return results
+}
+
+func printTable(rows []rokocr.CompareRow, opts rokocr.CompareOptions) {
+	headers := append([]string{opts.Key}, opts.Columns...)
+	for _, f := range opts.Fields {
+		headers = append(headers, f, "Δ "+f)
+	}
+	if opts.Missing {
+		headers = append(headers, "status")
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader(headers)
+	for _, row := range rows {
+		record := []string{row.Key}
+		for _, c := range opts.Columns {
+			record = append(record, row.Columns[c])
+		}
+		for _, f := range opts.Fields {
+			v := row.Values[f]
+			record = append(record, rokocr.FormatCompareValue(v.End), rokocr.FormatCompareValue(v.Delta()))
+		}
+		if opts.Missing {
+			record = append(record, row.Status)
+		}
+		table.Append(record)
+	}
+	table.Render()
+}
+
+func main() {
+	if len(flags.Start) == 0 || len(flags.End) == 0 {
+		config.Usage()
+		os.Exit(2)
+	}
+
+	var template schema.OCRTemplate
+	if len(flags.Template) > 0 {
+		var err error
+		if template, err = schema.LoadTemplate(flags.Template); err != nil {
+			log.Fatalf("Failed to load template: %v => %v", flags.Template, err)
+		}
+	}
+
+	opts := rokocr.CompareOptions{
+		Key:     flags.Key,
+		Fields:  config.List(flags.Fields),
+		Columns: config.List(flags.Columns),
+		Missing: flags.Missing,
+	}
+
+	rows := rokocr.Compare(readCSV(flags.Start, template), readCSV(flags.End, template), template, opts)
+	log.Infof("Compared %v governors", len(rows))
+
+	if len(flags.Output) == 0 {
+		printTable(rows, opts)
+		return
+	}
+
+	delimiter, err := rokocr.ParseDelimiter(flags.CSVDelimiter)
+	if err != nil {
+		log.Fatalf("Invalid -csv-delimiter: %v", err)
+	}
+
+	f, err := os.Create(flags.Output)
+	if err != nil {
+		log.Fatalf("Failed to write comparison: %v", err)
+	}
+	defer f.Close()
+
+	if err := rokocr.WriteCompareCSV(rows, opts, rokocr.CSVOptions{Delimiter: delimiter, Header: true, BOM: flags.CSVBOM}, f); err != nil {
+		log.Fatalf("Failed to write comparison: %v", err)
+	}
+	log.Infof("Comparison written to: %v", flags.Output)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	config "github.com/rokmonster/ocr/internal/pkg/config/emulatorconfig"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/emulator"
+	log "github.com/sirupsen/logrus"
+)
+
+var flags = config.Parse()
+
+// loadRoutine - routine of the flag, nil if not given
+func loadRoutine(name, what string) *emulator.Routine {
+	if len(strings.TrimSpace(name)) == 0 {
+		return nil
+	}
+	r, err := emulator.LoadRoutine(name)
+	if err != nil {
+		log.Fatalf("Failed to load %v routine: %v", what, err)
+	}
+	return &r
+}
+
+// scan - runs rok-scanner capturing the ranking open on the device, so all of its outputs (-db, -sheets-id,
+// -webhook, ...) are available
+func scan(ctx context.Context, serial string) error {
+	args := []string{
+		"-adb-capture", fmt.Sprint(flags.Capture),
+		"-adb-serial", serial,
+		"-adb-port", fmt.Sprint(flags.ADBPort),
+	}
+	if len(flags.ADBPlan) > 0 {
+		args = append(args, "-adb-plan", flags.ADBPlan)
+	}
+	args = append(args, flags.ScannerArgs...)
+
+	cmd := exec.CommandContext(ctx, flags.Scanner, args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// interrupted scanner still writes out what it has recognized
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = time.Minute
+
+	log.Infof("Running %v %v", flags.Scanner, strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %v", flags.Scanner, err)
+	}
+	return nil
+}
+
+func main() {
+	schedule, err := emulator.ParseSchedule(flags.At)
+	if err != nil {
+		log.Fatalf("Invalid -at: %v", err)
+	}
+	if _, err := exec.LookPath(flags.Scanner); err != nil {
+		log.Fatalf("Can't find rok-scanner (-scanner): %v", err)
+	}
+
+	creds, err := emulator.LoadCredentials(flags.Credentials)
+	if err != nil {
+		log.Fatalf("Failed to load credentials: %v", err)
+	}
+
+	opts := emulator.Options{
+		Container:     flags.Container,
+		ADBPort:       flags.ADBPort,
+		BootTimeout:   flags.BootTimeout,
+		Package:       flags.Package,
+		APKs:          flags.APKList(),
+		StartDelay:    flags.StartDelay,
+		Login:         loadRoutine(flags.Login, "login"),
+		Relogin:       flags.Relogin,
+		Open:          loadRoutine(flags.Open, "open"),
+		Credentials:   creds,
+		Scan:          scan,
+		StopContainer: flags.Stop,
+	}
+	if opts.Open == nil {
+		log.Warnf("No -open routine, the game has to show the ranking list on its own after loading")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	orchestrator := emulator.New(opts)
+	if flags.Once {
+		if err := orchestrator.Cycle(ctx); err != nil {
+			log.Fatalf("Scan failed: %v", err)
+		}
+		return
+	}
+
+	log.Infof("Scanning daily at %v", schedule)
+	orchestrator.Run(ctx, schedule)
+}
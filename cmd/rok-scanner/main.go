@@ -1,12 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/adbcapture"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/clipboard"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/events"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/fieldcache"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/pdfpages"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/quality"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/resultsdb"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/session"
 	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/training"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/urlinput"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/videoframes"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/watchfolder"
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
 
 	"github.com/olekukonko/tablewriter"
 	config "github.com/rokmonster/ocr/internal/pkg/config/scannerconfig"
@@ -30,7 +57,12 @@ func printResultsTable(data []schema.OCRResult, template schema.OCRTemplate) {
 		rowData := []string{row.Filename}
 
 		for _, x := range template.Table {
-			rowData = append(rowData, fmt.Sprintf("%v", row.Data[x.Field]))
+			value := schema.FormatValue(row.Data[x.Field])
+			if row.Fields[x.Field].LowConfidence {
+				// make unreliable values easy to spot
+				value = value + " (?)"
+			}
+			rowData = append(rowData, value)
 		}
 		table.Append(rowData)
 	}
@@ -38,19 +70,1060 @@ func printResultsTable(data []schema.OCRResult, template schema.OCRTemplate) {
 	table.Render()
 }
 
-func writeCSV(data []schema.OCRResult, template schema.OCRTemplate) {
+func writeCSV(data []schema.OCRResult, template schema.OCRTemplate, name string) {
 
-	fd, err := os.Create(fmt.Sprintf("%s/%v.csv", flags.OutputDirectory, time.Now().Unix()))
+	fd, err := os.Create(fmt.Sprintf("%s/%v.csv", flags.OutputDirectory, name))
 	if err != nil {
 		log.Fatalf("Failed to write csv: %v", err)
 		return
 	}
 	defer fd.Close()
 
-	rokocr.WriteCSV(data, template, fd)
+	if err := rokocr.WriteCSVWithOptions(data, template, csvOptions(), fd); err != nil {
+		log.Errorf("Failed to write csv: %v", err)
+	}
+}
+
+func writeXLSX(data []schema.OCRResult, template schema.OCRTemplate, name string) {
+	fd, err := os.Create(fmt.Sprintf("%s/%v.xlsx", flags.OutputDirectory, name))
+	if err != nil {
+		log.Errorf("Failed to write xlsx: %v", err)
+		return
+	}
+	defer fd.Close()
+
+	if err := rokocr.WriteXLSX(data, template, fd); err != nil {
+		log.Errorf("Failed to write xlsx: %v", err)
+	}
+}
+
+// writeHTMLReport - thumbnails are cropped out of the screenshots in media dir, records merged of several
+// screenshots have none
+func writeHTMLReport(data []schema.OCRResult, template schema.OCRTemplate, templates []schema.OCRTemplate, name string) {
+	fd, err := os.Create(filepath.Join(flags.OutputDirectory, name+"_report.html"))
+	if err != nil {
+		log.Errorf("Failed to write html report: %v", err)
+		return
+	}
+	defer fd.Close()
+
+	// ranking lists have many rows in one screenshot
+	screenshots := make(map[string]image.Image)
+	crops := func(row schema.OCRResult) map[string]image.Image {
+		if len(row.Parts) > 1 {
+			return nil
+		}
+		key := fmt.Sprintf("%v#%v", row.Filename, row.Page)
+		img, ok := screenshots[key]
+		if !ok {
+			img, err = readScreenshot(row)
+			if err != nil {
+				logutils.File(row.Filename).Warnf("No thumbnails: %v", err)
+			}
+			screenshots[key] = img
+		}
+		if img == nil {
+			return nil
+		}
+		t := resultTemplate(row, template, templates)
+		return t.ResultCrops(t.Panel(img, row), row)
+	}
+
+	if err := rokocr.WriteHTMLReport(data, template, crops, fd); err != nil {
+		log.Errorf("Failed to write html report: %v", err)
+	}
+}
+
+// writeTemplated - results rendered through every -output-template, named after the template file
+func writeTemplated(data []schema.OCRResult, template schema.OCRTemplate, name string) {
+	for _, path := range flags.OutputTemplateList() {
+		tmpl, err := rokocr.ParseOutputTemplateFile(path)
+		if err != nil {
+			log.Errorf("Failed to parse output template: %v", err)
+			continue
+		}
+
+		ext := rokocr.OutputTemplateExtension(path)
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		base = strings.TrimSuffix(base, ext)
+		fd, err := os.Create(filepath.Join(flags.OutputDirectory, fmt.Sprintf("%v_%v%v", name, base, ext)))
+		if err != nil {
+			log.Errorf("Failed to write %v: %v", path, err)
+			continue
+		}
+
+		if err := rokocr.WriteTemplated(data, template, tmpl, fd); err != nil {
+			log.Errorf("Failed to render %v: %v", path, err)
+		}
+		fd.Close()
+	}
+}
+
+func writeReport(report *rokocr.RunReport, name string) {
+	path := flags.Report
+	if len(strings.TrimSpace(path)) == 0 {
+		path = filepath.Join(flags.OutputDirectory, name+"_report.json")
+	}
+
+	fd, err := os.Create(path)
+	if err != nil {
+		log.Errorf("Failed to write report: %v", err)
+		return
+	}
+	defer fd.Close()
+
+	if err := report.Write(fd); err != nil {
+		log.Errorf("Failed to write report: %v", err)
+	}
+}
+
+// readScreenshot - image the result was recognized from (page of a PDF), with borders cropped the same way
+func readScreenshot(row schema.OCRResult) (image.Image, error) {
+	f := filepath.Join(flags.MediaDirectory, row.Filename)
+
+	var img image.Image
+	var err error
+	if row.Page > 0 {
+		img, err = pdfpages.Page(context.Background(), f, row.Page, flags.PDFOptions())
+	} else {
+		img, err = imgutils.ReadImageFile(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if flags.CropBorders {
+		img = imgutils.CropBorders(img)
+	}
+	return img, nil
+}
+
+func writeAnnotated(data []schema.OCRResult, template schema.OCRTemplate) {
+	for _, row := range data {
+		img, err := readScreenshot(row)
+		if err != nil {
+			logutils.File(row.Filename).Warnf("Can't annotate: %v", err)
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(row.Filename), filepath.Ext(row.Filename))
+		if row.Page > 0 {
+			name = fmt.Sprintf("%s_p%d", name, row.Page)
+		}
+		if row.Panel > 0 {
+			name = fmt.Sprintf("%s_%d", name, row.Panel)
+		}
+		name = name + "_annotated.png"
+		_ = imgutils.WritePNGImage(template.AnnotatedResult(template.Panel(img, row), row), filepath.Join(flags.OutputDirectory, name))
+	}
+}
+
+func dumpCrops(data []schema.OCRResult, template schema.OCRTemplate) {
+	for _, row := range data {
+		img, err := readScreenshot(row)
+		if err != nil {
+			logutils.File(row.Filename).Warnf("Can't dump crops: %v", err)
+			continue
+		}
+
+		prefix := strings.TrimSuffix(filepath.Base(row.Filename), filepath.Ext(row.Filename))
+		if row.Page > 0 {
+			prefix = fmt.Sprintf("%s_p%d", prefix, row.Page)
+		}
+		if row.Panel > 0 {
+			prefix = fmt.Sprintf("%s_%d", prefix, row.Panel)
+		}
+		if err := template.DumpCrops(template.Panel(img, row), flags.CropsDir, prefix); err != nil {
+			logutils.File(row.Filename).Errorf("Failed to dump crops: %v", err)
+			continue
+		}
+
+		// recognized text as draft training labels (see rok-templates boxes), labels already fixed by hand are kept
+		for k, f := range row.Fields {
+			label := training.LabelFile(filepath.Join(flags.CropsDir, fmt.Sprintf("%s_%s.png", prefix, k)))
+			if _, err := os.Stat(label); err == nil {
+				continue
+			}
+			_ = os.WriteFile(label, []byte(strings.TrimSpace(f.Text)+"\n"), 0644)
+		}
+	}
+}
+
+// sheetsBatchSize - results are pushed to Google Sheets in batches while scanning, so stats show up live
+const sheetsBatchSize = 20
+
+type sheetsPusher struct {
+	exporter  *rokocr.SheetsExporter
+	template  schema.OCRTemplate
+	templates []schema.OCRTemplate
+	pending   []schema.OCRResult
+}
+
+// newSheetsPusher - if template is not known upfront (adb capture), it's picked from templates for every batch
+func newSheetsPusher(template schema.OCRTemplate, templates []schema.OCRTemplate) *sheetsPusher {
+	p := &sheetsPusher{template: template, templates: templates}
+	if len(strings.TrimSpace(flags.SheetsID)) == 0 {
+		return p
+	}
+
+	exporter, err := rokocr.NewSheetsExporter(context.Background(), rokocr.SheetsOptions{
+		CredentialsFile: flags.SheetsCredentials,
+		SpreadsheetID:   flags.SheetsID,
+		Sheet:           flags.SheetsTab,
+		KeyField:        flags.SheetsKey,
+		Retry:           retryutils.Options{MaxRetries: flags.MaxRetries, Backoff: flags.Backoff},
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up Google Sheets: %v", err)
+	}
+	p.exporter = exporter
+	return p
+}
+
+func (p *sheetsPusher) add(result schema.OCRResult) {
+	if p.exporter == nil {
+		return
+	}
+	p.pending = append(p.pending, result)
+	if len(p.pending) >= sheetsBatchSize {
+		p.flush()
+	}
+}
+
+func (p *sheetsPusher) flush() {
+	if p.exporter == nil || len(p.pending) == 0 {
+		return
+	}
+
+	batch := p.pending
+	p.pending = nil
+
+	template := p.template
+	if len(template.OCRSchema) == 0 && len(p.templates) > 0 {
+		template = mostUsedTemplate(batch, p.templates)
+	}
+
+	if flags.ValidOnly {
+		batch, _ = template.FilterValid(batch)
+	}
+
+	if err := p.exporter.Push(context.Background(), batch, template); err != nil {
+		log.Errorf("Failed to push results to Google Sheets: %v", err)
+		return
+	}
+	log.Infof("Pushed %v rows to Google Sheets", len(batch))
+}
+
+// resultsRecorder - stores the run & every result into results database (-db) as it's recognized,
+// so even an interrupted scan is kept
+type resultsRecorder struct {
+	// mu - failures are recorded from recognition goroutines
+	mu        sync.Mutex
+	store     *resultsdb.Store
+	run       resultsdb.Run
+	template  schema.OCRTemplate
+	templates []schema.OCRTemplate
+}
+
+func newResultsRecorder(template schema.OCRTemplate, templates []schema.OCRTemplate, source string) *resultsRecorder {
+	r := &resultsRecorder{template: template, templates: templates}
+	if len(strings.TrimSpace(flags.ResultsDB)) == 0 {
+		return r
+	}
+
+	store, err := resultsdb.Open(flags.ResultsDB)
+	if err != nil {
+		log.Fatalf("Failed to open results database: %v", err)
+	}
+	if r.run, err = store.StartRun(template, source, flags.ResultsKey, scanTags()); err != nil {
+		log.Fatalf("Failed to record scan: %v", err)
+	}
+	r.store = store
+	log.Infof("Recording scan #%d into: %v", r.run.ID, flags.ResultsDB)
+	return r
+}
+
+func (r *resultsRecorder) add(result schema.OCRResult) {
+	if r.store == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.store.AddResults(&r.run, resultTemplate(result, r.template, r.templates), result); err != nil {
+		log.Errorf("Failed to record result: %v", err)
+	}
+}
+
+// failed - file which couldn't be recognized, counted as a failure of the run's template (see stats command)
+func (r *resultsRecorder) failed(file string, err error) {
+	r.add(schema.OCRResult{Filename: mediaRelative(file), Data: map[string]interface{}{}, Error: err.Error()})
+}
+
+func (r *resultsRecorder) finish(template schema.OCRTemplate) {
+	if r.store == nil {
+		return
+	}
+	defer r.store.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Template = template.Title
+	if err := r.store.FinishRun(&r.run); err != nil {
+		log.Errorf("Failed to record scan: %v", err)
+	}
+}
+
+// openSession - resumes session given by -session, or starts a new one
+func openSession() *session.Session {
+	id := strings.TrimSpace(flags.Session)
+	if len(id) == 0 {
+		id = session.NewID()
+	}
+
+	scan, err := session.Open(filepath.Join(flags.OutputDirectory, session.Folder), id)
+	if err != nil {
+		log.Fatalf("Failed to open session: %v", err)
+	}
+	if n := scan.Resumed(); n > 0 {
+		log.Infof("Resuming session %v, %v files already processed", scan.ID, n)
+	} else {
+		log.Infof("Session %v, if interrupted resume with: -session %v", scan.ID, scan.ID)
+	}
+	return scan
+}
+
+// openFieldCache - cache of -field-cache, nil if disabled
+func openFieldCache() *fieldcache.Cache {
+	if len(strings.TrimSpace(flags.FieldCache)) == 0 {
+		return nil
+	}
+	cache, err := fieldcache.Open(flags.FieldCache)
+	if err != nil {
+		log.Fatalf("Failed to open field cache: %v", err)
+	}
+	log.Infof("Field cache: %v (%v fields)", flags.FieldCache, cache.Len())
+	return cache
+}
+
+// closeSession - journal of a completed scan is removed, unless the session was named explicitly
+func closeSession(scan *session.Session) {
+	if len(strings.TrimSpace(flags.Session)) > 0 {
+		_ = scan.Close()
+		return
+	}
+	if err := scan.Remove(); err != nil {
+		log.Warnf("Failed to remove session journal: %v", err)
+	}
+}
+
+// scanSource - what is being scanned, recorded with the run
+func scanSource() string {
+	switch {
+	case flags.ADBCapture > 0:
+		return "adb:" + flags.ADBSerial
+	case len(flags.Video) > 0:
+		return flags.Video
+	default:
+		return flags.MediaDirectory
+	}
+}
+
+// stats - per template statistics of all scans in results database (stats command): how many screenshots were
+// recognized, failure rate & confidence of fields, and how the latest scan compares to earlier ones
+func stats() {
+	store := openResultsDB()
+	defer store.Close()
+
+	runs, err := store.Stats()
+	if err != nil {
+		log.Fatalf("Failed to read statistics: %v", err)
+	}
+	summaries := resultsdb.Summarize(runs)
+	if len(summaries) == 0 {
+		log.Warnf("No scans in: %v", flags.ResultsDB)
+		return
+	}
+	resultsdb.WriteStats(os.Stdout, summaries)
+}
+
+// history - results of the governor (history command) across all scans in results database,
+// with -forceTemplate its fields are the columns
+func history() {
+	if len(flags.Args) != 1 {
+		log.Errorf("history expects a single governor key (see -db-key)")
+		config.Usage()
+		os.Exit(1)
+	}
+
+	var template schema.OCRTemplate
+	if len(strings.TrimSpace(flags.ForceTemplate)) > 0 {
+		var err error
+		if template, err = schema.LoadTemplate(flags.ForceTemplate); err != nil {
+			log.Fatalf("Failed to load template: %v => %v", flags.ForceTemplate, err)
+		}
+	}
+
+	store := openResultsDB()
+	defer store.Close()
+
+	key, entries, err := store.HistoryOf(template, flags.ResultsKey, flags.Args[0])
+	if err != nil {
+		log.Fatalf("Failed to read history: %v", err)
+	}
+	if len(entries) == 0 {
+		log.Warnf("No results of %q in: %v", key, flags.ResultsDB)
+		return
+	}
+	resultsdb.WriteHistory(os.Stdout, entries, resultsdb.HistoryFields(template, entries))
+}
+
+// openResultsDB - results database (-db) the commands query
+func openResultsDB() *resultsdb.Store {
+	if len(flags.ResultsDB) == 0 {
+		log.Fatalf("No results database, set -db")
+	}
+	store, err := resultsdb.Open(flags.ResultsDB)
+	if err != nil {
+		log.Fatalf("Failed to open results database: %v", err)
+	}
+	return store
+}
+
+type discordNotifier struct {
+	notifier *rokocr.DiscordNotifier
+	failures atomic.Int64
+}
+
+func newDiscordNotifier() *discordNotifier {
+	n := &discordNotifier{}
+	if len(strings.TrimSpace(flags.DiscordWebhook)) > 0 {
+		n.notifier = rokocr.NewDiscordNotifier(flags.DiscordWebhook, retryutils.Options{MaxRetries: flags.MaxRetries, Backoff: flags.Backoff})
+	}
+	return n
+}
+
+func (n *discordNotifier) failed(file string, err error) {
+	n.failures.Add(1)
+	if n.notifier == nil || !errors.Is(err, tesseractutils.ErrNoTemplateMatch) {
+		return
+	}
+	if err := n.notifier.NoTemplateMatch(context.Background(), filepath.Base(file), err); err != nil {
+		log.Errorf("Failed to notify Discord: %v", err)
+	}
+}
+
+func (n *discordNotifier) completed(data []schema.OCRResult, template schema.OCRTemplate, took time.Duration) {
+	if n.notifier == nil {
+		return
+	}
+
+	summary := rokocr.ScanSummary{
+		Template: template.Title,
+		Rows:     len(data),
+		Failures: int(n.failures.Load()),
+		Took:     took,
+	}
+
+	if len(flags.PreviousScan) > 0 && len(flags.DeltaKey) > 0 && len(flags.DeltaField) > 0 {
+		previous, err := readCSV(flags.PreviousScan, template)
+		if err != nil {
+			log.Errorf("Failed to read previous scan: %v", err)
+		} else {
+			summary.DeltaField = flags.DeltaField
+			summary.Deltas = rokocr.Deltas(previous, data, template, flags.DeltaKey, flags.DeltaField)
+		}
+	}
+
+	if err := n.notifier.ScanCompleted(context.Background(), summary); err != nil {
+		log.Errorf("Failed to notify Discord: %v", err)
+	}
+}
+
+// newWebhookSink - nil, unless -webhook is set
+func newWebhookSink() *rokocr.WebhookSink {
+	if len(strings.TrimSpace(flags.Webhook)) == 0 {
+		return nil
+	}
+	return rokocr.NewWebhookSink(rokocr.WebhookOptions{
+		URL:    flags.Webhook,
+		Secret: flags.WebhookSecret,
+		Source: scanSource(),
+		Retry:  retryutils.Options{MaxRetries: flags.MaxRetries, Backoff: flags.Backoff},
+	})
+}
+
+// openEvents - nil (publishes nothing), unless -events is set
+func openEvents() *events.Publisher {
+	if len(strings.TrimSpace(flags.Events)) == 0 {
+		return nil
+	}
+	publisher, err := events.Open(flags.Events, flags.EventsTopic)
+	if err != nil {
+		log.Fatalf("Failed to set up events: %v", err)
+	}
+	return publisher
+}
+
+func readCSV(name string, template schema.OCRTemplate) ([]schema.OCRResult, error) {
+	fd, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return rokocr.ReadCSV(fd, template)
+}
+
+// captureFromDevice - taps through the ranking list on the device, screenshots are recognized on the fly
+func captureFromDevice(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	device, err := adbcapture.Connect(flags.ADBPort, flags.ADBSerial)
+	if err != nil {
+		log.Fatalf("Failed to connect to device: %v", err)
+	}
+
+	plan := adbcapture.DefaultRankingPlan()
+	if len(flags.ADBPlan) > 0 {
+		if plan, err = adbcapture.LoadRankingPlan(flags.ADBPlan); err != nil {
+			log.Fatalf("Failed to load capture plan: %v", err)
+		}
+	}
+
+	prefix := fmt.Sprintf("adb_%v", time.Now().Unix())
+	captured := adbcapture.CaptureRanking(opts.Context(), device, plan, flags.ADBCapture, prefix)
+	return recognizeImages(captured, templates, opts)
+}
+
+// framesFromVideo - pulls unique, stable screens out of a screen recording, and recognizes them on the fly
+func framesFromVideo(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	videoOpts := videoframes.DefaultOptions()
+	videoOpts.FFmpeg = flags.FFmpeg
+	videoOpts.FPS = flags.VideoFPS
+
+	frames, errs := videoframes.Frames(opts.Context(), flags.Video, videoOpts)
+	go func() {
+		for err := range errs {
+			log.Errorf("Failed to read video: %v", err)
+		}
+	}()
+
+	prefix := fmt.Sprintf("video_%v", time.Now().Unix())
+	return recognizeImages(videoframes.UniqueScreens(frames, videoOpts, prefix), templates, opts)
+}
+
+// singleImage - one-off recognition of a screenshot piped to stdin, or copied to the clipboard. Nothing is saved
+// to media dir, result is printed & written out as usual.
+func singleImage(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	var img image.Image
+	var err error
+	name := "stdin.png"
+	if flags.FromClipboard {
+		name = "clipboard.png"
+		img, err = clipboard.ReadImage(opts.Context())
+	} else {
+		img, err = imgutils.ReadImage(os.Stdin)
+	}
+	if err != nil {
+		log.Fatalf("Failed to read image from %v: %v", strings.TrimSuffix(name, ".png"), err)
+	}
+
+	images := make(chan tesseractutils.NamedImage, 1)
+	images <- tesseractutils.NamedImage{Name: name, Image: img}
+	close(images)
+
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+		for r := range tesseractutils.ProcessStreamWithOptions(opts.Context(), images, templates, 1, opts) {
+			if len(r.Error) > 0 {
+				logutils.File(r.Filename).Error(r.Error)
+				opts.OnFailure(filepath.Join(flags.MediaDirectory, r.Filename), resultError(r))
+				continue
+			}
+			out <- r
+		}
+	}()
+
+	return out
+}
+
+// downloadImages - screenshots given as links (e.g. Discord attachments) are downloaded into media dir,
+// and recognized with the best matching template
+func downloadImages(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	retry := retryutils.Options{MaxRetries: flags.MaxRetries, Backoff: flags.Backoff}
+
+	images := make(chan tesseractutils.NamedImage)
+	go func() {
+		defer close(images)
+		for i, link := range flags.URLs() {
+			f, err := urlinput.Fetch(opts.Context(), link, flags.MediaDirectory, urlinput.FileName(link, i+1), retry)
+			if err != nil {
+				log.Errorf("Failed to download: %v", err)
+				continue
+			}
+			img, err := imgutils.ReadImageFile(f)
+			if err != nil {
+				logutils.File(f).Errorf("Can't read: %v", err)
+				continue
+			}
+			logutils.File(f).Info("Downloaded")
+			select {
+			case images <- tesseractutils.NamedImage{Name: filepath.Base(f), Image: img}:
+			case <-opts.Context().Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+		for r := range tesseractutils.ProcessStreamWithOptions(opts.Context(), images, templates, opts.Jobs, opts) {
+			if len(r.Error) > 0 {
+				logutils.File(r.Filename).Error(r.Error)
+				opts.OnFailure(filepath.Join(flags.MediaDirectory, r.Filename), resultError(r))
+				continue
+			}
+			out <- r
+		}
+	}()
+
+	return out
+}
+
+// recognizeMediaDir - every screenshot of media dir is recognized with the best matching template, instead of one for all
+// (screens of several templates are merged into records, see -aggregate)
+func recognizeMediaDir(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	images := make(chan tesseractutils.NamedImage)
+	go func() {
+		defer close(images)
+		dir, _ := filepath.Abs(flags.MediaDirectory)
+		for _, f := range fileutils.ListFiles(dir, opts.Files) {
+			img, err := imgutils.ReadImageFile(f)
+			if err != nil {
+				log.WithField(logutils.FileKey, mediaRelative(f)).Errorf("Can't read: %v", err)
+				opts.OnFailure(f, err)
+				continue
+			}
+			select {
+			case images <- tesseractutils.NamedImage{Name: mediaRelative(f), Image: img}:
+			case <-opts.Context().Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+		for r := range tesseractutils.ProcessStreamWithOptions(opts.Context(), images, templates, opts.Jobs, opts) {
+			if len(r.Error) > 0 {
+				logutils.File(r.Filename).Error(r.Error)
+				opts.OnFailure(filepath.Join(flags.MediaDirectory, r.Filename), resultError(r))
+				continue
+			}
+			out <- r
+		}
+	}()
+
+	return out
+}
+
+// stitchMediaDir - screenshots of media dir are one scrolled ranking list, stitched into one image (kept in output dir)
+// and read row by row
+func stitchMediaDir(template schema.OCRTemplate, force bool, opts tesseractutils.Options) <-chan schema.OCRResult {
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+
+		dir, _ := filepath.Abs(flags.MediaDirectory)
+		files := fileutils.ListFiles(dir, opts.Files)
+		name := fmt.Sprintf("%v_stitched.png", time.Now().Unix())
+
+		img, results, err := tesseractutils.ParseStitchedWithOptions(name, files, template, force, opts)
+		if opts.Context().Err() != nil {
+			// rows were cut short
+			return
+		}
+		if err != nil {
+			log.Errorf("Failed to stitch %v screenshots: %v", len(files), err)
+			opts.OnFailure(filepath.Join(flags.MediaDirectory, name), err)
+			return
+		}
+		if err := imgutils.WritePNGImage(img, filepath.Join(flags.OutputDirectory, name)); err != nil {
+			log.Warnf("Failed to save stitched image: %v", err)
+		}
+		log.Infof("Stitched %v screenshots into %v, %v rows", len(files), name, len(results))
+
+		for _, r := range results {
+			out <- r
+		}
+	}()
+	return out
+}
+
+// recognizeImages - images are saved to media dir (so annotate & crops work the same as with files),
+// and recognized with the best matching template
+func recognizeImages(images <-chan tesseractutils.NamedImage, templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	fileutils.Mkdirs(flags.MediaDirectory)
+
+	saved := make(chan tesseractutils.NamedImage)
+	go func() {
+		defer close(saved)
+		for img := range images {
+			if err := imgutils.WritePNGImage(img.Image, filepath.Join(flags.MediaDirectory, img.Name)); err != nil {
+				logutils.File(img.Name).Warnf("Failed to save image: %v", err)
+			}
+			logutils.File(img.Name).Infof("Captured")
+			select {
+			case saved <- img:
+			case <-opts.Context().Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+		for r := range tesseractutils.ProcessStreamWithOptions(opts.Context(), saved, templates, opts.Jobs, opts) {
+			if len(r.Error) > 0 {
+				logutils.File(r.Filename).Error(r.Error)
+				opts.OnFailure(filepath.Join(flags.MediaDirectory, r.Filename), resultError(r))
+				continue
+			}
+			out <- r
+		}
+	}()
+
+	return out
+}
+
+// watchMediaDir - recognizes screenshots as they arrive into media dir, until interrupted. Every result is appended
+// to a per-template CSV right away, and the file is moved to done (or failed) sub-folder.
+func watchMediaDir(templates []schema.OCRTemplate, opts tesseractutils.Options) <-chan schema.OCRResult {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	watchOpts := watchfolder.DefaultOptions()
+	watchOpts.Files = flags.ListOptions()
+	watchOpts.Settle = flags.WatchSettle
+
+	files, err := watchfolder.Watch(ctx, flags.MediaDirectory, watchOpts)
+	if err != nil {
+		log.Fatalf("Failed to watch media dir: %v", err)
+	}
+	log.Infof("Watching %v for new screenshots, press Ctrl+C to stop", flags.MediaDirectory)
+
+	images := make(chan tesseractutils.NamedImage)
+	go func() {
+		defer close(images)
+		for f := range files {
+			img, err := imgutils.ReadImageFile(f)
+			if err != nil {
+				logutils.File(f).Errorf("Can't read: %v", err)
+				moveProcessed(f, watchfolder.FailedFolder)
+				continue
+			}
+			images <- tesseractutils.NamedImage{Name: filepath.Base(f), Image: img}
+		}
+	}()
+
+	out := make(chan schema.OCRResult)
+	go func() {
+		defer close(out)
+		defer stop()
+		// in-flight screenshots are finished after interrupt, so no file is left half-processed
+		for r := range tesseractutils.ProcessStreamWithOptions(context.Background(), images, templates, opts.Jobs, opts) {
+			f := filepath.Join(flags.MediaDirectory, r.Filename)
+			if len(r.Error) > 0 {
+				logutils.File(r.Filename).Error(r.Error)
+				opts.OnFailure(f, resultError(r))
+				if len(r.Quality) > 0 {
+					moveProcessed(f, quality.ReviewFolder)
+					continue
+				}
+				moveProcessed(f, watchfolder.FailedFolder)
+				continue
+			}
+
+			appendCSV(r, templateByTitle(templates, r.Template))
+			if target := moveProcessed(f, watchfolder.DoneFolder); len(target) > 0 {
+				r.Filename, _ = filepath.Rel(flags.MediaDirectory, target)
+			}
+			logutils.File(r.Filename).Infof("Done")
+			out <- r
+		}
+	}()
+
+	return out
+}
+
+func moveProcessed(f, folder string) string {
+	target, err := watchfolder.Move(f, folder)
+	if err != nil {
+		logutils.File(f).Errorf("Failed to move to %v: %v", folder, err)
+		return ""
+	}
+	return target
+}
+
+// resultTemplate - template the result was recognized with, picked per image out of templates (if known),
+// or the one used for the whole scan
+func resultTemplate(result schema.OCRResult, template schema.OCRTemplate, templates []schema.OCRTemplate) schema.OCRTemplate {
+	if len(result.Template) > 0 && len(templates) > 0 {
+		return templateByTitle(templates, result.Template)
+	}
+	return template
+}
+
+func templateByTitle(templates []schema.OCRTemplate, title string) schema.OCRTemplate {
+	for _, t := range templates {
+		if t.Title == title {
+			return t
+		}
+	}
+	return templates[0]
+}
+
+// appendCSV - appends single result to watch_<template>.csv in output dir, header is written only into a new file
+func appendCSV(r schema.OCRResult, template schema.OCRTemplate) {
+	name := strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			return unicode.ToLower(c)
+		}
+		return '_'
+	}, template.Title)
+
+	fd, err := os.OpenFile(fmt.Sprintf("%s/watch_%s.csv", flags.OutputDirectory, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Errorf("Failed to append csv: %v", err)
+		return
+	}
+	defer fd.Close()
+
+	opts := csvOptions()
+	if st, err := fd.Stat(); err == nil && st.Size() > 0 {
+		opts.Header = false
+		opts.BOM = false
+	}
+
+	if err := rokocr.WriteCSVWithOptions([]schema.OCRResult{r}, template, opts, fd); err != nil {
+		log.Errorf("Failed to append csv: %v", err)
+	}
+}
+
+// mostUsedTemplate - captured screens are matched one by one, output (table, csv) follows the most common template
+// aggregate - merges parts of records (see rokocr.Aggregate), template of the most common record is used for export
+func aggregate(data []schema.OCRResult, template schema.OCRTemplate, templates []schema.OCRTemplate) ([]schema.OCRResult, schema.OCRTemplate) {
+	if len(templates) == 0 {
+		templates = []schema.OCRTemplate{template}
+	}
+
+	before := len(data)
+	merged, records := rokocr.Aggregate(data, templates)
+	if len(records) == 0 {
+		log.Warnf("None of the templates is a part of a record, nothing to aggregate")
+		return data, template
+	}
+
+	all := append([]schema.OCRTemplate{}, templates...)
+	for _, r := range records {
+		all = append(all, r)
+	}
+	template = mostUsedTemplate(merged, all)
+	log.Infof("Merged %v screens into %v rows (%v)", before, len(merged), template.Title)
+	return merged, template
+}
+
+func mostUsedTemplate(data []schema.OCRResult, templates []schema.OCRTemplate) schema.OCRTemplate {
+	counts := make(map[string]int)
+	for _, r := range data {
+		counts[r.Template]++
+	}
+
+	best := templates[0]
+	for _, t := range templates {
+		if counts[t.Title] > counts[best.Title] {
+			best = t
+		}
+	}
+	return best
+}
+
+func csvOptions() rokocr.CSVOptions {
+	delimiter, err := rokocr.ParseDelimiter(flags.CSVDelimiter)
+	if err != nil {
+		log.Fatalf("Invalid -csv-delimiter: %v", err)
+	}
+	return rokocr.CSVOptions{Delimiter: delimiter, Header: flags.CSVHeader, BOM: flags.CSVBOM}
+}
+
+func recognitionOptions() tesseractutils.Options {
+	check, err := flags.QualityOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return tesseractutils.Options{
+		TmpDirectory:      flags.TmpDirectory,
+		TessdataDirectory: flags.TessdataDirectory,
+		Files:             flags.ListOptions(),
+		WantAlternatives:  flags.Alternatives,
+		MinConfidence:     flags.MinConfidence,
+		Languages:         flags.LanguageList(),
+		Jobs:              flags.Jobs,
+		MaxRetries:        flags.MaxRetries,
+		Backoff:           flags.Backoff,
+		CropBorders:       flags.CropBorders,
+		PDF:               flags.PDFOptions(),
+		Quality:           check,
+		Tags:              scanTags(),
+	}
+}
+
+// scanTags - kingdom, alliance & date every result is tagged with
+func scanTags() schema.ScanTags {
+	tags, err := flags.ScanTags()
+	if err != nil {
+		log.Fatalf("Invalid scan tags: %v", err)
+	}
+	return tags
+}
+
+// resultError - failure of a streamed screenshot, as batch recognition reports it
+func resultError(r schema.OCRResult) error {
+	if len(r.Quality) > 0 {
+		return fmt.Errorf("%w: %s", quality.ErrLowQuality, strings.Join(r.Quality, ", "))
+	}
+	return fmt.Errorf("%w: %v", tesseractutils.ErrNoTemplateMatch, r.Error)
+}
+
+// mediaRelative - name of the file as results have it, relative to media dir
+func mediaRelative(f string) string {
+	dir, _ := filepath.Abs(flags.MediaDirectory)
+	abs, _ := filepath.Abs(f)
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return rel
+	}
+	return f
+}
+
+// toReview - copies the screenshot, which failed the quality pre-check, into review folder of output dir
+func toReview(f string) {
+	if _, err := os.Stat(f); err != nil {
+		// not saved anywhere, e.g. screenshot from stdin
+		return
+	}
+
+	dir := filepath.Join(flags.OutputDirectory, quality.ReviewFolder)
+	fileutils.Mkdirs(dir)
+	if err := fileutils.CopyFile(f, filepath.Join(dir, filepath.Base(f))); err != nil {
+		logutils.File(f).Errorf("Failed to copy to %v: %v", dir, err)
+	}
+}
+
+// runBench - times the pipeline over screenshots of media dir (see -bench), nothing is written to output dir
+func runBench(templates []schema.OCRTemplate, force bool) {
+	files := fileutils.ListFiles(flags.MediaDirectory, flags.ListOptions())
+	if len(files) == 0 {
+		log.Fatalf("No screenshots to benchmark in: %v", flags.MediaDirectory)
+	}
+	log.Infof("Benchmarking %v screenshots, %v runs with -jobs %v", len(files), flags.BenchRuns, flags.Jobs)
+
+	report := rokocr.Bench(interruptContext(), files, templates, rokocr.BenchOptions{
+		Runs:        flags.BenchRuns,
+		Recognition: recognitionOptions(),
+		CSV:         csvOptions(),
+		Force:       force,
+	})
+
+	printTimings("Stage", report.Stages)
+	printTimings("Field", report.Fields)
+	log.Infof("%v images (%v unreadable, %v unmatched) => %v results in %v, %.2f images/s",
+		report.Images, report.Unreadable, report.Unmatched, report.Results, report.Took.Round(time.Millisecond), report.ImagesPerSecond())
+}
+
+func printTimings(name string, timings []rokocr.Timing) {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.1f ms", float64(d.Microseconds())/1000)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{name, "Count", "Total", "Avg", "p50", "p95", "Max"})
+	for _, t := range timings {
+		table.Append([]string{t.Name, fmt.Sprint(t.Count()), ms(t.Total()), ms(t.Avg()), ms(t.Percentile(50)), ms(t.Percentile(95)), ms(t.Max())})
+	}
+	table.Render()
+}
+
+// startProfiling - CPU profile of the run (-cpuprofile), returned function stops it, and writes heap profile (-memprofile)
+func startProfiling() func() {
+	if len(flags.CPUProfile) > 0 {
+		fd, err := os.Create(flags.CPUProfile)
+		if err != nil {
+			log.Fatalf("Failed to create CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(fd); err != nil {
+			log.Fatalf("Failed to start CPU profile: %v", err)
+		}
+	}
+
+	return func() {
+		if len(flags.CPUProfile) > 0 {
+			pprof.StopCPUProfile()
+			log.Infof("CPU profile written to: %v", flags.CPUProfile)
+		}
+		if len(flags.MemProfile) > 0 {
+			fd, err := os.Create(flags.MemProfile)
+			if err != nil {
+				log.Errorf("Failed to create heap profile: %v", err)
+				return
+			}
+			defer fd.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(fd); err != nil {
+				log.Errorf("Failed to write heap profile: %v", err)
+				return
+			}
+			log.Infof("Heap profile written to: %v", flags.MemProfile)
+		}
+	}
+}
+
+// interruptContext - cancelled on the first Ctrl+C (or SIGTERM), recognition stops and results so far are written
+// out. Signals are reset then, so another Ctrl+C quits right away.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Warnf("Stopping recognition, press Ctrl+C again to quit without writing results")
+	}()
+	return ctx
 }
 
 func main() {
+	switch flags.Command {
+	case "stats":
+		stats()
+		return
+	case "history":
+		history()
+		return
+	}
+
+	// fail on bad output options before spending time on OCR
+	_ = csvOptions()
+	if tags := scanTags(); !tags.Empty() {
+		log.Infof("Tagging results: kingdom %q, alliance %q, date %v", tags.Kingdom, tags.Alliance, tags.Date)
+	}
+	for _, arg := range flags.URLs() {
+		if !urlinput.IsURL(arg) {
+			log.Fatalf("Unexpected argument %q, expected http(s) links to screenshots", arg)
+		}
+	}
+
+	defer startProfiling()()
 
 	rokocr.Prepare(flags.CommonConfiguration)
 	rokocr.DownloadTesseractData(flags.CommonConfiguration)
@@ -58,23 +1131,224 @@ func main() {
 
 	force := false
 	var template schema.OCRTemplate
+	var templates []schema.OCRTemplate
 
 	if len(strings.TrimSpace(flags.ForceTemplate)) > 0 {
 		force = true
-		template, _ = schema.LoadTemplate(flags.ForceTemplate)
+		var err error
+		if template, err = schema.LoadTemplate(flags.ForceTemplate); err != nil {
+			log.Fatalf("Failed to load template: %v => %v", flags.ForceTemplate, err)
+		}
 		log.Infof("Running scanner in force mode with template: %v (%vx%v)", template.Title, template.Width, template.Height)
 	} else {
-		templates := schema.LoadTemplates(flags.TemplatesDirectory)
+		templates = schema.LoadTemplates(flags.TemplatesDirectory)
 		if len(templates) == 0 {
 			log.Fatalf("No templates found in: %v", flags.TemplatesDirectory)
 		}
 		log.Debugf("Loaded %v templates", len(templates))
-		template = schema.FindTemplate(flags.MediaDirectory, templates)
-		log.Infof("I think this template is best match: %v (%vx%v)", template.Title, template.Width, template.Height)
+		if !flags.StreamInput() {
+			template = schema.FindTemplateWithOptions(flags.MediaDirectory, flags.ListOptions(), templates)
+			log.Infof("I think this template is best match: %v (%vx%v)", template.Title, template.Width, template.Height)
+		}
 	}
 
-	data := tesseractutils.RunRecognition(flags.MediaDirectory, flags.TessdataDirectory, template, force)
+	// fail before recognition, not with a tesseract error on the first field in a language without model
+	used := templates
+	if force {
+		used = []schema.OCRTemplate{template}
+	}
+	if err := rokocr.EnsureTemplateLanguages(flags.CommonConfiguration, used, flags.LanguageList()); err != nil {
+		log.Fatalf("Templates use languages which can't be recognized: %v", err)
+	}
+
+	if flags.Bench {
+		if force {
+			templates = []schema.OCRTemplate{template}
+		}
+		runBench(templates, force)
+		return
+	}
+
+	// with adb capture, video or watching, template is known only after the screens are recognized
+	if key := strings.TrimSpace(flags.DedupKey); len(key) > 0 && (!flags.StreamInput() || force) {
+		if _, ok := template.OCRSchema[key]; !ok {
+			log.Fatalf("Can't dedup by %q, template %v has no such field", key, template.Title)
+		}
+	}
+
+	var audit *rokocr.AuditLog
+	if len(strings.TrimSpace(flags.AuditLog)) > 0 {
+		fd, err := os.Create(flags.AuditLog)
+		if err != nil {
+			log.Fatalf("Failed to create audit log: %v", err)
+		}
+		defer fd.Close()
+		audit = rokocr.NewAuditLog(fd)
+	}
+
+	sheets := newSheetsPusher(template, templates)
+	recorder := newResultsRecorder(template, templates, scanSource())
+	discord := newDiscordNotifier()
+	webhook := newWebhookSink()
+	publisher := openEvents()
+	defer publisher.Close()
+	start := time.Now()
+	publisher.Publish(events.Event{Type: events.ScanStarted, Source: scanSource(), Template: template.Title})
+
+	opts := recognitionOptions()
+	if !flags.Watch {
+		// watching stops on its own at interrupt, finishing in-flight screenshots
+		opts = opts.WithContext(interruptContext())
+	}
+	if cache := openFieldCache(); cache != nil {
+		defer cache.Close()
+		opts.Cache = cache
+	}
+	report := rokocr.NewRunReport(scanSource())
+	opts.OnFailure = func(file string, err error) {
+		report.Failed(mediaRelative(file), err)
+		recorder.failed(file, err)
+		discord.failed(file, err)
+		if errors.Is(err, quality.ErrLowQuality) {
+			toReview(file)
+		}
+	}
+
+	if force {
+		templates = []schema.OCRTemplate{template}
+	}
+
+	// media dir scans are journaled, so an interrupted scan can be resumed
+	var scan *session.Session
+	perImage := flags.Aggregate && !force
+	if !flags.StreamInput() && !flags.Stitch && !perImage {
+		scan = openSession()
+		opts.Skip = scan.Done
+		opts.OnProcessed = func(file string, results []schema.OCRResult, err error) {
+			if err := scan.Record(file, results, err); err != nil {
+				log.Errorf("Failed to record session progress: %v", err)
+			}
+		}
+	}
+
+	var results <-chan schema.OCRResult
+	switch {
+	case flags.ADBCapture > 0:
+		results = captureFromDevice(templates, opts)
+	case len(flags.Video) > 0:
+		results = framesFromVideo(templates, opts)
+	case flags.Watch:
+		results = watchMediaDir(templates, opts)
+	case flags.Stdin || flags.FromClipboard:
+		results = singleImage(templates, opts)
+	case len(flags.URLs()) > 0:
+		results = downloadImages(templates, opts)
+	case flags.Stitch:
+		results = stitchMediaDir(template, force, opts)
+	case perImage:
+		results = recognizeMediaDir(templates, opts)
+	default:
+		results = tesseractutils.RunRecognitionChanWithOptions(flags.MediaDirectory, template, force, opts)
+	}
+
+	var data []schema.OCRResult
+	for elem := range results {
+		if audit != nil {
+			if err := audit.Write(elem, template); err != nil {
+				log.Errorf("Failed to write audit log: %v", err)
+			}
+		}
+		if len(elem.Quality) > 0 {
+			toReview(filepath.Join(flags.MediaDirectory, elem.Filename))
+		}
+		used := resultTemplate(elem, template, templates)
+		report.Add(elem, used)
+		sheets.add(elem)
+		recorder.add(elem)
+		if webhook != nil {
+			webhook.Add(elem, used.Title)
+		}
+		result := elem
+		publisher.Publish(events.Event{Type: events.ScanResult, Source: scanSource(), Template: used.Title, Result: &result})
+		data = append(data, elem)
+	}
+	sheets.flush()
+	if webhook != nil {
+		webhook.Close()
+	}
+
+	interrupted := opts.Context().Err() != nil
+	if interrupted {
+		log.Warnf("Interrupted, writing out %v results recognized so far", len(data))
+	}
+
+	if scan != nil {
+		// including results of files processed before resuming
+		data = scan.Results()
+	}
+
+	if (flags.StreamInput() || perImage) && !force {
+		template = mostUsedTemplate(data, templates)
+	}
+	if flags.Aggregate {
+		data, template = aggregate(data, template, templates)
+	}
+	recorder.finish(template)
+	if opts.Cache != nil {
+		stats := opts.Cache.Stats()
+		report.FieldCache = &stats
+		log.Infof("Field cache: %v hits, %v misses (%.0f%% hit rate)", stats.Hits, stats.Misses, stats.HitRate()*100)
+	}
+	report.Finish()
+
+	name := fmt.Sprintf("%v", time.Now().Unix())
+	if flags.ValidOnly {
+		valid, rejected := template.FilterValid(data)
+		log.Infof("Valid rows: %v, rejected: %v", len(valid), len(rejected))
+		if len(rejected) > 0 {
+			writeCSV(rejected, template, name+"_rejected")
+		}
+		data = valid
+	}
+
+	if key := strings.TrimSpace(flags.DedupKey); len(key) > 0 {
+		before := len(data)
+		data = rokocr.Dedup(data, template, key)
+		log.Infof("Removed %v duplicate rows by %v", before-len(data), key)
+	}
 
 	printResultsTable(data, template)
-	writeCSV(data, template)
+	writeCSV(data, template, name)
+	writeReport(report, name)
+	if flags.XLSX {
+		writeXLSX(data, template, name)
+	}
+	writeTemplated(data, template, name)
+	if flags.HTML {
+		writeHTMLReport(data, template, templates, name)
+	}
+
+	if flags.Annotate {
+		writeAnnotated(data, template)
+	}
+
+	if len(strings.TrimSpace(flags.CropsDir)) > 0 {
+		dumpCrops(data, template)
+	}
+
+	discord.completed(data, template, time.Since(start))
+	status := fmt.Sprintf("Completed in %v", time.Since(start).Round(time.Second))
+	if interrupted {
+		status = "Interrupted"
+	}
+	publisher.Publish(events.Event{Type: events.ScanCompleted, Source: scanSource(), Template: template.Title, Rows: len(data), Failures: report.Summary.Failed, Status: status})
+
+	if scan != nil {
+		if interrupted {
+			_ = scan.Close()
+			log.Infof("Resume the scan with: -session %v", scan.ID)
+			return
+		}
+		closeSession(scan)
+	}
 }
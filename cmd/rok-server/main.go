@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/events"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/storage"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/urlinput"
 	"github.com/rokmonster/ocr/internal/pkg/www"
 	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
@@ -23,6 +34,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
 	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var flags = config.Parse()
@@ -42,10 +55,42 @@ func main() {
 		}
 	}
 
+	if flags.HealthCheck {
+		os.Exit(healthCheck())
+	}
+
+	if len(flags.AddUser) > 0 {
+		addUser()
+		os.Exit(0)
+	}
+
 	rokocr.Prepare(flags.CommonConfiguration)
 	rokocr.DownloadTesseractData(flags.CommonConfiguration)
 	rokocr.PreloadTemplates(flags.CommonConfiguration)
 
+	// templates are reloaded as they change, no need to restart the server while iterating on one
+	templateStore := templatestore.New(flags.TemplatesDirectory)
+	log.Infof("Loaded %v templates from %v", len(templateStore.Templates()), flags.TemplatesDirectory)
+	templateStore.OnReload(func(templates []schema.OCRTemplate) {
+		if err := rokocr.EnsureTemplateLanguages(flags.CommonConfiguration, templates, nil); err != nil {
+			log.Errorf("Templates use languages which can't be recognized: %v", err)
+		}
+	})
+	go func() {
+		if err := templateStore.Watch(context.Background()); err != nil {
+			log.Errorf("Templates won't be reloaded: %v", err)
+		}
+	}()
+
+	// uploads & reports, by default in media folder (./media/job_N)
+	location := flags.Storage
+	if len(location) == 0 {
+		location = flags.MediaDirectory
+	}
+	store, err := storage.Open(context.Background(), location)
+	utils.Panic(err)
+	log.Infof("Storing uploads & reports in %v", store)
+
 	gin.SetMode(gin.ReleaseMode)
 	gin.DisableConsoleColor()
 
@@ -80,13 +125,72 @@ func main() {
 	router.Use(static.Serve("/", web.EmbeddedFS(web.StaticFS, "static")))
 	router.SetHTMLTemplate(web.CreateTemplateEngine(web.StaticFS, "template"))
 
-	oauth := middlewares.NewOAuth2Middleware(router, flags.OAuthClientID, flags.OAuthSecretID, flags.TLSDomain)
+	oauth := middlewares.NewOAuth2Middleware(router, middlewares.AuthOptions{
+		Domains:         flags.TLSDomain,
+		GoogleClientID:  flags.OAuthClientID,
+		GoogleSecret:    flags.OAuthSecretID,
+		DiscordClientID: flags.DiscordClientID,
+		DiscordSecret:   flags.DiscordSecretID,
+		Admins:          strings.Split(flags.Admins, ","),
+		Users:           middlewares.NewUserStore(db),
+	})
+
+	jobsController := www.NewJobsController(db, templateStore, flags.TessdataDirectory, store, www.QueueOptions{
+		Size:      flags.QueueSize,
+		Workers:   flags.QueueWorkers,
+		RateLimit: flags.RateLimit,
+	})
+	jobsController.TrainingDirectory = flags.TrainingDirectory
+	jobsController.Links = urlinput.NewGuard(strings.Split(flags.URLHosts, ","))
+	if len(flags.Events) > 0 {
+		jobsController.Events, err = events.Open(flags.Events, flags.EventsTopic)
+		utils.Panic(err)
+	}
+	jobsController.Resume()
+
+	// probes - no auth
+	health := www.NewHealthController()
+	health.AddCheck("database", func() error {
+		return db.View(func(*bolt.Tx) error { return nil })
+	})
+	health.AddCheck("templates", func() error {
+		if len(templateStore.Templates()) == 0 {
+			return errors.New("no templates loaded")
+		}
+		return nil
+	})
+	health.AddCheck("jobs", jobsController.Ready)
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz)
+	if len(flags.DiscordBotToken) > 0 {
+		bot, err := www.NewDiscordBot(www.DiscordBotOptions{
+			Token:         flags.DiscordBotToken,
+			ApplicationID: flags.DiscordClientID,
+			PublicKey:     flags.DiscordPublicKey,
+			Guilds:        strings.Split(flags.DiscordGuilds, ","),
+			ResultsDB:     flags.ResultsDB,
+			ResultsKey:    flags.ResultsKey,
+		}, templateStore, flags.TessdataDirectory)
+		if err != nil {
+			log.Fatalf("Discord bot: %v", err)
+		}
+		// no auth, Discord signs interactions
+		router.POST("/discord/interactions", bot.Interactions)
+		go func() {
+			if err := bot.RegisterCommands(context.Background()); err != nil {
+				log.Errorf("Discord bot: %v", err)
+			}
+		}()
+	}
+
+	// JSON Schema of templates - no auth, editors fetch it on their own
+	router.GET("/schema/"+schema.TemplateJSONSchemaName, www.TemplateJSONSchema)
 
 	rootRouter := router.Group("")
 	{
 		devices := rootRouter.Group("/devices")
 		{
-			controller := www.NewRemoteDevicesController(flags.TemplatesDirectory, flags.TessdataDirectory)
+			controller := www.NewRemoteDevicesController(templateStore, flags.TessdataDirectory)
 
 			// websocket - no-auth (different auth in future)
 			devices.GET("/ws", controller.Websocket)
@@ -99,30 +203,42 @@ func main() {
 			}
 		}
 
-		api := rootRouter.Group("/api")
+		// API clients sign in with basic auth of a local account
+		api := rootRouter.Group("/api", oauth.APIMiddleware())
 		{
 			apiController := www.NewAPIController(flags.TessdataDirectory)
 			api.POST("/hoh", apiController.ScanHOH)
+			api.POST("/fingerprint", apiController.Fingerprint)
+			api.POST("/jobs", jobsController.CreateJobAPI)
+			api.GET("/jobs/:id", jobsController.GetJobAPI)
 		}
 
 		// all job's API's require auth
 		jobs := rootRouter.Group("/jobs", oauth.Middleware())
 		{
-			controller := www.NewJobsController(db, flags.TessdataDirectory)
+			controller := jobsController
 			jobs.GET("/", controller.GetJobsList)
 			jobs.GET("/create", controller.CreateJobForm)
-			jobs.GET("/:id", controller.GetJobByID)
-			jobs.GET("/:id/start", controller.StartJobByID)
-			jobs.GET("/:id/csv", controller.ExportJobAsCSV)
-			jobs.GET("/:id/results", controller.ExportJobResultsHTML)
-			jobs.GET("/:id/delete", controller.DeleteJobByID)
-			jobs.POST("/:id/upload", controller.UploadFilesForJob)
+
+			// users only see their own jobs (admins all of them)
+			job := jobs.Group("/:id", controller.RequireJobAccess)
+			job.GET("", controller.GetJobByID)
+			job.GET("/start", controller.StartJobByID)
+			job.GET("/csv", controller.ExportJobAsCSV)
+			job.GET("/results", controller.ExportJobResultsHTML)
+			job.GET("/progress", controller.JobProgressWebsocket)
+			job.GET("/review", controller.ReviewJob)
+			job.POST("/review", controller.SaveReview)
+			job.GET("/review/:result/:field", controller.ReviewCrop)
+			job.GET("/delete", controller.DeleteJobByID)
+			job.POST("/upload", controller.UploadFilesForJob)
+			job.GET("/files", controller.ListJobFiles)
 		}
 
-		// all templates API's require auth
-		templates := rootRouter.Group("/templates", oauth.Middleware())
+		// managing templates requires admin role
+		templates := rootRouter.Group("/templates", oauth.Middleware(), oauth.AdminMiddleware())
 		{
-			controller := www.NewTemplatesController(flags.TemplatesDirectory, flags.TessdataDirectory)
+			controller := www.NewTemplatesController(templateStore, flags.TemplatesDirectory, flags.TessdataDirectory)
 			templates.GET("/", controller.ListTemplates)
 			templates.GET("/new", controller.NewTemplateForm)
 			templates.POST("/new", controller.NewTemplatePost)
@@ -130,7 +246,10 @@ func main() {
 			templates.POST("/:session", controller.ExportTemplateByID)
 			templates.GET("/:session/image", controller.GetTemplateImage)
 			templates.POST("/:session/scan", controller.TestTemplateByID)
+			templates.GET("/:session/download", controller.DownloadTemplateByID)
+			templates.POST("/:session/preview", controller.PreviewArea)
 			templates.POST("/:session/add-area", controller.AddAreaOnTemplate)
+			templates.POST("/:session/remove-area", controller.RemoveAreaFromTemplate)
 			templates.POST("/:session/add-checkpoint", controller.AddCheckpointOnTemplate)
 		}
 	}
@@ -139,6 +258,12 @@ func main() {
 		c.Redirect(307, "/jobs")
 	})
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var srv *http.Server
+	var serve func() error
+	var grpcOpts []grpc.ServerOption
 	if flags.TLS && len(flags.TLSDomain) > 0 {
 		domains := strings.Split(flags.TLSDomain, ",")
 		log.Infof("Starting Autocert mode on TLS: %v", domains)
@@ -148,14 +273,111 @@ func main() {
 			HostPolicy: autocert.HostWhitelist(domains...),
 			Cache:      autocert.DirCache(cacheDir),
 		}
-		log.Fatal(runWithAutocertManager(router, &m))
+		srv, serve = autocertServer(router, &m)
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(srv.TLSConfig)))
 	} else {
 		log.Infof("Starting in plain HTTP on port: %v", flags.ListenPort)
-		log.Fatal(router.Run(fmt.Sprintf(":%d", flags.ListenPort)))
+		srv = &http.Server{Addr: fmt.Sprintf(":%d", flags.ListenPort), Handler: router}
+		serve = srv.ListenAndServe
 	}
+
+	go func() {
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	var grpcServer *grpc.Server
+	if flags.GRPCPort > 0 {
+		grpcServer = www.NewGRPCServer(www.NewGRPCService(jobsController, templateStore, oauth), grpcOpts...)
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", flags.GRPCPort))
+		utils.Panic(err)
+		log.Infof("Starting gRPC API on port: %v", flags.GRPCPort)
+		go func() {
+			if err := grpcServer.Serve(l); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	if len(flags.TelegramToken) > 0 {
+		bot := www.NewTelegramBot(flags.TelegramToken, templateStore, flags.TessdataDirectory, strings.Split(flags.TelegramChats, ","))
+		go func() {
+			if err := bot.Run(ctx); err != nil {
+				log.Errorf("Telegram bot stopped: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	shutdown(srv, grpcServer, jobsController)
+}
+
+// shutdown - stops taking jobs (/readyz fails), waits for running ones while still serving their progress & results,
+// then stops HTTP. Jobs which didn't finish within -shutdown-timeout are queued again on the next start.
+func shutdown(srv *http.Server, grpcServer *grpc.Server, jobs *www.JobsController) {
+	log.Infof("Shutting down, waiting up to %v for running jobs", flags.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), flags.ShutdownTimeout)
+	defer cancel()
+	if err := jobs.Shutdown(ctx); err != nil {
+		log.Warnf("Running jobs didn't finish, they will be queued again on the next start: %v", err)
+	}
+	jobs.Events.Close()
+
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer httpCancel()
+	if grpcServer != nil {
+		// streams (e.g. WatchJob) are cut off once HTTP is stopped
+		go func() {
+			<-httpCtx.Done()
+			grpcServer.Stop()
+		}()
+		grpcServer.GracefulStop()
+	}
+	if err := srv.Shutdown(httpCtx); err != nil {
+		log.Warnf("Failed to stop HTTP server: %v", err)
+	}
+	log.Info("Stopped")
+}
+
+// healthCheck - exit code of -healthcheck, 0 if the server on -port is ready
+func healthCheck() int {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/readyz", flags.ListenPort))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintln(os.Stderr, resp.Status)
+		return 1
+	}
+	return 0
+}
+
+// addUser - creates local account of -add-user, with password read from stdin
+func addUser() {
+	db, err := bolt.Open("db.bolt", 0666, &bolt.Options{Timeout: time.Second})
+	utils.Panic(err)
+	defer db.Close()
+
+	fmt.Fprintf(os.Stderr, "Password for %v: ", flags.AddUser)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(password) == 0 {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+
+	if err := middlewares.NewUserStore(db).Add(flags.AddUser, strings.TrimRight(password, "\r\n"), flags.AddAdmin); err != nil {
+		log.Fatalf("Failed to add user: %v", err)
+	}
+	log.Infof("User %v saved (admin: %v)", flags.AddUser, flags.AddAdmin)
 }
 
-func runWithAutocertManager(r http.Handler, m *autocert.Manager) error {
+// autocertServer - HTTPS server with certificates of the manager, http is redirected to https
+func autocertServer(r http.Handler, m *autocert.Manager) (*http.Server, func() error) {
 	tlsConfig := m.TLSConfig()
 	tlsConfig.MinVersion = tls.VersionTLS12
 
@@ -165,16 +387,18 @@ func runWithAutocertManager(r http.Handler, m *autocert.Manager) error {
 		Handler:   r,
 	}
 
-	l, err := activation.ListenersWithNames()
-	if err == nil && len(l) >= 2 {
-		log.Info("Running with Unix activation listeners")
-		go http.Serve(l["http"][0], m.HTTPHandler(http.HandlerFunc(redirect)))
-		return s.ServeTLS(l["https"][0], "", "")
-	}
+	return s, func() error {
+		l, err := activation.ListenersWithNames()
+		if err == nil && len(l) >= 2 {
+			log.Info("Running with Unix activation listeners")
+			go http.Serve(l["http"][0], m.HTTPHandler(http.HandlerFunc(redirect)))
+			return s.ServeTLS(l["https"][0], "", "")
+		}
 
-	log.Infof("Starting HTTP Listeners")
-	go http.ListenAndServe(":http", m.HTTPHandler(http.HandlerFunc(redirect)))
-	return s.ListenAndServeTLS("", "")
+		log.Infof("Starting HTTP Listeners")
+		go http.ListenAndServe(":http", m.HTTPHandler(http.HandlerFunc(redirect)))
+		return s.ListenAndServeTLS("", "")
+	}
 }
 
 func redirect(w http.ResponseWriter, req *http.Request) {
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	config "github.com/rokmonster/ocr/internal/pkg/config/templatesconfig"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/pdfpages"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/training"
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	log "github.com/sirupsen/logrus"
+)
+
+var flags = config.Parse()
+
+func compile() {
+	fileutils.Mkdirs(flags.OutputDirectory)
+
+	for _, f := range fileutils.GetFilesInDirectory(flags.TemplatesDirectory) {
+		if filepath.Ext(f) != ".json" {
+			continue
+		}
+
+		template, err := schema.LoadTemplate(f)
+		if err != nil {
+			log.Errorf("Failed to load template: %v => %v", filepath.Base(f), err)
+			continue
+		}
+		if template.Abstract {
+			// bases are compiled into the templates extending them
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(f), ".json") + schema.TemplateBinaryExt
+		if err := schema.WriteTemplateBinary(template, filepath.Join(flags.OutputDirectory, name)); err != nil {
+			log.Errorf("Failed to write template: %v => %v", name, err)
+			continue
+		}
+
+		log.Infof("Compiled: %v => %v", filepath.Base(f), name)
+	}
+}
+
+func migrate() {
+	fileutils.Mkdirs(flags.OutputDirectory)
+
+	for _, f := range fileutils.GetFilesInDirectory(flags.TemplatesDirectory) {
+		if filepath.Ext(f) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			log.Errorf("Failed to read template: %v => %v", filepath.Base(f), err)
+			continue
+		}
+
+		migrated, version, err := schema.MigrateTemplateJSON(data)
+		if err != nil {
+			log.Errorf("Failed to migrate template: %v => %v", filepath.Base(f), err)
+			continue
+		}
+
+		// not through the struct, it would drop keys it doesn't know
+		out, err := schema.FormatTemplateJSON(migrated)
+		if err != nil {
+			log.Errorf("Failed to format template: %v => %v", filepath.Base(f), err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(flags.OutputDirectory, filepath.Base(f)), out, 0644); err != nil {
+			log.Errorf("Failed to write template: %v => %v", filepath.Base(f), err)
+			continue
+		}
+
+		log.Infof("Migrated: %v (schema version %v => %v)", filepath.Base(f), version, schema.CurrentSchemaVersion)
+	}
+}
+
+// checkpoints <template.json> <sample1> <sample2> [sampleN...]
+func checkpoints() {
+	if len(flags.Args) < 3 {
+		log.Errorf("Usage: checkpoints <template.json> <sample1> <sample2> [sampleN...]")
+		os.Exit(1)
+	}
+
+	template, err := schema.LoadTemplate(flags.Args[0])
+	if err != nil {
+		log.Fatalf("Failed to load template: %v => %v", flags.Args[0], err)
+	}
+
+	var samples []image.Image
+	for _, f := range flags.Args[1:] {
+		img, err := imgutils.ReadImageFile(f)
+		if err != nil {
+			log.Fatalf("Failed to read sample: %v => %v", f, err)
+		}
+		samples = append(samples, img)
+	}
+
+	generated, err := template.GenerateCheckpoints(samples, schema.DefaultCheckpointOptions())
+	if err != nil {
+		log.Fatalf("Failed to generate checkpoints: %v", err)
+	}
+
+	for _, c := range generated {
+		log.Infof("Checkpoint: %v => %v (threshold: %v)", c.Crop.CropRectangle(), c.Fingerprint, c.Threshold)
+	}
+	template.Checkpoints = generated
+
+	fileutils.Mkdirs(flags.OutputDirectory)
+	out, _ := json.MarshalIndent(&template, "", "  ")
+	name := filepath.Join(flags.OutputDirectory, filepath.Base(flags.Args[0]))
+	if err := os.WriteFile(name, out, 0644); err != nil {
+		log.Fatalf("Failed to write template: %v => %v", name, err)
+	}
+
+	log.Infof("Written: %v (%v checkpoints)", name, len(generated))
+}
+
+// validate [template.json...] - all JSON templates in templates dir, if none given
+func validate() {
+	files := flags.Args
+	if len(files) == 0 {
+		for _, f := range fileutils.GetFilesInDirectory(flags.TemplatesDirectory) {
+			if filepath.Ext(f) == ".json" {
+				files = append(files, f)
+			}
+		}
+	}
+
+	opts := schema.ValidateOptions{Languages: rokocr.AvailableLanguages(flags.TessdataDirectory), Engines: ocrengine.Names()}
+
+	failed := 0
+	for _, f := range files {
+		errs := schema.ValidateTemplateFile(f, opts)
+		for _, e := range errs {
+			log.Errorf("[%v] %v", filepath.Base(f), e)
+		}
+		if len(errs) > 0 {
+			failed++
+			continue
+		}
+		log.Infof("[%v] OK", filepath.Base(f))
+	}
+
+	if failed > 0 {
+		log.Errorf("%v of %v templates have problems", failed, len(files))
+		os.Exit(1)
+	}
+}
+
+func templateRepository() *rokocr.TemplateRepository {
+	if len(strings.TrimSpace(flags.Index)) == 0 {
+		log.Fatalf("Template repository is not set, use -index")
+	}
+
+	repo, err := rokocr.NewTemplateRepository(flags.Index, retryutils.Options{MaxRetries: 3, Backoff: time.Second})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return repo
+}
+
+func printIndexEntries(entries []rokocr.TemplateIndexEntry) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Name", "Title", "Version", "Resolution", "Languages", "Tags"})
+	for _, e := range entries {
+		table.Append([]string{
+			e.Name, e.Title, e.Version, fmt.Sprintf("%vx%v", e.Width, e.Height),
+			strings.Join(e.Languages, ","), strings.Join(e.Tags, ","),
+		})
+	}
+	table.Render()
+}
+
+func list() {
+	index, err := templateRepository().Index(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to fetch template index: %v", err)
+	}
+	printIndexEntries(index.Templates)
+}
+
+// search <query>
+func search() {
+	if len(flags.Args) == 0 {
+		log.Errorf("Usage: search <query>")
+		os.Exit(1)
+	}
+
+	index, err := templateRepository().Index(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to fetch template index: %v", err)
+	}
+
+	found := index.Search(strings.Join(flags.Args, " "))
+	if len(found) == 0 {
+		log.Infof("No templates found for: %v", strings.Join(flags.Args, " "))
+		return
+	}
+	printIndexEntries(found)
+}
+
+// pull [name...]
+func pull() {
+	repo := templateRepository()
+	index, err := repo.Index(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to fetch template index: %v", err)
+	}
+
+	entries := index.Templates
+	if len(flags.Args) > 0 {
+		entries = nil
+		for _, name := range flags.Args {
+			e, ok := index.Find(name)
+			if !ok {
+				log.Fatalf("Template not found in repository: %v", name)
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	failed := 0
+	for _, e := range entries {
+		target := filepath.Join(flags.TemplatesDirectory, filepath.FromSlash(e.File))
+		if _, err := os.Stat(target); err == nil && !flags.Force {
+			log.Infof("Skipping %v: %v already exists (use -force to overwrite)", e.Name, target)
+			continue
+		}
+
+		installed, err := repo.Install(context.Background(), index, e, flags.TemplatesDirectory)
+		if err != nil {
+			log.Errorf("Failed to install %v: %v", e.Name, err)
+			failed++
+			continue
+		}
+		log.Infof("Installed: %v => %v", e.Name, strings.Join(installed, ", "))
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func index() {
+	index, err := rokocr.BuildTemplateIndex(flags.TemplatesDirectory)
+	if err != nil {
+		log.Fatalf("Failed to build template index: %v", err)
+	}
+
+	fileutils.Mkdirs(flags.OutputDirectory)
+	out, _ := json.MarshalIndent(&index, "", "  ")
+	name := filepath.Join(flags.OutputDirectory, rokocr.TemplateIndexName)
+	if err := os.WriteFile(name, out, 0644); err != nil {
+		log.Fatalf("Failed to write template index: %v => %v", name, err)
+	}
+
+	log.Infof("Written: %v (%v templates)", name, len(index.Templates))
+}
+
+// fingerprint <image> [x,y,w,h]
+func fingerprint() {
+	if len(flags.Args) < 1 || len(flags.Args) > 2 {
+		log.Errorf("Usage: fingerprint <image> [x,y,w,h]")
+		os.Exit(1)
+	}
+
+	img, err := readScreenshot(flags.Args[0], 0)
+	if err != nil {
+		log.Fatalf("Failed to read image: %v => %v", flags.Args[0], err)
+	}
+
+	var crop *schema.OCRCrop
+	if len(flags.Args) > 1 {
+		if crop, err = schema.ParseCrop(flags.Args[1]); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Infof("Image: %v (%vx%v)", filepath.Base(flags.Args[0]), img.Bounds().Dx(), img.Bounds().Dy())
+	for _, algo := range schema.HashAlgorithms {
+		hash, err := schema.Fingerprint(img, crop, algo)
+		if err != nil {
+			log.Fatalf("Failed to compute %v: %v", algo, err)
+		}
+		fmt.Printf("%s\t%s\n", algo, hash)
+	}
+}
+
+// why-no-match <screenshot...>
+func whyNoMatch() {
+	if len(flags.Args) < 1 {
+		log.Errorf("Usage: why-no-match <screenshot...>")
+		os.Exit(1)
+	}
+
+	templates := schema.LoadTemplates(flags.TemplatesDirectory)
+	if len(templates) == 0 {
+		log.Fatalf("No templates in: %v", flags.TemplatesDirectory)
+	}
+
+	for _, f := range flags.Args {
+		img, err := readScreenshot(f, 0)
+		if err != nil {
+			log.Errorf("Failed to read screenshot: %v => %v", f, err)
+			continue
+		}
+
+		diagnoses := schema.DiagnoseTemplates(img, templates)
+		fmt.Printf("\n%v (%vx%v): ", filepath.Base(f), img.Bounds().Dx(), img.Bounds().Dy())
+		if diagnoses[0].Matches {
+			fmt.Printf("matches %v\n", diagnoses[0].Template.Title)
+		} else {
+			fmt.Printf("no template matches\n")
+		}
+		printDiagnoses(diagnoses)
+	}
+}
+
+// printDiagnoses - summary of every template, then distances of every checkpoint. Close misses are marked with "<".
+func printDiagnoses(diagnoses []schema.TemplateDiagnosis) {
+	distance := func(d, threshold int) string {
+		if d == math.MaxInt {
+			return "-"
+		}
+		return fmt.Sprintf("%v / %v", d, threshold)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Template", "Resolution", "Match", "Fingerprint", "Checkpoints", "Why not"})
+	for _, d := range diagnoses {
+		match := "no"
+		if d.Matches {
+			match = "yes"
+		} else if d.CloseMiss() {
+			match = "no <"
+		}
+		// fingerprint threshold is used only by templates without checkpoints
+		fingerprint, checkpoints := distance(d.Distance, d.Threshold), "-"
+		if d.Checkpoints > 0 {
+			fingerprint = strings.Split(fingerprint, " ")[0]
+			checkpoints = fmt.Sprintf("%v / %v (quorum %v)", d.CheckpointsMatched, d.Checkpoints, d.Quorum)
+		}
+		table.Append([]string{
+			d.Template.Title, fmt.Sprintf("%vx%v", d.Template.Width, d.Template.Height), match,
+			fingerprint, checkpoints, strings.Join(d.Reasons, "; "),
+		})
+	}
+	table.Render()
+
+	for _, d := range diagnoses {
+		if len(d.Areas) == 0 && len(d.Excluding) == 0 {
+			continue
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeader([]string{d.Template.Title, "Crop", "Distance", "Match", ""})
+		areas := func(kind string, list []schema.AreaDiagnosis) {
+			for _, a := range list {
+				match := "no"
+				if a.Matches {
+					match = "yes"
+				} else if a.Optional {
+					match = "no (optional)"
+				}
+				note := a.Error
+				if miss := a.Miss(); miss > 0 && miss <= schema.CloseMiss {
+					note = fmt.Sprintf("< close miss, %v over threshold", miss)
+				}
+				crop := "-"
+				if a.Crop != nil {
+					crop = fmt.Sprintf("%v,%v+%vx%v", a.Crop.X, a.Crop.Y, a.Crop.W, a.Crop.H)
+				}
+				table.Append([]string{fmt.Sprintf("%v %v", kind, a.Index), crop, distance(a.Distance, a.Threshold), match, note})
+			}
+		}
+		areas("checkpoint", d.Areas)
+		areas("must_not_match", d.Excluding)
+		table.Render()
+	}
+}
+
+// schema - JSON Schema of templates, for validation & autocomplete in editors
+func jsonSchema() {
+	fileutils.Mkdirs(flags.OutputDirectory)
+	out, _ := json.MarshalIndent(schema.TemplateJSONSchema(), "", "  ")
+	name := filepath.Join(flags.OutputDirectory, schema.TemplateJSONSchemaName)
+	if err := os.WriteFile(name, out, 0644); err != nil {
+		log.Fatalf("Failed to write JSON schema: %v => %v", name, err)
+	}
+
+	log.Infof("Written: %v", name)
+}
+
+// boxes <crops dir> [field...]
+func boxes() {
+	if len(flags.Args) < 1 {
+		log.Errorf("Usage: boxes <crops dir> [field...]")
+		os.Exit(1)
+	}
+
+	samples, unlabeled, err := training.FindSamples(flags.Args[0], flags.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to list crops: %v", err)
+	}
+	for _, f := range unlabeled {
+		log.Warnf("Skipping %v: no label (%v)", filepath.Base(f), filepath.Base(training.LabelFile(f)))
+	}
+	if len(samples) == 0 {
+		log.Fatalf("No labeled crops in %v", flags.Args[0])
+	}
+
+	dir := filepath.Join(flags.OutputDirectory, flags.Model+"-ground-truth")
+	if err := training.WriteGroundTruth(samples, dir); err != nil {
+		log.Fatalf("Failed to write ground truth: %v", err)
+	}
+
+	log.Infof("Written: %v (%v samples, %v unlabeled skipped)", dir, len(samples), len(unlabeled))
+}
+
+// corrections <results.json> <screenshots dir> [field...]
+func corrections() {
+	if len(flags.Args) < 2 {
+		log.Errorf("Usage: corrections <results.json> <screenshots dir> [field...]")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(flags.Args[0])
+	if err != nil {
+		log.Fatalf("Failed to read results: %v", err)
+	}
+	var results []schema.OCRResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		log.Fatalf("Failed to parse results: %v", err)
+	}
+
+	found := training.Corrections(results, flags.Args[2:])
+	if len(found) == 0 {
+		log.Fatalf("No corrected fields in %v", flags.Args[0])
+	}
+
+	templates := schema.LoadTemplates(flags.TemplatesDirectory)
+	if len(templates) == 0 {
+		log.Fatalf("No templates in %v", flags.TemplatesDirectory)
+	}
+
+	dir := filepath.Join(flags.OutputDirectory, flags.Model+"-ground-truth")
+	written := 0
+	// results of ranking lists share the screenshot
+	screenshots := make(map[string]image.Image)
+	for _, c := range found {
+		key := fmt.Sprintf("%v#%v", c.Result.Filename, c.Result.Page)
+		img, ok := screenshots[key]
+		if !ok {
+			img, err = readScreenshot(filepath.Join(flags.Args[1], c.Result.Filename), c.Result.Page)
+			if err != nil {
+				log.Warnf("Skipping %v: %v", c.Result.Filename, err)
+			}
+			screenshots[key] = img
+		}
+		if img == nil {
+			continue
+		}
+
+		template := correctedTemplate(c.Result, img, templates)
+		crop, ok := template.ResultCrops(template.Panel(img, c.Result), c.Result)[c.Field]
+		if !ok {
+			log.Warnf("Skipping %v: no crop of %v in %v", c.Name(), c.Field, template.Title)
+			continue
+		}
+		if err := training.AddSample(dir, c.Name(), crop, c.Label); err != nil {
+			log.Fatalf("Failed to write ground truth: %v", err)
+		}
+		written++
+	}
+
+	log.Infof("Written: %v (%v of %v corrected fields)", dir, written, len(found))
+}
+
+// readScreenshot - screenshot (or page of a PDF) with borders cropped, as recognition does
+func readScreenshot(f string, page int) (image.Image, error) {
+	var img image.Image
+	var err error
+	if page > 0 {
+		img, err = pdfpages.Page(context.Background(), f, page, pdfpages.DefaultOptions())
+	} else {
+		img, err = imgutils.ReadImageFile(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return imgutils.CropBorders(img), nil
+}
+
+// correctedTemplate - template the result was recognized with, by title, or the closest one to the screenshot
+func correctedTemplate(r schema.OCRResult, img image.Image, templates []schema.OCRTemplate) schema.OCRTemplate {
+	for _, t := range templates {
+		if len(r.Template) > 0 && t.Title == r.Template {
+			return t
+		}
+	}
+	return schema.PickTemplateForImage(img, templates)
+}
+
+func main() {
+	switch flags.Command {
+	case "compile":
+		compile()
+	case "migrate":
+		migrate()
+	case "checkpoints":
+		checkpoints()
+	case "validate":
+		validate()
+	case "list":
+		list()
+	case "search":
+		search()
+	case "pull":
+		pull()
+	case "index":
+		index()
+	case "schema":
+		jsonSchema()
+	case "fingerprint":
+		fingerprint()
+	case "why-no-match":
+		whyNoMatch()
+	case "boxes":
+		boxes()
+	case "corrections":
+		corrections()
+	default:
+		log.Errorf("Unknown command: %q", flags.Command)
+		config.Usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package ocr
+
+import (
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+)
+
+// conversions between the types of this package and the internal ones, internal types never leave the package
+
+func publicTemplates(templates []ocrschema.OCRTemplate) []Template {
+	var result []Template
+	for _, t := range templates {
+		result = append(result, Template{t: t})
+	}
+	return result
+}
+
+func internalTemplates(templates []Template) []ocrschema.OCRTemplate {
+	var result []ocrschema.OCRTemplate
+	for _, t := range templates {
+		result = append(result, t.t)
+	}
+	return result
+}
+
+func publicResult(r ocrschema.OCRResult) Result {
+	result := Result{
+		Filename: r.Filename,
+		Panel:    r.Panel,
+		Page:     r.Page,
+		Row:      r.Row,
+		Template: r.Template,
+		Data:     r.Data,
+		Quality:  r.Quality,
+		Error:    r.Error,
+	}
+	if len(r.Fields) > 0 {
+		result.Fields = make(map[string]FieldResult, len(r.Fields))
+	}
+	for k, f := range r.Fields {
+		field := FieldResult{Text: f.Text, Confidence: f.Confidence, Value: f.Value, LowConfidence: f.LowConfidence}
+		if f.Crop != nil {
+			field.Crop = f.Crop.CropRectangle()
+		}
+		result.Fields[k] = field
+	}
+	return result
+}
+
+func publicResults(results []ocrschema.OCRResult) []Result {
+	var result []Result
+	for _, r := range results {
+		result = append(result, publicResult(r))
+	}
+	return result
+}
+
+func internalResults(results []Result) []ocrschema.OCRResult {
+	var result []ocrschema.OCRResult
+	for _, r := range results {
+		x := ocrschema.OCRResult{
+			Filename: r.Filename,
+			Panel:    r.Panel,
+			Page:     r.Page,
+			Row:      r.Row,
+			Template: r.Template,
+			Data:     r.Data,
+			Quality:  r.Quality,
+			Error:    r.Error,
+		}
+		if len(r.Fields) > 0 {
+			x.Fields = make(map[string]ocrschema.FieldResult, len(r.Fields))
+		}
+		for k, f := range r.Fields {
+			x.Fields[k] = ocrschema.FieldResult{
+				Crop:          &ocrschema.OCRCrop{X: f.Crop.Min.X, Y: f.Crop.Min.Y, W: f.Crop.Dx(), H: f.Crop.Dy()},
+				Text:          f.Text,
+				Confidence:    f.Confidence,
+				Value:         f.Value,
+				LowConfidence: f.LowConfidence,
+			}
+		}
+		result = append(result, x)
+	}
+	return result
+}
+
+// internal - zero value stays zero, it selects the default engine
+func (c EngineConfig) internal() ocrengine.Config {
+	cfg := ocrengine.Config{
+		Name:          c.Name,
+		Binary:        c.Binary,
+		URL:           c.URL,
+		GoogleAPIKey:  c.GoogleAPIKey,
+		AzureEndpoint: c.AzureEndpoint,
+		AzureKey:      c.AzureKey,
+	}
+	if len(cfg.Name) > 0 && len(cfg.Binary) == 0 {
+		cfg.Binary = ocrengine.DefaultConfig().Binary
+	}
+	return cfg
+}
@@ -0,0 +1,51 @@
+package ocr
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func TestResultConversion(t *testing.T) {
+	internal := ocrschema.OCRResult{
+		Filename: "ranking.png",
+		Row:      3,
+		Template: "Ranking",
+		Data:     map[string]interface{}{"name": "PlayerOne", "power": int64(12345)},
+		Fields: map[string]ocrschema.FieldResult{
+			"power": {Crop: &ocrschema.OCRCrop{X: 10, Y: 20, W: 100, H: 30}, Text: "12,345", Confidence: 91, Value: int64(12345)},
+		},
+	}
+
+	result := publicResult(internal)
+	want := FieldResult{Crop: image.Rect(10, 20, 110, 50), Text: "12,345", Confidence: 91, Value: int64(12345)}
+	if got := result.Fields["power"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("field = %+v, want %+v", got, want)
+	}
+	if result.Filename != "ranking.png" || result.Row != 3 || result.Template != "Ranking" || result.Data["name"] != "PlayerOne" {
+		t.Errorf("result = %+v", result)
+	}
+
+	// converted back, it's exported the same
+	if back := publicResults(internalResults([]Result{result})); !reflect.DeepEqual(back[0], result) {
+		t.Errorf("round trip = %+v, want %+v", back[0], result)
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates("templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) == 0 {
+		t.Fatal("no bundled templates")
+	}
+	for _, template := range templates {
+		w, h := template.Size()
+		if len(template.Title()) == 0 || w <= 0 || h <= 0 || len(template.Columns()) == 0 {
+			t.Errorf("template %q: %vx%v, columns %v", template.Title(), w, h, template.Columns())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ocr
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+)
+
+// CSVOptions - how WriteCSV formats the table
+type CSVOptions struct {
+	Delimiter rune
+	// Header - write column titles as the first row
+	Header bool
+	// BOM - prefix output with UTF-8 byte order mark, so Excel doesn't break non-latin governor names
+	BOM bool
+}
+
+// DefaultCSVOptions - comma separated, with header row
+func DefaultCSVOptions() CSVOptions {
+	o := rokocr.DefaultCSVOptions()
+	return CSVOptions{Delimiter: o.Delimiter, Header: o.Header, BOM: o.BOM}
+}
+
+// WriteCSV - results as CSV, columns (titles & order) are taken from the template table,
+// or all fields in alphabetical order if the template has none
+func WriteCSV(w io.Writer, results []Result, template Template, opts CSVOptions) error {
+	o := rokocr.CSVOptions{Delimiter: opts.Delimiter, Header: opts.Header, BOM: opts.BOM}
+	return rokocr.WriteCSVWithOptions(internalResults(results), template.t, o, w)
+}
+
+// WriteXLSX - results as Excel workbook, columns like WriteCSV
+func WriteXLSX(w io.Writer, results []Result, template Template) error {
+	return rokocr.WriteXLSX(internalResults(results), template.t, w)
+}
+
+// WriteTemplated - results rendered through a Go text/template (sprig functions, sortBy, thousands, padLeft, padRight
+// & md helpers), e.g. Markdown leaderboards or BBCode posts
+func WriteTemplated(w io.Writer, results []Result, template Template, text string) error {
+	tmpl, err := rokocr.ParseOutputTemplate("output", text)
+	if err != nil {
+		return err
+	}
+	return rokocr.WriteTemplated(internalResults(results), template.t, tmpl, w)
+}
+
+// WriteJSON - results as JSON array
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
@@ -1,5 +1,7 @@
 package config
 
+import "github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+
 type CommonConfiguration struct {
 	MediaDirectory     string
 	TemplatesDirectory string
@@ -7,4 +9,8 @@ type CommonConfiguration struct {
 	TessdataDirectory  string
 	TmpDirectory       string
 	DeleteTempFiles    bool
+	OCREngine          ocrengine.Config
+	// TessdataVariant - standard, fast or best models are downloaded, TessdataDownload - missing ones at all
+	TessdataVariant  string
+	TessdataDownload bool
 }
@@ -0,0 +1,63 @@
+package compareconfig
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/config"
+)
+
+type ROKCompareConfig struct {
+	Template     string
+	Key          string
+	Fields       string
+	Columns      string
+	Missing      bool
+	Output       string
+	CSVDelimiter string
+	CSVBOM       bool
+
+	Start string
+	End   string
+}
+
+func Parse() ROKCompareConfig {
+	var flags ROKCompareConfig
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <start.csv> <end.csv>\n\nCompares two scans (e.g. start & end of KvK) per governor.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&flags.Template, "template", "", "Template the scans were made with (maps CSV column titles to fields), column titles are used as is if empty")
+	flag.StringVar(&flags.Key, "key", "id", "Field joining rows of both scans (e.g. governor id)")
+	flag.StringVar(&flags.Fields, "fields", "power,kill_points,dead", "Comma separated numeric fields to compare")
+	flag.StringVar(&flags.Columns, "columns", "name", "Comma separated fields copied from the end scan (e.g. name, alliance)")
+	flag.BoolVar(&flags.Missing, "missing", false, "Include governors present only in one of the scans")
+	flag.StringVar(&flags.Output, "output", "", "Write comparison as CSV into this file (printed as table if empty)")
+	flag.StringVar(&flags.CSVDelimiter, "csv-delimiter", ",", "CSV column delimiter (single character, or \"tab\")")
+	flag.BoolVar(&flags.CSVBOM, "csv-bom", false, "Prefix CSV with UTF-8 BOM (for Excel)")
+	config.ParseFlags()
+
+	flags.Start = flag.Arg(0)
+	flags.End = flag.Arg(1)
+
+	return flags
+}
+
+func Usage() {
+	flag.Usage()
+}
+
+// List - comma separated flag value as list, without empty entries
+func List(value string) []string {
+	var list []string
+	for _, x := range strings.Split(value, ",") {
+		if x = strings.TrimSpace(x); len(x) > 0 {
+			list = append(list, x)
+		}
+	}
+	return list
+}
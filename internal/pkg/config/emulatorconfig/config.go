@@ -0,0 +1,86 @@
+package emulatorconfig
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/config"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/emulator"
+	adb "github.com/zach-klippenstein/goadb"
+)
+
+type ROKEmulatorConfig struct {
+	Container   emulator.Container
+	ADBPort     int
+	BootTimeout time.Duration
+	Stop        bool
+
+	Package     string
+	APK         string
+	StartDelay  time.Duration
+	Credentials string
+	Login       string
+	Relogin     bool
+	Open        string
+
+	Scanner string
+	Capture int
+	ADBPlan string
+	// ScannerArgs - arguments after --, passed to rok-scanner as is
+	ScannerArgs []string
+
+	At   string
+	Once bool
+}
+
+func Parse() ROKEmulatorConfig {
+	var flags ROKEmulatorConfig
+	defaults := emulator.DefaultContainer()
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [-- rok-scanner flags]\n\nRuns an Android emulator container, and scans the kingdom ranking on schedule.\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&flags.Container.Docker, "docker", defaults.Docker, "Path to docker (or podman)")
+	flag.StringVar(&flags.Container.Image, "image", defaults.Image, "Android container image (redroid)")
+	flag.StringVar(&flags.Container.Name, "container", defaults.Name, "Name of the container, reused if it exists")
+	flag.IntVar(&flags.Container.Port, "device-port", defaults.Port, "Local port the container's adb is published on")
+	flag.StringVar(&flags.Container.Data, "data", defaults.Data, "Directory mounted as the container's /data, keeps the game & its login")
+	flag.IntVar(&flags.Container.Width, "width", defaults.Width, "Screen width of the emulator")
+	flag.IntVar(&flags.Container.Height, "height", defaults.Height, "Screen height of the emulator")
+	flag.IntVar(&flags.Container.DPI, "dpi", defaults.DPI, "Screen density of the emulator")
+	flag.IntVar(&flags.ADBPort, "adb-port", adb.AdbPort, "ADB Port")
+	flag.DurationVar(&flags.BootTimeout, "boot-timeout", 3*time.Minute, "How long to wait for Android to boot")
+	flag.BoolVar(&flags.Stop, "stop", false, "Stop the container after every scan (started again by the next one)")
+	flag.StringVar(&flags.Package, "package", emulator.DefaultPackage, "Package of the game")
+	flag.StringVar(&flags.APK, "apk", "", "Comma separated APK of the game (or base & split APKs), installed if the game is missing")
+	flag.DurationVar(&flags.StartDelay, "start-delay", 90*time.Second, "Wait after launching the game, for it to load")
+	flag.StringVar(&flags.Credentials, "credentials", os.Getenv("ROKOCR_CREDENTIALS"), "File with NAME=value credentials typed by routines (e.g. ROK_EMAIL, ROK_PASSWORD), missing ones are taken from environment")
+	flag.StringVar(&flags.Login, "login", "", "JSON routine logging into the game, done after the game is installed")
+	flag.BoolVar(&flags.Relogin, "relogin", false, "Do -login routine before every scan, not only after install")
+	flag.StringVar(&flags.Open, "open", "", "JSON routine opening the ranking list from the city view")
+	flag.StringVar(&flags.Scanner, "scanner", "rok-scanner", "Path to rok-scanner, which captures & recognizes the ranking")
+	flag.IntVar(&flags.Capture, "capture", 300, "How many governors of the ranking to capture")
+	flag.StringVar(&flags.ADBPlan, "adb-plan", "", "JSON file with tap coordinates of the ranking list, passed to rok-scanner")
+	flag.StringVar(&flags.At, "at", "00:00", "Comma separated times of day (HH:MM, UTC) to scan at")
+	flag.BoolVar(&flags.Once, "once", false, "Scan once right away and exit, instead of on schedule")
+	config.ParseFlags()
+
+	flags.ScannerArgs = flag.Args()
+	return flags
+}
+
+// APKList - -apk as list, without empty entries
+func (c ROKEmulatorConfig) APKList() []string {
+	var list []string
+	for _, x := range strings.Split(c.APK, ",") {
+		if x = strings.TrimSpace(x); len(x) > 0 {
+			list = append(list, x)
+		}
+	}
+	return list
+}
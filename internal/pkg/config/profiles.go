@@ -0,0 +1,159 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFiles - config files looked up in the working directory, when -config isn't given
+var ConfigFiles = []string{"rokocr.yaml", "rokocr.yml", "rokocr.toml"}
+
+// Profile - flag values by flag name (e.g. templates, tessdata, jobs, lang), lists are joined with commas
+type Profile map[string]interface{}
+
+// ConfigFile - named profiles, so scans of several kingdoms don't need long flag lists
+type ConfigFile struct {
+	// Default - profile used when -profile isn't given
+	Default  string             `yaml:"default" toml:"default"`
+	Profiles map[string]Profile `yaml:"profiles" toml:"profiles"`
+}
+
+// LoadConfigFile - reads YAML or TOML (by extension) config file
+func LoadConfigFile(file string) (ConfigFile, error) {
+	var cfg ConfigFile
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".toml") {
+		err = toml.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("can't parse %v: %w", file, err)
+	}
+	return cfg, nil
+}
+
+// ProfileNames - profiles of the file, sorted
+func (c ConfigFile) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile - profile by name, empty name - the default one (no default - no profile)
+func (c ConfigFile) Profile(name string) (Profile, error) {
+	if len(name) == 0 {
+		name = c.Default
+	}
+	if len(name) == 0 {
+		return nil, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q, available: %v", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+// Apply - sets flags of the profile which weren't given on the command line. Settings which aren't
+// flags of the tool are skipped, so one profile can serve the scanner, server & other tools.
+func (p Profile) Apply(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for name, value := range p {
+		if explicit[name] {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			log.Debugf("Profile setting %q is not a flag of %v, skipped", name, filepath.Base(os.Args[0]))
+			continue
+		}
+		if err := fs.Set(name, flagValue(value)); err != nil {
+			return fmt.Errorf("profile setting %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flagValue - YAML/TOML value as it would be given on the command line
+func flagValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		values := make([]string, len(list))
+		for i, e := range list {
+			values[i] = fmt.Sprint(e)
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// ParseFlags - parses the command line (flag.Parse), then fills in flags not given explicitly
+// from the profile of the config file (-config, -profile). Missing config file is fine, unless it was
+// asked for. Exits on broken config file or unknown profile.
+func ParseFlags() {
+	file := flag.String("config", "", fmt.Sprintf("Config file with profiles, YAML or TOML (default: %v in working dir, if present)", strings.Join(ConfigFiles, ", ")))
+	profile := flag.String("profile", os.Getenv("ROKOCR_PROFILE"), "Profile of the config file to use (default: default of the config file)")
+	format := flag.String("log-format", logutils.FormatText, "Log format: text, or json (one object per line, with file, template & field of the line)")
+	flag.Parse()
+	setLogFormat(*format)
+
+	if len(*file) == 0 {
+		for _, f := range ConfigFiles {
+			if _, err := os.Stat(f); err == nil {
+				*file = f
+				break
+			}
+		}
+	}
+	if len(*file) == 0 {
+		if len(*profile) > 0 {
+			log.Fatalf("Profile %q given, but there is no config file (%v)", *profile, strings.Join(ConfigFiles, ", "))
+		}
+		return
+	}
+
+	cfg, err := LoadConfigFile(*file)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	p, err := cfg.Profile(*profile)
+	if err != nil {
+		log.Fatalf("%v: %v", *file, err)
+	}
+	if err := p.Apply(flag.CommandLine); err != nil {
+		log.Fatalf("%v: %v", *file, err)
+	}
+	// profile may set it too
+	setLogFormat(*format)
+	if p != nil {
+		name := *profile
+		if len(name) == 0 {
+			name = cfg.Default
+		}
+		log.Infof("Using profile %q of %v", name, *file)
+	}
+}
+
+func setLogFormat(format string) {
+	if err := logutils.SetFormat(format); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -24,6 +24,6 @@ func Parse() ROKRemoteConfig {
 	flag.StringVar(&flags.TmpDirectory, "tmp", os.TempDir(), "Directory for temporary files (cropped ones)")
 	flag.IntVar(&flags.ADBPort, "adb-port", adb.AdbPort, "ADB Port")
 	flag.StringVar(&flags.Server, "rok-server", "http://localhost:8080", "rokserver to connect to")
-	flag.Parse()
+	config.ParseFlags()
 	return flags
 }
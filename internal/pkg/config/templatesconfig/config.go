@@ -0,0 +1,61 @@
+package templatesconfig
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rokmonster/ocr/internal/pkg/config"
+)
+
+type ROKTemplatesConfig struct {
+	config.CommonConfiguration
+	Command string
+	Args    []string
+
+	Index string
+	Force bool
+	Model string
+}
+
+func Parse() ROKTemplatesConfig {
+	var flags ROKTemplatesConfig
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <command> [args]\n\nCommands:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  compile\tcompile JSON templates into binary (%s) files in output dir\n", ".rokt")
+		fmt.Fprintf(flag.CommandLine.Output(), "  migrate\tupgrade JSON templates to current schema version, written to output dir\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  checkpoints <template.json> <sample...>\tgenerate checkpoints from 2+ screenshots of the same screen\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  validate [template.json...]\tcheck templates (all in templates dir by default), problems are reported with line numbers\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  list\tlist templates available in the repository (-index)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  search <query>\tfind repository templates by name, title, tags, language or resolution (e.g. 1920x1080)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  pull [name...]\tinstall (all or given) repository templates into templates dir\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  index\twrite %s of templates dir into output dir, for publishing a repository\n", "index.json")
+		fmt.Fprintf(flag.CommandLine.Output(), "  fingerprint <image> [x,y,w,h]\tprint hashes of the image (or its crop) in every hash_algo, as fingerprints & checkpoints expect them\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  why-no-match <screenshot...>\tprint distances of the screenshot to every template of templates dir & its checkpoints, marking close misses\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  schema\twrite JSON Schema of templates (%s) into output dir, for validation & autocomplete in editors\n", "template.schema.json")
+		fmt.Fprintf(flag.CommandLine.Output(), "  boxes <crops dir> [field...]\tgenerate tesstrain ground truth (box files) of labeled crops (%s), into output dir\n", "crop.gt.txt")
+		fmt.Fprintf(flag.CommandLine.Output(), "  corrections <results.json> <screenshots dir> [field...]\tgenerate tesstrain ground truth of fields corrected in review (rok-server job results), into output dir\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&flags.TemplatesDirectory, "templates", "./templates", "templates dir")
+	flag.StringVar(&flags.TessdataDirectory, "tessdata", "./tessdata", "tesseract data files directory")
+	flag.StringVar(&flags.OutputDirectory, "output", "./out", "output dir")
+	flag.StringVar(&flags.Index, "index", "", "Template repository: URL of index.json, or github:owner/repo[@ref][/dir]")
+	flag.BoolVar(&flags.Force, "force", false, "pull: overwrite templates already in templates dir")
+	flag.StringVar(&flags.Model, "model", "rok_digits", "boxes & corrections: name of the trained model, ground truth goes into <output>/<model>-ground-truth")
+	config.ParseFlags()
+
+	flags.Command = flag.Arg(0)
+	if flag.NArg() > 1 {
+		flags.Args = flag.Args()[1:]
+	}
+
+	return flags
+}
+
+func Usage() {
+	flag.Usage()
+}
@@ -0,0 +1,112 @@
+package ocrschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// allowListCharsets - shorthands usable as AllowList entries
+var allowListCharsets = map[string]string{
+	"digits": "0123456789",
+	"hex":    "0123456789abcdefABCDEF",
+	"lower":  "abcdefghijklmnopqrstuvwxyz",
+	"upper":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alpha":  "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alnum":  "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+// AllowList - characters the field may contain, passed to tesseract as whitelist & used to filter recognized text.
+// Entries are shorthands (digits, hex, lower, upper, alpha, alnum) or literal characters, e.g. ["digits", ",", "."].
+// A single string ("digits") and numbers ([0, 1, 2], as older templates have) are accepted too.
+type AllowList []string
+
+// AllowListCharsets - supported shorthands
+func AllowListCharsets() []string {
+	var names []string
+	for name := range allowListCharsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (a *AllowList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = AllowList{single}
+		return nil
+	}
+
+	var entries []interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("allowlist: expected string or list of strings, got %s", data)
+	}
+
+	list := make(AllowList, 0, len(entries))
+	for _, e := range entries {
+		switch v := e.(type) {
+		case string:
+			list = append(list, v)
+		case float64:
+			if v < 0 || v != math.Trunc(v) {
+				return fmt.Errorf("allowlist: invalid number %v", v)
+			}
+			list = append(list, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return fmt.Errorf("allowlist: invalid entry %v", e)
+		}
+	}
+	*a = list
+	return nil
+}
+
+// Chars - all allowed characters (shorthands expanded), in order & without duplicates
+func (a AllowList) Chars() string {
+	seen := make(map[rune]bool)
+	var chars strings.Builder
+	for _, entry := range a {
+		if charset, ok := allowListCharsets[entry]; ok {
+			entry = charset
+		}
+		for _, r := range entry {
+			if !seen[r] {
+				seen[r] = true
+				chars.WriteRune(r)
+			}
+		}
+	}
+	return chars.String()
+}
+
+// Filter - removes characters which aren't allowed (engines don't always honor the whitelist).
+// Whitespace is kept, as it separates words.
+func (a AllowList) Filter(text string) string {
+	chars := a.Chars()
+	if len(chars) == 0 {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune(chars, r) {
+			return r
+		}
+		return -1
+	}, text)
+}
+
+// unknownCharset - entry looks like a mistyped shorthand ("digit"), rather than literal characters
+func unknownCharset(entry string) bool {
+	if _, ok := allowListCharsets[entry]; ok || len(entry) < 2 {
+		return false
+	}
+	for _, r := range entry {
+		if !unicode.IsLower(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+)
+
+// default search margin & score of anchors
+const (
+	DefaultAnchorSearch   = 40
+	DefaultAnchorMinScore = 0.8
+)
+
+// OCRAnchor - known icon (e.g. the power symbol), located on the screenshot around its expected position.
+// Crops of fields anchored to it move by the same offset, so small UI shifts between game versions don't break the template.
+type OCRAnchor struct {
+	// Crop - expected position of the icon, in template coordinates
+	Crop *OCRCrop `json:"crop"`
+	// Image - icon file, relative to template file location. Empty - the crop of reference_image
+	Image string `json:"image,omitempty"`
+	// Search - how far (in template pixels) from the expected position the icon is looked for
+	Search int `json:"search,omitempty"`
+	// MinScore - lowest match score (normalized cross-correlation, up to 1) the icon is accepted with
+	MinScore float64 `json:"min_score,omitempty"`
+
+	// icon - already loaded icon (see Stitched)
+	icon image.Image
+}
+
+func (a OCRAnchor) search() int {
+	if a.Search > 0 {
+		return a.Search
+	}
+	return DefaultAnchorSearch
+}
+
+func (a OCRAnchor) minScore() float64 {
+	if a.MinScore > 0 {
+		return a.MinScore
+	}
+	return DefaultAnchorMinScore
+}
+
+// AnchorKeys - names of anchors, in alphabetical order
+func (b *OCRTemplate) AnchorKeys() []string {
+	var keys []string
+	for k := range b.Anchors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type anchorImage struct {
+	modified time.Time
+	img      image.Image
+}
+
+// anchorImages - icons by file & crop, templates are parsed for every screenshot
+var anchorImages sync.Map
+
+// AnchorImage - icon of the anchor, as its file or cut out of the reference image
+func (b *OCRTemplate) AnchorImage(name string) (image.Image, error) {
+	a, ok := b.Anchors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown anchor: %v", name)
+	}
+	if a.icon != nil {
+		return a.icon, nil
+	}
+
+	file := b.ResolvePath(a.Image)
+	if len(a.Image) == 0 {
+		if len(b.ReferenceImage) == 0 {
+			return nil, fmt.Errorf("anchor %v has no image, and template has no reference_image", name)
+		}
+		if a.Crop == nil {
+			return nil, fmt.Errorf("anchor %v has no crop", name)
+		}
+		file = b.ReferenceImagePath()
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key := file
+	if len(a.Image) == 0 {
+		key = fmt.Sprintf("%s@%v", file, a.Crop.CropRectangle())
+	}
+	if cached, ok := anchorImages.Load(key); ok && cached.(anchorImage).modified.Equal(info.ModTime()) {
+		return cached.(anchorImage).img, nil
+	}
+
+	img, err := imgutils.ReadImageFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Image) == 0 {
+		if img, err = imgutils.CropImage(b.NormalizeImage(img), a.Crop.CropRectangle()); err != nil {
+			return nil, err
+		}
+	}
+
+	anchorImages.Store(key, anchorImage{modified: info.ModTime(), img: img})
+	return img, nil
+}
+
+// LocateAnchors - offset (in image pixels) of every anchor found on the image from its expected position.
+// Anchors which are not found are left out.
+func (b *OCRTemplate) LocateAnchors(img image.Image) map[string]image.Point {
+	offsets := make(map[string]image.Point)
+	sx, sy := b.ScaleFactor(img)
+
+	for _, k := range b.AnchorKeys() {
+		a := b.Anchors[k]
+		if a.Crop == nil {
+			continue
+		}
+		icon, err := b.AnchorImage(k)
+		if err != nil {
+			logutils.Template(b.Title).Warnf("Anchor %v: %v", k, err)
+			continue
+		}
+
+		// icon & its position at the resolution of the image
+		expected := a.Crop.Scale(sx, sy).CropRectangle()
+		if icon.Bounds().Dx() != expected.Dx() || icon.Bounds().Dy() != expected.Dy() {
+			icon = imgutils.ResizeImage(icon, expected.Dx(), expected.Dy())
+		}
+
+		mx, my := int(math.Round(float64(a.search())*sx)), int(math.Round(float64(a.search())*sy))
+		area := image.Rect(expected.Min.X-mx, expected.Min.Y-my, expected.Max.X+mx, expected.Max.Y+my).Add(img.Bounds().Min)
+		at, score := imgutils.MatchTemplate(img, icon, area)
+		if score < a.minScore() {
+			logutils.Template(b.Title).Warnf("Anchor %v not found (best score: %.2f)", k, score)
+			continue
+		}
+
+		offset := at.Sub(img.Bounds().Min).Sub(expected.Min)
+		logutils.Template(b.Title).Debugf("Anchor %v found at %v (offset %v, score: %.2f)", k, at, offset, score)
+		offsets[k] = offset
+	}
+	return offsets
+}
+
+// MoveAnchored - returns a copy of the template, with crops of anchored fields moved by offsets of their anchors
+// (see LocateAnchors). Offsets are in image pixels, so template has to be scaled to the image already (see ScaledTo).
+func (b OCRTemplate) MoveAnchored(offsets map[string]image.Point) OCRTemplate {
+	if len(offsets) == 0 {
+		return b
+	}
+
+	fields := make(map[string]OCRSchema, len(b.OCRSchema))
+	for k, s := range b.OCRSchema {
+		if offset, ok := offsets[s.anchor()]; ok && s.Crop != nil {
+			c := *s.Crop
+			c.X, c.Y = c.X+offset.X, c.Y+offset.Y
+			s.Crop = &c
+		}
+		fields[k] = s
+	}
+	b.OCRSchema = fields
+	return b
+}
+
+// anchor - name of the anchor the field moves with, set explicitly or by its crop relative to it
+func (s OCRSchema) anchor() string {
+	if len(s.Anchor) == 0 && s.Crop != nil {
+		return s.Crop.From
+	}
+	return s.Anchor
+}
+
+// validateAnchors - anchors need a crop & an icon, and fields can reference only existing anchors
+func (b *OCRTemplate) validateAnchors() []ValidationError {
+	var errs []ValidationError
+
+	for _, k := range b.AnchorKeys() {
+		a := b.Anchors[k]
+		path := fmt.Sprintf("anchors.%s", k)
+		err := b.validateCrop(a.Crop)
+		if a.Crop != nil && a.Crop.spec != nil {
+			if _, _, e := b.cropRect(a.Crop.spec, false); e != nil {
+				err = e
+			}
+		}
+		if err != nil {
+			errs = append(errs, ValidationError{Path: path + ".crop", Message: err.Error()})
+		}
+		if len(a.Image) == 0 && len(b.ReferenceImage) == 0 {
+			errs = append(errs, ValidationError{Path: path + ".image", Message: "image is missing, and template has no reference_image to cut it from"})
+		}
+		if a.Search < 0 {
+			errs = append(errs, ValidationError{Path: path + ".search", Message: "search can't be negative"})
+		}
+		if a.MinScore < 0 || a.MinScore > 1 {
+			errs = append(errs, ValidationError{Path: path + ".min_score", Message: fmt.Sprintf("min_score %v is out of range 0-1", a.MinScore)})
+		}
+	}
+
+	for _, k := range b.FieldKeys() {
+		if anchor := b.OCRSchema[k].Anchor; len(anchor) > 0 {
+			if _, ok := b.Anchors[anchor]; !ok {
+				errs = append(errs, ValidationError{Path: fmt.Sprintf("ocr_schema.%s.anchor", k), Message: fmt.Sprintf("unknown anchor %q", anchor)})
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,48 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+type AnnotateOptions struct {
+	FontScale      int
+	ShowConfidence bool
+}
+
+var (
+	annotateBoxColor  = color.RGBA{R: 255, A: 255}
+	annotateTextColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	annotateBackColor = color.RGBA{A: 200}
+)
+
+// AnnotatedResult - draws every field crop & recognized value on a copy of the image
+func (b *OCRTemplate) AnnotatedResult(img image.Image, result OCRResult) image.Image {
+	return b.AnnotatedResultWithOptions(img, result, AnnotateOptions{FontScale: 2, ShowConfidence: true})
+}
+
+func (b *OCRTemplate) AnnotatedResultWithOptions(img image.Image, result OCRResult, opts AnnotateOptions) image.Image {
+	dst := imgutils.CloneRGBA(b.NormalizeImage(img))
+
+	for _, k := range b.FieldKeys() {
+		s := b.OCRSchema[k]
+		if s.Crop == nil {
+			continue
+		}
+
+		r := s.Crop.CropRectangle()
+		imgutils.DrawRectangle(dst, r, annotateBoxColor, 2)
+
+		label := fmt.Sprintf("%s: %s", k, FormatValue(result.Data[k]))
+		if f, ok := result.Fields[k]; ok && opts.ShowConfidence {
+			label = fmt.Sprintf("%s (%.0f%%)", label, f.Confidence)
+		}
+
+		imgutils.DrawLabel(dst, r.Max.X+4, r.Min.Y, label, opts.FontScale, annotateTextColor, annotateBackColor)
+	}
+
+	return dst
+}
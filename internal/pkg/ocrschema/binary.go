@@ -0,0 +1,109 @@
+package ocrschema
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// TemplateBinaryExt - extension of compiled (gob encoded) templates. JSON stays the authoring format.
+const TemplateBinaryExt = ".rokt"
+
+// binaryTemplate - same layout as OCRTemplate, but without MarshalBinary (gob would recurse otherwise)
+type binaryTemplate OCRTemplate
+
+func init() {
+	// callback & allowlist are free-form in JSON templates
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+func (b *OCRTemplate) MarshalBinary() ([]byte, error) {
+	t := binaryTemplate(*b)
+	t.BaseDir = ""
+
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(&t)
+	return buf.Bytes(), err
+}
+
+func (b *OCRTemplate) UnmarshalBinary(data []byte) error {
+	var t binaryTemplate
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&t); err != nil {
+		return err
+	}
+	// gob drops empty lists, free-form callbacks are written back to JSON as they were read
+	for k, s := range t.OCRSchema {
+		if c, ok := s.Callback.([]interface{}); ok && c == nil {
+			s.Callback = []interface{}{}
+			t.OCRSchema[k] = s
+		}
+	}
+	*b = OCRTemplate(t)
+	return nil
+}
+
+// binaryCrop - OCRCrop with the crop as written (spec), so compiled templates keep percentages & points
+type binaryCrop struct {
+	X, Y, W, H int
+	From       string
+	Origin     *image.Point
+	Spec       *binaryCropSpec
+}
+
+type binaryCropSpec struct {
+	From     string
+	Values   [4]OCRValue
+	Rect     image.Rectangle
+	Resolved bool
+}
+
+func (b *OCRCrop) GobEncode() ([]byte, error) {
+	c := binaryCrop{X: b.X, Y: b.Y, W: b.W, H: b.H, From: b.From, Origin: b.Origin}
+	if s := b.spec; s != nil {
+		c.Spec = &binaryCropSpec{From: s.From, Values: s.Values, Rect: s.rect, Resolved: s.resolved}
+	}
+
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(&c)
+	return buf.Bytes(), err
+}
+
+func (b *OCRCrop) GobDecode(data []byte) error {
+	var c binaryCrop
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&c); err != nil {
+		return err
+	}
+	*b = OCRCrop{X: c.X, Y: c.Y, W: c.W, H: c.H, From: c.From, Origin: c.Origin}
+	if s := c.Spec; s != nil {
+		b.spec = &cropSpec{From: s.From, Values: s.Values, rect: s.Rect, resolved: s.Resolved}
+	}
+	return nil
+}
+
+func LoadTemplateBinary(fileName string) (OCRTemplate, error) {
+	var t OCRTemplate
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return t, err
+	}
+
+	if err := t.UnmarshalBinary(data); err != nil {
+		return t, fmt.Errorf("invalid binary template: %w", err)
+	}
+	if abs, e := filepath.Abs(fileName); e == nil {
+		t.BaseDir = filepath.Dir(abs)
+	}
+	return t, t.checkHashes()
+}
+
+func WriteTemplateBinary(t OCRTemplate, fileName string) error {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
@@ -0,0 +1,122 @@
+package ocrschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "templates", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no bundled templates: %v", err)
+	}
+
+	// crops written with percentages & relative to points are kept as written
+	relative := filepath.Join(t.TempDir(), "relative.json")
+	writeFile(t, relative, `{
+		"title": "Relative crops",
+		"width": 200,
+		"height": 100,
+		"points": {"badge": ["10%", 20]},
+		"ocr_schema": {
+			"name": {"lang": ["eng"], "crop": ["10%", "10%", "50%", 20]},
+			"power": {"lang": ["eng"], "crop": {"from": "badge", "x": 5, "y": 0, "w": 40, "h": 10}},
+			"kills": {"lang": ["eng"], "crop": {"from": "center", "x": 0, "y": 0, "w": "10%", "h": 10}}
+		}
+	}`)
+	files = append(files, relative)
+
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			fromJSON, err := LoadTemplate(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compiled := filepath.Join(t.TempDir(), "template"+TemplateBinaryExt)
+			if err := WriteTemplateBinary(fromJSON, compiled); err != nil {
+				t.Fatal(err)
+			}
+			fromBinary, err := LoadTemplateBinary(compiled)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// templates are compared as they're written out, gob doesn't keep empty & missing lists apart
+			want, err := json.Marshal(&fromJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(&fromBinary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("binary template differs from JSON:\njson:   %s\nbinary: %s", want, got)
+			}
+
+			// resolved pixels & points are the same too
+			for k, f := range fromJSON.OCRSchema {
+				if got, want := fromBinary.OCRSchema[k].Crop, f.Crop; !reflect.DeepEqual(got, want) {
+					t.Errorf("crop of %v = %+v, want %+v", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadTemplatesPrefersUpToDateBinary(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "profile.json")
+	compiled := filepath.Join(dir, "profile"+TemplateBinaryExt)
+
+	writeFile(t, source, `{"title": "Profile", "width": 100, "height": 100}`)
+	template, err := LoadTemplate(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.Title = "Profile (compiled)"
+	if err := WriteTemplateBinary(template, compiled); err != nil {
+		t.Fatal(err)
+	}
+
+	touch := func(name string, at time.Time) {
+		if err := os.Chtimes(name, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		jsonAt    time.Time
+		compileAt time.Time
+		want      string
+	}{
+		{"binary is newer", now.Add(-time.Hour), now, "Profile (compiled)"},
+		{"same time", now, now, "Profile (compiled)"},
+		{"JSON changed since", now, now.Add(-time.Hour), "Profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			touch(source, tt.jsonAt)
+			touch(compiled, tt.compileAt)
+
+			templates, skipped := LoadTemplatesWithErrors(dir)
+			if len(skipped) > 0 {
+				t.Fatalf("skipped: %v", skipped)
+			}
+			if len(templates) != 1 {
+				t.Fatalf("got %d templates, want 1", len(templates))
+			}
+			if templates[0].Title != tt.want {
+				t.Errorf("loaded %q, want %q", templates[0].Title, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ocrschema
+
+// TemplateBuilder - helps constructing templates in code (tests, tools, template maker)
+type TemplateBuilder struct {
+	template OCRTemplate
+}
+
+func NewTemplateBuilder(title string) *TemplateBuilder {
+	return &TemplateBuilder{
+		template: OCRTemplate{
+			SchemaVersion: CurrentSchemaVersion,
+			Title:         title,
+			Version:       "1",
+			OCRSchema:     make(map[string]OCRSchema),
+		},
+	}
+}
+
+func (tb *TemplateBuilder) Version(version string) *TemplateBuilder {
+	tb.template.Version = version
+	return tb
+}
+
+func (tb *TemplateBuilder) Author(author string) *TemplateBuilder {
+	tb.template.Author = author
+	return tb
+}
+
+func (tb *TemplateBuilder) Size(w, h int) *TemplateBuilder {
+	tb.template.Width = w
+	tb.template.Height = h
+	return tb
+}
+
+func (tb *TemplateBuilder) Fingerprint(fingerprint string, threshold int) *TemplateBuilder {
+	tb.template.Fingerprint = fingerprint
+	tb.template.Threshold = threshold
+	return tb
+}
+
+func (tb *TemplateBuilder) Checkpoint(crop OCRCrop, fingerprint string) *TemplateBuilder {
+	tb.template.Checkpoints = append(tb.template.Checkpoints, OCRCheckpoint{Crop: &crop, Fingerprint: fingerprint})
+	return tb
+}
+
+func (tb *TemplateBuilder) Field(key string, field OCRSchema) *TemplateBuilder {
+	tb.template.OCRSchema[key] = field
+	return tb
+}
+
+func (tb *TemplateBuilder) NumberField(key string, crop OCRCrop) *TemplateBuilder {
+	return tb.Field(key, NewNumberField(&crop))
+}
+
+func (tb *TemplateBuilder) TextField(key string, crop OCRCrop, languages ...string) *TemplateBuilder {
+	if len(languages) == 0 {
+		languages = []string{"eng"}
+	}
+	return tb.Field(key, NewTextField(&crop, languages...))
+}
+
+func (tb *TemplateBuilder) TableColumn(title, key string) *TemplateBuilder {
+	tb.template.Table = append(tb.template.Table, OCRTableField{Title: title, Field: key})
+	return tb
+}
+
+// Build - returns the template, if there are no table columns defined, all fields are used (sorted by key)
+func (tb *TemplateBuilder) Build() (OCRTemplate, error) {
+	t := tb.template
+	t.OCRSchema = make(map[string]OCRSchema, len(tb.template.OCRSchema))
+	for k, v := range tb.template.OCRSchema {
+		t.OCRSchema[k] = v
+	}
+	t.Table = append([]OCRTableField{}, tb.template.Table...)
+	t.Checkpoints = append([]OCRCheckpoint{}, tb.template.Checkpoints...)
+
+	if len(t.Table) == 0 {
+		for _, k := range t.FieldKeys() {
+			t.Table = append(t.Table, OCRTableField{Title: k, Field: k})
+		}
+	}
+
+	return t, t.Validate()
+}
@@ -0,0 +1,56 @@
+package ocrschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateBuilder(t *testing.T) {
+	template, err := NewTemplateBuilder("Profile").
+		Author("tests").
+		Size(1920, 1080).
+		Fingerprint("e8d4d8f8f8d0d8d8", 10).
+		Checkpoint(OCRCrop{X: 0, Y: 0, W: 400, H: 100}, "9be4e4ecccf0f0f1").
+		TextField("name", OCRCrop{X: 500, Y: 300, W: 400, H: 50}).
+		NumberField("power", OCRCrop{X: 500, Y: 400, W: 300, H: 50}).
+		NumberField("kills", OCRCrop{X: 500, Y: 500, W: 300, H: 50}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	if err := template.Validate(); err != nil {
+		t.Errorf("Validate(): %v", err)
+	}
+
+	// without columns, all fields are in the table
+	var columns []string
+	for _, c := range template.Table {
+		columns = append(columns, c.Field)
+	}
+	if want := []string{"kills", "name", "power"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("table = %v, want %v", columns, want)
+	}
+}
+
+func TestTemplateBuilderReportsInvalid(t *testing.T) {
+	_, err := NewTemplateBuilder("Profile").
+		Size(1920, 1080).
+		Fingerprint("e8d4d8f8f8d0d8d8", 10).
+		NumberField("power", OCRCrop{X: 1800, Y: 400, W: 300, H: 50}).
+		TableColumn("Kills", "kills").
+		Build()
+
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) == 0 {
+		t.Fatalf("Build() = %v, want validation errors", err)
+	}
+	paths := make(map[string]bool)
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	for _, p := range []string{"ocr_schema.power.crop", "table[0] (Kills).field"} {
+		if !paths[p] {
+			t.Errorf("no error for %v, got %v", p, errs)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// loadChain - loads templates of Chain, they can't chain further, have rows or be parts of records
+func (b *OCRTemplate) loadChain() error {
+	b.Chained = nil
+	for _, f := range b.Chain {
+		path := b.ResolvePath(f)
+		c, err := loadTemplateFile(path)
+		if err != nil {
+			return fmt.Errorf("chain %v: %w", f, err)
+		}
+		switch {
+		case len(c.Chain) > 0:
+			return fmt.Errorf("chain %v: chained templates can't chain other ones", f)
+		case c.Rows != nil:
+			return fmt.Errorf("chain %v: chained templates can't have rows", f)
+		case c.Record != nil:
+			return fmt.Errorf("chain %v: chained templates can't be parts of records", f)
+		}
+		if len(c.Title) == 0 {
+			c.Title = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		}
+		b.Chained = append(b.Chained, c)
+		// a change of a chained template changes this one too
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		b.Sources = append(append(b.Sources, c.Sources...), path)
+	}
+	return nil
+}
+
+// AppliesTo - chained template reads the image: its checkpoints (or fingerprint) match, or it has none
+func (b *OCRTemplate) AppliesTo(img image.Image) bool {
+	if len(b.Fingerprint) == 0 && len(b.Checkpoints) == 0 {
+		return true
+	}
+	return b.Matches(img)
+}
+
+// MergeChained - adds fields of a chained template's result to the result, fields already recognized
+// by the template itself are kept
+func MergeChained(r *OCRResult, chained OCRResult) {
+	if r.Data == nil {
+		r.Data = make(map[string]interface{})
+	}
+	for k, v := range chained.Data {
+		if existing := r.Data[k]; existing == nil || len(strings.TrimSpace(FormatValue(existing))) == 0 {
+			r.Data[k] = v
+			if f, ok := chained.Fields[k]; ok {
+				if r.Fields == nil {
+					r.Fields = make(map[string]FieldResult)
+				}
+				r.Fields[k] = f
+			}
+		}
+	}
+	r.Took += chained.Took
+}
+
+// ChainedColumns - table columns of chained templates which the template table doesn't show already
+func (b *OCRTemplate) ChainedColumns(shown []OCRTableField) []OCRTableField {
+	fields := make(map[string]bool)
+	for _, x := range shown {
+		fields[x.Field] = true
+	}
+
+	var columns []OCRTableField
+	for _, c := range b.Chained {
+		table := c.Table
+		if len(table) == 0 {
+			for _, k := range c.ColumnKeys() {
+				table = append(table, OCRTableField{Title: k, Field: k})
+			}
+		}
+		for _, x := range table {
+			if !fields[x.Field] {
+				fields[x.Field] = true
+				columns = append(columns, x)
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,158 @@
+package ocrschema
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// CheckpointOptions - knobs for GenerateCheckpoints
+type CheckpointOptions struct {
+	// Columns x Rows - grid the screen is divided into when looking for candidates
+	Columns int
+	Rows    int
+	// Count - how many checkpoints to emit (at most)
+	Count int
+	// MaxDistance - cells with bigger hash distance between samples are considered unstable
+	MaxDistance int
+	// MinContrast - std deviation of luminance, flat (single color) cells give meaningless hashes
+	MinContrast float64
+}
+
+func DefaultCheckpointOptions() CheckpointOptions {
+	return CheckpointOptions{
+		Columns:     8,
+		Rows:        6,
+		Count:       4,
+		MaxDistance: 4,
+		MinContrast: 12,
+	}
+}
+
+type checkpointCandidate struct {
+	crop     OCRCrop
+	distance int
+	contrast float64
+}
+
+// GenerateCheckpoints - takes a few screenshots of the same screen (with different data in it), and finds
+// regions which look the same on all of them. Those become checkpoints, with fingerprint taken from the first
+// sample and threshold a bit above the distance seen between the samples.
+// Samples are scaled to template size, so returned crops are in template coordinates.
+func (b *OCRTemplate) GenerateCheckpoints(samples []image.Image, opts CheckpointOptions) ([]OCRCheckpoint, error) {
+	if len(samples) < 2 {
+		return nil, errors.New("need at least 2 sample images to find stable regions")
+	}
+	if opts.Columns <= 0 || opts.Rows <= 0 || opts.Count <= 0 {
+		return nil, fmt.Errorf("invalid options: %+v", opts)
+	}
+
+	var images []image.Image
+	for _, img := range samples {
+		if b.Width <= 0 || b.Height <= 0 {
+			b.Width, b.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+		images = append(images, imgutils.CloneRGBA(b.NormalizeImage(img)))
+	}
+
+	cellW, cellH := b.Width/opts.Columns, b.Height/opts.Rows
+	if cellW < 8 || cellH < 8 {
+		return nil, fmt.Errorf("grid %vx%v is too dense for %vx%v template", opts.Columns, opts.Rows, b.Width, b.Height)
+	}
+
+	var candidates []checkpointCandidate
+	for row := 0; row < opts.Rows; row++ {
+		for col := 0; col < opts.Columns; col++ {
+			crop := OCRCrop{X: col * cellW, Y: row * cellH, W: cellW, H: cellH}
+			if c, ok := b.checkpointCandidate(images, crop, opts); ok {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	// most stable first, then the most "detailed" ones
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].contrast > candidates[j].contrast
+	})
+
+	if len(candidates) > opts.Count {
+		candidates = candidates[:opts.Count]
+	}
+
+	var checkpoints []OCRCheckpoint
+	for _, c := range candidates {
+		crop := c.crop
+		sub, _ := imgutils.CropImage(images[0], crop.CropRectangle())
+		hash, err := b.ImageHash(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		checkpoints = append(checkpoints, OCRCheckpoint{
+			Crop:        &crop,
+			Fingerprint: fmt.Sprintf("%x", hash.GetHash()),
+			Threshold:   c.distance + 2,
+		})
+	}
+
+	if len(checkpoints) == 0 {
+		return nil, errors.New("no stable regions found, samples are too different")
+	}
+
+	return checkpoints, nil
+}
+
+func (b *OCRTemplate) checkpointCandidate(images []image.Image, crop OCRCrop, opts CheckpointOptions) (checkpointCandidate, bool) {
+	candidate := checkpointCandidate{crop: crop, contrast: math.MaxFloat64}
+
+	first, _ := imgutils.CropImage(images[0], crop.CropRectangle())
+	firstHash, err := b.ImageHash(first)
+	if err != nil {
+		return candidate, false
+	}
+
+	for _, img := range images {
+		sub, _ := imgutils.CropImage(img, crop.CropRectangle())
+		candidate.contrast = math.Min(candidate.contrast, luminanceStdDev(sub))
+
+		hash, err := b.ImageHash(sub)
+		if err != nil {
+			return candidate, false
+		}
+		distance, err := firstHash.Distance(hash)
+		if err != nil {
+			return candidate, false
+		}
+		if distance > candidate.distance {
+			candidate.distance = distance
+		}
+	}
+
+	return candidate, candidate.distance <= opts.MaxDistance && candidate.contrast >= opts.MinContrast
+}
+
+func luminanceStdDev(img image.Image) float64 {
+	var sum, sumSq, n float64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			l := 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	mean := sum / n
+	return math.Sqrt(math.Max(0, sumSq/n-mean*mean))
+}
@@ -0,0 +1,89 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testScreen - 200x100 image of 4 areas with different stripes, so every area has its own fingerprint
+func testScreen() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			area := x / 50
+			var v uint8
+			switch area {
+			case 0:
+				v = uint8(x * 5)
+			case 1:
+				v = uint8(255 - y*2)
+			case 2:
+				v = uint8((x + y) % 64 * 4)
+			default:
+				if (x/7+y/5)%2 == 0 {
+					v = 220
+				}
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+// testCheckpoint - checkpoint over the area of testScreen, matching it or not (fingerprint with all bits flipped)
+func testCheckpoint(t *testing.T, img image.Image, area int, matching, optional bool) OCRCheckpoint {
+	t.Helper()
+	crop := &OCRCrop{X: area * 50, Y: 0, W: 50, H: 100}
+	fingerprint, err := Fingerprint(img, crop, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matching {
+		hash, _ := HashFromString(fingerprint, "")
+		fingerprint = fmt.Sprintf("%x", ^hash.GetHash())
+	}
+	return OCRCheckpoint{Crop: crop, Fingerprint: fingerprint, Optional: optional}
+}
+
+func TestCheckpointQuorum(t *testing.T) {
+	img := testScreen()
+
+	type checkpoint struct {
+		matching, optional bool
+	}
+	tests := []struct {
+		name        string
+		quorum      int
+		checkpoints []checkpoint
+		matched     int
+		matches     bool
+	}{
+		{"all (quorum 0), all match", 0, []checkpoint{{true, false}, {true, false}, {true, false}}, 3, true},
+		{"all (quorum 0), one missed", 0, []checkpoint{{true, false}, {false, false}, {true, false}}, 2, false},
+		{"2 of 3 met", 2, []checkpoint{{true, false}, {false, false}, {true, false}}, 2, true},
+		{"3 of 4 met", 3, []checkpoint{{true, false}, {true, false}, {false, false}, {true, false}}, 3, true},
+		{"3 of 4 missed", 3, []checkpoint{{true, false}, {false, false}, {false, false}, {true, false}}, 2, false},
+		{"quorum over checkpoints means all", 5, []checkpoint{{true, false}, {false, false}}, 1, false},
+		{"optional missed, required matched", 0, []checkpoint{{true, false}, {false, true}, {true, false}}, 2, true},
+		{"optional matched, required missed", 0, []checkpoint{{false, false}, {true, true}}, 1, false},
+		{"only optional ones, one has to match", 0, []checkpoint{{false, true}, {true, true}}, 1, true},
+		{"only optional ones, none matched", 0, []checkpoint{{false, true}, {false, true}}, 0, false},
+		{"optional counts towards quorum", 2, []checkpoint{{true, false}, {false, false}, {true, true}}, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := OCRTemplate{Title: tt.name, Width: 200, Height: 100, CheckpointQuorum: tt.quorum}
+			for i, c := range tt.checkpoints {
+				template.Checkpoints = append(template.Checkpoints, testCheckpoint(t, img, i, c.matching, c.optional))
+			}
+
+			matched, matches := template.checkpointHits(img)
+			if matched != tt.matched || matches != tt.matches {
+				t.Errorf("checkpointHits() = %v, %v, want %v, %v", matched, matches, tt.matched, tt.matches)
+			}
+		})
+	}
+}
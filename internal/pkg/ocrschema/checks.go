@@ -0,0 +1,129 @@
+package ocrschema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckKeys - names of sanity checks, in alphabetical order
+func (b *OCRTemplate) CheckKeys() []string {
+	var keys []string
+	for k := range b.Checks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FailedChecks - sanity checks the data (with computed fields) doesn't pass, name => values it was evaluated with.
+// Checks with a missing or non-numeric input are skipped, empty fields are reported by validation of the field.
+func (b *OCRTemplate) FailedChecks(data map[string]interface{}) map[string]string {
+	failed := make(map[string]string)
+
+	for _, name := range b.CheckKeys() {
+		e, fields, err := parseCondition(b.Checks[name])
+		if err != nil {
+			failed[name] = err.Error()
+			continue
+		}
+
+		values := make(map[string]float64)
+		lookup := func(k string) (float64, error) {
+			v, err := b.numericField(data, k)
+			if err == nil {
+				values[k] = v
+			}
+			return v, err
+		}
+
+		ok, err := e.eval(lookup)
+		if err != nil || ok != 0 {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var used []string
+		for _, f := range fields {
+			if !seen[f] {
+				seen[f] = true
+				used = append(used, fmt.Sprintf("%s=%v", f, values[f]))
+			}
+		}
+		failed[name] = fmt.Sprintf("%s (%s)", b.Checks[name], strings.Join(used, ", "))
+	}
+
+	return failed
+}
+
+// numericField - recognized or computed value of data as a number
+func (b *OCRTemplate) numericField(data map[string]interface{}, k string) (float64, error) {
+	v := data[k]
+	if v == nil {
+		return 0, fmt.Errorf("%s is missing", k)
+	}
+	if s, ok := b.OCRSchema[k]; ok {
+		return s.numericValue(v)
+	}
+	if f, ok := v.(float64); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("%s is not a number", k)
+}
+
+// checkInputs - recognized fields the check depends on, directly or through computed fields
+func (b *OCRTemplate) checkInputs(name string) []string {
+	_, fields, err := parseCondition(b.Checks[name])
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var inputs []string
+	var visit func(k string)
+	visit = func(k string) {
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		if _, ok := b.OCRSchema[k]; ok {
+			inputs = append(inputs, k)
+			return
+		}
+		if src, ok := b.Computed[k]; ok {
+			if _, refs, err := parseExpression(src); err == nil {
+				for _, f := range refs {
+					visit(f)
+				}
+			}
+		}
+	}
+	for _, f := range fields {
+		visit(f)
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// validateChecks - conditions have to parse, and reference only existing fields
+func (b *OCRTemplate) validateChecks() []ValidationError {
+	var errs []ValidationError
+
+	for _, k := range b.CheckKeys() {
+		path := fmt.Sprintf("checks.%s", k)
+		_, fields, err := parseCondition(b.Checks[k])
+		if err != nil {
+			errs = append(errs, ValidationError{Path: path, Message: err.Error()})
+			continue
+		}
+		for _, f := range fields {
+			_, recognized := b.OCRSchema[f]
+			_, computed := b.Computed[f]
+			if !recognized && !computed {
+				errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("unknown field %q", f)})
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,140 @@
+package ocrschema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ComputedKeys - names of computed fields, in alphabetical order
+func (b *OCRTemplate) ComputedKeys() []string {
+	var keys []string
+	for k := range b.Computed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ColumnKeys - recognized fields followed by computed ones, default export columns if template has no Table
+func (b *OCRTemplate) ColumnKeys() []string {
+	return append(b.FieldKeys(), b.ComputedKeys()...)
+}
+
+// ComputeFields - returns recognized data with computed fields added. Fields which can't be computed
+// (input missing or not a number) are nil, and reported in the returned errors.
+func (b *OCRTemplate) ComputeFields(data map[string]interface{}) (map[string]interface{}, map[string]error) {
+	if len(b.Computed) == 0 {
+		return data, nil
+	}
+
+	result := make(map[string]interface{}, len(data)+len(b.Computed))
+	for k, v := range data {
+		result[k] = v
+	}
+
+	values := make(map[string]float64)
+	visiting := make(map[string]bool)
+	var lookup func(name string) (float64, error)
+	lookup = func(name string) (float64, error) {
+		if v, ok := values[name]; ok {
+			return v, nil
+		}
+
+		src, computed := b.Computed[name]
+		if !computed {
+			s, ok := b.OCRSchema[name]
+			if !ok || data[name] == nil {
+				return 0, fmt.Errorf("%s is missing", name)
+			}
+			v, err := s.numericValue(data[name])
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", name, err)
+			}
+			values[name] = v
+			return v, nil
+		}
+
+		if visiting[name] {
+			return 0, fmt.Errorf("%s depends on itself", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		e, _, err := parseExpression(src)
+		if err != nil {
+			return 0, err
+		}
+		v, err := e.eval(lookup)
+		if err != nil {
+			return 0, err
+		}
+		values[name] = v
+		return v, nil
+	}
+
+	errs := make(map[string]error)
+	for _, k := range b.ComputedKeys() {
+		v, err := lookup(k)
+		if err != nil {
+			result[k] = nil
+			errs[k] = err
+			continue
+		}
+		result[k] = v
+	}
+	return result, errs
+}
+
+// validateComputed - expressions have to parse, and reference only existing fields without cycles
+func (b *OCRTemplate) validateComputed() []ValidationError {
+	var errs []ValidationError
+
+	refs := make(map[string][]string)
+	for _, k := range b.ComputedKeys() {
+		path := fmt.Sprintf("computed.%s", k)
+		if _, ok := b.OCRSchema[k]; ok {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("%q is already a field of ocr_schema", k)})
+		}
+
+		_, fields, err := parseExpression(b.Computed[k])
+		if err != nil {
+			errs = append(errs, ValidationError{Path: path, Message: err.Error()})
+			continue
+		}
+		for _, f := range fields {
+			_, recognized := b.OCRSchema[f]
+			_, computed := b.Computed[f]
+			if !recognized && !computed {
+				errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("unknown field %q", f)})
+			}
+		}
+		refs[k] = fields
+	}
+
+	// cycles between computed fields
+	state := make(map[string]int) // 1 - visiting, 2 - done
+	var visit func(k string) bool
+	visit = func(k string) bool {
+		switch state[k] {
+		case 1:
+			return false
+		case 2:
+			return true
+		}
+		state[k] = 1
+		for _, f := range refs[k] {
+			if _, ok := b.Computed[f]; ok && !visit(f) {
+				return false
+			}
+		}
+		state[k] = 2
+		return true
+	}
+	for _, k := range b.ComputedKeys() {
+		if state[k] == 0 && !visit(k) {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("computed.%s", k), Message: "fields depend on each other in a cycle"})
+		}
+	}
+
+	return errs
+}
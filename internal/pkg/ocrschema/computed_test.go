@@ -0,0 +1,135 @@
+package ocrschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func killsTemplate(computed, checks map[string]string) OCRTemplate {
+	return OCRTemplate{
+		OCRSchema: map[string]OCRSchema{
+			"kp": {Type: TypeInt},
+			"t4": {Type: TypeInt},
+			"t5": {Type: TypeInt},
+		},
+		Computed: computed,
+		Checks:   checks,
+	}
+}
+
+func TestComputeFields(t *testing.T) {
+	template := killsTemplate(map[string]string{
+		"t45":       "t4 + t5",
+		"t45_score": "t4 * 10 + t5 * 20",
+		"ratio":     "round(kp / t45 * 100)",
+		"a":         "b + 1",
+		"b":         "a + 1",
+		"self":      "self * 2",
+		"per_t5":    "kp / t5",
+	}, nil)
+
+	data := map[string]interface{}{"kp": int64(500), "t4": int64(10), "t5": int64(0)}
+	got, errs := template.ComputeFields(data)
+
+	want := map[string]interface{}{"t45": 10.0, "t45_score": 100.0, "ratio": 5000.0}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if got["kp"] != int64(500) {
+		t.Errorf("recognized kp = %v, want it kept", got["kp"])
+	}
+
+	wantErrs := map[string]string{
+		"a":      "depends on itself",
+		"b":      "depends on itself",
+		"self":   "self depends on itself",
+		"per_t5": "division by zero",
+	}
+	if len(errs) != len(wantErrs) {
+		t.Errorf("got errors %v, want %v", errs, wantErrs)
+	}
+	for k, msg := range wantErrs {
+		if errs[k] == nil || !strings.Contains(errs[k].Error(), msg) {
+			t.Errorf("%s: got %v, want error %q", k, errs[k], msg)
+		}
+		if v, ok := got[k]; !ok || v != nil {
+			t.Errorf("%s = %v, want nil", k, v)
+		}
+	}
+}
+
+func TestComputeFieldsMissingInput(t *testing.T) {
+	template := killsTemplate(map[string]string{"t45": "t4 + t5"}, nil)
+
+	got, errs := template.ComputeFields(map[string]interface{}{"t4": int64(10)})
+	if got["t45"] != nil || errs["t45"] == nil || !strings.Contains(errs["t45"].Error(), "t5 is missing") {
+		t.Errorf("got %v (%v), want nil & t5 is missing", got["t45"], errs["t45"])
+	}
+
+	// recognized as text, parsed like the field would be
+	got, errs = template.ComputeFields(map[string]interface{}{"t4": "1,000", "t5": int64(5)})
+	if got["t45"] != 1005.0 || len(errs) > 0 {
+		t.Errorf("got %v (%v), want 1005", got["t45"], errs)
+	}
+}
+
+func TestValidateComputed(t *testing.T) {
+	tests := []struct {
+		name     string
+		computed map[string]string
+		want     []string
+	}{
+		{"valid", map[string]string{"t45": "t4 + t5", "score": "t45 * 2"}, nil},
+		{"unknown field", map[string]string{"t45": "t4 + t6"}, []string{`computed.t45: unknown field "t6"`}},
+		{"clash with ocr_schema", map[string]string{"kp": "t4 * 10"}, []string{`computed.kp: "kp" is already a field of ocr_schema`}},
+		{"syntax error", map[string]string{"t45": "t4 +"}, []string{"computed.t45: unexpected end of expression"}},
+		{"self reference", map[string]string{"a": "a + 1"}, []string{"computed.a: fields depend on each other in a cycle"}},
+		{"cycle", map[string]string{"a": "b + 1", "b": "c + 1", "c": "a + t4"}, []string{"computed.a: fields depend on each other in a cycle"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := killsTemplate(tt.computed, nil)
+			var got []string
+			for _, e := range template.validateComputed() {
+				got = append(got, e.Path+": "+e.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailedChecks(t *testing.T) {
+	template := killsTemplate(map[string]string{"t45_score": "t4 * 10 + t5 * 20"}, map[string]string{
+		"kill_points": "kp >= t45_score",
+		"t5_fewer":    "t5 <= t4",
+		"exact":       "kp == 500",
+		"not_zero":    "kp != 0",
+		"broken":      "kp +",
+		"no_compare":  "kp + t4",
+		"missing":     "deaths < kp",
+	})
+
+	data, _ := template.ComputeFields(map[string]interface{}{"kp": int64(400), "t4": int64(10), "t5": int64(20)})
+	failed := template.FailedChecks(data)
+
+	want := map[string]string{
+		"kill_points": "kp >= t45_score (kp=400, t45_score=500)",
+		"t5_fewer":    "t5 <= t4 (t5=20, t4=10)",
+		"exact":       "kp == 500 (kp=400)",
+		"broken":      "unexpected end of expression",
+		"no_compare":  "missing comparison",
+	}
+	if len(failed) != len(want) {
+		t.Errorf("got %v, want %v", failed, want)
+	}
+	for k, msg := range want {
+		if !strings.Contains(failed[k], msg) {
+			t.Errorf("%s: got %q, want %q", k, failed[k], msg)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package ocrschema
+
+import "fmt"
+
+// Correct - sets the field of the result to text typed by a reviewer. The value is post-processed like recognized
+// text (pattern & type), and computed fields are computed again. Text recognized by OCR is kept as Original.
+func (b *OCRTemplate) Correct(r *OCRResult, field, text string) error {
+	s, ok := b.OCRSchema[field]
+	if !ok {
+		return fmt.Errorf("unknown field: %v", field)
+	}
+
+	f := r.Fields[field]
+	if !f.Corrected {
+		f.Original = f.Text
+	}
+	f.Text = text
+	f.Value, f.Transforms = s.PostProcess(text)
+	f.Corrected = true
+	f.LowConfidence = false
+	f.Confidence = 100
+	if f.Crop == nil {
+		f.Crop = s.Crop.Pixels()
+	}
+
+	if r.Fields == nil {
+		r.Fields = make(map[string]FieldResult)
+	}
+	r.Fields[field] = f
+
+	data := make(map[string]interface{}, len(r.Data))
+	for k, v := range r.Data {
+		if _, computed := b.Computed[k]; !computed {
+			data[k] = v
+		}
+	}
+	data[field] = f.Value
+	r.Data, _ = b.ComputeFields(data)
+	return nil
+}
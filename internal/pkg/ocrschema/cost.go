@@ -0,0 +1,74 @@
+package ocrschema
+
+import "github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+
+// TemplateCost - static metrics of a single template, which correlate with OCR time per image
+type TemplateCost struct {
+	Title       string   `json:"title"`
+	Fields      int      `json:"fields"`
+	CropArea    int      `json:"crop_area"`
+	Languages   []string `json:"languages"`
+	Checkpoints int      `json:"checkpoints"`
+	// Preprocess - total number of preprocessing steps over all fields
+	Preprocess int `json:"preprocess"`
+}
+
+// CostEstimate - per image cost of a set of templates, multiply by image count for a batch
+type CostEstimate struct {
+	Templates   []TemplateCost `json:"templates"`
+	Fields      int            `json:"fields"`
+	CropArea    int            `json:"crop_area"`
+	Languages   []string       `json:"languages"`
+	Checkpoints int            `json:"checkpoints"`
+	Preprocess  int            `json:"preprocess"`
+}
+
+func (b *OCRTemplate) EstimateCost() TemplateCost {
+	cost := TemplateCost{
+		Title:       b.Title,
+		Fields:      len(b.OCRSchema),
+		Checkpoints: len(b.Checkpoints),
+	}
+
+	var languages []string
+	for _, s := range b.OCRSchema {
+		if s.Crop != nil {
+			cost.CropArea = cost.CropArea + s.Crop.W*s.Crop.H
+		}
+		cost.Preprocess = cost.Preprocess + len(s.Preprocess)
+		if len(s.Languages) == 0 {
+			languages = append(languages, "eng")
+		}
+		languages = append(languages, s.Languages...)
+	}
+	cost.Languages = stringutils.Unique(languages)
+
+	return cost
+}
+
+func EstimateCost(templates []OCRTemplate) CostEstimate {
+	var estimate CostEstimate
+	for _, t := range templates {
+		estimate = estimate.Add(CostEstimate{Templates: []TemplateCost{t.EstimateCost()}})
+	}
+	return estimate
+}
+
+// Add - sums two estimates (e.g. from different batches)
+func (c CostEstimate) Add(other CostEstimate) CostEstimate {
+	result := CostEstimate{
+		Templates: append(append([]TemplateCost{}, c.Templates...), other.Templates...),
+	}
+
+	var languages []string
+	for _, t := range result.Templates {
+		result.Fields = result.Fields + t.Fields
+		result.CropArea = result.CropArea + t.CropArea
+		result.Checkpoints = result.Checkpoints + t.Checkpoints
+		result.Preprocess = result.Preprocess + t.Preprocess
+		languages = append(languages, t.Languages...)
+	}
+	result.Languages = stringutils.Unique(languages)
+
+	return result
+}
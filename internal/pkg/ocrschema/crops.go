@@ -0,0 +1,123 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	log "github.com/sirupsen/logrus"
+)
+
+// NormalizeImage - crops are defined in template coordinates, so scale the image to template size
+func (b *OCRTemplate) NormalizeImage(img image.Image) image.Image {
+	if b.Width > 0 && b.Height > 0 && (b.Width != img.Bounds().Dx() || b.Height != img.Bounds().Dy()) {
+		return imgutils.ResizeImage(img, b.Width, b.Height)
+	}
+	return img
+}
+
+// Panels - splits vertically stitched screenshots into separate screens, otherwise returns the image itself
+func (b *OCRTemplate) Panels(img image.Image) []image.Image {
+	if !imgutils.IsStacked(img, b.Width, b.Height) {
+		return []image.Image{img}
+	}
+
+	// template height, scaled to the width of this image
+	panelHeight := b.Height * img.Bounds().Dx() / b.Width
+	return imgutils.SplitStacked(img, panelHeight)
+}
+
+// Panel - returns the panel, result was extracted from (see OCRResult.Panel)
+func (b *OCRTemplate) Panel(img image.Image, result OCRResult) image.Image {
+	panels := b.Panels(img)
+	if result.Panel > 0 && result.Panel <= len(panels) {
+		return panels[result.Panel-1]
+	}
+	return img
+}
+
+// FieldKeys - schema keys in stable order
+func (b *OCRTemplate) FieldKeys() []string {
+	var keys []string
+	for k := range b.OCRSchema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CropField - crops field area out of already normalized image
+func (b *OCRTemplate) CropField(img image.Image, key string) (image.Image, error) {
+	s, ok := b.OCRSchema[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %v", key)
+	}
+	if s.Crop == nil {
+		return nil, fmt.Errorf("field %v has no crop", key)
+	}
+
+	r := s.Crop.CropRectangle()
+	if r.Empty() || !r.In(img.Bounds()) {
+		return nil, fmt.Errorf("field %v crop %v is out of image bounds %v", key, r, img.Bounds())
+	}
+
+	return imgutils.CropImage(img, r)
+}
+
+// ResultCrops - crops of the (not normalized) image every field of the result was read from, by field. Crops follow
+// anchors & the row of a list like recognition does, fields outside of the image are left out.
+func (b *OCRTemplate) ResultCrops(img image.Image, result OCRResult) map[string]image.Image {
+	scaled := *b
+	if b.Width > 0 && b.Height > 0 && (b.Width != img.Bounds().Dx() || b.Height != img.Bounds().Dy()) {
+		scaled = b.ScaledTo(img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if len(b.Anchors) > 0 {
+		scaled = scaled.MoveAnchored(b.LocateAnchors(img))
+	}
+	if result.Row > 0 {
+		if offsets := b.RowOffsets(img); result.Row <= len(offsets) {
+			scaled = scaled.Moved(image.Pt(0, offsets[result.Row-1]))
+		}
+	}
+
+	crops := make(map[string]image.Image)
+	for k, s := range scaled.OCRSchema {
+		if s.Crop == nil {
+			continue
+		}
+		r := s.Crop.CropRectangle().Add(img.Bounds().Min)
+		if r.Empty() || !r.In(img.Bounds()) {
+			continue
+		}
+		if crop, err := imgutils.CropImage(img, r); err == nil {
+			crops[k] = crop
+		}
+	}
+	return crops
+}
+
+// DumpCrops - writes every field crop as <prefix>_<field>.png, e.g. for building a training set
+func (b *OCRTemplate) DumpCrops(img image.Image, outDir, prefix string) error {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	img = b.NormalizeImage(img)
+
+	for _, k := range b.FieldKeys() {
+		crop, err := b.CropField(img, k)
+		if err != nil {
+			log.Warnf("[%s] skipping crop: %v", prefix, err)
+			continue
+		}
+
+		if err := imgutils.WritePNGImage(crop, filepath.Join(outDir, fmt.Sprintf("%s_%s.png", prefix, k))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
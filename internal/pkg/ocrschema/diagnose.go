@@ -0,0 +1,170 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// CloseMiss - how many bits over its threshold a hash can be to count as a close miss (e.g. compression artifacts),
+// rather than a different screen
+const CloseMiss = 4
+
+// AreaDiagnosis - hash distance of a checkpoint (or must_not_match area) on the image
+type AreaDiagnosis struct {
+	Index     int
+	Crop      *OCRCrop
+	Distance  int
+	Threshold int
+	Optional  bool
+	Matches   bool
+	// Error - fingerprint can't be parsed, or the crop is outside of the image
+	Error string
+}
+
+// Miss - how many bits over the threshold the area is, 0 if it matches or can't be compared
+func (a AreaDiagnosis) Miss() int {
+	if a.Matches || len(a.Error) > 0 {
+		return 0
+	}
+	return a.Distance - a.Threshold
+}
+
+// TemplateDiagnosis - why the image does (not) match the template: fingerprint, every checkpoint & look-alike area
+type TemplateDiagnosis struct {
+	TemplateScore
+	// Threshold - max fingerprint distance, used only by templates without checkpoints
+	Threshold int
+	// Quorum - checkpoints which have to match
+	Quorum int
+	// Areas - checkpoints, in template order
+	Areas []AreaDiagnosis
+	// Excluding - must_not_match areas
+	Excluding []AreaDiagnosis
+	// AspectRatio - image & template aspect ratio differ, crops are stretched
+	AspectRatio bool
+	// Reasons - why the template doesn't match, empty if it does
+	Reasons []string
+}
+
+// CloseMiss - template doesn't match, but only by a few bits of a hash (see CloseMiss)
+func (d TemplateDiagnosis) CloseMiss() bool {
+	if d.Matches || len(d.Reasons) == 0 {
+		return false
+	}
+	if len(d.Areas) == 0 {
+		return d.Distance != math.MaxInt && d.Distance-d.Threshold <= CloseMiss
+	}
+	for _, a := range d.Areas {
+		if a.Miss() > CloseMiss {
+			return false
+		}
+	}
+	return true
+}
+
+// Diagnose - like Score, with distances of every checkpoint and reasons of a mismatch
+func (b *OCRTemplate) Diagnose(img image.Image) TemplateDiagnosis {
+	d := TemplateDiagnosis{TemplateScore: b.Score(img), Threshold: b.Threshold, Quorum: b.checkpointQuorum()}
+
+	scaled := b.ScaledTo(img.Bounds().Dx(), img.Bounds().Dy())
+	for i, c := range scaled.Checkpoints {
+		d.Areas = append(d.Areas, b.diagnoseArea(img, i, c))
+	}
+	for i, c := range scaled.MustNotMatch {
+		d.Excluding = append(d.Excluding, b.diagnoseArea(img, i, c))
+	}
+
+	if b.Width > 0 && b.Height > 0 {
+		ratio := float64(img.Bounds().Dx()) / float64(img.Bounds().Dy())
+		d.AspectRatio = math.Abs(ratio/(float64(b.Width)/float64(b.Height))-1) > 0.02
+	}
+
+	if d.Matches {
+		return d
+	}
+
+	if len(d.Areas) == 0 {
+		switch {
+		case d.Distance == math.MaxInt:
+			d.Reasons = append(d.Reasons, "fingerprint can't be compared")
+		case d.Distance > d.Threshold:
+			d.Reasons = append(d.Reasons, fmt.Sprintf("fingerprint distance %v > threshold %v", d.Distance, d.Threshold))
+		}
+	} else {
+		explicitQuorum := b.CheckpointQuorum > 0 && b.CheckpointQuorum <= len(b.Checkpoints)
+		if d.CheckpointsMatched < d.Quorum {
+			d.Reasons = append(d.Reasons, fmt.Sprintf("%v of %v checkpoints matched, %v needed", d.CheckpointsMatched, d.Checkpoints, d.Quorum))
+		}
+		if !explicitQuorum {
+			var missed []string
+			for _, a := range d.Areas {
+				if !a.Matches && !a.Optional {
+					missed = append(missed, fmt.Sprint(a.Index))
+				}
+			}
+			if len(missed) > 0 && d.CheckpointsMatched >= d.Quorum {
+				d.Reasons = append(d.Reasons, fmt.Sprintf("required checkpoints missed: %v", strings.Join(missed, ", ")))
+			}
+		}
+	}
+	for _, a := range d.Excluding {
+		if a.Matches {
+			d.Reasons = append(d.Reasons, fmt.Sprintf("must_not_match %v matches, it's a look-alike screen", a.Index))
+		}
+	}
+	if d.AspectRatio {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("aspect ratio of %vx%v differs from %vx%v", img.Bounds().Dx(), img.Bounds().Dy(), b.Width, b.Height))
+	}
+	return d
+}
+
+func (b *OCRTemplate) diagnoseArea(img image.Image, i int, c OCRCheckpoint) AreaDiagnosis {
+	a := AreaDiagnosis{Index: i, Crop: c.Crop, Distance: math.MaxInt, Threshold: c.threshold(), Optional: c.Optional}
+
+	expected, err := HashFromString(c.Fingerprint, b.HashAlgo)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if c.Crop == nil {
+		a.Error = "crop is missing"
+		return a
+	}
+	r := c.Crop.CropRectangle().Add(img.Bounds().Min)
+	if r.Empty() || !r.In(img.Bounds()) {
+		a.Error = fmt.Sprintf("crop %v is outside of the image", c.Crop.CropRectangle())
+		return a
+	}
+
+	sub, _ := imgutils.CropImage(img, r)
+	hash, err := b.ImageHash(sub)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if a.Distance, err = expected.Distance(hash); err != nil {
+		a.Error = err.Error()
+		a.Distance = math.MaxInt
+		return a
+	}
+	a.Matches = a.Distance <= a.Threshold
+	return a
+}
+
+// DiagnoseTemplates - diagnosis of every template, best scoring first (see RankTemplates)
+func DiagnoseTemplates(img image.Image, availableTemplate []OCRTemplate) []TemplateDiagnosis {
+	diagnoses := make([]TemplateDiagnosis, 0, len(availableTemplate))
+	for _, t := range availableTemplate {
+		diagnoses = append(diagnoses, t.Diagnose(img))
+	}
+
+	sort.SliceStable(diagnoses, func(i, j int) bool {
+		return diagnoses[i].better(diagnoses[j].TemplateScore)
+	})
+	return diagnoses
+}
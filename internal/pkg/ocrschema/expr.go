@@ -0,0 +1,350 @@
+package ocrschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression of computed fields: numbers, field names, + - * / %, parentheses and functions
+type expression interface {
+	eval(lookup func(name string) (float64, error)) (float64, error)
+}
+
+type numberExpr float64
+
+type fieldExpr string
+
+type unaryExpr struct {
+	x expression
+}
+
+type binaryExpr struct {
+	op   byte
+	x, y expression
+}
+
+// compareExpr - condition of checks, evaluates to 1 (true) or 0
+type compareExpr struct {
+	op   string
+	x, y expression
+}
+
+type callExpr struct {
+	name string
+	args []expression
+}
+
+// exprFunctions - name => (argument count, -1 means at least one)
+var exprFunctions = map[string]int{
+	"min":   -1,
+	"max":   -1,
+	"abs":   1,
+	"round": 1,
+	"floor": 1,
+	"ceil":  1,
+}
+
+func (e numberExpr) eval(func(string) (float64, error)) (float64, error) {
+	return float64(e), nil
+}
+
+func (e fieldExpr) eval(lookup func(string) (float64, error)) (float64, error) {
+	return lookup(string(e))
+}
+
+func (e unaryExpr) eval(lookup func(string) (float64, error)) (float64, error) {
+	x, err := e.x.eval(lookup)
+	return -x, err
+}
+
+func (e binaryExpr) eval(lookup func(string) (float64, error)) (float64, error) {
+	x, err := e.x.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	y, err := e.y.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+
+	switch e.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	case '/', '%':
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		if e.op == '%' {
+			return math.Mod(x, y), nil
+		}
+		return x / y, nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", e.op)
+}
+
+func (e compareExpr) eval(lookup func(string) (float64, error)) (float64, error) {
+	x, err := e.x.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	y, err := e.y.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+
+	var ok bool
+	switch e.op {
+	case "<":
+		ok = x < y
+	case "<=":
+		ok = x <= y
+	case ">":
+		ok = x > y
+	case ">=":
+		ok = x >= y
+	case "==":
+		ok = x == y
+	case "!=":
+		ok = x != y
+	default:
+		return 0, fmt.Errorf("unknown operator %q", e.op)
+	}
+	if ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (e callExpr) eval(lookup func(string) (float64, error)) (float64, error) {
+	args := make([]float64, len(e.args))
+	for i, a := range e.args {
+		v, err := a.eval(lookup)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "min":
+		return fold(args, math.Min), nil
+	case "max":
+		return fold(args, math.Max), nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "round":
+		return math.Round(args[0]), nil
+	case "floor":
+		return math.Floor(args[0]), nil
+	case "ceil":
+		return math.Ceil(args[0]), nil
+	}
+	return 0, fmt.Errorf("unknown function %q", e.name)
+}
+
+func fold(values []float64, fn func(x, y float64) float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = fn(result, v)
+	}
+	return result
+}
+
+// exprParser - recursive descent parser, precedence: unary minus > * / % > + -
+type exprParser struct {
+	src    string
+	pos    int
+	fields []string
+}
+
+// parseExpression - parses the expression, and returns it with names of referenced fields
+func parseExpression(src string) (expression, []string, error) {
+	p := &exprParser{src: src}
+	e, err := p.sum()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.skip(); p.pos < len(p.src) {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos+1)
+	}
+	return e, p.fields, nil
+}
+
+// parseCondition - parses comparison of two expressions (e.g. "dead <= power / 2"), returns it with names of referenced fields
+func parseCondition(src string) (expression, []string, error) {
+	p := &exprParser{src: src}
+	x, err := p.sum()
+	if err != nil {
+		return nil, nil, err
+	}
+	op, ok := p.comparison()
+	if !ok {
+		return nil, nil, errors.New("missing comparison (< <= > >= == !=)")
+	}
+	y, err := p.sum()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.skip(); p.pos < len(p.src) {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos+1)
+	}
+	return compareExpr{op: op, x: x, y: y}, p.fields, nil
+}
+
+// comparison - consumes comparison operator
+func (p *exprParser) comparison() (string, bool) {
+	p.skip()
+	for _, op := range []string{"<=", ">=", "==", "!=", "<", ">"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) skip() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept - consumes next character if it's one of chars
+func (p *exprParser) accept(chars string) (byte, bool) {
+	p.skip()
+	if p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) >= 0 {
+		p.pos++
+		return p.src[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *exprParser) sum() (expression, error) {
+	x, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+-")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) product() (expression, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*/%")
+		if !ok {
+			return x, nil
+		}
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) unary() (expression, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.unary()
+		return unaryExpr{x: x}, err
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expression, error) {
+	if _, ok := p.accept("("); ok {
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing ) at %d", p.pos+1)
+		}
+		return x, nil
+	}
+
+	p.skip()
+	start := p.pos
+	switch {
+	case p.pos >= len(p.src):
+		return nil, errors.New("unexpected end of expression")
+	case isDigit(p.src[p.pos]) || p.src[p.pos] == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return numberExpr(v), nil
+	case isIdentStart(p.src[p.pos]):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if _, ok := p.accept("("); ok {
+			return p.call(name)
+		}
+		p.fields = append(p.fields, name)
+		return fieldExpr(name), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos+1)
+}
+
+func (p *exprParser) call(name string) (expression, error) {
+	count, ok := exprFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+
+	var args []expression
+	if _, ok := p.accept(")"); !ok {
+		for {
+			a, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ) of %s at %d", name, p.pos+1)
+			}
+			break
+		}
+	}
+
+	if (count < 0 && len(args) == 0) || (count >= 0 && len(args) != count) {
+		return nil, fmt.Errorf("wrong number of arguments of %s: %d", name, len(args))
+	}
+	return callExpr{name: name, args: args}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
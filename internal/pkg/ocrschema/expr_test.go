@@ -0,0 +1,166 @@
+package ocrschema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lookupOf(values map[string]float64) func(string) (float64, error) {
+	return func(name string) (float64, error) {
+		v, ok := values[name]
+		if !ok {
+			return 0, fmt.Errorf("%s is missing", name)
+		}
+		return v, nil
+	}
+}
+
+func TestExpression(t *testing.T) {
+	lookup := lookupOf(map[string]float64{"t4": 10, "t5": 20, "deaths": 7})
+
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"7 % 4 + 1", 4},
+		{"2 * 3 % 4", 2},
+		{"-3 + 5", 2},
+		{"--3", 3},
+		{"-(2 + 3) * 2", -10},
+		{"2 * -3", -6},
+		{"t4 * 10 + t5 * 20", 500},
+		{"min(t4, t5, deaths)", 7},
+		{"max(t4, t5, deaths)", 20},
+		{"min(5)", 5},
+		{"abs(t4 - t5)", 10},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(deaths / 2)", 4},
+		{"floor(deaths / 2)", 3},
+		{"ceil(deaths / 2)", 4},
+		{"max(abs(-4), round(3.4)) * 2", 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, _, err := parseExpression(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.eval(lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionFields(t *testing.T) {
+	_, fields, err := parseExpression("max(t4, t5) + t4 * deaths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"t4", "t5", "t4", "deaths"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("got %v, want %v", fields, want)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", "missing )"},
+		{"1 2", "unexpected"},
+		{"1 + * 2", "unexpected"},
+		{"sqrt(4)", `unknown function "sqrt"`},
+		{"abs(1, 2)", "wrong number of arguments of abs: 2"},
+		{"min()", "wrong number of arguments of min: 0"},
+		{"1 < 2", "unexpected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if _, _, err := parseExpression(tt.src); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionEvalErrors(t *testing.T) {
+	lookup := lookupOf(map[string]float64{"zero": 0, "kills": 10})
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"kills / 0", "division by zero"},
+		{"kills % zero", "division by zero"},
+		{"kills / (zero * 2)", "division by zero"},
+		{"kills + deaths", "deaths is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, _, err := parseExpression(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := e.eval(lookup); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCondition(t *testing.T) {
+	lookup := lookupOf(map[string]float64{"kp": 500, "t4": 10, "t5": 20})
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"kp >= t4 * 10 + t5 * 20", true},
+		{"kp > t4 * 10 + t5 * 20", false},
+		{"kp <= 500", true},
+		{"kp < 500", false},
+		{"kp == 500", true},
+		{"kp != 500", false},
+		{"t4 + t5 == 30", true},
+		{"-t4 < 0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, _, err := parseCondition(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.eval(lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != 0) != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, src := range []string{"kp + t4", "kp < ", "kp < t4 < t5"} {
+		if _, _, err := parseCondition(src); err == nil {
+			t.Errorf("%q: condition parsed", src)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ocrschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// keys of template JSON resolved while reading it, they don't make it into the merged template
+const (
+	extendsKey  = "extends"
+	includeKey  = "include"
+	abstractKey = "abstract"
+)
+
+// ReadTemplateJSON - template JSON of the file, migrated to CurrentSchemaVersion, with its base template (extends)
+// & includes merged in. Objects are merged key by key (the file wins), arrays & other values are replaced, and null
+// removes the key (e.g. a field of the base). Returns the files merged into it as well, base ones first.
+func ReadTemplateJSON(fileName string) ([]byte, []string, error) {
+	t, sources, err := resolveTemplateJSON(fileName, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(t)
+	return data, sources, err
+}
+
+// resolveTemplateJSON - chain holds the files being resolved, to report cycles
+func resolveTemplateJSON(fileName string, chain []string) (map[string]interface{}, []string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range chain {
+		if f == abs {
+			return nil, nil, fmt.Errorf("%v extends itself (%v)", filepath.Base(abs), strings.Join(baseNames(append(chain, abs)), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, version, err := MigrateTemplateJSON(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", filepath.Base(abs), err)
+	}
+	if version != CurrentSchemaVersion {
+		// migrated on every load, until it's written out (rok-templates migrate)
+		log.Debugf("Template %v migrated from schema version %v to %v", filepath.Base(abs), version, CurrentSchemaVersion)
+	}
+	var t map[string]interface{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", filepath.Base(abs), err)
+	}
+
+	parents, err := templateParents(t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", filepath.Base(abs), err)
+	}
+	delete(t, extendsKey)
+	delete(t, includeKey)
+	if len(parents) == 0 {
+		return t, nil, nil
+	}
+
+	merged := map[string]interface{}{}
+	var sources []string
+	for _, p := range parents {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(abs), p)
+		}
+		base, baseSources, err := resolveTemplateJSON(p, chain)
+		if err != nil {
+			return nil, nil, err
+		}
+		// only templates made to be extended are abstract, not the ones extending them
+		delete(base, abstractKey)
+		merged = mergeTemplateJSON(merged, base)
+		sources = append(append(sources, baseSources...), p)
+	}
+	return mergeTemplateJSON(merged, t), sources, nil
+}
+
+// TemplateJSONParents - base template (extends) & includes of the template JSON, as written (relative to its file)
+func TemplateJSONParents(data []byte) ([]string, error) {
+	var t map[string]interface{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return templateParents(t)
+}
+
+// templateParents - extends & include of the template, in the order they are merged
+func templateParents(t map[string]interface{}) ([]string, error) {
+	var parents []string
+	switch extends := t[extendsKey].(type) {
+	case nil:
+	case string:
+		if len(extends) > 0 {
+			parents = append(parents, extends)
+		}
+	default:
+		return nil, fmt.Errorf("extends: expected a file name")
+	}
+	switch include := t[includeKey].(type) {
+	case nil:
+	case []interface{}:
+		for _, i := range include {
+			name, ok := i.(string)
+			if !ok || len(name) == 0 {
+				return nil, fmt.Errorf("include: expected file names")
+			}
+			parents = append(parents, name)
+		}
+	default:
+		return nil, fmt.Errorf("include: expected a list of file names")
+	}
+	return parents, nil
+}
+
+// mergeTemplateJSON - override merged into base (which is modified)
+func mergeTemplateJSON(base, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		if v == nil {
+			delete(base, k)
+			continue
+		}
+		if o, ok := v.(map[string]interface{}); ok {
+			if b, ok := base[k].(map[string]interface{}); ok {
+				base[k] = mergeTemplateJSON(b, o)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
+
+func baseNames(files []string) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	return names
+}
@@ -0,0 +1,139 @@
+package ocrschema
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/corona10/goimagehash"
+	"github.com/corona10/goimagehash/etcs"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"golang.org/x/image/draw"
+)
+
+const (
+	HashDifference = "dhash"
+	HashPerception = "phash"
+	HashAverage    = "ahash"
+	HashWavelet    = "whash"
+)
+
+var HashAlgorithms = []string{HashDifference, HashPerception, HashAverage, HashWavelet}
+
+func hashKind(algo string) (goimagehash.Kind, error) {
+	switch strings.ToLower(algo) {
+	case "", HashDifference:
+		return goimagehash.DHash, nil
+	case HashPerception:
+		return goimagehash.PHash, nil
+	case HashAverage:
+		return goimagehash.AHash, nil
+	case HashWavelet:
+		return goimagehash.WHash, nil
+	}
+	return goimagehash.Unknown, fmt.Errorf("unknown hash algorithm: %q", algo)
+}
+
+// ComputeHash - hashes the image with given algorithm (empty means dhash)
+func ComputeHash(img image.Image, algo string) (*goimagehash.ImageHash, error) {
+	kind, err := hashKind(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case goimagehash.PHash:
+		return goimagehash.PerceptionHash(img)
+	case goimagehash.AHash:
+		return goimagehash.AverageHash(img)
+	case goimagehash.WHash:
+		return waveletHash(img)
+	default:
+		return goimagehash.DifferenceHash(img)
+	}
+}
+
+// Fingerprint - hash of the image (or its crop, if given) in the hex format of template fingerprints & checkpoints
+func Fingerprint(img image.Image, crop *OCRCrop, algo string) (string, error) {
+	if crop != nil {
+		r := crop.CropRectangle().Add(img.Bounds().Min)
+		if r.Empty() || !r.In(img.Bounds()) {
+			return "", fmt.Errorf("crop %v,%v+%vx%v is outside of %vx%v", crop.X, crop.Y, crop.W, crop.H, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		var err error
+		if img, err = imgutils.CropImage(img, r); err != nil {
+			return "", err
+		}
+	}
+
+	hash, err := ComputeHash(img, algo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.GetHash()), nil
+}
+
+// HashFromString - parses fingerprint (hex, as stored in templates)
+func HashFromString(s, algo string) (*goimagehash.ImageHash, error) {
+	kind, err := hashKind(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fingerprint %q: %v", s, err)
+	}
+
+	return goimagehash.NewImageHash(value, kind), nil
+}
+
+// waveletHash - goimagehash only knows the kind, so here is a Haar implementation:
+// image is scaled to 64x64 grayscale, three levels of Haar decomposition leave an 8x8
+// low-frequency (LL) band, each coefficient is compared with the median.
+func waveletHash(img image.Image) (*goimagehash.ImageHash, error) {
+	if img == nil {
+		return nil, errors.New("image object can not be nil")
+	}
+
+	const size = 64
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	band := make([][]float64, size)
+	for y := 0; y < size; y++ {
+		band[y] = make([]float64, size)
+		for x := 0; x < size; x++ {
+			r, g, b, _ := scaled.At(x, y).RGBA()
+			band[y][x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+
+	for n := size; n > 8; n = n / 2 {
+		next := make([][]float64, n/2)
+		for y := 0; y < n/2; y++ {
+			next[y] = make([]float64, n/2)
+			for x := 0; x < n/2; x++ {
+				next[y][x] = (band[2*y][2*x] + band[2*y][2*x+1] + band[2*y+1][2*x] + band[2*y+1][2*x+1]) / 2
+			}
+		}
+		band = next
+	}
+
+	var flat []float64
+	for _, row := range band {
+		flat = append(flat, row...)
+	}
+	median := etcs.MedianOfPixels(append([]float64{}, flat...))
+
+	var value uint64
+	for idx, p := range flat {
+		if p > median {
+			value |= 1 << uint(len(flat)-idx-1)
+		}
+	}
+
+	return goimagehash.NewImageHash(value, goimagehash.WHash), nil
+}
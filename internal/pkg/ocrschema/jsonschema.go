@@ -0,0 +1,249 @@
+package ocrschema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// JSONSchemaURL - draft of JSON Schema TemplateJSONSchema follows
+	JSONSchemaURL = "http://json-schema.org/draft-07/schema#"
+	// TemplateJSONSchemaName - file name of the schema written by rok-templates & served by rok-server
+	TemplateJSONSchemaName = "template.schema.json"
+)
+
+// schemaDescriptions - descriptions shown by editors, by <type>.<json key>
+var schemaDescriptions = map[string]string{
+	"OCRTemplate.schema_version":    "Schema version of the template, older ones are migrated while loading",
+	"OCRTemplate.extends":           "Base template (relative to this file) the template only overrides parts of",
+	"OCRTemplate.include":           "Partial templates merged in after the base, e.g. shared fields",
+	"OCRTemplate.abstract":          "Template only serves as a base of others, it's not used for recognition",
+	"OCRTemplate.title":             "Name of the template, shown in results & reports",
+	"OCRTemplate.width":             "Width of the screenshots crops are defined on, other resolutions are scaled",
+	"OCRTemplate.height":            "Height of the screenshots crops are defined on, other resolutions are scaled",
+	"OCRTemplate.ocr_schema":        "Fields to recognize, name => field",
+	"OCRTemplate.fingerprint":       "Hash of the whole screen, picks the template for a screenshot",
+	"OCRTemplate.threshold":         "Max hash distance of the fingerprint",
+	"OCRTemplate.hash_algo":         "Hash of fingerprint & checkpoints",
+	"OCRTemplate.table":             "Columns of reports: [title, field, bold, color]",
+	"OCRTemplate.checkpoints":       "Areas which look the same on every screenshot of the screen",
+	"OCRTemplate.checkpoint_quorum": "How many checkpoints have to match, 0 - all required ones",
+	"OCRTemplate.must_not_match":    "Areas of look-alike screens, the screenshot isn't this template if any matches",
+	"OCRTemplate.computed":          "Fields calculated from recognized ones, name => expression",
+	"OCRTemplate.checks":            "Sanity rules across fields, name => condition",
+	"OCRTemplate.record":            "Screen is a part of a record spread over several screens",
+	"OCRTemplate.rows":              "Ranking list with a result per row",
+	"OCRTemplate.anchors":           "Icons located on the screenshot, anchored crops follow their position",
+	"OCRTemplate.points":            "Named points crops can be relative to: [x, y]",
+	"OCRTemplate.chain":             "Templates (relative to this file) reading other regions of the screen, their fields are merged into the results",
+	"OCRTemplate.roster_file":       "Known governor names (relative to this file)",
+	"OCRTemplate.reference_image":   "Screenshot the template was made of (relative to this file)",
+
+	"OCRSchema.lang":                 "Tesseract languages, e.g. [\"eng\"]",
+	"OCRSchema.lang_fallback":        "Languages tried one by one after lang, the most confident result is kept",
+	"OCRSchema.model":                "Custom traineddata in tessdata dir, used instead of lang",
+	"OCRSchema.oem":                  "Tesseract OCR engine mode",
+	"OCRSchema.psm":                  "Tesseract page segmentation mode, 7 - single line",
+	"OCRSchema.crop":                 "Area of the field",
+	"OCRSchema.allowlist":            "Characters the field may contain: shorthands (" + strings.Join(AllowListCharsets(), ", ") + ") or literal characters",
+	"OCRSchema.anchor":               "Anchor the crop moves with",
+	"OCRSchema.engine":               "OCR engine of this field, empty - the globally selected one",
+	"OCRSchema.preprocess":           "Operations applied to the crop before OCR, in order",
+	"OCRSchema.vote":                 "Numeric fields: recognize this many times & pick every digit by majority",
+	"OCRSchema.pattern":              "Regex applied to the text, first capture group becomes the value",
+	"OCRSchema.type":                 "Type of the value",
+	"OCRSchema.decimal_separator":    "\".\" or \",\", guessed from the value if empty",
+	"OCRSchema.required":             "Value can't be empty",
+	"OCRSchema.min_confidence":       "Lowest accepted OCR confidence",
+	"OCRSchema.match":                "Regex the value has to match",
+	"OCRSchema.min":                  "Lowest accepted value",
+	"OCRSchema.max":                  "Highest accepted value",
+	"OCRSchema.case_insensitive_key": "Ignore case & extra whitespace when the field is used as a key",
+
+	"OCRCheckpoint.threshold": "Max hash distance of the checkpoint, 0 - default (1)",
+	"OCRCheckpoint.exact":     "Hashes have to be the same (max distance 0)",
+	"OCRCheckpoint.optional":  "Doesn't have to match, but counts towards checkpoint_quorum",
+
+	"OCRAnchor.image":     "Icon file (relative to this file), empty - the crop of reference_image",
+	"OCRAnchor.search":    "How far from the crop (in template pixels) the icon is looked for",
+	"OCRAnchor.min_score": "Lowest match score (up to 1) the icon is accepted with",
+
+	"OCRRows.area":   "Part of the screen with the list, the first row starts at its top",
+	"OCRRows.height": "Height of a row",
+	"OCRRows.anchor": "Anchor repeated in every row (detect: anchor)",
+
+	"OCRRecord.name": "Parts with the same name belong to the same kind of record",
+	"OCRRecord.key":  "Field identifying the record, e.g. governor id",
+}
+
+// schemaEnums - allowed values, by <type>.<json key>
+var schemaEnums = map[string][]string{
+	"OCRTemplate.hash_algo": HashAlgorithms,
+	"OCRSchema.type":        FieldTypes,
+	"OCRRows.detect":        RowDetectors,
+}
+
+// TemplateJSONSchema - JSON Schema of template files, for validation & autocomplete in editors (e.g. VS Code)
+func TemplateJSONSchema() map[string]interface{} {
+	g := schemaGenerator{definitions: make(map[string]interface{})}
+
+	root := g.object(reflect.TypeOf(OCRTemplate{}))
+	root["$schema"] = JSONSchemaURL
+	root["title"] = "ROK OCR template"
+	root["properties"].(map[string]interface{})["$schema"] = map[string]interface{}{
+		"type":        "string",
+		"description": "JSON Schema of the file",
+	}
+	root["definitions"] = g.definitions
+	return root
+}
+
+type schemaGenerator struct {
+	definitions map[string]interface{}
+}
+
+// of - schema of a type, structs are added to definitions & referenced
+func (g schemaGenerator) of(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(OCRValue{}):
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "number", "description": "Pixels"},
+				map[string]interface{}{"type": "string", "pattern": `^-?[0-9.]+%$`, "description": "Percent of template width / height"},
+			},
+		}
+	case reflect.TypeOf(OCRPoint{}):
+		return map[string]interface{}{"type": "array", "items": g.of(reflect.TypeOf(OCRValue{})), "minItems": 2, "maxItems": 2}
+	case reflect.TypeOf(OCRCrop{}):
+		return g.crop()
+	case reflect.TypeOf(OCRTableField{}):
+		return map[string]interface{}{
+			"type": "array",
+			"items": []interface{}{
+				map[string]interface{}{"type": "string", "description": "Title"},
+				map[string]interface{}{"type": "string", "description": "Field"},
+				map[string]interface{}{"type": "boolean", "description": "Bold"},
+				map[string]interface{}{"type": "string", "description": "Color"},
+			},
+		}
+	case reflect.TypeOf(AllowList{}):
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": []string{"string", "integer"}}},
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.of(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// placeholder first, types referencing themselves would recurse forever
+			g.definitions[t.Name()] = true
+			g.definitions[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.of(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.of(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	// interface{} - anything
+	return map[string]interface{}{}
+}
+
+// object - schema of a struct by its json tags, fields without omitempty are required
+func (g schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		s := g.of(f.Type)
+		key := fmt.Sprintf("%s.%s", t.Name(), name)
+		if d, ok := schemaDescriptions[key]; ok {
+			s = withKey(s, "description", d)
+		}
+		if e, ok := schemaEnums[key]; ok {
+			s = withKey(s, "enum", e)
+		}
+		if key == "OCRSchema.preprocess" {
+			s["items"] = map[string]interface{}{"type": "string", "pattern": fmt.Sprintf(`^(%s)(:[0-9.]+)?$`, strings.Join(PreprocessSteps(), "|"))}
+		}
+		properties[name] = s
+
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	o := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+// crop - [x, y, w, h] or an object relative to a point (see OCRCrop)
+func (g schemaGenerator) crop() map[string]interface{} {
+	value := g.of(reflect.TypeOf(OCRValue{}))
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{
+				"type":        "array",
+				"description": "[x, y, w, h]",
+				"items":       value,
+				"minItems":    4,
+				"maxItems":    4,
+			},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"from": map[string]interface{}{
+						"type":        "string",
+						"description": "Point x & y are relative to: a point of the template, an anchor or one of " + strings.Join(ScreenPoints(), ", "),
+					},
+					"x": value,
+					"y": value,
+					"w": value,
+					"h": value,
+				},
+				"required":             []string{"x", "y", "w", "h"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// withKey - references can't have siblings in draft-07, they're wrapped in allOf then
+func withKey(s map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if _, ok := s["$ref"]; ok {
+		s = map[string]interface{}{"allOf": []interface{}{s}}
+	}
+	s[key] = value
+	return s
+}
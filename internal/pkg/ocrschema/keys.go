@@ -0,0 +1,30 @@
+package ocrschema
+
+import "strings"
+
+// NormalizeKey - value as used for joining rows, honoring CaseInsensitiveKey
+func (s *OCRSchema) NormalizeKey(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	v := strings.TrimSpace(FormatValue(value))
+	if s.CaseInsensitiveKey {
+		v = strings.ToLower(strings.Join(strings.Fields(v), " "))
+	}
+	return v
+}
+
+// KeyOf - normalized key of the result for a given field (e.g. governor id)
+func (b *OCRTemplate) KeyOf(r OCRResult, field string) string {
+	s := b.OCRSchema[field]
+	return s.NormalizeKey(r.Data[field])
+}
+
+// DisplayKeyOf - key of the result for a given field as it was recognized, for showing rows joined by KeyOf
+func (b *OCRTemplate) DisplayKeyOf(r OCRResult, field string) string {
+	if r.Data[field] == nil {
+		return ""
+	}
+	return strings.TrimSpace(FormatValue(r.Data[field]))
+}
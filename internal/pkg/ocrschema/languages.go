@@ -0,0 +1,20 @@
+package ocrschema
+
+import "strings"
+
+// LanguageChain - language models to try in order: `lang` (nil - engine default), then every `lang_fallback`
+func (s *OCRSchema) LanguageChain() [][]string {
+	chain := [][]string{s.Languages}
+	for _, fallback := range s.LanguageFallback {
+		var languages []string
+		for _, l := range strings.Split(fallback, "+") {
+			if l = strings.TrimSpace(l); len(l) > 0 {
+				languages = append(languages, l)
+			}
+		}
+		if len(languages) > 0 {
+			chain = append(chain, languages)
+		}
+	}
+	return chain
+}
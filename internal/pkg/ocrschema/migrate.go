@@ -0,0 +1,164 @@
+package ocrschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CurrentSchemaVersion - layout of OCRTemplate this build understands.
+// Templates without schema_version are treated as version 1.
+const CurrentSchemaVersion = 2
+
+type migration func(t map[string]interface{}) error
+
+// migrations[n] upgrades raw template JSON from version n to n+1
+var migrations = map[int]migration{
+	1: migrateV1ToV2,
+}
+
+// v1 -> v2:
+//   - `whitelist` in ocr_schema entries is renamed to `allowlist`
+//   - whole screen fingerprint is also expressed as a full-frame checkpoint (if there are none),
+//     so matching goes through a single code path. Threshold 0 of v1 means exact, but it's the default (1)
+//     of a checkpoint, so such checkpoint is marked exact.
+func migrateV1ToV2(t map[string]interface{}) error {
+	if s, ok := t["ocr_schema"].(map[string]interface{}); ok {
+		for name, v := range s {
+			if v == nil {
+				// removes the field of a base template (see ReadTemplateJSON)
+				continue
+			}
+			field, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("ocr_schema.%s: expected an object", name)
+			}
+			if w, ok := field["whitelist"]; ok {
+				if _, exists := field["allowlist"]; !exists {
+					field["allowlist"] = w
+				}
+				delete(field, "whitelist")
+			}
+		}
+	}
+
+	checkpoints, _ := t["checkpoints"].([]interface{})
+	fingerprint, _ := t["fingerprint"].(string)
+	w, _ := t["width"].(float64)
+	h, _ := t["height"].(float64)
+
+	if len(checkpoints) == 0 && len(fingerprint) > 0 && w > 0 && h > 0 {
+		checkpoint := map[string]interface{}{
+			"crop":        []interface{}{0, 0, w, h},
+			"fingerprint": fingerprint,
+		}
+		if threshold, _ := t["threshold"].(float64); threshold > 0 {
+			checkpoint["threshold"] = threshold
+		} else {
+			checkpoint["exact"] = true
+		}
+		t["checkpoints"] = []interface{}{checkpoint}
+	}
+
+	return nil
+}
+
+// MigrateTemplateJSON - upgrades raw template JSON to CurrentSchemaVersion.
+// Returns new JSON and version template had before migration.
+func MigrateTemplateJSON(data []byte) ([]byte, int, error) {
+	var t map[string]interface{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, 0, err
+	}
+
+	version := 1
+	if v, ok := t["schema_version"].(float64); ok && v > 0 {
+		version = int(v)
+	}
+
+	if version > CurrentSchemaVersion {
+		return nil, version, fmt.Errorf("template schema version %d is newer than supported %d", version, CurrentSchemaVersion)
+	}
+
+	if version == CurrentSchemaVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, version, fmt.Errorf("no migration from schema version %d", v)
+		}
+		if err := m(t); err != nil {
+			return nil, version, fmt.Errorf("migration %d -> %d: %v", v, v+1, err)
+		}
+	}
+
+	t["schema_version"] = CurrentSchemaVersion
+	// conditions of checks (>=, <) stay readable
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(t)
+	return bytes.TrimSpace(out.Bytes()), version, err
+}
+
+// FormatTemplateJSON - template JSON indented like template maker output: top level keys in the order of OCRTemplate
+// fields, then keys unknown to this build (e.g. game_version of old templates) sorted. Values are kept as they are,
+// so nothing the struct doesn't know is lost (e.g. when writing out migrated templates).
+func FormatTemplateJSON(data []byte) ([]byte, error) {
+	var t map[string]json.RawMessage
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+
+	var keys, unknown []string
+	known := make(map[string]bool)
+	for _, k := range templateKeys() {
+		known[k] = true
+		if _, ok := t[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	for k := range t {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, k := range append(keys, unknown...) {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, _ := json.Marshal(k)
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(t[k])
+	}
+	buf.WriteString("}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// templateKeys - JSON keys of OCRTemplate, in order of its fields
+func templateKeys() []string {
+	var keys []string
+	typ := reflect.TypeOf(OCRTemplate{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if len(name) > 0 && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
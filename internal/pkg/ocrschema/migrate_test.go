@@ -0,0 +1,125 @@
+package ocrschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateV1FingerprintCheckpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		want      OCRCheckpoint
+	}{
+		{"exact", 0, OCRCheckpoint{Fingerprint: "e8d4d8f8f8d0d8d8", Exact: true}},
+		{"threshold", 15, OCRCheckpoint{Fingerprint: "e8d4d8f8f8d0d8d8", Threshold: 15}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1 := fmt.Sprintf(`{"title": "v1", "width": 200, "height": 100, "fingerprint": "e8d4d8f8f8d0d8d8", "threshold": %d}`, tt.threshold)
+			data, version, err := MigrateTemplateJSON([]byte(v1))
+			if err != nil || version != 1 {
+				t.Fatalf("MigrateTemplateJSON() = %v, %v", version, err)
+			}
+
+			var template OCRTemplate
+			if err := json.Unmarshal(data, &template); err != nil {
+				t.Fatal(err)
+			}
+			if len(template.Checkpoints) != 1 {
+				t.Fatalf("got %d checkpoints, want 1", len(template.Checkpoints))
+			}
+			c := template.Checkpoints[0]
+			if c.Fingerprint != tt.want.Fingerprint || c.Threshold != tt.want.Threshold || c.Exact != tt.want.Exact {
+				t.Errorf("checkpoint = %+v, want %+v", c, tt.want)
+			}
+			if got := c.threshold(); got != tt.threshold {
+				t.Errorf("threshold() = %v, want %v as in v1", got, tt.threshold)
+			}
+			if r := c.Crop.CropRectangle(); r.Dx() != 200 || r.Dy() != 100 {
+				t.Errorf("crop = %v, want the whole frame", r)
+			}
+		})
+	}
+}
+
+func TestExactCheckpoint(t *testing.T) {
+	img := testScreen()
+	c := testCheckpoint(t, img, 0, true, false)
+
+	// one bit off: within the default threshold, but not exact
+	hash, _ := HashFromString(c.Fingerprint, "")
+	c.Fingerprint = fmt.Sprintf("%016x", hash.GetHash()^1)
+
+	for _, exact := range []bool{false, true} {
+		c.Exact = exact
+		template := OCRTemplate{Width: 200, Height: 100, Checkpoints: []OCRCheckpoint{c}}
+		if _, matches := template.checkpointHits(img); matches == exact {
+			t.Errorf("exact %v: checkpoint one bit off matches = %v", exact, matches)
+		}
+	}
+}
+
+func TestBundledTemplatesAreCurrent(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "templates", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, version, err := MigrateTemplateJSON(data); err != nil || version != CurrentSchemaVersion {
+			t.Errorf("%v: schema version %v (%v), run rok-templates migrate", filepath.Base(f), version, err)
+		}
+	}
+}
+
+func TestMigrateKeepsUnknownKeys(t *testing.T) {
+	v1 := `{
+		"title": "v1",
+		"game_version": "1004516_160511",
+		"psm": 7,
+		"width": 200,
+		"height": 100,
+		"ocr_schema": {"power": {"crop": [0, 0, 10, 10], "whitelist": [0, 1], "oem": 1}},
+		"checks": {"power": "power >= 0"}
+	}`
+	data, _, err := MigrateTemplateJSON([]byte(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FormatTemplateJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["game_version"] != "1004516_160511" || got["psm"] != float64(7) {
+		t.Errorf("unknown top level keys are lost: %s", out)
+	}
+	power := got["ocr_schema"].(map[string]interface{})["power"].(map[string]interface{})
+	if power["oem"] != float64(1) || power["allowlist"] == nil || power["whitelist"] != nil {
+		t.Errorf("field isn't migrated as is: %v", power)
+	}
+
+	// known keys first, in order of the struct, then unknown ones
+	s := string(out)
+	order := []string{`"schema_version"`, `"title"`, `"width"`, `"ocr_schema"`, `"checks"`, `"game_version"`, `"psm"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(s, order[i-1]) > strings.Index(s, order[i]) {
+			t.Errorf("%v is after %v:\n%s", order[i-1], order[i], s)
+		}
+	}
+	if !strings.Contains(s, `"power >= 0"`) {
+		t.Errorf("check is escaped:\n%s", s)
+	}
+}
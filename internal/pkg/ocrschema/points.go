@@ -0,0 +1,242 @@
+package ocrschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// built-in points, as fractions of template width & height
+var screenPoints = map[string][2]float64{
+	"top_left":     {0, 0},
+	"top":          {0.5, 0},
+	"top_right":    {1, 0},
+	"left":         {0, 0.5},
+	"center":       {0.5, 0.5},
+	"right":        {1, 0.5},
+	"bottom_left":  {0, 1},
+	"bottom":       {0.5, 1},
+	"bottom_right": {1, 1},
+}
+
+// ScreenPoints - names of built-in points crops can be relative to (see OCRCrop.From)
+func ScreenPoints() []string {
+	var names []string
+	for k := range screenPoints {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OCRValue - coordinate of a crop or point, in pixels (a number) or in percent of template width/height ("12.5%")
+type OCRValue struct {
+	Value   float64
+	Percent bool
+}
+
+func (v OCRValue) MarshalJSON() ([]byte, error) {
+	if v.Percent {
+		return json.Marshal(strconv.FormatFloat(v.Value, 'f', -1, 64) + "%")
+	}
+	return json.Marshal(v.Value)
+}
+
+func (v *OCRValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		v.Percent = false
+		return json.Unmarshal(data, &v.Value)
+	}
+
+	number, ok := strings.CutSuffix(strings.TrimSpace(s), "%")
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return fmt.Errorf("invalid coordinate %q, expected pixels or percent (e.g. \"12.5%%\")", s)
+	}
+	v.Value, v.Percent = value, ok
+	return nil
+}
+
+// pixels - the value at template size (width for x, height for y)
+func (v OCRValue) pixels(size int) (int, error) {
+	if !v.Percent {
+		return int(math.Round(v.Value)), nil
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("%v%% needs template width & height", v.Value)
+	}
+	return int(math.Round(v.Value * float64(size) / 100)), nil
+}
+
+// OCRPoint - named point crops can be relative to, written as [x, y]
+type OCRPoint struct {
+	X OCRValue
+	Y OCRValue
+}
+
+func (p OCRPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]OCRValue{p.X, p.Y})
+}
+
+func (p *OCRPoint) UnmarshalJSON(data []byte) error {
+	var v []OCRValue
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("point has %d coordinates, expected [x, y]", len(v))
+	}
+	p.X, p.Y = v[0], v[1]
+	return nil
+}
+
+// cropSpec - crop as written in the template, before it's resolved to pixels
+type cropSpec struct {
+	From   string
+	Values [4]OCRValue
+
+	// rect - crop it was resolved to, the spec is written back only while the crop stays the same
+	rect     image.Rectangle
+	resolved bool
+}
+
+// object - spec needs the object form (from), or the array one does
+func (s *cropSpec) object() bool {
+	return len(s.From) > 0
+}
+
+// PointKeys - names of template points, in alphabetical order
+func (b *OCRTemplate) PointKeys() []string {
+	var keys []string
+	for k := range b.Points {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// point - position of the named point in template pixels: a point of the template, an anchor (top left corner of its
+// expected crop) or a built-in one
+func (b *OCRTemplate) point(name string, anchors bool) (image.Point, error) {
+	if p, ok := b.Points[name]; ok {
+		x, err := p.X.pixels(b.Width)
+		if err != nil {
+			return image.Point{}, fmt.Errorf("point %v: %w", name, err)
+		}
+		y, err := p.Y.pixels(b.Height)
+		if err != nil {
+			return image.Point{}, fmt.Errorf("point %v: %w", name, err)
+		}
+		return image.Pt(x, y), nil
+	}
+	if a, ok := b.Anchors[name]; ok {
+		if !anchors {
+			return image.Point{}, fmt.Errorf("crops of anchors can't be relative to anchor %v", name)
+		}
+		if a.Crop == nil {
+			return image.Point{}, fmt.Errorf("anchor %v has no crop", name)
+		}
+		return image.Pt(a.Crop.X, a.Crop.Y), nil
+	}
+	if f, ok := screenPoints[name]; ok {
+		if b.Width <= 0 || b.Height <= 0 {
+			return image.Point{}, fmt.Errorf("point %v needs template width & height", name)
+		}
+		return image.Pt(int(math.Round(f[0]*float64(b.Width))), int(math.Round(f[1]*float64(b.Height)))), nil
+	}
+	return image.Point{}, fmt.Errorf("unknown point %q, expected one of points, anchors or %s", name, strings.Join(ScreenPoints(), ", "))
+}
+
+// cropRect - pixels of the crop spec, and the position of its point (from)
+func (b *OCRTemplate) cropRect(s *cropSpec, anchors bool) (image.Rectangle, image.Point, error) {
+	var origin image.Point
+	if len(s.From) > 0 {
+		p, err := b.point(s.From, anchors)
+		if err != nil {
+			return image.Rectangle{}, origin, err
+		}
+		origin = p
+	}
+
+	var v [4]int
+	for i, size := range []int{b.Width, b.Height, b.Width, b.Height} {
+		px, err := s.Values[i].pixels(size)
+		if err != nil {
+			return image.Rectangle{}, origin, err
+		}
+		v[i] = px
+	}
+	return image.Rect(origin.X+v[0], origin.Y+v[1], origin.X+v[0]+v[2], origin.Y+v[1]+v[3]), origin, nil
+}
+
+// resolveCrop - sets pixels of a crop written with percentages or relative to a point. Crops which can't be
+// resolved are left empty, Validate reports why.
+func (b *OCRTemplate) resolveCrop(c *OCRCrop, anchors bool) {
+	if c == nil || c.spec == nil {
+		return
+	}
+	r, origin, err := b.cropRect(c.spec, anchors)
+	if err != nil {
+		return
+	}
+
+	c.X, c.Y, c.W, c.H = r.Min.X, r.Min.Y, r.Dx(), r.Dy()
+	c.From, c.Origin = c.spec.From, nil
+	if len(c.From) > 0 {
+		c.Origin = &origin
+	}
+	c.spec.rect, c.spec.resolved = r, true
+}
+
+// resolveCrops - resolves all crops of the template (anchors first, other crops can be relative to them)
+func (b *OCRTemplate) resolveCrops() {
+	for _, a := range b.Anchors {
+		b.resolveCrop(a.Crop, false)
+	}
+	for _, s := range b.OCRSchema {
+		b.resolveCrop(s.Crop, true)
+	}
+	for _, c := range b.Checkpoints {
+		b.resolveCrop(c.Crop, true)
+	}
+	for _, c := range b.MustNotMatch {
+		b.resolveCrop(c.Crop, true)
+	}
+	if b.Rows != nil {
+		b.resolveCrop(b.Rows.Area, true)
+	}
+}
+
+// jsonTemplate - same layout as OCRTemplate, but without UnmarshalJSON
+type jsonTemplate OCRTemplate
+
+// UnmarshalJSON - crops written with percentages or relative to points are resolved to pixels once parsed
+func (b *OCRTemplate) UnmarshalJSON(data []byte) error {
+	var t jsonTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	*b = OCRTemplate(t)
+	b.resolveCrops()
+	return nil
+}
+
+// validatePoints - coordinates of points have to be resolvable, and not shadow anchors
+func (b *OCRTemplate) validatePoints() []ValidationError {
+	var errs []ValidationError
+	for _, k := range b.PointKeys() {
+		path := fmt.Sprintf("points.%s", k)
+		if _, err := b.point(k, true); err != nil {
+			errs = append(errs, ValidationError{Path: path, Message: err.Error()})
+		}
+		if _, ok := b.Anchors[k]; ok {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("point %v has the name of an anchor, anchor is never used as a point", k)})
+		}
+	}
+	return errs
+}
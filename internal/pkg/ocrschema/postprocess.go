@@ -0,0 +1,61 @@
+package ocrschema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PostProcess - cleans up raw tesseract output according to schema rules, and converts it to schema Type.
+// Returns the value & list of applied transformations (for FieldResult.Transforms).
+// If value can't be converted, cleaned up text is returned.
+func (s *OCRSchema) PostProcess(text string) (interface{}, []string) {
+	var transforms []string
+
+	if filtered := s.AllowList.Filter(text); filtered != text {
+		transforms = append(transforms, fmt.Sprintf("allowlist: %q => %q", text, filtered))
+		text = filtered
+	}
+
+	if len(s.Pattern) > 0 {
+		value, err := s.applyPattern(text)
+		switch {
+		case err != nil:
+			transforms = append(transforms, fmt.Sprintf("pattern: %v", err))
+		case value != text:
+			transforms = append(transforms, fmt.Sprintf("pattern: %q => %q", text, value))
+			text = value
+		}
+	}
+
+	if len(s.Type) > 0 && s.Type != TypeString {
+		value, err := s.TypedValue(text)
+		if err != nil {
+			transforms = append(transforms, fmt.Sprintf("%s: %v", s.Type, err))
+			return text, transforms
+		}
+		transforms = append(transforms, fmt.Sprintf("%s: %q => %v", s.Type, text, FormatValue(value)))
+		return value, transforms
+	}
+
+	return text, transforms
+}
+
+// applyPattern - returns first capture group (or whole match, if pattern has no groups).
+// If pattern doesn't match at all, text is returned as is and validation deals with it.
+func (s *OCRSchema) applyPattern(text string) (string, error) {
+	re, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return text, err
+	}
+
+	m := re.FindStringSubmatch(text)
+	switch {
+	case m == nil:
+		return text, fmt.Errorf("%q doesn't match %q", text, s.Pattern)
+	case len(m) > 1:
+		return strings.TrimSpace(m[1]), nil
+	default:
+		return strings.TrimSpace(m[0]), nil
+	}
+}
@@ -0,0 +1,69 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+type preprocessor struct {
+	// arg - default argument, when step is written without one (e.g. "upscale" == "upscale:2")
+	arg   float64
+	apply func(img image.Image, arg float64) image.Image
+}
+
+var preprocessors = map[string]preprocessor{
+	"grayscale": {apply: func(img image.Image, _ float64) image.Image { return imgutils.Grayscale(img) }},
+	"invert":    {apply: func(img image.Image, _ float64) image.Image { return imgutils.Invert(img) }},
+	"threshold": {arg: 128, apply: func(img image.Image, arg float64) image.Image { return imgutils.Threshold(img, uint8(arg)) }},
+	"upscale":   {arg: 2, apply: func(img image.Image, arg float64) image.Image { return imgutils.Upscale(img, arg) }},
+	"denoise":   {apply: func(img image.Image, _ float64) image.Image { return imgutils.Denoise(img) }},
+	"contrast":  {arg: 1.5, apply: func(img image.Image, arg float64) image.Image { return imgutils.Contrast(img, arg) }},
+}
+
+// PreprocessSteps - names of supported preprocessing operations
+func PreprocessSteps() []string {
+	var names []string
+	for k := range preprocessors {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parsePreprocessStep - "name" or "name:arg", e.g. "threshold:140", "upscale:3"
+func parsePreprocessStep(step string) (preprocessor, float64, error) {
+	name, value, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(step)), ":")
+
+	p, ok := preprocessors[name]
+	if !ok {
+		return p, 0, fmt.Errorf("unknown preprocess step %q, expected one of: %s", step, strings.Join(PreprocessSteps(), ", "))
+	}
+
+	arg := p.arg
+	if hasArg {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f <= 0 {
+			return p, 0, fmt.Errorf("invalid argument of preprocess step %q", step)
+		}
+		arg = f
+	}
+
+	return p, arg, nil
+}
+
+// PreprocessImage - applies Preprocess steps (in order) to the field crop before OCR
+func (s *OCRSchema) PreprocessImage(img image.Image) (image.Image, error) {
+	for _, step := range s.Preprocess {
+		p, arg, err := parsePreprocessStep(step)
+		if err != nil {
+			return img, err
+		}
+		img = p.apply(img, arg)
+	}
+	return img, nil
+}
@@ -0,0 +1,89 @@
+package ocrschema
+
+import "fmt"
+
+// OCRRecord - screen is a part of a logical record spread over several screens (governor profile, more info, kill
+// details), parts are merged into a single row (see rokocr.Aggregate)
+type OCRRecord struct {
+	// Name - parts with the same name belong to the same kind of record, e.g. "governor"
+	Name string `json:"name"`
+	// Key - field identifying the record (e.g. governor id). Parts without one belong to the record of the preceding screen
+	Key string `json:"key,omitempty"`
+}
+
+// RecordTemplate - template of the records merged from parts: fields, computed fields, checks & table columns
+// of all parts, in order. When parts share a field, the first part's definition is used.
+func RecordTemplate(name string, parts []OCRTemplate) OCRTemplate {
+	t := OCRTemplate{
+		Title:     name,
+		OCRSchema: make(map[string]OCRSchema),
+		Record:    &OCRRecord{Name: name},
+	}
+
+	columns := make(map[string]bool)
+	for _, p := range parts {
+		if t.Width == 0 {
+			t.Width, t.Height = p.Width, p.Height
+		}
+		if p.Record != nil && len(t.Record.Key) == 0 {
+			t.Record.Key = p.Record.Key
+		}
+
+		for _, c := range append([]OCRTemplate{p}, p.Chained...) {
+			for k, s := range c.OCRSchema {
+				if _, ok := t.OCRSchema[k]; !ok {
+					t.OCRSchema[k] = s
+				}
+			}
+		}
+		for k, e := range p.Computed {
+			if t.Computed == nil {
+				t.Computed = make(map[string]string)
+			}
+			if _, ok := t.Computed[k]; !ok {
+				t.Computed[k] = e
+			}
+		}
+		for k, c := range p.Checks {
+			if t.Checks == nil {
+				t.Checks = make(map[string]string)
+			}
+			if _, ok := t.Checks[k]; !ok {
+				t.Checks[k] = c
+			}
+		}
+
+		table := p.Table
+		if len(table) == 0 {
+			for _, k := range p.ColumnKeys() {
+				table = append(table, OCRTableField{Title: k, Field: k})
+			}
+		}
+		table = append(table, p.ChainedColumns(table)...)
+		for _, c := range table {
+			if !columns[c.Field] {
+				columns[c.Field] = true
+				t.Table = append(t.Table, c)
+			}
+		}
+	}
+	return t
+}
+
+// validateRecord - record needs a name, and its key has to be a recognized field
+func (b *OCRTemplate) validateRecord() []ValidationError {
+	if b.Record == nil {
+		return nil
+	}
+
+	var errs []ValidationError
+	if len(b.Record.Name) == 0 {
+		errs = append(errs, ValidationError{Path: "record.name", Message: "name is missing"})
+	}
+	if key := b.Record.Key; len(key) > 0 {
+		if _, ok := b.OCRSchema[key]; !ok {
+			errs = append(errs, ValidationError{Path: "record.key", Message: fmt.Sprintf("unknown field %q, not in ocr_schema", key)})
+		}
+	}
+	return errs
+}
@@ -4,6 +4,65 @@ import "time"
 
 type OCRResult struct {
 	Filename string                 `json:"filename"`
+	Panel    int                    `json:"panel,omitempty"` // 1-based panel of stitched screenshot, 0 if not stitched
+	Page     int                    `json:"page,omitempty"`  // 1-based page of PDF, 0 if not a PDF
+	Row      int                    `json:"row,omitempty"`   // 1-based row of a ranking list, 0 if template has no rows
 	Data     map[string]interface{} `json:"data"`
+	Fields   map[string]FieldResult `json:"fields,omitempty"`
 	Took     time.Duration          `json:"duration"`
+	Template string                 `json:"template,omitempty"`
+	// Match - set when template was picked per image, out of several
+	Match *TemplateMatch `json:"match,omitempty"`
+	// Parts - files merged into this record, in order (see OCRTemplate.Record)
+	Parts []string `json:"parts,omitempty"`
+	// Quality - issues found by the pre-check of the screenshot (blurry, letterboxed, ...)
+	Quality []string `json:"quality,omitempty"`
+	// Problems - why the row was rejected, by field (see OCRTemplate.FilterValid)
+	Problems map[string][]string `json:"problems,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// FieldResult - everything we know about a single recognized field, from crop to final value
+type FieldResult struct {
+	Crop       *OCRCrop `json:"crop,omitempty"`
+	Preprocess []string `json:"preprocess,omitempty"`
+	// Languages - language models of the kept result (see LanguageChain)
+	Languages  []string    `json:"lang,omitempty"`
+	Text       string      `json:"text"`
+	Confidence float64     `json:"confidence"`
+	Transforms []string    `json:"transforms,omitempty"`
+	Value      interface{} `json:"value"`
+	// LowConfidence - confidence stayed below MinConfidence, even after retries
+	LowConfidence bool `json:"low_confidence,omitempty"`
+	// Retries - how many alternative variants were tried because of low confidence,
+	// Variant - the one which was kept (empty if none beat the first result)
+	Retries int    `json:"retries,omitempty"`
+	Variant string `json:"variant,omitempty"`
+	// Votes - recognitions the digits were voted from (see OCRSchema.Vote), Agreement - share of them
+	// reading exactly the voted digits
+	Votes     int     `json:"votes,omitempty"`
+	Agreement float64 `json:"agreement,omitempty"`
+	// Corrected - Text was typed by a reviewer (see OCRTemplate.Correct), Original - text recognized by OCR
+	Corrected bool   `json:"corrected,omitempty"`
+	Original  string `json:"original,omitempty"`
+
+	Alternatives []FieldAlternative `json:"alternatives,omitempty"`
+}
+
+type FieldAlternative struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Confidence - average confidence over all recognized fields (0 if there are none)
+func (r OCRResult) Confidence() float64 {
+	if len(r.Fields) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, f := range r.Fields {
+		sum = sum + f.Confidence
+	}
+	return sum / float64(len(r.Fields))
 }
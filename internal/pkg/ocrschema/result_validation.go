@@ -0,0 +1,134 @@
+package ocrschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+)
+
+// ValidateField - checks recognized field against schema rules (required, confidence, regex & range)
+func (s *OCRSchema) ValidateField(f FieldResult) []string {
+	var problems []string
+
+	value := strings.TrimSpace(FormatValue(f.Value))
+	if f.Value == nil {
+		value = ""
+	}
+
+	if len(value) == 0 {
+		if s.Required {
+			problems = append(problems, "required value is empty")
+		}
+		// nothing else to check on empty value
+		return problems
+	}
+
+	if s.MinConfidence > 0 && f.Confidence < s.MinConfidence {
+		problems = append(problems, fmt.Sprintf("confidence %.1f is below %.1f", f.Confidence, s.MinConfidence))
+	} else if f.LowConfidence {
+		// flagged by recognition, against global threshold
+		problems = append(problems, fmt.Sprintf("low confidence %.1f", f.Confidence))
+	}
+
+	if len(s.Match) > 0 {
+		if re, err := regexp.Compile(s.Match); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid match pattern: %v", err))
+		} else if !re.MatchString(value) {
+			problems = append(problems, fmt.Sprintf("value %q doesn't match %q", value, s.Match))
+		}
+	}
+
+	if s.Min != nil || s.Max != nil {
+		n, err := s.numericValue(f.Value)
+		switch {
+		case err != nil:
+			problems = append(problems, err.Error())
+		case s.Min != nil && n < *s.Min:
+			problems = append(problems, fmt.Sprintf("value %v is below minimum %v", n, *s.Min))
+		case s.Max != nil && n > *s.Max:
+			problems = append(problems, fmt.Sprintf("value %v is above maximum %v", n, *s.Max))
+		}
+	}
+
+	return problems
+}
+
+// numericValue - typed values are used as is, text is parsed
+func (s *OCRSchema) numericValue(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), nil
+	case int:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	}
+	return stringutils.ParseNumberWithDecimal(FormatValue(v), s.DecimalSeparator)
+}
+
+// ValidateResult - returns problems of every field, keyed by field name (empty map if result is clean)
+func (b *OCRTemplate) ValidateResult(r OCRResult) map[string][]string {
+	problems := make(map[string][]string)
+
+	for _, k := range b.FieldKeys() {
+		s := b.OCRSchema[k]
+
+		f, ok := r.Fields[k]
+		if !ok {
+			// results stored before fields were tracked only have the data
+			f = FieldResult{Value: r.Data[k]}
+		}
+
+		if p := s.ValidateField(f); len(p) > 0 {
+			problems[k] = p
+		}
+	}
+
+	// failed sanity check means one of its (recognized) inputs is misread
+	failed := b.FailedChecks(r.Data)
+	for _, name := range b.CheckKeys() {
+		msg, ok := failed[name]
+		if !ok {
+			continue
+		}
+		for _, k := range b.checkInputs(name) {
+			problems[k] = append(problems[k], fmt.Sprintf("fails check %s: %s", name, msg))
+		}
+	}
+
+	return problems
+}
+
+// FilterValid - splits results into clean ones (to publish) and rejected ones (to review), keeping their order.
+// Rejected results have their Problems set.
+func (b *OCRTemplate) FilterValid(results []OCRResult) (valid, rejected []OCRResult) {
+	for _, r := range results {
+		if problems := b.ValidateResult(r); len(problems) == 0 {
+			valid = append(valid, r)
+		} else {
+			r.Problems = problems
+			rejected = append(rejected, r)
+		}
+	}
+	return valid, rejected
+}
+
+// FormatProblems - problems of a result in one line, by field name, e.g. "power: value 5 is below minimum 10"
+func FormatProblems(problems map[string][]string) string {
+	var keys []string
+	for k := range problems {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, p := range problems[k] {
+			parts = append(parts, fmt.Sprintf("%s: %s", k, p))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
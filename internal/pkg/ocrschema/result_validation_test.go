@@ -0,0 +1,80 @@
+package ocrschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterValid(t *testing.T) {
+	minPower := 1000.0
+	template := OCRTemplate{
+		OCRSchema: map[string]OCRSchema{
+			"name":  {Required: true},
+			"id":    {Match: `^\d{8}$`},
+			"power": {Min: &minPower},
+		},
+	}
+	row := func(file, name, id string, power interface{}) OCRResult {
+		return OCRResult{Filename: file, Data: map[string]interface{}{"name": name, "id": id, "power": power}}
+	}
+
+	results := []OCRResult{
+		row("1.png", "PlayerOne", "12345678", int64(5000)),
+		row("2.png", "", "12345678", int64(5000)),
+		row("3.png", "PlayerThree", "1234", int64(5000)),
+		row("4.png", "PlayerFour", "87654321", "1,500"),
+		row("5.png", "PlayerFive", "123", int64(10)),
+		row("6.png", "PlayerSix", "11112222", int64(1000)),
+	}
+
+	valid, rejected := template.FilterValid(results)
+
+	files := func(rows []OCRResult) []string {
+		var names []string
+		for _, r := range rows {
+			names = append(names, r.Filename)
+		}
+		return names
+	}
+	if got, want := files(valid), []string{"1.png", "4.png", "6.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("valid = %v, want %v", got, want)
+	}
+	if got, want := files(rejected), []string{"2.png", "3.png", "5.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rejected = %v, want %v", got, want)
+	}
+
+	for _, r := range valid {
+		if len(r.Problems) > 0 {
+			t.Errorf("%v is valid, but has problems %v", r.Filename, r.Problems)
+		}
+	}
+
+	// every rejected row says which fields are wrong, and why
+	wantProblems := map[string][]string{
+		"2.png": {"name"},
+		"3.png": {"id"},
+		"5.png": {"id", "power"},
+	}
+	for _, r := range rejected {
+		var fields []string
+		for _, k := range []string{"id", "name", "power"} {
+			if len(r.Problems[k]) > 0 {
+				fields = append(fields, k)
+			}
+		}
+		if !reflect.DeepEqual(fields, wantProblems[r.Filename]) {
+			t.Errorf("%v: problems of %v, want %v (%v)", r.Filename, fields, wantProblems[r.Filename], r.Problems)
+		}
+	}
+	if got := FormatProblems(rejected[2].Problems); !strings.HasPrefix(got, "id: ") || !strings.Contains(got, "; power: value 10 is below minimum 1000") {
+		t.Errorf("FormatProblems() = %q", got)
+	}
+
+	// input isn't modified
+	for _, r := range results {
+		if r.Problems != nil {
+			t.Errorf("%v: problems set on the input", r.Filename)
+		}
+	}
+}
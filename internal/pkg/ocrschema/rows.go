@@ -0,0 +1,193 @@
+package ocrschema
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+)
+
+// how rows of a list are found (see OCRRows.Detect)
+const (
+	// RowsFixed - rows follow each other every Height pixels, from the top of the area
+	RowsFixed = "fixed"
+	// RowsSeparators - rows start below horizontal separator lines
+	RowsSeparators = "separators"
+	// RowsAnchor - every row has the same icon (anchor), e.g. rank badge or avatar frame
+	RowsAnchor = "anchor"
+)
+
+var RowDetectors = []string{RowsFixed, RowsSeparators, RowsAnchor}
+
+// OCRRows - ranking lists show several governors on one screen. Crops of ocr_schema are drawn on the first row
+// of the list, and every detected row produces a separate result (see OCRResult.Row).
+type OCRRows struct {
+	// Area - part of the screen with the list, the first row starts at its top
+	Area *OCRCrop `json:"area"`
+	// Height - height of a row (in template pixels)
+	Height int `json:"height"`
+	// Detect - fixed (default), separators or anchor
+	Detect string `json:"detect,omitempty"`
+	// Anchor - name of the anchor repeated in every row, with its crop on the first row (detect: anchor)
+	Anchor string `json:"anchor,omitempty"`
+}
+
+func (r *OCRRows) detector() string {
+	if len(r.Detect) == 0 {
+		return RowsFixed
+	}
+	return r.Detect
+}
+
+// RowOffsets - vertical offsets (in image pixels) of the list rows from the first row of the template, in order
+// from top. Rows not fully inside the area are left out. Templates without rows have a single row with no offset.
+func (b *OCRTemplate) RowOffsets(img image.Image) []int {
+	if b.Rows == nil || b.Rows.Area == nil || b.Rows.Height <= 0 {
+		return []int{0}
+	}
+
+	sx, sy := b.ScaleFactor(img)
+	area := b.Rows.Area.Scale(sx, sy).CropRectangle().Add(img.Bounds().Min)
+	height := int(math.Round(float64(b.Rows.Height) * sy))
+	if height <= 0 {
+		return []int{0}
+	}
+
+	var tops []int
+	switch b.Rows.detector() {
+	case RowsSeparators:
+		top := area.Min.Y
+		for _, y := range append(imgutils.HorizontalLines(img, area), area.Max.Y) {
+			// rows are at least half of the expected height, anything smaller is a gap between lines
+			if y-top >= height/2 {
+				tops = append(tops, top)
+			}
+			top = y + 1
+		}
+	case RowsAnchor:
+		a, ok := b.Anchors[b.Rows.Anchor]
+		if !ok || a.Crop == nil {
+			logutils.Template(b.Title).Warnf("Rows: unknown anchor %q", b.Rows.Anchor)
+			return nil
+		}
+		icon, err := b.AnchorImage(b.Rows.Anchor)
+		if err != nil {
+			logutils.Template(b.Title).Warnf("Rows: %v", err)
+			return nil
+		}
+		crop := a.Crop.Scale(sx, sy)
+		if icon.Bounds().Dx() != crop.W || icon.Bounds().Dy() != crop.H {
+			icon = imgutils.ResizeImage(icon, crop.W, crop.H)
+		}
+		// icon's position within the row
+		inRow := crop.Y - int(math.Round(float64(b.Rows.Area.Y)*sy))
+		for _, m := range imgutils.MatchTemplateAll(img, icon, area, a.minScore()) {
+			tops = append(tops, m.At.Y-inRow)
+		}
+		sort.Ints(tops)
+	default:
+		for top := area.Min.Y; top+height <= area.Max.Y; top += height {
+			tops = append(tops, top)
+		}
+	}
+
+	var offsets []int
+	for _, top := range tops {
+		if top >= area.Min.Y && top+height <= area.Max.Y {
+			offsets = append(offsets, top-area.Min.Y)
+		}
+	}
+	logutils.Template(b.Title).Debugf("%v rows detected (%s)", len(offsets), b.Rows.detector())
+	return offsets
+}
+
+// Moved - returns a copy of the template with crops of all fields moved by offset (e.g. to a row of a list)
+func (b OCRTemplate) Moved(offset image.Point) OCRTemplate {
+	if offset == (image.Point{}) {
+		return b
+	}
+
+	fields := make(map[string]OCRSchema, len(b.OCRSchema))
+	for k, s := range b.OCRSchema {
+		if s.Crop != nil {
+			c := *s.Crop
+			c.X, c.Y = c.X+offset.X, c.Y+offset.Y
+			s.Crop = &c
+		}
+		fields[k] = s
+	}
+	b.OCRSchema = fields
+	return b
+}
+
+// validateRows - list area has to fit the template, and row detection has to be known
+func (b *OCRTemplate) validateRows() []ValidationError {
+	r := b.Rows
+	if r == nil {
+		return nil
+	}
+
+	var errs []ValidationError
+	if err := b.validateCrop(r.Area); err != nil {
+		errs = append(errs, ValidationError{Path: "rows.area", Message: err.Error()})
+	}
+	if r.Height <= 0 || (r.Area != nil && r.Height > r.Area.H) {
+		errs = append(errs, ValidationError{Path: "rows.height", Message: fmt.Sprintf("height %d has to be positive, and fit the area", r.Height)})
+	}
+	if !containsString(RowDetectors, r.detector()) {
+		errs = append(errs, ValidationError{Path: "rows.detect", Message: fmt.Sprintf("unknown row detection %q, expected one of: %s", r.Detect, strings.Join(RowDetectors, ", "))})
+	}
+	if r.detector() == RowsAnchor {
+		if _, ok := b.Anchors[r.Anchor]; !ok {
+			errs = append(errs, ValidationError{Path: "rows.anchor", Message: fmt.Sprintf("unknown anchor %q", r.Anchor)})
+		}
+	}
+	return errs
+}
+
+// StitchMinScore - lowest match score of the overlap of two consecutive screenshots of a scrolled list
+const StitchMinScore = 0.9
+
+// Stitched - merges screenshots of the scrolled list into one tall image (see imgutils.StitchVertical), and returns it
+// with a copy of the template for it: scaled to the screenshots, and with the list area covering the whole list.
+// This way rows cut by the screen edge, or visible in several screenshots, are read exactly once.
+func (b *OCRTemplate) Stitched(images []image.Image) (image.Image, OCRTemplate, error) {
+	if b.Rows == nil || b.Rows.Area == nil {
+		return nil, *b, fmt.Errorf("template %v has no rows to stitch", b.Title)
+	}
+	if len(images) == 0 {
+		return nil, *b, fmt.Errorf("nothing to stitch")
+	}
+
+	size := images[0].Bounds().Size()
+	scaled := b.ScaledTo(size.X, size.Y)
+	img, _, err := imgutils.StitchVertical(images, scaled.Rows.Area.CropRectangle(), StitchMinScore)
+	if err != nil {
+		return nil, *b, err
+	}
+
+	// icons are cut out of reference image of the screen size, before it grows
+	if len(scaled.Anchors) > 0 {
+		anchors := make(map[string]OCRAnchor, len(scaled.Anchors))
+		for k, a := range scaled.Anchors {
+			if icon, err := scaled.AnchorImage(k); err == nil {
+				a.icon = icon
+			}
+			anchors[k] = a
+		}
+		scaled.Anchors = anchors
+	}
+
+	grown := img.Bounds().Dy() - size.Y
+	rows := *scaled.Rows
+	area := *rows.Area
+	area.H += grown
+	rows.Area = &area
+	scaled.Rows = &rows
+	scaled.Height += grown
+	return img, scaled, nil
+}
@@ -0,0 +1,100 @@
+package ocrschema
+
+import (
+	"image"
+	"math"
+)
+
+// Scale - returns a copy of the crop, scaled by given factors (rounded to the nearest pixel). Crops relative
+// to a point (with an origin) keep their aspect ratio: the point is scaled by given factors, the offset & size
+// by the smaller one, the way the game scales its UI on screens of other aspect ratios.
+func (b *OCRCrop) Scale(sx, sy float64) *OCRCrop {
+	if b == nil {
+		return nil
+	}
+
+	if b.Origin != nil {
+		s := math.Min(sx, sy)
+		origin := image.Pt(int(math.Round(float64(b.Origin.X)*sx)), int(math.Round(float64(b.Origin.Y)*sy)))
+		x := origin.X + int(math.Round(float64(b.X-b.Origin.X)*s))
+		y := origin.Y + int(math.Round(float64(b.Y-b.Origin.Y)*s))
+		return &OCRCrop{
+			X:      x,
+			Y:      y,
+			W:      int(math.Round(float64(b.X-b.Origin.X+b.W)*s)) + origin.X - x,
+			H:      int(math.Round(float64(b.Y-b.Origin.Y+b.H)*s)) + origin.Y - y,
+			From:   b.From,
+			Origin: &origin,
+		}
+	}
+
+	x := int(math.Round(float64(b.X) * sx))
+	y := int(math.Round(float64(b.Y) * sy))
+	return &OCRCrop{
+		X: x,
+		Y: y,
+		W: int(math.Round(float64(b.X+b.W)*sx)) - x,
+		H: int(math.Round(float64(b.Y+b.H)*sy)) - y,
+	}
+}
+
+// ScaleFactor - how much bigger (or smaller) the image is compared to the template
+func (b *OCRTemplate) ScaleFactor(img image.Image) (float64, float64) {
+	if b.Width <= 0 || b.Height <= 0 {
+		return 1, 1
+	}
+	return float64(img.Bounds().Dx()) / float64(b.Width), float64(img.Bounds().Dy()) / float64(b.Height)
+}
+
+// ScaledTo - returns a copy of the template for w x h screenshots, with all crops (fields, checkpoints, anchors & rows)
+// scaled proportionally. This way 1280x720, 1920x1080 & 2560x1440 captures can all be read in their
+// native resolution by a single template, instead of resizing the image itself (and losing detail).
+func (b OCRTemplate) ScaledTo(w, h int) OCRTemplate {
+	if b.Width <= 0 || b.Height <= 0 || (b.Width == w && b.Height == h) {
+		return b
+	}
+
+	sx, sy := float64(w)/float64(b.Width), float64(h)/float64(b.Height)
+
+	fields := make(map[string]OCRSchema, len(b.OCRSchema))
+	for k, s := range b.OCRSchema {
+		s.Crop = s.Crop.Scale(sx, sy)
+		fields[k] = s
+	}
+	b.OCRSchema = fields
+
+	b.Checkpoints = scaleCheckpoints(b.Checkpoints, sx, sy)
+	b.MustNotMatch = scaleCheckpoints(b.MustNotMatch, sx, sy)
+
+	if len(b.Anchors) > 0 {
+		anchors := make(map[string]OCRAnchor, len(b.Anchors))
+		for k, a := range b.Anchors {
+			a.Crop = a.Crop.Scale(sx, sy)
+			a.Search = int(math.Round(float64(a.search()) * sx))
+			anchors[k] = a
+		}
+		b.Anchors = anchors
+	}
+	if b.Rows != nil {
+		rows := *b.Rows
+		rows.Area = rows.Area.Scale(sx, sy)
+		rows.Height = int(math.Round(float64(rows.Height) * sy))
+		b.Rows = &rows
+	}
+
+	b.Width, b.Height = w, h
+	return b
+}
+
+func scaleCheckpoints(list []OCRCheckpoint, sx, sy float64) []OCRCheckpoint {
+	if list == nil {
+		return nil
+	}
+
+	checkpoints := make([]OCRCheckpoint, len(list))
+	for i, c := range list {
+		c.Crop = c.Crop.Scale(sx, sy)
+		checkpoints[i] = c
+	}
+	return checkpoints
+}
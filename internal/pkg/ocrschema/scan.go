@@ -0,0 +1,79 @@
+package ocrschema
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fields scan tags are stored in, in OCRResult.Data
+const (
+	ScanKingdomField  = "kingdom"
+	ScanAllianceField = "alliance"
+	ScanDateField     = "scan_date"
+)
+
+// ScanDateFormat - layout of ScanTags.Date
+const ScanDateFormat = "2006-01-02"
+
+// ScanColumns - export columns of scan tags, appended after the template table
+var ScanColumns = []OCRTableField{
+	{Title: "Kingdom", Field: ScanKingdomField},
+	{Title: "Alliance", Field: ScanAllianceField},
+	{Title: "Scan date", Field: ScanDateField},
+}
+
+// ScanTags - which kingdom & alliance were scanned and when, carried into every result of the scan,
+// so datasets merged over several kingdoms (or weeks) stay attributable
+type ScanTags struct {
+	Kingdom  string `json:"kingdom,omitempty"`
+	Alliance string `json:"alliance,omitempty"`
+	// Date - YYYY-MM-DD (see ScanDateFormat)
+	Date string `json:"date,omitempty"`
+}
+
+// Empty - scan isn't tagged
+func (t ScanTags) Empty() bool {
+	return len(t.Kingdom) == 0 && len(t.Alliance) == 0 && len(t.Date) == 0
+}
+
+// Validate - kingdom is a number, date is YYYY-MM-DD
+func (t ScanTags) Validate() error {
+	if len(t.Kingdom) > 0 && strings.Trim(t.Kingdom, "0123456789") != "" {
+		return fmt.Errorf("kingdom %q is not a number", t.Kingdom)
+	}
+	if len(t.Date) > 0 {
+		if _, err := time.Parse(ScanDateFormat, t.Date); err != nil {
+			return fmt.Errorf("scan date %q is not YYYY-MM-DD", t.Date)
+		}
+	}
+	return nil
+}
+
+// Values - tag fields which are set, by field name
+func (t ScanTags) Values() map[string]string {
+	values := make(map[string]string)
+	for field, v := range map[string]string{ScanKingdomField: t.Kingdom, ScanAllianceField: t.Alliance, ScanDateField: t.Date} {
+		if len(v) > 0 {
+			values[field] = v
+		}
+	}
+	return values
+}
+
+// Apply - adds tags to the result data. Fields the template recognized itself (e.g. alliance tag of
+// a governor profile) are kept, they're more precise than the tag of the whole scan.
+func (t ScanTags) Apply(r *OCRResult) {
+	values := t.Values()
+	if len(values) == 0 {
+		return
+	}
+	if r.Data == nil {
+		r.Data = make(map[string]interface{})
+	}
+	for field, v := range values {
+		if existing := r.Data[field]; existing == nil || len(strings.TrimSpace(FormatValue(existing))) == 0 {
+			r.Data[field] = v
+		}
+	}
+}
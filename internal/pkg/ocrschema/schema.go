@@ -1,18 +1,31 @@
 package ocrschema
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"image"
-	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
 
 	"github.com/corona10/goimagehash"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
 	log "github.com/sirupsen/logrus"
 )
 
 type OCRTemplate struct {
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Extends - base template (file relative to this one) the template only overrides parts of, Include - partial
+	// templates merged in after it (e.g. shared fields). Both are merged while loading (see ReadTemplateJSON).
+	Extends string   `json:"extends,omitempty"`
+	Include []string `json:"include,omitempty"`
+	// Abstract - template only serves as a base of others, it's not loaded for recognition
+	Abstract bool `json:"abstract,omitempty"`
+
 	Title       string               `json:"title,omitempty"`
 	Version     string               `json:"version,omitempty"`
 	Author      string               `json:"author,omitempty"`
@@ -21,33 +34,174 @@ type OCRTemplate struct {
 	OCRSchema   map[string]OCRSchema `json:"ocr_schema,omitempty"`
 	Fingerprint string               `json:"fingerprint,omitempty"`
 	Threshold   int                  `json:"threshold,omitempty"`
-	Table       []OCRTableField      `json:"table,omitempty"`
-	Checkpoints []OCRCheckpoint      `json:"checkpoints,omitempty"`
+	// HashAlgo - one of dhash (default), phash, ahash, whash. Used for fingerprint & checkpoints.
+	HashAlgo    string          `json:"hash_algo,omitempty"`
+	Table       []OCRTableField `json:"table,omitempty"`
+	Checkpoints []OCRCheckpoint `json:"checkpoints,omitempty"`
+	// CheckpointQuorum - how many checkpoints has to match, 0 means all required (non-optional) ones
+	CheckpointQuorum int `json:"checkpoint_quorum,omitempty"`
+	// MustNotMatch - if any of these matches, it's a look-alike screen (popup, other tab), not this template
+	MustNotMatch []OCRCheckpoint `json:"must_not_match,omitempty"`
+
+	// Computed - fields calculated from recognized ones after OCR, name => expression,
+	// e.g. "t4_kills * 10 + t5_kills * 20" (see ComputeFields)
+	Computed map[string]string `json:"computed,omitempty"`
+	// Checks - sanity rules across fields, name => condition, e.g. "kill_points >= t4_points + t5_points".
+	// Results failing them have misread fields (see FailedChecks)
+	Checks map[string]string `json:"checks,omitempty"`
+
+	// Record - screen is a part of a record spread over several screens, e.g. profile + more info (see OCRRecord)
+	Record *OCRRecord `json:"record,omitempty"`
+
+	// Rows - ranking list with several governors on one screen, every row is a separate result (see OCRRows)
+	Rows *OCRRows `json:"rows,omitempty"`
+
+	// Anchors - icons located on the screenshot, crops of fields anchored to them follow their position (see OCRAnchor)
+	Anchors map[string]OCRAnchor `json:"anchors,omitempty"`
+	// Points - named points crops can be relative to, besides anchors & the built-in ones (see OCRCrop)
+	Points map[string]OCRPoint `json:"points,omitempty"`
+
+	// Chain - templates (files relative to this one) reading other regions of the same screen, e.g. the top bar.
+	// They're recognized on every screenshot this template reads, and their fields are merged into its results.
+	Chain []string `json:"chain,omitempty"`
+	// Chained - templates of Chain, loaded with the template
+	Chained []OCRTemplate `json:"-"`
+
+	// RosterFile & ReferenceImage are relative to template file location (see ResolvePath)
+	RosterFile     string `json:"roster_file,omitempty"`
+	ReferenceImage string `json:"reference_image,omitempty"`
+
+	// BaseDir - directory of the file template was loaded from
+	BaseDir string `json:"-"`
+	// Sources - files merged into the template (base & includes), a change of any of them changes the template
+	Sources []string `json:"-"`
 }
 
 type OCRCheckpoint struct {
 	Crop        *OCRCrop `json:"crop,omitempty"`
 	Fingerprint string   `json:"fingerprint,omitempty"`
+	// Threshold - max hash distance for this checkpoint, 0 means default (1)
+	Threshold int `json:"threshold,omitempty"`
+	// Exact - hashes have to be the same (max distance 0), e.g. a whole screen fingerprint of a v1 template
+	Exact bool `json:"exact,omitempty"`
+	// Optional - doesn't have to match (e.g. area which animates), but still counts towards CheckpointQuorum
+	Optional bool `json:"optional,omitempty"`
+}
+
+func (c *OCRCheckpoint) threshold() int {
+	if c.Exact {
+		return 0
+	}
+	if c.Threshold > 0 {
+		return c.Threshold
+	}
+	return 1
 }
 
+// LoadTemplate - reads JSON template (migrating older schema versions, merging its base & includes, loading its chain). Missing file, bad JSON
+// or unparsable fingerprints are reported as errors.
 func LoadTemplate(fileName string) (OCRTemplate, error) {
+	t, err := loadTemplateFile(fileName)
+	if err != nil {
+		return t, err
+	}
+	if err := t.loadChain(); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// loadTemplateFile - template of the JSON file, without its chain
+func loadTemplateFile(fileName string) (OCRTemplate, error) {
 	var t OCRTemplate
-	b, _ := ioutil.ReadFile(fileName)
-	err := json.Unmarshal(b, &t)
-	return t, err
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return t, err
+	}
+	b, sources, err := ReadTemplateJSON(fileName)
+	if err != nil {
+		return t, fmt.Errorf("invalid template JSON: %w", err)
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("invalid template JSON: %w", err)
+	}
+	t.Sources = sources
+	if abs, e := filepath.Abs(fileName); e == nil {
+		t.BaseDir = filepath.Dir(abs)
+	}
+	return t, t.checkHashes()
+}
+
+// checkHashes - fingerprints have to be parsable, otherwise template silently never matches.
+// Empty fingerprint is fine, such template can still be forced.
+func (b *OCRTemplate) checkHashes() error {
+	if _, err := hashKind(b.HashAlgo); err != nil {
+		return err
+	}
+	if len(b.Fingerprint) > 0 {
+		if _, err := b.ParseHash(); err != nil {
+			return err
+		}
+	}
+	for i, c := range b.Checkpoints {
+		if _, err := HashFromString(c.Fingerprint, b.HashAlgo); err != nil {
+			return fmt.Errorf("checkpoint %d: %w", i, err)
+		}
+	}
+	for i, c := range b.MustNotMatch {
+		if _, err := HashFromString(c.Fingerprint, b.HashAlgo); err != nil {
+			return fmt.Errorf("must_not_match %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ResolvePath - absolute paths are kept as is, relative ones are resolved against template directory.
+// Templates not loaded from disk (no BaseDir) resolve against current working directory.
+func (b *OCRTemplate) ResolvePath(p string) string {
+	if len(p) == 0 || filepath.IsAbs(p) || len(b.BaseDir) == 0 {
+		return p
+	}
+	return filepath.Join(b.BaseDir, p)
+}
+
+// RosterPath - roster_file, resolved against the template directory
+func (b *OCRTemplate) RosterPath() string {
+	return b.ResolvePath(b.RosterFile)
 }
 
-func differenceHashFromString(s string) *goimagehash.ImageHash {
-	result, _ := strconv.ParseUint(s, 16, 64)
-	return goimagehash.NewImageHash(result, goimagehash.DHash)
+// ReferenceImagePath - reference_image, resolved against the template directory
+func (b *OCRTemplate) ReferenceImagePath() string {
+	return b.ResolvePath(b.ReferenceImage)
 }
 
+// ParseHash - fingerprint of the template as hash
+func (b *OCRTemplate) ParseHash() (*goimagehash.ImageHash, error) {
+	return HashFromString(b.Fingerprint, b.HashAlgo)
+}
+
+// Hash - like ParseHash, but invalid fingerprint gives an empty hash (of template hash kind)
 func (b *OCRTemplate) Hash() *goimagehash.ImageHash {
-	return differenceHashFromString(b.Fingerprint)
+	hash, err := b.ParseHash()
+	if err != nil {
+		logutils.Template(b.Title).Debug(err)
+		kind, _ := hashKind(b.HashAlgo)
+		return goimagehash.NewImageHash(0, kind)
+	}
+	return hash
+}
+
+// ImageHash - hash of the image, computed with the algorithm this template uses
+func (b *OCRTemplate) ImageHash(img image.Image) (*goimagehash.ImageHash, error) {
+	return ComputeHash(img, b.HashAlgo)
 }
 
-func hashMatches(b image.Image, hash *goimagehash.ImageHash) bool {
-	imgHash, _ := goimagehash.DifferenceHash(b)
+func (b *OCRTemplate) hashMatches(img image.Image, hash *goimagehash.ImageHash, threshold int) bool {
+	imgHash, err := b.ImageHash(img)
+	if err != nil {
+		return false
+	}
+
 	distance, err := imgHash.Distance(hash)
 	// if we get error, that means this template is no go...
 	if err != nil {
@@ -58,32 +212,105 @@ func hashMatches(b image.Image, hash *goimagehash.ImageHash) bool {
 		log.Debugf("Expected hash: %x, real hash: %x, distance: %v", hash.GetHash(), imgHash.GetHash(), distance)
 	}
 
-	// max distance allowed here is 1
-	return 1 >= distance
+	return threshold >= distance
 }
 
 func (b *OCRTemplate) Matches(img image.Image) bool {
-	imageHash, _ := goimagehash.DifferenceHash(img)
+	if b.excluded(img) {
+		return false
+	}
 
 	if len(b.Checkpoints) == 0 {
+		imageHash, err := b.ImageHash(img)
+		if err != nil {
+			return false
+		}
 		return b.Match(imageHash)
 	}
 
-	// if we have checkpoints, check if all checkpoints matches
-	for _, s := range b.Checkpoints {
-		expectedHash := differenceHashFromString(s.Fingerprint)
-		subImg, _ := imgutils.CropImage(img, s.Crop.CropRectangle())
-		if !hashMatches(subImg, expectedHash) {
+	_, ok := b.checkpointHits(img)
+	return ok
+}
+
+// excluded - one of MustNotMatch checkpoints matches the image
+func (b *OCRTemplate) excluded(img image.Image) bool {
+	if len(b.MustNotMatch) == 0 {
+		return false
+	}
+
+	scaled := b.ScaledTo(img.Bounds().Dx(), img.Bounds().Dy())
+	for _, s := range scaled.MustNotMatch {
+		expectedHash, err := HashFromString(s.Fingerprint, b.HashAlgo)
+		if err != nil {
+			continue
+		}
+		subImg, _ := imgutils.CropImage(img, s.Crop.CropRectangle().Add(img.Bounds().Min))
+		if b.hashMatches(subImg, expectedHash, s.threshold()) {
+			logutils.Template(b.Title).Debugf("Area %v matches look-alike screen, excluded", s.Crop)
+			return true
+		}
+	}
+	return false
+}
+
+// checkpointQuorum - CheckpointQuorum, or number of required checkpoints (at least one)
+func (b *OCRTemplate) checkpointQuorum() int {
+	if b.CheckpointQuorum > 0 && b.CheckpointQuorum <= len(b.Checkpoints) {
+		return b.CheckpointQuorum
+	}
+
+	required := 0
+	for _, c := range b.Checkpoints {
+		if !c.Optional {
+			required++
+		}
+	}
+	if required == 0 && len(b.Checkpoints) > 0 {
+		return 1
+	}
+	return required
+}
+
+// checkpointHits - how many checkpoints match the image, and whether it is enough: quorum is reached
+// and, without explicit CheckpointQuorum, none of the required checkpoints is missed
+func (b *OCRTemplate) checkpointHits(img image.Image) (int, bool) {
+	quorum := b.checkpointQuorum()
+	explicitQuorum := b.CheckpointQuorum > 0 && b.CheckpointQuorum <= len(b.Checkpoints)
+
+	// checkpoint crops are in template coordinates, scale them to the image resolution
+	scaled := b.ScaledTo(img.Bounds().Dx(), img.Bounds().Dy())
+
+	// if we have checkpoints, check if enough checkpoints matches
+	matched := 0
+	missedRequired := 0
+	for _, s := range scaled.Checkpoints {
+		expectedHash, err := HashFromString(s.Fingerprint, b.HashAlgo)
+		if err != nil {
+			logutils.Template(b.Title).Debugf("checkpoint %v: %v", s.Crop, err)
+			continue
+		}
+		subImg, _ := imgutils.CropImage(img, s.Crop.CropRectangle().Add(img.Bounds().Min))
+		if b.hashMatches(subImg, expectedHash, s.threshold()) {
+			matched++
+		} else {
+			if !s.Optional {
+				missedRequired++
+			}
 			log.Debugf("Area %v doesn't match expected hash: %v", s.Crop, s.Fingerprint)
-			return false
 		}
 	}
 
-	return true
+	logutils.Template(b.Title).Debugf("%v of %v checkpoints matched (quorum: %v)", matched, len(b.Checkpoints), quorum)
+	return matched, matched >= quorum && (explicitQuorum || missedRequired == 0)
 }
 
 func (b *OCRTemplate) Match(hash *goimagehash.ImageHash) bool {
-	distance, err := b.Hash().Distance(hash)
+	expected, err := b.ParseHash()
+	if err != nil {
+		return false
+	}
+
+	distance, err := expected.Distance(hash)
 	// if we get error, that means this template is no go...
 	if err != nil {
 		return false
@@ -93,20 +320,60 @@ func (b *OCRTemplate) Match(hash *goimagehash.ImageHash) bool {
 	return distance <= b.Threshold
 }
 
+// MaxVote - every vote is another OCR run of the field
+const MaxVote = 15
+
 type OCRSchema struct {
-	Callback  interface{}   `json:"callback,omitempty"`
-	Languages []string      `json:"lang,omitempty"`
-	OEM       int           `json:"oem,omitempty"`
-	PSM       int           `json:"psm,omitempty"`
-	Crop      *OCRCrop      `json:"crop,omitempty"`
-	AllowList []interface{} `json:"allowlist,omitempty"`
+	Callback  interface{} `json:"callback,omitempty"`
+	Languages []string    `json:"lang,omitempty"`
+	// LanguageFallback - further language models (e.g. "chi_sim", or combined "kor+eng") tried one by one
+	// after `lang`, result with the highest confidence is kept
+	LanguageFallback []string `json:"lang_fallback,omitempty"`
+	// Model - custom traineddata in tessdata dir (e.g. "rok_digits" for rok_digits.traineddata, fine-tuned on the
+	// game font), used instead of `lang`. Fallbacks are still tried after it
+	Model     string    `json:"model,omitempty"`
+	OEM       int       `json:"oem,omitempty"`
+	PSM       int       `json:"psm,omitempty"`
+	Crop      *OCRCrop  `json:"crop,omitempty"`
+	AllowList AllowList `json:"allowlist,omitempty"`
+	// Anchor - name of the anchor, crop is moved with (see OCRTemplate.Anchors)
+	Anchor string `json:"anchor,omitempty"`
+	// Engine - OCR engine of this field (e.g. "google" for CJK names), empty - the globally selected one
+	Engine string `json:"engine,omitempty"`
+
+	// Preprocess - operations applied to the crop before OCR, in order. Supported:
+	// grayscale, invert, threshold[:level], upscale[:factor], denoise, contrast[:factor]
+	Preprocess []string `json:"preprocess,omitempty"`
+
+	// Vote - numeric fields: recognize the crop this many times with jittered crop & preprocessing, and pick
+	// every digit by majority (8 vs 6, 1 vs 7 misreads). 0 or 1 - disabled, at most MaxVote
+	Vote int `json:"vote,omitempty"`
+
+	// Pattern - regex applied to recognized text, first capture group (or whole match) becomes the value.
+	// E.g. `^([\d,]+)` turns "123,456,789 (+5%)" into "123,456,789"
+	Pattern string `json:"pattern,omitempty"`
+	// Type - string (default), int, float, duration or percent, see TypedValue
+	Type string `json:"type,omitempty"`
+	// DecimalSeparator - "." or ",", guessed from the value if empty (1,234,567 vs 1.234.567)
+	DecimalSeparator string `json:"decimal_separator,omitempty"`
+
+	// validation of recognized value, see ValidateField
+	Required      bool     `json:"required,omitempty"`
+	MinConfidence float64  `json:"min_confidence,omitempty"`
+	Match         string   `json:"match,omitempty"`
+	Min           *float64 `json:"min,omitempty"`
+	Max           *float64 `json:"max,omitempty"`
+
+	// CaseInsensitiveKey - when field is used as a key (dedup, merge, diff), ignore case & extra whitespace.
+	// Only affects matching, recorded value stays as recognized.
+	CaseInsensitiveKey bool `json:"case_insensitive_key,omitempty"`
 }
 
 func NewNumberField(cropArea *OCRCrop) OCRSchema {
 	return OCRSchema{
 		Languages: []string{"eng"},
 		Callback:  []string{},
-		AllowList: []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		AllowList: AllowList{"digits"},
 		PSM:       7,
 		OEM:       1,
 		Crop:      cropArea,
@@ -149,32 +416,109 @@ func (b *OCRTableField) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// OCRCrop - area of the screenshot in template pixels. In JSON it's [x, y, w, h] in pixels or percent of template
+// size ("25%"), or {"from": "bottom_right", "x": -300, "y": -80, "w": 280, "h": 60} relative to a named point.
 type OCRCrop struct {
 	X int
 	Y int
 	W int
 	H int
+
+	// From - named point X & Y were relative to (see OCRTemplate.Points), empty - top left corner of the image
+	From string
+	// Origin - position of From in template pixels. Crops with an origin keep their aspect ratio when scaled
+	// to screenshots of another aspect ratio, only the point moves proportionally (see Scale).
+	Origin *image.Point
+
+	// spec - crop as written, before it was resolved to pixels (see OCRTemplate.resolveCrops)
+	spec *cropSpec
 }
 
 func (b *OCRCrop) CropRectangle() image.Rectangle {
 	return image.Rect(b.X, b.Y, b.X+b.W, b.Y+b.H)
 }
 
+// Pixels - copy of the crop in plain pixels, the way it's recorded in results
+func (b *OCRCrop) Pixels() *OCRCrop {
+	if b == nil {
+		return nil
+	}
+	return &OCRCrop{X: b.X, Y: b.Y, W: b.W, H: b.H}
+}
+
+// ParseCrop - crop written as "x,y,w,h" (pixels), e.g. on command line
+func ParseCrop(s string) (*OCRCrop, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid crop %q, expected x,y,w,h", s)
+	}
+
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid crop %q, expected x,y,w,h", s)
+		}
+		v[i] = n
+	}
+	return &OCRCrop{X: v[0], Y: v[1], W: v[2], H: v[3]}, nil
+}
+
+// MarshalJSON - crops are written the way they were read, until they're changed (moved, scaled, edited)
 func (b *OCRCrop) MarshalJSON() ([]byte, error) {
+	if s := b.spec; s != nil && (!s.resolved || s.rect == b.CropRectangle()) {
+		if s.object() {
+			return json.Marshal(struct {
+				From string   `json:"from"`
+				X    OCRValue `json:"x"`
+				Y    OCRValue `json:"y"`
+				W    OCRValue `json:"w"`
+				H    OCRValue `json:"h"`
+			}{s.From, s.Values[0], s.Values[1], s.Values[2], s.Values[3]})
+		}
+		return json.Marshal(s.Values)
+	}
 	return json.Marshal([]int{b.X, b.Y, b.W, b.H})
 }
 
 func (b *OCRCrop) UnmarshalJSON(data []byte) error {
+	*b = OCRCrop{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v struct {
+			From string   `json:"from"`
+			X    OCRValue `json:"x"`
+			Y    OCRValue `json:"y"`
+			W    OCRValue `json:"w"`
+			H    OCRValue `json:"h"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		b.spec = &cropSpec{From: v.From, Values: [4]OCRValue{v.X, v.Y, v.W, v.H}}
+		return nil
+	}
 
-	var v []interface{}
+	var v []OCRValue
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
+	if len(v) != 4 {
+		return fmt.Errorf("crop has %d values, expected [x, y, w, h]", len(v))
+	}
+
+	relative := false
+	for _, x := range v {
+		relative = relative || x.Percent
+	}
+	if relative {
+		b.spec = &cropSpec{Values: [4]OCRValue{v[0], v[1], v[2], v[3]}}
+		return nil
+	}
 
-	b.X = int(v[0].(float64))
-	b.Y = int(v[1].(float64))
-	b.W = int(v[2].(float64))
-	b.H = int(v[3].(float64))
+	b.X = int(v[0].Value)
+	b.Y = int(v[1].Value)
+	b.W = int(v[2].Value)
+	b.H = int(v[3].Value)
 
 	return nil
 }
@@ -0,0 +1,88 @@
+package ocrschema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir - changes working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvePathIndependentOfWorkingDirectory(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := filepath.Join(root, "templates")
+	writeFile(t, filepath.Join(templates, "profile.json"), `{
+		"title": "Profile",
+		"width": 100,
+		"height": 100,
+		"roster_file": "rosters/kingdom.txt",
+		"reference_image": "profile.png"
+	}`)
+	writeFile(t, filepath.Join(templates, "rosters", "kingdom.txt"), "PlayerOne\n")
+	writeFile(t, filepath.Join(templates, "profile.png"), "")
+
+	load := func(wd, file string) OCRTemplate {
+		chdir(t, wd)
+		template, err := LoadTemplate(file)
+		if err != nil {
+			t.Fatalf("LoadTemplate(%v) from %v: %v", file, wd, err)
+		}
+		return template
+	}
+
+	fromRoot := load(root, filepath.Join("templates", "profile.json"))
+	fromTemplates := load(templates, "profile.json")
+
+	if got, want := fromRoot.RosterPath(), filepath.Join(templates, "rosters", "kingdom.txt"); got != want {
+		t.Errorf("RosterPath() = %v, want %v", got, want)
+	}
+	if fromRoot.RosterPath() != fromTemplates.RosterPath() {
+		t.Errorf("RosterPath() differs by working directory: %v vs %v", fromRoot.RosterPath(), fromTemplates.RosterPath())
+	}
+	if fromRoot.ReferenceImagePath() != fromTemplates.ReferenceImagePath() {
+		t.Errorf("ReferenceImagePath() differs by working directory: %v vs %v", fromRoot.ReferenceImagePath(), fromTemplates.ReferenceImagePath())
+	}
+
+	// absolute paths are kept as they are
+	abs := filepath.Join(root, "elsewhere.txt")
+	if got := fromRoot.ResolvePath(abs); got != abs {
+		t.Errorf("ResolvePath(%v) = %v", abs, got)
+	}
+}
+
+func TestValidateReportsMissingRoster(t *testing.T) {
+	dir := t.TempDir()
+	template := OCRTemplate{BaseDir: dir, RosterFile: "missing.txt"}
+
+	errs, _ := template.Validate().(ValidationErrors)
+	for _, e := range errs {
+		if e.Path == "roster_file" {
+			return
+		}
+	}
+	t.Errorf("expected roster_file error, got %v", errs)
+}
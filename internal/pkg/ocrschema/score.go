@@ -0,0 +1,128 @@
+package ocrschema
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/corona10/goimagehash"
+)
+
+// TemplateScore - how well an image fits the template
+type TemplateScore struct {
+	Template OCRTemplate
+	// Distance - of image hash to template fingerprint, math.MaxInt if they can't be compared
+	Distance           int
+	Checkpoints        int
+	CheckpointsMatched int
+	Matches            bool
+}
+
+// checkpointRatio - share of matched checkpoints, templates without checkpoints have 0
+func (s TemplateScore) checkpointRatio() float64 {
+	if s.Checkpoints == 0 {
+		return 0
+	}
+	return float64(s.CheckpointsMatched) / float64(s.Checkpoints)
+}
+
+// better - matching templates first, then more checkpoint hits (checkpoints tell apart similar screens,
+// e.g. governor profile vs. more info), then closer fingerprint
+func (s TemplateScore) better(o TemplateScore) bool {
+	if s.Matches != o.Matches {
+		return s.Matches
+	}
+	if a, b := s.checkpointRatio(), o.checkpointRatio(); a != b {
+		return a > b
+	}
+	if s.Distance != o.Distance {
+		return s.Distance < o.Distance
+	}
+	return templateLess(s.Template, o.Template)
+}
+
+// Score - fingerprint distance & checkpoint hits of the image
+func (b *OCRTemplate) Score(img image.Image) TemplateScore {
+	hash, err := b.ImageHash(img)
+	return b.score(img, hash, err)
+}
+
+func (b *OCRTemplate) score(img image.Image, hash *goimagehash.ImageHash, hashErr error) TemplateScore {
+	score := TemplateScore{Template: *b, Distance: math.MaxInt, Checkpoints: len(b.Checkpoints)}
+
+	if expected, err := b.ParseHash(); err == nil && hashErr == nil {
+		if distance, err := expected.Distance(hash); err == nil {
+			score.Distance = distance
+		}
+	}
+
+	if len(b.Checkpoints) > 0 {
+		score.CheckpointsMatched, score.Matches = b.checkpointHits(img)
+	} else {
+		score.Matches = score.Distance <= b.Threshold
+	}
+	if score.Matches && b.excluded(img) {
+		score.Matches = false
+	}
+
+	return score
+}
+
+// RankTemplates - scores the image against every template, best first
+func RankTemplates(img image.Image, availableTemplate []OCRTemplate) []TemplateScore {
+	type hashed struct {
+		hash *goimagehash.ImageHash
+		err  error
+	}
+	hashes := make(map[string]hashed)
+
+	scores := make([]TemplateScore, 0, len(availableTemplate))
+	for _, t := range availableTemplate {
+		h, ok := hashes[t.HashAlgo]
+		if !ok {
+			h.hash, h.err = t.ImageHash(img)
+			hashes[t.HashAlgo] = h
+		}
+		scores = append(scores, t.score(img, h.hash, h.err))
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].better(scores[j])
+	})
+	return scores
+}
+
+// TemplateMatch - why the template was picked for the result, and how close the next best one was
+type TemplateMatch struct {
+	// Distance - -1 if fingerprint couldn't be compared
+	Distance           int    `json:"distance"`
+	Checkpoints        int    `json:"checkpoints,omitempty"`
+	CheckpointsMatched int    `json:"checkpoints_matched,omitempty"`
+	RunnerUp           string `json:"runner_up,omitempty"`
+	RunnerUpDistance   int    `json:"runner_up_distance,omitempty"`
+}
+
+// NewTemplateMatch - summary of RankTemplates result
+func NewTemplateMatch(scores []TemplateScore) *TemplateMatch {
+	if len(scores) == 0 {
+		return nil
+	}
+
+	distance := func(d int) int {
+		if d == math.MaxInt {
+			return -1
+		}
+		return d
+	}
+
+	m := &TemplateMatch{
+		Distance:           distance(scores[0].Distance),
+		Checkpoints:        scores[0].Checkpoints,
+		CheckpointsMatched: scores[0].CheckpointsMatched,
+	}
+	if len(scores) > 1 {
+		m.RunnerUp = scores[1].Template.Title
+		m.RunnerUpDistance = distance(scores[1].Distance)
+	}
+	return m
+}
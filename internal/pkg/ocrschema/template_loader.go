@@ -1,56 +1,217 @@
 package ocrschema
 
 import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
-	"path/filepath"
 
 	"github.com/corona10/goimagehash"
 	log "github.com/sirupsen/logrus"
 )
 
+// TemplateLoadError - template file which was skipped, and why
+type TemplateLoadError struct {
+	File string
+	Err  error
+}
+
+func (e TemplateLoadError) Error() string {
+	return fmt.Sprintf("%v: %v", filepath.Base(e.File), e.Err)
+}
+
+func (e TemplateLoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadTemplates - loads all templates in directory, broken ones are logged & skipped
 func LoadTemplates(directory string) []OCRTemplate {
+	templates, skipped := LoadTemplatesWithErrors(directory)
+	for _, e := range skipped {
+		log.Errorf("Failed to load template: %v => %v", filepath.Base(e.File), e.Err)
+	}
+	return templates
+}
+
+// LoadTemplatesWithErrors - loads all JSON & binary templates in directory, and reports the ones which were skipped.
+// A template compiled next to its JSON (same name) is loaded once: from the binary if it's up to date, from JSON otherwise.
+// Validation problems are only logged, such templates are still loaded. Abstract templates (bases of others) are left out.
+func LoadTemplatesWithErrors(directory string) ([]OCRTemplate, []TemplateLoadError) {
 	var templates []OCRTemplate
-	for _, f := range fileutils.GetFilesInDirectory(directory) {
-		if filepath.Ext(f) == ".json" {
-			template, err := LoadTemplate(f)
-			if err == nil {
-				log.Debugf("Loaded template: %s => %s, hash: %s", f, template.Title, template.Fingerprint)
-				templates = append(templates, template)
-			} else {
-				log.Errorf("Failed to load template: %v => %v", filepath.Base(f), err)
+	var skipped []TemplateLoadError
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, []TemplateLoadError{{File: directory, Err: err}}
+	}
+
+	for _, f := range templateFiles(directory, entries) {
+		var load func(string) (OCRTemplate, error)
+		switch filepath.Ext(f) {
+		case ".json":
+			load = LoadTemplate
+		case TemplateBinaryExt:
+			load = LoadTemplateBinary
+		}
+
+		template, err := load(f)
+		if err == nil && template.Abstract {
+			log.Debugf("Skipped abstract template: %s", f)
+		} else if err == nil {
+			log.Debugf("Loaded template: %s => %s, hash: %s", f, template.Title, template.Fingerprint)
+			if errs, ok := template.Validate().(ValidationErrors); ok {
+				for _, e := range errs {
+					log.Warnf("[%v] %v", filepath.Base(f), e)
+				}
 			}
+			templates = append(templates, template)
+		} else {
+			skipped = append(skipped, TemplateLoadError{File: f, Err: err})
 		}
 	}
-	return templates
+	// directory listing order depends on the filesystem, keep it stable
+	SortTemplates(templates)
+
+	return templates, skipped
+}
+
+// templateFiles - template files of the directory, one per name: the compiled one, unless its JSON was changed since
+func templateFiles(directory string, entries []os.DirEntry) []string {
+	modified := func(f string) time.Time {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		f := filepath.Join(directory, entry.Name())
+		base := strings.TrimSuffix(f, filepath.Ext(f))
+
+		switch filepath.Ext(f) {
+		case ".json":
+			if binary := base + TemplateBinaryExt; !modified(binary).Before(modified(f)) {
+				log.Debugf("Template %v is compiled, loading %v", filepath.Base(f), filepath.Base(binary))
+				continue
+			}
+		case TemplateBinaryExt:
+			if source := base + ".json"; modified(f).Before(modified(source)) {
+				log.Debugf("Compiled template %v is older than its JSON, ignored", filepath.Base(f))
+				continue
+			}
+		default:
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// SortTemplates - stable order of templates, by title & version
+func SortTemplates(templates []OCRTemplate) {
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templateLess(templates[i], templates[j])
+	})
 }
 
 func FindTemplate(mediaDir string, availableTemplate []OCRTemplate) OCRTemplate {
-	for _, file := range fileutils.GetFilesInDirectory(mediaDir) {
+	return FindTemplateWithOptions(mediaDir, fileutils.DefaultImageListOptions(), availableTemplate)
+}
+
+func FindTemplateWithOptions(mediaDir string, list fileutils.ListOptions, availableTemplate []OCRTemplate) OCRTemplate {
+	for _, file := range fileutils.ListFiles(mediaDir, list) {
 		img, err := imgutils.ReadImageFile(file)
 		if err != nil {
 			log.Debugf("[%s] => error: %v", filepath.Base(file), err)
 			continue
 		}
 
-		imagehash, _ := goimagehash.DifferenceHash(img)
-		template := PickTemplate(imagehash, availableTemplate)
-		return template
+		return RankTemplates(imgutils.CropBorders(img), availableTemplate)[0].Template
 	}
 	// pick first template if no images found?
 	return availableTemplate[0]
 }
 
+// PickTemplate - closest template by fingerprint. Templates using different hash algorithm
+// than the given hash can't be compared, and are only picked if nothing else is available.
 func PickTemplate(hash *goimagehash.ImageHash, availableTemplate []OCRTemplate) OCRTemplate {
+	return pickTemplate(func(t OCRTemplate) (*goimagehash.ImageHash, error) {
+		return hash, nil
+	}, availableTemplate)
+}
+
+// PickTemplateForImage - same as PickTemplate, but image is hashed with algorithm of each template
+func PickTemplateForImage(img image.Image, availableTemplate []OCRTemplate) OCRTemplate {
+	hashes := make(map[string]*goimagehash.ImageHash)
+	return pickTemplate(func(t OCRTemplate) (*goimagehash.ImageHash, error) {
+		if h, ok := hashes[t.HashAlgo]; ok {
+			return h, nil
+		}
+		h, err := t.ImageHash(img)
+		if err == nil {
+			hashes[t.HashAlgo] = h
+		}
+		return h, err
+	}, availableTemplate)
+}
+
+func pickTemplate(hashFor func(t OCRTemplate) (*goimagehash.ImageHash, error), availableTemplate []OCRTemplate) OCRTemplate {
+	distanceOf := func(t OCRTemplate) int {
+		hash, err := hashFor(t)
+		if err != nil {
+			return math.MaxInt
+		}
+		expected, err := t.ParseHash()
+		if err != nil {
+			return math.MaxInt
+		}
+		distance, err := expected.Distance(hash)
+		if err != nil {
+			return math.MaxInt
+		}
+		return distance
+	}
+
 	best := availableTemplate[0]
+	bestDistance := distanceOf(best)
 
-	for _, t := range availableTemplate {
-		distance, _ := t.Hash().Distance(hash)
-		bestDistance, _ := best.Hash().Distance(hash)
-		if distance < bestDistance {
+	for _, t := range availableTemplate[1:] {
+		distance := distanceOf(t)
+		if distance < bestDistance || (distance == bestDistance && templateLess(t, best)) {
 			best = t
+			bestDistance = distance
 		}
 	}
 
 	return best
 }
+
+// templateLess - deterministic tie-break between equally good templates: by title, then by version
+func templateLess(a, b OCRTemplate) bool {
+	if a.Title != b.Title {
+		return a.Title < b.Title
+	}
+	return a.Version < b.Version
+}
+
+// SelectTemplate - picks best scoring template (see RankTemplates), and tells if it really matches
+func SelectTemplate(img image.Image, availableTemplate []OCRTemplate) (OCRTemplate, bool) {
+	if len(availableTemplate) == 0 {
+		return OCRTemplate{}, false
+	}
+
+	best := RankTemplates(img, availableTemplate)[0]
+	return best.Template, best.Matches
+}
@@ -0,0 +1,107 @@
+package ocrschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// testBatch - screenshots: the test screen, its negative, and a plain gradient
+func testBatch() []image.Image {
+	screen := testScreen()
+	negative := image.NewGray(screen.Bounds())
+	gradient := image.NewGray(screen.Bounds())
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			v := screen.(*image.Gray).GrayAt(x, y).Y
+			negative.SetGray(x, y, color.Gray{Y: 255 - v})
+			gradient.SetGray(x, y, color.Gray{Y: uint8(x + y/2)})
+		}
+	}
+	return []image.Image{screen, negative, gradient}
+}
+
+// testTemplates - several templates at the same distance of every screenshot: same fingerprints under
+// different titles, and versions of the same title
+func testTemplates(t *testing.T) []OCRTemplate {
+	t.Helper()
+	var templates []OCRTemplate
+	for i, img := range testBatch()[:2] {
+		fingerprint, err := Fingerprint(img, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, title := range []string{"Zeta", "Alpha", "Mid"} {
+			for _, version := range []string{"2", "1", ""} {
+				templates = append(templates, OCRTemplate{
+					Title:       fmt.Sprintf("%v %d", title, i),
+					Version:     version,
+					Width:       200,
+					Height:      100,
+					Fingerprint: fingerprint,
+					Threshold:   3,
+				})
+			}
+		}
+	}
+	return templates
+}
+
+// classify - output of the batch: picked template & ranking of every screenshot
+func classify(t *testing.T, batch []image.Image, templates []OCRTemplate) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	SortTemplates(templates)
+	for _, x := range templates {
+		fmt.Fprintf(&out, "sorted %v v%v\n", x.Title, x.Version)
+	}
+	for i, img := range batch {
+		picked := PickTemplateForImage(img, templates)
+		fmt.Fprintf(&out, "%d picked %v v%v\n", i, picked.Title, picked.Version)
+
+		scores := RankTemplates(img, templates)
+		for _, s := range scores {
+			fmt.Fprintf(&out, "%d ranked %v v%v %v %v\n", i, s.Template.Title, s.Template.Version, s.Distance, s.Matches)
+		}
+		match, err := json.Marshal(NewTemplateMatch(scores))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&out, "%d match %s\n", i, match)
+	}
+	return out.Bytes()
+}
+
+func TestClassificationIsReproducible(t *testing.T) {
+	batch := testBatch()
+	expected := classify(t, batch, testTemplates(t))
+
+	// the same batch again, with templates in other orders (as listed by another filesystem)
+	rnd := rand.New(rand.NewSource(1))
+	for run := 0; run < 20; run++ {
+		templates := testTemplates(t)
+		rnd.Shuffle(len(templates), func(i, j int) { templates[i], templates[j] = templates[j], templates[i] })
+
+		if got := classify(t, batch, templates); !bytes.Equal(got, expected) {
+			t.Fatalf("run %d differs:\n%s\nexpected:\n%s", run, got, expected)
+		}
+	}
+}
+
+func TestPickTemplateTieBreak(t *testing.T) {
+	img := testBatch()[0]
+	templates := testTemplates(t)
+
+	for i := range templates {
+		// rotate, so every template is first once
+		rotated := append(append([]OCRTemplate{}, templates[i:]...), templates[:i]...)
+		picked := PickTemplateForImage(img, rotated)
+		if picked.Title != "Alpha 0" || picked.Version != "" {
+			t.Fatalf("PickTemplateForImage() with %v first = %v v%v, want Alpha 0 v", templates[i].Title, picked.Title, picked.Version)
+		}
+	}
+}
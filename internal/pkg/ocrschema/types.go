@@ -0,0 +1,74 @@
+package ocrschema
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+)
+
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeFloat    = "float"
+	TypeDuration = "duration"
+	TypePercent  = "percent"
+)
+
+var FieldTypes = []string{TypeString, TypeInt, TypeFloat, TypeDuration, TypePercent}
+
+func isKnownType(t string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, x := range FieldTypes {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// TypedValue - converts recognized text into value of schema Type:
+// int => int64, float & percent => float64 (percent as shown, "12.5%" => 12.5), duration => int64 seconds.
+// Untyped (or string) fields are returned as is.
+func (s *OCRSchema) TypedValue(text string) (interface{}, error) {
+	switch s.Type {
+	case "", TypeString:
+		return text, nil
+	case TypeInt:
+		f, err := stringutils.ParseNumberWithDecimal(text, s.DecimalSeparator)
+		if err != nil {
+			return nil, err
+		}
+		return int64(math.Round(f)), nil
+	case TypeFloat:
+		return stringutils.ParseNumberWithDecimal(text, s.DecimalSeparator)
+	case TypePercent:
+		return stringutils.ParseNumberWithDecimal(strings.TrimSuffix(strings.TrimSpace(text), "%"), s.DecimalSeparator)
+	case TypeDuration:
+		d, err := stringutils.ParseDuration(text)
+		if err != nil {
+			return nil, err
+		}
+		return int64(d.Seconds()), nil
+	}
+
+	return nil, fmt.Errorf("unknown type: %q", s.Type)
+}
+
+// FormatValue - formats typed value for CSV & tables, avoids exponent notation of big floats
+func FormatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
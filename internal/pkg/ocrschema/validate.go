@@ -0,0 +1,269 @@
+package ocrschema
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// ValidationError - a single problem found in a template, Path points to the offending element,
+// Line is set only when validating the JSON file (see ValidateTemplateJSON)
+type ValidationError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidateOptions - checks needing environment of the template
+type ValidateOptions struct {
+	// Languages - available tesseract languages, fields using other ones are reported. Not checked if empty.
+	Languages []string
+	// Engines - supported OCR engines, fields using other ones are reported. Not checked if empty.
+	Engines []string
+}
+
+// tesseract accepts psm 0-13 & oem 0-3, template uses 0 as "default"
+const (
+	maxPSM = 13
+	maxOEM = 3
+)
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	var lines []string
+	for _, x := range e {
+		lines = append(lines, x.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate - checks the template for mistakes, which otherwise pop up deep inside a batch.
+// Returns nil or ValidationErrors.
+func (b *OCRTemplate) Validate() error {
+	return b.ValidateWithOptions(ValidateOptions{})
+}
+
+func (b *OCRTemplate) ValidateWithOptions(opts ValidateOptions) error {
+	var errs ValidationErrors
+
+	if _, err := hashKind(b.HashAlgo); err != nil {
+		errs = append(errs, ValidationError{Path: "hash_algo", Message: fmt.Sprintf("%v, expected one of: %s", err, strings.Join(HashAlgorithms, ", "))})
+	} else {
+		if len(b.Fingerprint) == 0 {
+			errs = append(errs, ValidationError{Path: "fingerprint", Message: "fingerprint is missing, template can be used only when forced"})
+		} else if _, err := HashFromString(b.Fingerprint, b.HashAlgo); err != nil {
+			errs = append(errs, ValidationError{Path: "fingerprint", Message: err.Error()})
+		}
+		for i, c := range b.Checkpoints {
+			if _, err := HashFromString(c.Fingerprint, b.HashAlgo); err != nil {
+				errs = append(errs, ValidationError{Path: fmt.Sprintf("checkpoints[%d].fingerprint", i), Message: err.Error()})
+			}
+		}
+		for i, c := range b.MustNotMatch {
+			if _, err := HashFromString(c.Fingerprint, b.HashAlgo); err != nil {
+				errs = append(errs, ValidationError{Path: fmt.Sprintf("must_not_match[%d].fingerprint", i), Message: err.Error()})
+			}
+		}
+	}
+
+	// relative to template file, missing ones are typically paths relative to where the template was made
+	for _, f := range []struct{ path, file string }{{"roster_file", b.RosterPath()}, {"reference_image", b.ReferenceImagePath()}} {
+		if len(f.file) == 0 {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			errs = append(errs, ValidationError{Path: f.path, Message: fmt.Sprintf("can't read %v (relative paths are resolved against the template's directory)", f.file)})
+		}
+	}
+
+	if b.Width <= 0 || b.Height <= 0 {
+		errs = append(errs, ValidationError{Path: "width", Message: fmt.Sprintf("invalid resolution %vx%v", b.Width, b.Height)})
+	}
+
+	if b.CheckpointQuorum < 0 || b.CheckpointQuorum > len(b.Checkpoints) {
+		errs = append(errs, ValidationError{Path: "checkpoint_quorum", Message: fmt.Sprintf("quorum %d is out of 0-%d checkpoints", b.CheckpointQuorum, len(b.Checkpoints))})
+	}
+
+	for i, c := range b.Checkpoints {
+		if c.Threshold < 0 {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("checkpoints[%d].threshold", i), Message: "threshold can't be negative"})
+		} else if c.Exact && c.Threshold > 0 {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("checkpoints[%d].threshold", i), Message: fmt.Sprintf("threshold %d of an exact checkpoint", c.Threshold)})
+		}
+		if err := b.validateCrop(c.Crop); err != nil {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("checkpoints[%d].crop", i), Message: err.Error()})
+		}
+	}
+
+	for i, c := range b.MustNotMatch {
+		if err := b.validateCrop(c.Crop); err != nil {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("must_not_match[%d].crop", i), Message: err.Error()})
+		}
+	}
+
+	errs = append(errs, b.validateComputed()...)
+	errs = append(errs, b.validateChecks()...)
+	errs = append(errs, b.validateAnchors()...)
+	errs = append(errs, b.validatePoints()...)
+	errs = append(errs, b.validateRows()...)
+	errs = append(errs, b.validateRecord()...)
+
+	fields := make(map[string]bool)
+	for i, t := range b.Table {
+		path := fmt.Sprintf("table[%d] (%s)", i, t.Title)
+		if len(strings.TrimSpace(t.Field)) == 0 {
+			errs = append(errs, ValidationError{Path: path + ".field", Message: "field is empty"})
+		} else if _, ok := b.OCRSchema[t.Field]; !ok && len(b.Computed[t.Field]) == 0 {
+			errs = append(errs, ValidationError{Path: path + ".field", Message: fmt.Sprintf("unknown field %q, not in ocr_schema or computed", t.Field)})
+		} else if fields[t.Field] {
+			errs = append(errs, ValidationError{Path: path + ".field", Message: fmt.Sprintf("field %q is listed more than once", t.Field)})
+		}
+		fields[t.Field] = true
+
+		if len(strings.TrimSpace(t.Color)) == 0 {
+			continue
+		}
+		if _, err := imgutils.ParseColor(t.Color); err != nil {
+			errs = append(errs, ValidationError{
+				Path:    path + ".color",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for _, k := range b.FieldKeys() {
+		s := b.OCRSchema[k]
+		if s.Crop == nil {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("ocr_schema.%s.crop", k), Message: "crop is missing"})
+		} else if err := b.validateCrop(s.Crop); err != nil {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("ocr_schema.%s.crop", k), Message: err.Error()})
+		}
+		if s.PSM < 0 || s.PSM > maxPSM {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("ocr_schema.%s.psm", k), Message: fmt.Sprintf("invalid page segmentation mode %d, expected 0-%d", s.PSM, maxPSM)})
+		}
+		if s.OEM < 0 || s.OEM > maxOEM {
+			errs = append(errs, ValidationError{Path: fmt.Sprintf("ocr_schema.%s.oem", k), Message: fmt.Sprintf("invalid engine mode %d, expected 0-%d", s.OEM, maxOEM)})
+		}
+		for i, entry := range s.AllowList {
+			if unknownCharset(entry) {
+				errs = append(errs, ValidationError{
+					Path:    fmt.Sprintf("ocr_schema.%s.allowlist[%d]", k, i),
+					Message: fmt.Sprintf("unknown charset %q, expected one of: %s (or list literal characters one by one)", entry, strings.Join(AllowListCharsets(), ", ")),
+				})
+			}
+		}
+		if len(s.Engine) > 0 && len(opts.Engines) > 0 && !containsString(opts.Engines, s.Engine) {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("ocr_schema.%s.engine", k),
+				Message: fmt.Sprintf("unknown engine %q, expected one of: %s", s.Engine, strings.Join(opts.Engines, ", ")),
+			})
+		}
+		if len(opts.Languages) > 0 {
+			if len(s.Model) > 0 && !containsString(opts.Languages, s.Model) {
+				errs = append(errs, ValidationError{
+					Path:    fmt.Sprintf("ocr_schema.%s.model", k),
+					Message: fmt.Sprintf("model %q is not available, expected %s.traineddata in tessdata dir", s.Model, s.Model),
+				})
+			}
+			for i, l := range s.Languages {
+				if !containsString(opts.Languages, l) {
+					errs = append(errs, ValidationError{
+						Path:    fmt.Sprintf("ocr_schema.%s.lang[%d]", k, i),
+						Message: fmt.Sprintf("language %q is not available, have: %s", l, strings.Join(opts.Languages, ", ")),
+					})
+				}
+			}
+			for i, fallback := range s.LanguageFallback {
+				for _, l := range strings.Split(fallback, "+") {
+					if !containsString(opts.Languages, strings.TrimSpace(l)) {
+						errs = append(errs, ValidationError{
+							Path:    fmt.Sprintf("ocr_schema.%s.lang_fallback[%d]", k, i),
+							Message: fmt.Sprintf("language %q is not available, have: %s", l, strings.Join(opts.Languages, ", ")),
+						})
+					}
+				}
+			}
+		}
+		if len(s.Pattern) > 0 {
+			if _, err := regexp.Compile(s.Pattern); err != nil {
+				errs = append(errs, ValidationError{
+					Path:    fmt.Sprintf("ocr_schema.%s.pattern", k),
+					Message: err.Error(),
+				})
+			}
+		}
+		for i, step := range s.Preprocess {
+			if _, _, err := parsePreprocessStep(step); err != nil {
+				errs = append(errs, ValidationError{
+					Path:    fmt.Sprintf("ocr_schema.%s.preprocess[%d]", k, i),
+					Message: err.Error(),
+				})
+			}
+		}
+		if s.Vote < 0 || s.Vote > MaxVote {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("ocr_schema.%s.vote", k),
+				Message: fmt.Sprintf("vote %d is out of range 0-%d", s.Vote, MaxVote),
+			})
+		}
+		if !isKnownType(s.Type) {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("ocr_schema.%s.type", k),
+				Message: fmt.Sprintf("unknown type %q, expected one of: %s", s.Type, strings.Join(FieldTypes, ", ")),
+			})
+		}
+		if sep := s.DecimalSeparator; len(sep) > 0 && sep != "." && sep != "," {
+			errs = append(errs, ValidationError{
+				Path:    fmt.Sprintf("ocr_schema.%s.decimal_separator", k),
+				Message: fmt.Sprintf("invalid decimal separator %q, expected \".\" or \",\"", sep),
+			})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateCrop - crop has to be non-empty, and inside template resolution (if it's known)
+func (b *OCRTemplate) validateCrop(c *OCRCrop) error {
+	if c == nil {
+		return fmt.Errorf("crop is missing")
+	}
+	if c.spec != nil {
+		if _, _, err := b.cropRect(c.spec, true); err != nil {
+			return err
+		}
+	}
+	if c.W <= 0 || c.H <= 0 {
+		return fmt.Errorf("empty crop %vx%v", c.W, c.H)
+	}
+	if c.X < 0 || c.Y < 0 {
+		return fmt.Errorf("crop starts outside of the image at %v,%v", c.X, c.Y)
+	}
+	if b.Width > 0 && b.Height > 0 && (c.X+c.W > b.Width || c.Y+c.H > b.Height) {
+		return fmt.Errorf("crop %v,%v+%vx%v is outside of %vx%v", c.X, c.Y, c.W, c.H, b.Width, b.Height)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
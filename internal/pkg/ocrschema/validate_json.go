@@ -0,0 +1,212 @@
+package ocrschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ValidateTemplateJSON - validates template file contents: JSON syntax, duplicate keys (silently dropped by
+// json.Unmarshal) and everything ValidateWithOptions checks. Errors point to line in the file.
+func ValidateTemplateJSON(data []byte, opts ValidateOptions) ValidationErrors {
+	return validateTemplateJSON(data, func() ([]byte, error) {
+		migrated, _, err := MigrateTemplateJSON(data)
+		return migrated, err
+	}, opts)
+}
+
+// ValidateTemplateFile - same as ValidateTemplateJSON, of the template with its base & includes merged in
+// (see ReadTemplateJSON). Problems of inherited parts have no line. Abstract templates are only checked for
+// JSON mistakes, they aren't complete on their own.
+func ValidateTemplateFile(fileName string, opts ValidateOptions) ValidationErrors {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return ValidationErrors{{Path: "(file)", Message: err.Error()}}
+	}
+	return validateTemplateJSON(data, func() ([]byte, error) {
+		merged, _, err := ReadTemplateJSON(fileName)
+		return merged, err
+	}, opts)
+}
+
+// validateTemplateJSON - merged returns migrated template JSON of data
+func validateTemplateJSON(data []byte, merged func() ([]byte, error), opts ValidateOptions) ValidationErrors {
+	lines := newLineIndex(data)
+
+	positions, duplicates, err := jsonPositions(data)
+	if err != nil {
+		return ValidationErrors{jsonError(err, lines)}
+	}
+
+	var errs ValidationErrors
+	for _, d := range duplicates {
+		errs = append(errs, ValidationError{Path: d.path, Line: lines.line(d.offset), Message: "duplicate key, only the last one is used"})
+	}
+
+	if _, _, err := MigrateTemplateJSON(data); err != nil {
+		return append(errs, ValidationError{Path: "schema_version", Line: lines.line(positions["schema_version"]), Message: err.Error()})
+	}
+	migrated, err := merged()
+	if err != nil {
+		return append(errs, ValidationError{Path: extendsKey, Line: lines.line(positions[extendsKey]), Message: err.Error()})
+	}
+
+	var t OCRTemplate
+	if err := json.Unmarshal(migrated, &t); err != nil {
+		return append(errs, jsonError(err, lines))
+	}
+	if t.Abstract {
+		return errs
+	}
+
+	if e, ok := t.ValidateWithOptions(opts).(ValidationErrors); ok {
+		for _, x := range e {
+			x.Line = lines.line(positions.find(x.Path))
+			errs = append(errs, x)
+		}
+	}
+
+	return errs
+}
+
+func jsonError(err error, lines lineIndex) ValidationError {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return ValidationError{Path: "(json)", Line: lines.line(syntax.Offset), Message: syntax.Error()}
+	}
+
+	var typed *json.UnmarshalTypeError
+	if errors.As(err, &typed) {
+		return ValidationError{Path: typed.Field, Line: lines.line(typed.Offset), Message: fmt.Sprintf("expected %v, got %v", typed.Type, typed.Value)}
+	}
+
+	return ValidationError{Path: "(json)", Message: err.Error()}
+}
+
+type lineIndex []int64
+
+func newLineIndex(data []byte) lineIndex {
+	starts := lineIndex{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, int64(i+1))
+		}
+	}
+	return starts
+}
+
+// line - 1-based line of the offset, 0 if offset is unknown
+func (l lineIndex) line(offset int64) int {
+	if offset < 0 {
+		return 0
+	}
+	for i := len(l) - 1; i >= 0; i-- {
+		if offset >= l[i] {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// jsonPaths - offset of every value, keyed by path like ocr_schema.kills.crop or table[0][3]
+type jsonPaths map[string]int64
+
+var pathDecoration = regexp.MustCompile(` \([^)]*\)`)
+
+// find - offset of the path, or its closest known parent (-1 if none). Decorations like "table[0] (Kills)" are ignored.
+func (p jsonPaths) find(path string) int64 {
+	path = pathDecoration.ReplaceAllString(path, "")
+	for len(path) > 0 {
+		if offset, ok := p[path]; ok {
+			return offset
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return -1
+}
+
+type duplicateKey struct {
+	path   string
+	offset int64
+}
+
+func jsonPositions(data []byte) (jsonPaths, []duplicateKey, error) {
+	positions := make(jsonPaths)
+	var duplicates []duplicateKey
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// decoder offset is at the end of previous token, skip to the start of the next one
+	next := func() int64 {
+		offset := dec.InputOffset()
+		for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n:,", rune(data[offset])) {
+			offset++
+		}
+		return offset
+	}
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		offset := next()
+		if len(path) > 0 {
+			positions[path] = offset
+		}
+
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			seen := make(map[string]bool)
+			for dec.More() {
+				keyOffset := next()
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := key.(string)
+				child := name
+				if len(path) > 0 {
+					child = path + "." + name
+				}
+				if seen[name] {
+					duplicates = append(duplicates, duplicateKey{path: child, offset: keyOffset})
+				}
+				seen[name] = true
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("unexpected data after the template")
+	}
+	return positions, duplicates, nil
+}
@@ -0,0 +1,44 @@
+package ocrschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTableColor(t *testing.T) {
+	tests := []struct {
+		color string
+		valid bool
+	}{
+		{"", true},
+		{"#ff8000", true},
+		{"#f80", true},
+		{"gold", true},
+		{"#ff80zz", false},
+		{"#ff80", false},
+		{"sunset", false},
+	}
+	for _, tt := range tests {
+		template := OCRTemplate{
+			OCRSchema: map[string]OCRSchema{"power": {Crop: &OCRCrop{W: 10, H: 10}}},
+			Table:     []OCRTableField{{Title: "Power", Field: "power", Color: tt.color}},
+		}
+
+		var colorErr *ValidationError
+		errs, _ := template.Validate().(ValidationErrors)
+		for i, e := range errs {
+			if strings.HasSuffix(e.Path, ".color") {
+				colorErr = &errs[i]
+			}
+		}
+
+		switch {
+		case tt.valid && colorErr != nil:
+			t.Errorf("color %q: unexpected error %v", tt.color, colorErr)
+		case !tt.valid && colorErr == nil:
+			t.Errorf("color %q: expected an error, got %v", tt.color, errs)
+		case !tt.valid && !strings.Contains(colorErr.Error(), "(Power)"):
+			t.Errorf("color %q: error %q doesn't name the column", tt.color, colorErr)
+		}
+	}
+}
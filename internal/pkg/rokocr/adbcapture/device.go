@@ -0,0 +1,88 @@
+package adbcapture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	adb "github.com/zach-klippenstein/goadb"
+)
+
+// Device - the part of adb device we need, *adb.Device satisfies it
+type Device interface {
+	RunCommand(cmd string, args ...string) (string, error)
+}
+
+var _ Device = (*adb.Device)(nil)
+
+// Connect - starts (if needed) the adb server and returns device by serial, or the only connected one if serial is empty
+func Connect(port int, serial string) (*adb.Device, error) {
+	client, err := adb.NewWithConfig(adb.ServerConfig{Port: port})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.StartServer(); err != nil {
+		return nil, fmt.Errorf("can't start adb server: %v", err)
+	}
+
+	if len(serial) > 0 {
+		return client.Device(adb.DeviceWithSerial(serial)), nil
+	}
+
+	serials, err := client.ListDeviceSerials()
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(serials) {
+	case 0:
+		return nil, fmt.Errorf("no devices found. Check your connection, or try `adb devices`")
+	case 1:
+		return client.Device(adb.DeviceWithSerial(serials[0])), nil
+	}
+
+	return nil, fmt.Errorf("%v devices connected (%v), pick one with serial", len(serials), serials)
+}
+
+func Screenshot(d Device) (image.Image, error) {
+	output, err := d.RunCommand("screencap", "-p")
+	if err != nil {
+		return nil, err
+	}
+	return imgutils.ReadImage(bytes.NewBufferString(output))
+}
+
+func Tap(d Device, p image.Point) error {
+	_, err := d.RunCommand("input", "tap", fmt.Sprintf("%v", p.X), fmt.Sprintf("%v", p.Y))
+	return err
+}
+
+// Swipe - slow swipes scroll precisely, fast ones fling the list
+func Swipe(d Device, from, to image.Point, durationMs int) error {
+	_, err := d.RunCommand("input", "swipe",
+		fmt.Sprintf("%v", from.X), fmt.Sprintf("%v", from.Y),
+		fmt.Sprintf("%v", to.X), fmt.Sprintf("%v", to.Y),
+		fmt.Sprintf("%v", durationMs))
+	return err
+}
+
+// Text - types text into the focused input, it can't contain double quotes (adb shell limitation)
+func Text(d Device, text string) error {
+	// input treats %s as a space, the rest is quoted for the device shell
+	text = strings.ReplaceAll(text, " ", "%s")
+	_, err := d.RunCommand("input", "text", "'"+strings.ReplaceAll(text, "'", `'\''`)+"'")
+	return err
+}
+
+// Key - presses a key, by keycode name (ENTER, BACK, TAB, ...)
+func Key(d Device, key string) error {
+	key = strings.ToUpper(key)
+	if !strings.HasPrefix(key, "KEYCODE_") {
+		key = "KEYCODE_" + key
+	}
+	_, err := d.RunCommand("input", "keyevent", key)
+	return err
+}
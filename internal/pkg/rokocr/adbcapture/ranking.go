@@ -0,0 +1,163 @@
+package adbcapture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	log "github.com/sirupsen/logrus"
+)
+
+// RankingPlan - where to tap in the governor ranking list. Coordinates are in Width x Height screen space,
+// and are scaled to the real device resolution.
+type RankingPlan struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Rows - centers of ranking rows visible without scrolling; after the last one, list is scrolled
+	// by a single row (distance between two last rows), and the last row is tapped again
+	Rows []image.Point `json:"rows"`
+	// Before - taps after opening the profile, before taking the screenshot (e.g. "more info" button)
+	Before []image.Point `json:"before,omitempty"`
+	// Close - taps closing everything opened, back to the ranking list
+	Close []image.Point `json:"close"`
+	// DelayMs - wait after every tap (milliseconds), the game needs time to animate
+	DelayMs int `json:"delay_ms"`
+}
+
+func (p RankingPlan) delay() time.Duration {
+	return time.Duration(p.DelayMs) * time.Millisecond
+}
+
+// DefaultRankingPlan - individual power ranking, 1920x1080 landscape. It's only a starting point,
+// menus move between game updates, so plans can be loaded from JSON (see LoadRankingPlan).
+func DefaultRankingPlan() RankingPlan {
+	return RankingPlan{
+		Width:  1920,
+		Height: 1080,
+		Rows: []image.Point{
+			{X: 960, Y: 365},
+			{X: 960, Y: 490},
+			{X: 960, Y: 615},
+			{X: 960, Y: 740},
+		},
+		Before:  []image.Point{{X: 530, Y: 715}},
+		Close:   []image.Point{{X: 1700, Y: 90}, {X: 1620, Y: 160}},
+		DelayMs: 1500,
+	}
+}
+
+func LoadRankingPlan(name string) (RankingPlan, error) {
+	plan := DefaultRankingPlan()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return plan, err
+	}
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return plan, fmt.Errorf("invalid plan %v: %v", name, err)
+	}
+	if len(plan.Rows) == 0 {
+		return plan, fmt.Errorf("invalid plan %v: no rows", name)
+	}
+	return plan, nil
+}
+
+func (p RankingPlan) scaled(w, h int) RankingPlan {
+	scale := func(points []image.Point) []image.Point {
+		var result []image.Point
+		for _, x := range points {
+			result = append(result, image.Pt(x.X*w/p.Width, x.Y*h/p.Height))
+		}
+		return result
+	}
+
+	p.Rows, p.Before, p.Close = scale(p.Rows), scale(p.Before), scale(p.Close)
+	p.Width, p.Height = w, h
+	return p
+}
+
+// CaptureRanking - taps through `count` governors of the ranking list (list has to be open on the device),
+// and emits profile screenshots. Channel is closed when done, on ctx cancel, or on device error.
+func CaptureRanking(ctx context.Context, d Device, plan RankingPlan, count int, prefix string) <-chan tesseractutils.NamedImage {
+	out := make(chan tesseractutils.NamedImage)
+
+	go func() {
+		defer close(out)
+
+		first, err := Screenshot(d)
+		if err != nil {
+			log.Errorf("[adb] can't take a screenshot: %v", err)
+			return
+		}
+		plan = plan.scaled(first.Bounds().Dx(), first.Bounds().Dy())
+		log.Debugf("[adb] device screen: %vx%v", plan.Width, plan.Height)
+
+		for i := 0; i < count; i++ {
+			img, err := captureRow(ctx, d, plan, i)
+			if err != nil {
+				log.Errorf("[adb] governor %v: %v", i+1, err)
+				return
+			}
+
+			select {
+			case out <- tesseractutils.NamedImage{Name: fmt.Sprintf("%s_%04d.png", prefix, i+1), Image: img}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func captureRow(ctx context.Context, d Device, plan RankingPlan, i int) (image.Image, error) {
+	row := plan.Rows[min(i, len(plan.Rows)-1)]
+
+	if i >= len(plan.Rows) && len(plan.Rows) > 1 {
+		// scroll by exactly one row, so the next governor is under the last row
+		prev := plan.Rows[len(plan.Rows)-2]
+		if err := Swipe(d, row, prev, 1000); err != nil {
+			return nil, err
+		}
+		if err := wait(ctx, plan.delay()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range append([]image.Point{row}, plan.Before...) {
+		if err := Tap(d, p); err != nil {
+			return nil, err
+		}
+		if err := wait(ctx, plan.delay()); err != nil {
+			return nil, err
+		}
+	}
+
+	img, err := Screenshot(d)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range plan.Close {
+		if err := Tap(d, p); err != nil {
+			return nil, err
+		}
+		if err := wait(ctx, plan.delay()); err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package rokocr
+
+import (
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	log "github.com/sirupsen/logrus"
+)
+
+// Aggregate - merges results of screens, which are parts of one record (see OCRTemplate.Record), into a single result
+// per record, e.g. governor profile, more info & kill details captured one after another. Parts are matched by the record
+// key (governor id), parts without a key belong to the record of the preceding screen. A field recognized by several
+// parts is taken from the most confident one, computed fields are calculated again from the merged data.
+//
+// Results are matched with templates by title (results without one belong to the only template). Returns results in order of the first part of every record (results of
+// templates without record are kept as they are), with templates of the records by name (see ocrschema.RecordTemplate).
+func Aggregate(data []schema.OCRResult, templates []schema.OCRTemplate) ([]schema.OCRResult, map[string]schema.OCRTemplate) {
+	parts := make(map[string][]schema.OCRTemplate)
+	byTitle := make(map[string]schema.OCRTemplate)
+	for _, t := range templates {
+		byTitle[t.Title] = t
+		if t.Record != nil && len(t.Record.Name) > 0 {
+			parts[t.Record.Name] = append(parts[t.Record.Name], t)
+		}
+	}
+
+	records := make(map[string]schema.OCRTemplate, len(parts))
+	for name, list := range parts {
+		records[name] = schema.RecordTemplate(name, list)
+	}
+	if len(records) == 0 {
+		return data, records
+	}
+
+	var result []schema.OCRResult
+	// current - record of the preceding screen by record name, keys - records by name & key
+	current := make(map[string]int)
+	keys := make(map[string]int)
+
+	for _, r := range data {
+		t, ok := byTitle[r.Template]
+		if len(r.Template) == 0 && len(templates) == 1 {
+			// recognized with a single (forced) template
+			t, ok = templates[0], true
+		}
+		if !ok || t.Record == nil || len(t.Record.Name) == 0 {
+			result = append(result, r)
+			continue
+		}
+		name := t.Record.Name
+
+		var key string
+		if len(t.Record.Key) > 0 {
+			key = t.KeyOf(r, t.Record.Key)
+		}
+
+		idx, found := current[name]
+		switch {
+		case len(key) > 0:
+			if i, seen := keys[name+"\x00"+key]; seen {
+				idx, found = i, true
+			} else if found && len(recordKey(result[idx], records[name])) > 0 {
+				// preceding record belongs to another governor
+				found = false
+			}
+		case !found:
+			log.Debugf("[aggregate] %v: %v part without %v, and no record before it", r.Filename, name, t.Record.Key)
+		}
+
+		if !found {
+			idx = len(result)
+			result = append(result, schema.OCRResult{
+				Filename: r.Filename,
+				Data:     make(map[string]interface{}),
+				Fields:   make(map[string]schema.FieldResult),
+				Template: name,
+			})
+		}
+		mergePart(&result[idx], r)
+		current[name] = idx
+		if k := recordKey(result[idx], records[name]); len(k) > 0 {
+			keys[name+"\x00"+k] = idx
+		}
+	}
+
+	for i, r := range result {
+		record, ok := records[r.Template]
+		if !ok || len(r.Parts) == 0 {
+			continue
+		}
+		data, errs := record.ComputeFields(r.Data)
+		for k, err := range errs {
+			log.Debugf("[aggregate] %v: failed to compute '%s': %v", strings.Join(r.Parts, ", "), k, err)
+		}
+		result[i].Data = data
+	}
+
+	return result, records
+}
+
+func recordKey(r schema.OCRResult, record schema.OCRTemplate) string {
+	if record.Record == nil || len(record.Record.Key) == 0 {
+		return ""
+	}
+	return record.KeyOf(r, record.Record.Key)
+}
+
+// mergePart - fields missing in the record, or recognized with higher confidence by the part, are taken from the part
+func mergePart(record *schema.OCRResult, part schema.OCRResult) {
+	record.Parts = append(record.Parts, part.Filename)
+	record.Took += part.Took
+	record.Quality = append(record.Quality, part.Quality...)
+
+	for k, v := range part.Data {
+		if _, known := part.Fields[k]; !known && len(part.Fields) > 0 {
+			// computed field of the part, calculated again for the record
+			continue
+		}
+		existing, ok := record.Data[k]
+		empty := !ok || existing == nil || len(strings.TrimSpace(schema.FormatValue(existing))) == 0
+		if !empty && part.Fields[k].Confidence <= record.Fields[k].Confidence {
+			continue
+		}
+		if v == nil || len(strings.TrimSpace(schema.FormatValue(v))) == 0 {
+			continue
+		}
+		record.Data[k] = v
+		if f, ok := part.Fields[k]; ok {
+			record.Fields[k] = f
+		}
+	}
+}
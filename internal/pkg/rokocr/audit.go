@@ -0,0 +1,59 @@
+package rokocr
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// AuditRecord - a single line in audit log, explains how a value was recorded
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Filename string    `json:"filename"`
+	Template string    `json:"template"`
+	Version  string    `json:"version,omitempty"`
+	Field    string    `json:"field"`
+	schema.FieldResult
+}
+
+// AuditLog - streaming JSONL sink, safe for use from multiple goroutines
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w)}
+}
+
+func (a *AuditLog) Write(result schema.OCRResult, template schema.OCRTemplate) error {
+	var keys []string
+	for k := range result.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range keys {
+		err := a.enc.Encode(AuditRecord{
+			Time:        now,
+			Filename:    result.Filename,
+			Template:    template.Title,
+			Version:     template.Version,
+			Field:       k,
+			FieldResult: result.Fields[k],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package rokocr
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+)
+
+// stages of the pipeline timed by Bench, in the order they run
+const (
+	StageDecode = "decode"
+	StageHash   = "hash"
+	StageMatch  = "match"
+	StageOCR    = "ocr"
+	StageExport = "export"
+)
+
+// BenchOptions - what Bench runs, Recognition is used as the scanner would (jobs, languages, min confidence, ...)
+type BenchOptions struct {
+	// Runs - how many times the sample set is processed, later runs show timings with warm engines & caches
+	Runs        int
+	Recognition tesseractutils.Options
+	CSV         CSVOptions
+	// Force - the first template is used even if it doesn't match (see -forceTemplate)
+	Force bool
+}
+
+// Timing - durations of a stage (or field) over all samples
+type Timing struct {
+	Name    string
+	Samples []time.Duration
+}
+
+func (t Timing) Count() int {
+	return len(t.Samples)
+}
+
+func (t Timing) Total() time.Duration {
+	var total time.Duration
+	for _, d := range t.Samples {
+		total += d
+	}
+	return total
+}
+
+func (t Timing) Avg() time.Duration {
+	if len(t.Samples) == 0 {
+		return 0
+	}
+	return t.Total() / time.Duration(len(t.Samples))
+}
+
+// Percentile - p (0-100) of the samples, e.g. 95 - 95% of samples took at most this long
+func (t Timing) Percentile(p float64) time.Duration {
+	if len(t.Samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), t.Samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+func (t Timing) Max() time.Duration {
+	return t.Percentile(100)
+}
+
+// BenchReport - timings of Bench. Fields run concurrently with Jobs > 1, so their sum can exceed ocr stage.
+type BenchReport struct {
+	Runs   int
+	Jobs   int
+	Images int
+	// Unreadable & Unmatched - images which didn't get to OCR
+	Unreadable int
+	Unmatched  int
+	Results    int
+	Took       time.Duration
+	// Stages - in pipeline order, Fields - by template & field name, slowest first
+	Stages []Timing
+	Fields []Timing
+}
+
+// ImagesPerSecond - throughput of the whole pipeline
+func (r BenchReport) ImagesPerSecond() float64 {
+	if r.Took <= 0 {
+		return 0
+	}
+	return float64(r.Images) / r.Took.Seconds()
+}
+
+// timings - collects samples by name, OnField calls it from recognition goroutines
+type timings struct {
+	mu      sync.Mutex
+	order   []string
+	samples map[string][]time.Duration
+}
+
+func newTimings() *timings {
+	return &timings{samples: make(map[string][]time.Duration)}
+}
+
+func (t *timings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.samples[name]; !ok {
+		t.order = append(t.order, name)
+	}
+	t.samples[name] = append(t.samples[name], d)
+}
+
+func (t *timings) list() []Timing {
+	var list []Timing
+	for _, name := range t.order {
+		list = append(list, Timing{Name: name, Samples: t.samples[name]})
+	}
+	return list
+}
+
+// Bench - runs every file through the pipeline stage by stage (decode, fingerprint hash, template match, OCR of
+// every field & export of the results), and reports how long each took. Images are processed one at a time,
+// so stage timings aren't skewed by each other; fields of an image still run with Recognition.Jobs.
+func Bench(ctx context.Context, files []string, templates []schema.OCRTemplate, opts BenchOptions) BenchReport {
+	if opts.Runs < 1 {
+		opts.Runs = 1
+	}
+
+	// stages are listed in pipeline order, even if some never ran (e.g. nothing matched)
+	stages := newTimings()
+	for _, s := range []string{StageDecode, StageHash, StageMatch, StageOCR, StageExport} {
+		stages.order = append(stages.order, s)
+		stages.samples[s] = nil
+	}
+	fields := newTimings()
+
+	recognition, release := opts.Recognition.WithContext(ctx).Shared()
+	defer release()
+	recognition.OnField = func(template, field string, took time.Duration) {
+		fields.add(template+" / "+field, took)
+	}
+
+	report := BenchReport{Runs: opts.Runs, Jobs: recognition.Jobs}
+	start := time.Now()
+
+	for run := 0; run < opts.Runs && ctx.Err() == nil; run++ {
+		byTemplate := make(map[string][]schema.OCRResult)
+		matched := make(map[string]schema.OCRTemplate)
+
+		for _, f := range files {
+			if ctx.Err() != nil {
+				break
+			}
+			report.Images++
+
+			t := time.Now()
+			img, err := imgutils.ReadImageFile(f)
+			if err != nil {
+				logutils.File(f).Warnf("Can't read: %v", err)
+				report.Unreadable++
+				continue
+			}
+			if recognition.CropBorders {
+				img = imgutils.CropBorders(img)
+			}
+			stages.add(StageDecode, time.Since(t))
+
+			t = time.Now()
+			hashed := make(map[string]bool)
+			for _, template := range templates {
+				if !hashed[template.HashAlgo] {
+					hashed[template.HashAlgo] = true
+					_, _ = template.ImageHash(img)
+				}
+			}
+			stages.add(StageHash, time.Since(t))
+
+			t = time.Now()
+			scores := schema.RankTemplates(img, templates)
+			stages.add(StageMatch, time.Since(t))
+			if len(scores) == 0 || (!scores[0].Matches && !opts.Force) {
+				logutils.File(f).Warn("No template matches")
+				report.Unmatched++
+				continue
+			}
+
+			template := scores[0].Template
+			if opts.Force {
+				template = templates[0]
+			}
+			t = time.Now()
+			results := tesseractutils.ParseRowsWithOptions(filepath.Base(f), img, template, recognition)
+			stages.add(StageOCR, time.Since(t))
+
+			report.Results += len(results)
+			byTemplate[template.Title] = append(byTemplate[template.Title], results...)
+			matched[template.Title] = template
+		}
+
+		// exported as the scanner does, CSV & Excel of every template
+		for title, results := range byTemplate {
+			t := time.Now()
+			_ = WriteCSVWithOptions(results, matched[title], opts.CSV, io.Discard)
+			_ = WriteXLSX(results, matched[title], io.Discard)
+			stages.add(StageExport, time.Since(t))
+		}
+	}
+
+	report.Took = time.Since(start)
+	report.Stages = stages.list()
+	report.Fields = fields.list()
+	sort.SliceStable(report.Fields, func(i, j int) bool {
+		return report.Fields[i].Total() > report.Fields[j].Total()
+	})
+	return report
+}
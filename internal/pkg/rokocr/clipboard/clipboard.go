@@ -0,0 +1,70 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// ErrNoTool - none of the tools to read the clipboard with is installed
+var ErrNoTool = errors.New("no clipboard tool found (install wl-clipboard or xclip on Linux, pngpaste on macOS)")
+
+// powershell - writes clipboard image as PNG to stdout
+const powershell = `Add-Type -AssemblyName System.Windows.Forms,System.Drawing
+$img = [System.Windows.Forms.Clipboard]::GetImage()
+if ($img -eq $null) { [Console]::Error.WriteLine("no image in clipboard"); exit 1 }
+$ms = New-Object System.IO.MemoryStream
+$img.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png)
+$out = [Console]::OpenStandardOutput()
+$out.Write($ms.ToArray(), 0, $ms.Length)`
+
+// commands - platform tools printing the clipboard image to stdout, first installed one is used
+func commands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pngpaste", "-"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-STA", "-Command", powershell}}
+	default:
+		x11 := []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"}
+		if len(os.Getenv("WAYLAND_DISPLAY")) > 0 {
+			return [][]string{{"wl-paste", "--no-newline", "--type", "image/png"}, x11}
+		}
+		return [][]string{x11}
+	}
+}
+
+// ReadImage - image currently copied to the system clipboard
+func ReadImage(ctx context.Context) (image.Image, error) {
+	for _, c := range commands() {
+		binary, err := exec.LookPath(c[0])
+		if err != nil {
+			continue
+		}
+
+		var stderr strings.Builder
+		cmd := exec.CommandContext(ctx, binary, c[1:]...)
+		cmd.Stderr = &stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+				return nil, fmt.Errorf("%v: %v", c[0], msg)
+			}
+			return nil, fmt.Errorf("%v: %v", c[0], err)
+		}
+		if len(out) == 0 {
+			return nil, errors.New("no image in clipboard")
+		}
+		return imgutils.ReadImage(bytes.NewReader(out))
+	}
+	return nil, ErrNoTool
+}
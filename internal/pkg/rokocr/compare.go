@@ -0,0 +1,160 @@
+package rokocr
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// governor presence in compared scans
+const (
+	CompareBoth = "both"
+	CompareNew  = "new"
+	CompareGone = "gone"
+)
+
+// CompareOptions - how two scans (e.g. start & end of KvK) are compared
+type CompareOptions struct {
+	// Key - field joining rows of both scans (e.g. governor id)
+	Key string
+	// Fields - numeric fields to report start, end & delta of (e.g. power, kill points, deads)
+	Fields []string
+	// Columns - text fields copied from the latest scan having the governor (e.g. name, alliance)
+	Columns []string
+	// Missing - include governors present only in one of the scans
+	Missing bool
+}
+
+// CompareValue - value of a field in both scans, nil if missing or not a number
+type CompareValue struct {
+	Start *float64 `json:"start"`
+	End   *float64 `json:"end"`
+}
+
+// Delta - end minus start, nil if any of them is missing
+func (v CompareValue) Delta() *float64 {
+	if v.Start == nil || v.End == nil {
+		return nil
+	}
+	d := *v.End - *v.Start
+	return &d
+}
+
+// CompareRow - single governor
+type CompareRow struct {
+	Key     string                  `json:"key"`
+	Status  string                  `json:"status"`
+	Columns map[string]string       `json:"columns,omitempty"`
+	Values  map[string]CompareValue `json:"values"`
+}
+
+// Compare - joins the scans by key, and returns per-governor values & deltas, biggest delta of the first field first.
+// Rows without key are skipped, for duplicate keys the last row wins.
+func Compare(start, end []schema.OCRResult, template schema.OCRTemplate, opts CompareOptions) []CompareRow {
+	var result []CompareRow
+	for _, j := range joinScans(start, end, template, opts.Key) {
+		row := CompareRow{Status: CompareBoth, Columns: make(map[string]string), Values: make(map[string]CompareValue)}
+		// latest scan having the governor first
+		scans := []*schema.OCRResult{j.Current, j.Previous}
+		switch {
+		case j.Previous == nil:
+			row.Status = CompareNew
+			scans = scans[:1]
+		case j.Current == nil:
+			row.Status = CompareGone
+			scans = scans[1:]
+		}
+		if row.Status != CompareBoth && !opts.Missing {
+			continue
+		}
+
+		// joined by the normalized key, shown as recognized in the latest scan
+		row.Key = template.DisplayKeyOf(*scans[0], opts.Key)
+		for _, c := range opts.Columns {
+			for _, r := range scans {
+				if v := schema.FormatValue(r.Data[c]); len(v) > 0 {
+					row.Columns[c] = v
+					break
+				}
+			}
+		}
+		for _, f := range opts.Fields {
+			row.Values[f] = j.value(f)
+		}
+		result = append(result, row)
+	}
+
+	if len(opts.Fields) > 0 {
+		first := opts.Fields[0]
+		sort.SliceStable(result, func(i, j int) bool {
+			a, b := result[i].Values[first].Delta(), result[j].Values[first].Delta()
+			switch {
+			case a != nil && b != nil && *a != *b:
+				return *a > *b
+			case (a == nil) != (b == nil):
+				return a != nil
+			}
+			return result[i].Key < result[j].Key
+		})
+	}
+
+	return result
+}
+
+// FormatCompareValue - value of CompareValue for tables, empty if missing
+func FormatCompareValue(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// WriteCompareCSV - key, copied columns, then start, end & delta of every field (and status, with Missing)
+func WriteCompareCSV(rows []CompareRow, opts CompareOptions, csvOpts CSVOptions, w io.Writer) error {
+	if csvOpts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+	}
+
+	table := csv.NewWriter(w)
+	if csvOpts.Delimiter != 0 {
+		table.Comma = csvOpts.Delimiter
+	}
+
+	if csvOpts.Header {
+		headers := append([]string{opts.Key}, opts.Columns...)
+		for _, f := range opts.Fields {
+			headers = append(headers, f+" start", f+" end", f+" delta")
+		}
+		if opts.Missing {
+			headers = append(headers, "status")
+		}
+		if err := table.Write(headers); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		record := []string{row.Key}
+		for _, c := range opts.Columns {
+			record = append(record, row.Columns[c])
+		}
+		for _, f := range opts.Fields {
+			v := row.Values[f]
+			record = append(record, FormatCompareValue(v.Start), FormatCompareValue(v.End), FormatCompareValue(v.Delta()))
+		}
+		if opts.Missing {
+			record = append(record, row.Status)
+		}
+		if err := table.Write(record); err != nil {
+			return err
+		}
+	}
+	table.Flush()
+
+	return table.Error()
+}
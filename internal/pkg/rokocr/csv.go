@@ -1,28 +1,211 @@
 package rokocr
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"strings"
+	"unicode/utf8"
 
 	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
 )
 
+// CSVOptions - output format of WriteCSVWithOptions
+type CSVOptions struct {
+	Delimiter rune
+	// Header - write column titles as the first row
+	Header bool
+	// BOM - prefix output with UTF-8 byte order mark, so Excel doesn't break non-latin governor names
+	BOM bool
+}
+
+func DefaultCSVOptions() CSVOptions {
+	return CSVOptions{Delimiter: ',', Header: true}
+}
+
+// ParseDelimiter - delimiter as given on command line: a single character, or "tab", "\t"
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "tab", "\\t", "\t":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character, got %q", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+// tableColumns - export columns: template Table, or all fields (then computed ones) in alphabetical order if template has no table
+func tableColumns(template schema.OCRTemplate) []schema.OCRTableField {
+	columns := template.Table
+	if len(columns) == 0 {
+		for _, k := range template.ColumnKeys() {
+			columns = append(columns, schema.OCRTableField{Title: k, Field: k})
+		}
+	}
+	// fields of chained templates follow
+	return append(append([]schema.OCRTableField{}, columns...), template.ChainedColumns(columns)...)
+}
+
+// ExportColumns - table columns, followed by scan tags (kingdom, alliance, date) which rows have
+// and the table doesn't show already
+func ExportColumns(template schema.OCRTemplate, data []schema.OCRResult) []schema.OCRTableField {
+	columns := tableColumns(template)
+	shown := make(map[string]bool)
+	for _, x := range columns {
+		shown[x.Field] = true
+	}
+
+	for _, x := range schema.ScanColumns {
+		if shown[x.Field] {
+			continue
+		}
+		for _, r := range data {
+			if _, ok := r.Data[x.Field]; ok {
+				columns = append(columns, x)
+				break
+			}
+		}
+	}
+	return columns
+}
+
 func WriteCSV(data []schema.OCRResult, template schema.OCRTemplate, w io.Writer) {
-	headers := []string{"Filename"}
-	for _, x := range template.Table {
-		headers = append(headers, x.Title)
+	_ = WriteCSVWithOptions(data, template, DefaultCSVOptions(), w)
+}
+
+// WriteCSVWithOptions - writes results with columns (titles & order) from the template Table,
+// or all fields in alphabetical order if template has no table defined
+func WriteCSVWithOptions(data []schema.OCRResult, template schema.OCRTemplate, opts CSVOptions, w io.Writer) error {
+	columns := ExportColumns(template, data)
+
+	if opts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
 	}
 
 	table := csv.NewWriter(w)
-	_ = table.Write(headers)
+	if opts.Delimiter != 0 {
+		table.Comma = opts.Delimiter
+	}
+
+	// rows rejected by validation say why (see OCRTemplate.FilterValid)
+	problems := false
+	for _, row := range data {
+		problems = problems || len(row.Problems) > 0
+	}
+
+	if opts.Header {
+		headers := []string{"Filename"}
+		for _, x := range columns {
+			headers = append(headers, x.Title)
+		}
+		if problems {
+			headers = append(headers, "Problems")
+		}
+		if err := table.Write(headers); err != nil {
+			return err
+		}
+	}
+
 	for _, row := range data {
 		rowData := []string{row.Filename}
-		for _, x := range template.Table {
-			rowData = append(rowData, fmt.Sprintf("%v", row.Data[x.Field]))
+		for _, x := range columns {
+			rowData = append(rowData, schema.FormatValue(row.Data[x.Field]))
+		}
+		if problems {
+			rowData = append(rowData, schema.FormatProblems(row.Problems))
+		}
+		if err := table.Write(rowData); err != nil {
+			return err
 		}
-		_ = table.Write(rowData)
 	}
 	table.Flush()
 
+	return table.Error()
+}
+
+// ReadCSV - reads results written by WriteCSV (e.g. previous scan), columns are mapped to fields by template
+// Table titles (or used as field names, if template has no fields). Delimiter is detected from the header,
+// values are read as text, unknown columns are ignored.
+func ReadCSV(r io.Reader, template schema.OCRTemplate) ([]schema.OCRResult, error) {
+	br := skipBOM(r)
+	reader := csv.NewReader(br)
+	reader.Comma = detectDelimiter(br)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	for _, x := range tableColumns(template) {
+		fields[x.Title] = x.Field
+	}
+	if len(fields) > 0 {
+		for _, x := range schema.ScanColumns {
+			if _, ok := fields[x.Title]; !ok {
+				fields[x.Title] = x.Field
+			}
+		}
+	}
+
+	var results []schema.OCRResult
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := schema.OCRResult{Data: make(map[string]interface{})}
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			if i == 0 && header[i] == "Filename" {
+				result.Filename = value
+				continue
+			}
+			if field, ok := fields[header[i]]; ok {
+				result.Data[field] = value
+			} else if len(fields) == 0 {
+				result.Data[header[i]] = value
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func skipBOM(r io.Reader) *bufio.Reader {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && string(b) == "\ufeff" {
+		_, _ = br.Discard(3)
+	}
+	return br
+}
+
+// detectDelimiter - most frequent of the usual delimiters in the header line
+func detectDelimiter(br *bufio.Reader) rune {
+	// fewer bytes (short file) are returned along with an error
+	line, _ := br.Peek(br.Size())
+	if idx := strings.IndexByte(string(line), '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	best, count := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := strings.Count(string(line), string(d)); n > count {
+			best, count = d, n
+		}
+	}
+	return best
 }
@@ -0,0 +1,39 @@
+package rokocr
+
+import (
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	log "github.com/sirupsen/logrus"
+)
+
+// Dedup - keeps a single result per key field value (e.g. governor id), the one with higher aggregate confidence.
+// Scanning the same governor twice (or a double-captured screenshot) results in a single row. Order of first
+// occurrence is kept; results with empty key can't be matched & are kept as they are.
+func Dedup(data []schema.OCRResult, template schema.OCRTemplate, keyField string) []schema.OCRResult {
+	var result []schema.OCRResult
+	seen := make(map[string]int)
+
+	for _, r := range data {
+		key := template.KeyOf(r, keyField)
+		if len(key) == 0 {
+			result = append(result, r)
+			continue
+		}
+
+		idx, ok := seen[key]
+		if !ok {
+			seen[key] = len(result)
+			result = append(result, r)
+			continue
+		}
+
+		kept := result[idx]
+		if r.Confidence() > kept.Confidence() {
+			log.Debugf("[dedup] %v=%v: %v replaces %v (confidence %.1f > %.1f)", keyField, key, r.Filename, kept.Filename, r.Confidence(), kept.Confidence())
+			result[idx] = r
+		} else {
+			log.Debugf("[dedup] %v=%v: dropping %v, keeping %v", keyField, key, r.Filename, kept.Filename)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,58 @@
+package rokocr
+
+import (
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// namesTemplate - governor name as a case insensitive key
+func namesTemplate(caseInsensitive bool) schema.OCRTemplate {
+	return schema.OCRTemplate{
+		OCRSchema: map[string]schema.OCRSchema{
+			"name":  {CaseInsensitiveKey: caseInsensitive},
+			"power": {Type: schema.TypeInt},
+		},
+	}
+}
+
+func governor(file, name string, power int64, confidence float64) schema.OCRResult {
+	return schema.OCRResult{
+		Filename: file,
+		Data:     map[string]interface{}{"name": name, "power": power},
+		Fields:   map[string]schema.FieldResult{"name": {Value: name, Confidence: confidence}},
+	}
+}
+
+func TestDedupCaseInsensitiveKey(t *testing.T) {
+	data := []schema.OCRResult{
+		governor("1.png", "PlayerOne", 100, 80),
+		governor("2.png", "playerone", 100, 90),
+		governor("3.png", "PlayerOne ", 100, 85),
+		governor("4.png", "PlayerTwo", 200, 90),
+		governor("5.png", " PLAYERTWO ", 200, 70),
+	}
+
+	result := Dedup(data, namesTemplate(true), "name")
+	if len(result) != 2 {
+		t.Fatalf("got %d rows, want 2: %v", len(result), result)
+	}
+	// the more confident row is kept, with its name as recognized
+	if got := result[0]; got.Filename != "2.png" || got.Data["name"] != "playerone" {
+		t.Errorf("first row = %v %q, want 2.png \"playerone\"", got.Filename, got.Data["name"])
+	}
+	if got := result[1]; got.Filename != "4.png" || got.Data["name"] != "PlayerTwo" {
+		t.Errorf("second row = %v %q, want 4.png \"PlayerTwo\"", got.Filename, got.Data["name"])
+	}
+
+	// whitespace inside the name is collapsed, not removed: "Player One" is another governor
+	spaced := append(data[:1:1], governor("6.png", "Player  One", 100, 95), governor("7.png", "player one", 100, 90))
+	if result := Dedup(spaced, namesTemplate(true), "name"); len(result) != 2 || result[1].Data["name"] != "Player  One" {
+		t.Errorf("got %v, want PlayerOne & \"Player  One\"", result)
+	}
+
+	// without the flag, every casing is a separate governor (surrounding whitespace is always trimmed)
+	if n := len(Dedup(data, namesTemplate(false), "name")); n != 4 {
+		t.Errorf("case sensitive key: got %d rows, want 4", n)
+	}
+}
@@ -0,0 +1,118 @@
+package rokocr
+
+import (
+	"sort"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+)
+
+// Delta - change of a numeric field of a single governor between two scans
+type Delta struct {
+	Key      string  `json:"key"`
+	Previous float64 `json:"previous"`
+	Current  float64 `json:"current"`
+}
+
+func (d Delta) Change() float64 {
+	return d.Current - d.Previous
+}
+
+// Deltas - joins two scans by keyField, and returns change of valueField for every governor present in both,
+// biggest gain first. Rows without key or with non-numeric values are skipped, for duplicate keys the last row wins.
+// Key is the one of the current scan.
+func Deltas(previous, current []schema.OCRResult, template schema.OCRTemplate, keyField, valueField string) []Delta {
+	var deltas []Delta
+	for _, row := range joinScans(previous, current, template, keyField) {
+		v := row.value(valueField)
+		if v.Delta() == nil {
+			continue
+		}
+		deltas = append(deltas, Delta{Key: template.DisplayKeyOf(*row.Current, keyField), Previous: *v.Start, Current: *v.End})
+	}
+
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].Change() != deltas[j].Change() {
+			return deltas[i].Change() > deltas[j].Change()
+		}
+		return deltas[i].Key < deltas[j].Key
+	})
+
+	return deltas
+}
+
+// joinedRow - rows of a single governor in two scans, nil in the scan it's missing from
+type joinedRow struct {
+	Previous *schema.OCRResult
+	Current  *schema.OCRResult
+}
+
+// value - field in both rows, nil where missing or not a number
+func (j joinedRow) value(field string) CompareValue {
+	return CompareValue{Start: fieldValue(j.Previous, field), End: fieldValue(j.Current, field)}
+}
+
+// joinScans - rows of both scans by normalized key (see OCRTemplate.KeyOf), in the order governors appear first.
+// Rows without key are skipped, for duplicate keys the last row wins.
+func joinScans(previous, current []schema.OCRResult, template schema.OCRTemplate, keyField string) []joinedRow {
+	index := make(map[string]int)
+	var rows []joinedRow
+
+	add := func(results []schema.OCRResult, isCurrent bool) {
+		for i := range results {
+			key := template.KeyOf(results[i], keyField)
+			if len(key) == 0 {
+				continue
+			}
+			n, ok := index[key]
+			if !ok {
+				n = len(rows)
+				index[key] = n
+				rows = append(rows, joinedRow{})
+			}
+			if isCurrent {
+				rows[n].Current = &results[i]
+			} else {
+				rows[n].Previous = &results[i]
+			}
+		}
+	}
+	add(previous, false)
+	add(current, true)
+
+	return rows
+}
+
+func fieldValue(r *schema.OCRResult, field string) *float64 {
+	if r == nil {
+		return nil
+	}
+	v, err := numericValue(r.Data[field])
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// Change - change of a numeric value between two scans, false if either of them isn't a number
+func Change(previous, current interface{}) (float64, bool) {
+	before, err := numericValue(previous)
+	if err != nil {
+		return 0, false
+	}
+	after, err := numericValue(current)
+	if err != nil {
+		return 0, false
+	}
+	return after - before, true
+}
+
+func numericValue(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	}
+	return stringutils.ParseNumber(schema.FormatValue(v))
+}
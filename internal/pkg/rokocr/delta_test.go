@@ -0,0 +1,91 @@
+package rokocr
+
+import (
+	"reflect"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func TestDeltasCaseInsensitiveKey(t *testing.T) {
+	previous := []schema.OCRResult{governor("a.png", "PlayerOne", 1000, 90)}
+	current := []schema.OCRResult{governor("b.png", "playerone", 1500, 90)}
+
+	deltas := Deltas(previous, current, namesTemplate(true), "name", "power")
+	if len(deltas) != 1 {
+		t.Fatalf("got %d deltas, want 1: %v", len(deltas), deltas)
+	}
+	if d := deltas[0]; d.Key != "playerone" || d.Change() != 500 {
+		t.Errorf("delta = %+v, want playerone +500", d)
+	}
+
+	if deltas := Deltas(previous, current, namesTemplate(false), "name", "power"); len(deltas) != 0 {
+		t.Errorf("case sensitive key: got %v, want no deltas", deltas)
+	}
+}
+
+func TestCompareCaseInsensitiveKey(t *testing.T) {
+	start := []schema.OCRResult{governor("a.png", "PlayerOne", 1000, 90)}
+	end := []schema.OCRResult{governor("b.png", "playerONE", 1200, 90)}
+	opts := CompareOptions{Key: "name", Fields: []string{"power"}, Columns: []string{"name"}, Missing: true}
+
+	rows := Compare(start, end, namesTemplate(true), opts)
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1: %+v", len(rows), rows)
+	}
+	row := rows[0]
+	if row.Status != CompareBoth {
+		t.Errorf("status = %v, want %v", row.Status, CompareBoth)
+	}
+	// shown as recognized in the latest scan, not lower-cased
+	if row.Key != "playerONE" || row.Columns["name"] != "playerONE" {
+		t.Errorf("key = %q, name = %q, want playerONE", row.Key, row.Columns["name"])
+	}
+	if d := row.Values["power"].Delta(); d == nil || *d != 200 {
+		t.Errorf("power delta = %v, want 200", FormatCompareValue(d))
+	}
+
+	// without the flag, the governor looks gone & new
+	if rows := Compare(start, end, namesTemplate(false), opts); len(rows) != 2 {
+		t.Errorf("case sensitive key: got %d rows, want 2", len(rows))
+	}
+}
+
+// TestDeltasMatchCompare - both join the scans the same way, deltas are the rows of governors in both scans
+func TestDeltasMatchCompare(t *testing.T) {
+	start := []schema.OCRResult{
+		governor("1.png", "PlayerOne", 1000, 90),
+		governor("2.png", "PlayerTwo", 500, 90),
+		governor("3.png", "PlayerOne", 1100, 90), // duplicate, the last row wins
+		governor("4.png", "Gone", 300, 90),
+		{Filename: "5.png", Data: map[string]interface{}{"power": int64(1)}}, // no key
+	}
+	end := []schema.OCRResult{
+		governor("6.png", "PlayerTwo", 900, 90),
+		governor("7.png", "PlayerOne", 1200, 90),
+		governor("8.png", "New", 100, 90),
+		{Filename: "9.png", Data: map[string]interface{}{"name": "NoPower"}},
+	}
+	template := namesTemplate(false)
+
+	deltas := Deltas(start, end, template, "name", "power")
+	rows := Compare(start, end, template, CompareOptions{Key: "name", Fields: []string{"power"}, Missing: true})
+
+	if len(deltas) != 2 || deltas[0].Key != "PlayerTwo" || deltas[0].Change() != 400 || deltas[1].Key != "PlayerOne" || deltas[1].Change() != 100 {
+		t.Fatalf("deltas = %+v, want PlayerTwo +400, PlayerOne +100", deltas)
+	}
+	for i, d := range deltas {
+		if rows[i].Key != d.Key || *rows[i].Values["power"].Delta() != d.Change() {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], d)
+		}
+	}
+
+	status := make(map[string]string)
+	for _, r := range rows {
+		status[r.Key] = r.Status
+	}
+	want := map[string]string{"PlayerOne": CompareBoth, "PlayerTwo": CompareBoth, "Gone": CompareGone, "New": CompareNew, "NoPower": CompareNew}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("statuses = %v, want %v", status, want)
+	}
+}
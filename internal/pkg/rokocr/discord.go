@@ -0,0 +1,145 @@
+package rokocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+const (
+	discordColorInfo    = 0x2ecc71
+	discordColorWarning = 0xe67e22
+	// discordMaxDeltas - how many top / bottom governors are listed in the summary
+	discordMaxDeltas = 5
+)
+
+// ScanSummary - what happened during a scan, posted when it completes
+type ScanSummary struct {
+	Template string
+	Rows     int
+	Failures int
+	Took     time.Duration
+	// DeltaField & Deltas - optional change against previous scan, sorted biggest gain first (see Deltas)
+	DeltaField string
+	Deltas     []Delta
+}
+
+// DiscordNotifier - posts scan events to a Discord channel webhook
+type DiscordNotifier struct {
+	WebhookURL string
+	Retry      retryutils.Options
+	client     *http.Client
+}
+
+func NewDiscordNotifier(webhookURL string, retry retryutils.Options) *DiscordNotifier {
+	return &DiscordNotifier{
+		WebhookURL: webhookURL,
+		Retry:      retry,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+// ScanCompleted - summary embed: rows scanned, failures & top / bottom deltas
+func (d *DiscordNotifier) ScanCompleted(ctx context.Context, s ScanSummary) error {
+	color := discordColorInfo
+	if s.Failures > 0 {
+		color = discordColorWarning
+	}
+
+	embed := discordEmbed{
+		Title:     fmt.Sprintf("Scan completed: %s", s.Template),
+		Color:     color,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Fields: []discordField{
+			{Name: "Rows", Value: fmt.Sprintf("%d", s.Rows), Inline: true},
+			{Name: "Failures", Value: fmt.Sprintf("%d", s.Failures), Inline: true},
+			{Name: "Took", Value: s.Took.Round(time.Second).String(), Inline: true},
+		},
+	}
+
+	if len(s.Deltas) > 0 {
+		// top & bottom never overlap, with few governors bottom gets the rest
+		top := s.Deltas[:min(discordMaxDeltas, (len(s.Deltas)+1)/2)]
+		embed.Fields = append(embed.Fields, discordField{Name: fmt.Sprintf("Top %s", s.DeltaField), Value: formatDeltas(top)})
+
+		var bottom []Delta
+		for i := len(s.Deltas) - 1; i >= len(top) && len(bottom) < discordMaxDeltas; i-- {
+			bottom = append(bottom, s.Deltas[i])
+		}
+		if len(bottom) > 0 {
+			embed.Fields = append(embed.Fields, discordField{Name: fmt.Sprintf("Bottom %s", s.DeltaField), Value: formatDeltas(bottom)})
+		}
+	}
+
+	return d.post(ctx, embed)
+}
+
+// NoTemplateMatch - immediate alert, screenshot (or panel) didn't match any template
+func (d *DiscordNotifier) NoTemplateMatch(ctx context.Context, filename string, reason error) error {
+	return d.post(ctx, discordEmbed{
+		Title:       "Screenshot didn't match the template",
+		Description: fmt.Sprintf("`%s`\n%v", filename, reason),
+		Color:       discordColorWarning,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func formatDeltas(deltas []Delta) string {
+	var lines []string
+	for _, x := range deltas {
+		lines = append(lines, fmt.Sprintf("`%s` %+.0f", x.Key, x.Change()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d *DiscordNotifier) post(ctx context.Context, embed discordEmbed) error {
+	body, err := json.Marshal(map[string]interface{}{"embeds": []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+
+	return retryutils.Do(ctx, d.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.WebhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			data, _ := io.ReadAll(resp.Body)
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Container - Android running in a docker container (redroid), reachable with adb on a local port
+type Container struct {
+	// Docker - path to docker (or podman) binary
+	Docker string
+	Image  string
+	Name   string
+	// Port - host port of the container's adbd, bound to localhost only
+	Port int
+	// Data - host directory mounted as /data, keeps the installed game & its login between restarts
+	Data   string
+	Width  int
+	Height int
+	DPI    int
+}
+
+func DefaultContainer() Container {
+	return Container{
+		Docker: "docker",
+		Image:  "redroid/redroid:12.0.0_64only-latest",
+		Name:   "rokocr-redroid",
+		Port:   5555,
+		Data:   "redroid-data",
+		Width:  1920,
+		Height: 1080,
+		DPI:    320,
+	}
+}
+
+// Serial - adb serial of the container
+func (c Container) Serial() string {
+	return fmt.Sprintf("127.0.0.1:%d", c.Port)
+}
+
+// Start - runs the container, or starts it if it exists but is stopped. The existing container is reused
+// as is, so changed size or image needs `docker rm` of it first.
+func (c Container) Start(ctx context.Context) error {
+	running, err := c.docker(ctx, "inspect", "-f", "{{.State.Running}}", c.Name)
+	switch {
+	case err == nil && running == "true":
+		log.Debugf("[emulator] container %v is running", c.Name)
+		return nil
+	case err == nil:
+		log.Infof("[emulator] starting container %v", c.Name)
+		_, err = c.docker(ctx, "start", c.Name)
+		return err
+	case !strings.Contains(strings.ToLower(err.Error()), "no such"):
+		return err
+	}
+
+	// redroid needs privileged mode (binder), adb is bound to localhost as it's unauthenticated
+	args := []string{"run", "-d", "--privileged", "--name", c.Name, "-p", fmt.Sprintf("127.0.0.1:%d:5555", c.Port)}
+	if len(c.Data) > 0 {
+		data, err := filepath.Abs(c.Data)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-v", data+":/data")
+	}
+	args = append(args, c.Image,
+		fmt.Sprintf("androidboot.redroid_width=%d", c.Width),
+		fmt.Sprintf("androidboot.redroid_height=%d", c.Height),
+		fmt.Sprintf("androidboot.redroid_dpi=%d", c.DPI))
+
+	log.Infof("[emulator] creating container %v (%v, %vx%v)", c.Name, c.Image, c.Width, c.Height)
+	_, err = c.docker(ctx, args...)
+	return err
+}
+
+// Stop - stops the container, it's kept with its data for the next start
+func (c Container) Stop(ctx context.Context) error {
+	log.Infof("[emulator] stopping container %v", c.Name)
+	_, err := c.docker(ctx, "stop", c.Name)
+	return err
+}
+
+func (c Container) docker(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.Docker, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return "", fmt.Errorf("%v %v: %v", c.Docker, args[0], msg)
+		}
+		return "", fmt.Errorf("%v %v: %v", c.Docker, args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,168 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	adb "github.com/zach-klippenstein/goadb"
+)
+
+// bootPoll - how often the booting device is checked
+const bootPoll = 3 * time.Second
+
+var installSession = regexp.MustCompile(`\[(\d+)\]`)
+
+// Connect - connects adb (server on adbPort) to the network device, and waits until Android has booted
+func Connect(ctx context.Context, adbPort int, serial string, timeout time.Duration) (*adb.Device, error) {
+	client, err := adb.NewWithConfig(adb.ServerConfig{Port: adbPort})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.StartServer(); err != nil {
+		return nil, fmt.Errorf("can't start adb server: %v", err)
+	}
+
+	host, p, err := net.SplitHostPort(serial)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	device := client.Device(adb.DeviceWithSerial(serial))
+	for {
+		// adbd comes up a while after the container starts, and connection is lost on its restarts
+		err := client.Connect(host, port)
+		if err == nil {
+			var booted string
+			if booted, err = device.RunCommand("getprop", "sys.boot_completed"); err == nil && strings.TrimSpace(booted) == "1" {
+				return device, nil
+			}
+		}
+		log.Debugf("[emulator] waiting for %v to boot: %v", serial, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%v didn't boot in %v", serial, timeout)
+		case <-time.After(bootPoll):
+		}
+	}
+}
+
+// Installed - whether the package is installed on the device
+func Installed(d *adb.Device, pkg string) (bool, error) {
+	out, err := d.RunCommand("pm", "path", pkg)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.TrimSpace(out), "package:"), nil
+}
+
+// Install - installs the APK, or base & split APKs of one app (as downloaded from the store, e.g. base.apk and
+// config.*.apk)
+func Install(d *adb.Device, apks []string) error {
+	var remote []string
+	var size int64
+	defer func() {
+		for _, f := range remote {
+			_, _ = d.RunCommand("rm", "-f", f)
+		}
+	}()
+
+	for i, f := range apks {
+		name := fmt.Sprintf("/data/local/tmp/rokocr_%d.apk", i)
+		n, err := push(d, f, name)
+		if err != nil {
+			return fmt.Errorf("can't upload %v: %v", f, err)
+		}
+		remote = append(remote, name)
+		size += n
+	}
+
+	if len(remote) == 1 {
+		return pm(d, "install", "-r", remote[0])
+	}
+
+	out, err := d.RunCommand("pm", "install-create", "-r", "-S", fmt.Sprint(size))
+	if err != nil {
+		return err
+	}
+	m := installSession.FindStringSubmatch(out)
+	if m == nil {
+		return fmt.Errorf("can't create install session: %v", strings.TrimSpace(out))
+	}
+	for i, f := range remote {
+		stat, err := d.Stat(f)
+		if err != nil {
+			return err
+		}
+		if err := pm(d, "install-write", "-S", fmt.Sprint(stat.Size), m[1], fmt.Sprintf("%d.apk", i), f); err != nil {
+			return err
+		}
+	}
+	return pm(d, "install-commit", m[1])
+}
+
+func push(d *adb.Device, local, remote string) (int64, error) {
+	f, err := os.Open(local)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w, err := d.OpenWrite(remote, 0644, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, f)
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+	log.Debugf("[emulator] uploaded %v to %v (%v bytes)", filepath.Base(local), remote, n)
+	return n, w.Close()
+}
+
+// pm - runs package manager, which reports failures in the output
+func pm(d *adb.Device, args ...string) error {
+	out, err := d.RunCommand("pm", args...)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(out, "Success") {
+		return fmt.Errorf("pm %v: %v", args[0], strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// Launch - starts the app, as tapping its icon does
+func Launch(d *adb.Device, pkg string) error {
+	out, err := d.RunCommand("monkey", "-p", pkg, "-c", "android.intent.category.LAUNCHER", "1")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(out, "No activities found") {
+		return fmt.Errorf("%v has no launcher activity", pkg)
+	}
+	return nil
+}
+
+// Stop - force stops the app, so the next launch starts from the loading screen
+func Stop(d *adb.Device, pkg string) error {
+	_, err := d.RunCommand("am", "force-stop", pkg)
+	return err
+}
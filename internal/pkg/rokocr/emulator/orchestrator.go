@@ -0,0 +1,150 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/adbcapture"
+	log "github.com/sirupsen/logrus"
+	adb "github.com/zach-klippenstein/goadb"
+)
+
+// DefaultPackage - Rise of Kingdoms, Google Play version
+const DefaultPackage = "com.lilithgame.roc.gp"
+
+type Options struct {
+	Container Container
+	// ADBPort - port of the local adb server
+	ADBPort     int
+	BootTimeout time.Duration
+	// Package - app to install & launch, APKs - its APK (or base & split APKs), installed if it's missing
+	Package string
+	APKs    []string
+	// StartDelay - wait after launching the game, before the first routine (loading screen)
+	StartDelay time.Duration
+	// Login - done after the game is installed (or always, with Relogin), the login is kept in container's data
+	Login   *Routine
+	Relogin bool
+	// Open - goes from the city view to the ranking list
+	Open        *Routine
+	Credentials Credentials
+	// Scan - captures & recognizes the ranking list, open on the device with serial
+	Scan func(ctx context.Context, serial string) error
+	// StopContainer - stop the container after every cycle, it's started again by the next one
+	StopContainer bool
+}
+
+// Orchestrator - runs full capture & scan cycles on an emulator container: starts the container, installs and
+// launches the game, logs in, opens the ranking list, and scans it
+type Orchestrator struct {
+	opts Options
+}
+
+func New(opts Options) *Orchestrator {
+	return &Orchestrator{opts: opts}
+}
+
+// Cycle - one capture & scan, the game is closed afterwards
+func (o *Orchestrator) Cycle(ctx context.Context) error {
+	c := o.opts.Container
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("can't start container: %v", err)
+	}
+	if o.opts.StopContainer {
+		defer func() {
+			if err := c.Stop(context.Background()); err != nil {
+				log.Errorf("[emulator] can't stop container: %v", err)
+			}
+		}()
+	}
+
+	device, err := Connect(ctx, o.opts.ADBPort, c.Serial(), o.opts.BootTimeout)
+	if err != nil {
+		return err
+	}
+	log.Infof("[emulator] %v is up", c.Serial())
+
+	installed, err := o.install(device)
+	if err != nil {
+		return err
+	}
+
+	// a fresh start, whatever the game was left at
+	if err := Stop(device, o.opts.Package); err != nil {
+		return err
+	}
+	if err := Launch(device, o.opts.Package); err != nil {
+		return fmt.Errorf("can't launch %v: %v", o.opts.Package, err)
+	}
+	defer func() {
+		if err := Stop(device, o.opts.Package); err != nil {
+			log.Warnf("[emulator] can't close %v: %v", o.opts.Package, err)
+		}
+	}()
+	log.Infof("[emulator] launched %v, waiting %v for it to load", o.opts.Package, o.opts.StartDelay)
+	if err := wait(ctx, o.opts.StartDelay); err != nil {
+		return err
+	}
+
+	screen, err := adbcapture.Screenshot(device)
+	if err != nil {
+		return fmt.Errorf("can't take a screenshot: %v", err)
+	}
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+
+	if o.opts.Login != nil && (installed || o.opts.Relogin) {
+		log.Infof("[emulator] logging in")
+		if err := o.opts.Login.Run(ctx, device, w, h, o.opts.Credentials); err != nil {
+			return fmt.Errorf("login failed: %v", err)
+		}
+	}
+
+	if o.opts.Open != nil {
+		log.Infof("[emulator] opening the ranking list")
+		if err := o.opts.Open.Run(ctx, device, w, h, o.opts.Credentials); err != nil {
+			return fmt.Errorf("can't open the ranking list: %v", err)
+		}
+	}
+
+	return o.opts.Scan(ctx, c.Serial())
+}
+
+// install - installs the game if it's missing, true if it was installed now
+func (o *Orchestrator) install(device *adb.Device) (bool, error) {
+	ok, err := Installed(device, o.opts.Package)
+	if err != nil || ok {
+		return false, err
+	}
+	if len(o.opts.APKs) == 0 {
+		return false, fmt.Errorf("%v is not installed, and there is no APK to install", o.opts.Package)
+	}
+
+	log.Infof("[emulator] installing %v", o.opts.Package)
+	if err := Install(device, o.opts.APKs); err != nil {
+		return false, fmt.Errorf("can't install %v: %v", o.opts.Package, err)
+	}
+	return true, nil
+}
+
+// Run - runs a cycle at every time of the schedule, until ctx is cancelled. Failed cycles are logged,
+// the next one runs on schedule.
+func (o *Orchestrator) Run(ctx context.Context, schedule Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		log.Infof("[emulator] next scan at %v (in %v)", next.Format(time.RFC3339), time.Until(next).Round(time.Second))
+		if err := wait(ctx, time.Until(next)); err != nil {
+			return
+		}
+
+		start := time.Now()
+		if err := o.Cycle(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("[emulator] scan failed: %v", err)
+			continue
+		}
+		log.Infof("[emulator] scan completed in %v", time.Since(start).Round(time.Second))
+	}
+}
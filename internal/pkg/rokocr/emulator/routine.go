@@ -0,0 +1,163 @@
+package emulator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/adbcapture"
+	log "github.com/sirupsen/logrus"
+)
+
+// Step - one action of a routine, only one of the fields is set
+type Step struct {
+	Tap *image.Point `json:"tap,omitempty"`
+	// Swipe - from & to point, taking DurationMs
+	Swipe      []image.Point `json:"swipe,omitempty"`
+	DurationMs int           `json:"duration_ms,omitempty"`
+	// Text - typed into the focused input, ${NAME} is replaced with credential NAME (see Credentials)
+	Text string `json:"text,omitempty"`
+	// Key - keycode name (ENTER, BACK, ...)
+	Key string `json:"key,omitempty"`
+	// WaitMs - wait longer than DelayMs, e.g. for the game to load
+	WaitMs int `json:"wait_ms,omitempty"`
+}
+
+// Routine - steps done on the device, e.g. logging in or opening the ranking list. Coordinates are in
+// Width x Height screen space, and are scaled to the real device resolution (like adbcapture.RankingPlan).
+type Routine struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Steps  []Step `json:"steps"`
+	// DelayMs - wait after every step (milliseconds), the game needs time to animate
+	DelayMs int `json:"delay_ms"`
+}
+
+func LoadRoutine(name string) (Routine, error) {
+	r := Routine{Width: 1920, Height: 1080, DelayMs: 1500}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("invalid routine %v: %v", name, err)
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return r, fmt.Errorf("invalid routine %v: no screen size", name)
+	}
+	for i, s := range r.Steps {
+		if len(s.Swipe) > 0 && len(s.Swipe) != 2 {
+			return r, fmt.Errorf("invalid routine %v: swipe of step %v needs 2 points", name, i+1)
+		}
+	}
+	return r, nil
+}
+
+// Run - does the steps on the device (screen w x h), credentials are looked up in creds
+func (r Routine) Run(ctx context.Context, d adbcapture.Device, w, h int, creds Credentials) error {
+	scale := func(p image.Point) image.Point {
+		return image.Pt(p.X*w/r.Width, p.Y*h/r.Height)
+	}
+
+	for i, s := range r.Steps {
+		var err error
+		switch {
+		case s.Tap != nil:
+			err = adbcapture.Tap(d, scale(*s.Tap))
+		case len(s.Swipe) == 2:
+			err = adbcapture.Swipe(d, scale(s.Swipe[0]), scale(s.Swipe[1]), max(s.DurationMs, 100))
+		case len(s.Text) > 0:
+			var text string
+			if text, err = creds.Expand(s.Text); err == nil {
+				err = adbcapture.Text(d, text)
+			}
+		case len(s.Key) > 0:
+			err = adbcapture.Key(d, s.Key)
+		}
+		if err != nil {
+			// text isn't logged, it's likely a password
+			return fmt.Errorf("step %v: %v", i+1, err)
+		}
+
+		if err := wait(ctx, time.Duration(r.DelayMs+s.WaitMs)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Credentials - game account of the emulator (e.g. ROK_EMAIL, ROK_PASSWORD), typed by routines.
+// Values missing in the file are taken from the environment.
+type Credentials map[string]string
+
+// LoadCredentials - reads NAME=value lines (# comments), missing file gives no credentials
+func LoadCredentials(name string) (Credentials, error) {
+	creds := make(Credentials)
+	if len(name) == 0 {
+		return creds, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%v:%v: expected NAME=value", name, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		creds[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if info, err := f.Stat(); err == nil && info.Mode().Perm()&0o077 != 0 {
+		log.Warnf("Credentials file %v is readable by other users, consider chmod 600", name)
+	}
+	return creds, nil
+}
+
+// Expand - replaces ${NAME} with credential NAME, it's an error if there's no such credential
+func (c Credentials) Expand(text string) (string, error) {
+	var missing []string
+	expanded := os.Expand(text, func(name string) string {
+		if value, ok := c[name]; ok {
+			return value
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		missing = append(missing, name)
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no credential %v (in credentials file, or environment)", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule - times of day (UTC) a cycle runs at, as offsets from midnight
+type Schedule []time.Duration
+
+// ParseSchedule - comma separated HH:MM times, e.g. "00:00" or "00:00,12:00"
+func ParseSchedule(spec string) (Schedule, error) {
+	var s Schedule
+	for _, x := range strings.Split(spec, ",") {
+		if x = strings.TrimSpace(x); len(x) == 0 {
+			continue
+		}
+		t, err := time.Parse("15:04", x)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, expected HH:MM", x)
+		}
+		s = append(s, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("no times in schedule %q", spec)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s, nil
+}
+
+// Next - first scheduled time after now
+func (s Schedule) Next(now time.Time) time.Time {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, offset := range s {
+		if t := midnight.Add(offset); t.After(now) {
+			return t
+		}
+	}
+	return midnight.AddDate(0, 0, 1).Add(s[0])
+}
+
+func (s Schedule) String() string {
+	var times []string
+	for _, offset := range s {
+		times = append(times, time.Time{}.Add(offset).Format("15:04"))
+	}
+	return strings.Join(times, ", ") + " UTC"
+}
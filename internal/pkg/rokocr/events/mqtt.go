@@ -0,0 +1,149 @@
+package events
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// MQTT 3.1.1 packet types (high nibble of the fixed header)
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xe0
+)
+
+// mqttMaxClientID - longest client id every 3.1.1 broker accepts
+const mqttMaxClientID = 23
+
+// mqttConn - publishes with QoS 0, without keep alive (the broker never drops the idle connection, a dead one
+// shows up as a failed write)
+type mqttConn struct {
+	conn net.Conn
+}
+
+func dialMQTT(u *url.URL) (transport, error) {
+	host := u.Host
+	secure := u.Scheme == "mqtts" || u.Scheme == "ssl"
+	if len(u.Port()) == 0 {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if secure {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mqttHandshake(conn, u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &mqttConn{conn: conn}, nil
+}
+
+func mqttHandshake(conn net.Conn, u *url.URL) error {
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	hostname, _ := os.Hostname()
+	var nonce [2]byte
+	_, _ = rand.Read(nonce[:])
+	clientID := mqttClientID(hostname, os.Getpid(), nonce)
+
+	// protocol name & level 4 (3.1.1), flags, keep alive 0
+	flags := byte(0x02) // clean session
+	body := append(mqttString("MQTT"), 4, 0, 0, 0)
+	body = append(body, mqttString(clientID)...)
+	if user := u.User; user != nil {
+		flags |= 0x80
+		body = append(body, mqttString(user.Username())...)
+		if password, ok := user.Password(); ok {
+			flags |= 0x40
+			body = append(body, mqttString(password)...)
+		}
+	}
+	body[7] = flags
+
+	if _, err := conn.Write(mqttPacket(mqttConnect, body)); err != nil {
+		return err
+	}
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("no CONNACK: %w", err)
+	}
+	if ack[0] != mqttConnAck {
+		return errors.New("unexpected reply to CONNECT")
+	}
+	switch ack[3] {
+	case 0:
+		return nil
+	case 4, 5:
+		return errors.New("not authorized, check user & password of the URL")
+	default:
+		return fmt.Errorf("connection refused (code %d)", ack[3])
+	}
+}
+
+// mqttClientID - unique part (pid & random) first, as 3.1.1 brokers only have to accept ids up to 23 characters
+// and the hostname is cut to fit. Two clients with the same id kick each other off the broker.
+func mqttClientID(hostname string, pid int, nonce [2]byte) string {
+	id := fmt.Sprintf("rokocr-%d-%x-", pid, nonce)
+	if n := mqttMaxClientID - len(id); n < len(hostname) {
+		hostname = hostname[:max(n, 0)]
+	}
+	return strings.TrimSuffix(id+hostname, "-")
+}
+
+func (c *mqttConn) publish(topic string, payload []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	_, err := c.conn.Write(mqttPacket(mqttPublish, append(mqttString(topic), payload...)))
+	return err
+}
+
+func (c *mqttConn) close() error {
+	_, _ = c.conn.Write(mqttPacket(mqttDisconnect, nil))
+	return c.conn.Close()
+}
+
+// mqttPacket - fixed header (type & remaining length) followed by body
+func mqttPacket(kind byte, body []byte) []byte {
+	packet := []byte{kind}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttString - UTF-8 string prefixed with its length
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeBroker - accepts a single connection on a local port, serve talks to the client
+func fakeBroker(t *testing.T, serve func(conn net.Conn)) (addr string, done <-chan struct{}) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	return l.Addr().String(), finished
+}
+
+// readMQTTPacket - packet type & body of the next packet
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return kind, body, err
+}
+
+// readMQTTString - length prefixed string at the start of b, and the rest of b
+func readMQTTString(t *testing.T, b []byte) (string, []byte) {
+	t.Helper()
+	if len(b) < 2 {
+		t.Fatalf("no string in %q", b)
+	}
+	n := int(b[0])<<8 | int(b[1])
+	if len(b) < 2+n {
+		t.Fatalf("string of %d bytes in %q", n, b)
+	}
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+func TestMQTTPublish(t *testing.T) {
+	type connect struct {
+		protocol       string
+		level, flags   byte
+		clientID       string
+		user, password string
+		topic          string
+		payload        string
+		disconnected   bool
+	}
+	var got connect
+
+	addr, done := fakeBroker(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		kind, body, err := readMQTTPacket(r)
+		if err != nil || kind != mqttConnect {
+			t.Errorf("CONNECT = %x, %v", kind, err)
+			return
+		}
+		got.protocol, body = readMQTTString(t, body)
+		got.level, got.flags = body[0], body[1]
+		// keep alive
+		body = body[4:]
+		got.clientID, body = readMQTTString(t, body)
+		got.user, body = readMQTTString(t, body)
+		got.password, _ = readMQTTString(t, body)
+
+		_, _ = conn.Write([]byte{mqttConnAck, 2, 0, 0})
+
+		kind, body, err = readMQTTPacket(r)
+		if err != nil || kind != mqttPublish {
+			t.Errorf("PUBLISH = %x, %v", kind, err)
+			return
+		}
+		got.topic, body = readMQTTString(t, body)
+		got.payload = string(body)
+
+		kind, _, err = readMQTTPacket(r)
+		got.disconnected = err == nil && kind == mqttDisconnect
+	})
+
+	u, _ := url.Parse("mqtt://scanner:secret@" + addr)
+	conn, err := dialMQTT(u)
+	if err != nil {
+		t.Fatalf("dialMQTT(): %v", err)
+	}
+	// payload over 127 bytes has a 2 byte remaining length
+	payload := `{"type":"scan.result","template":"` + strings.Repeat("x", 200) + `"}`
+	if err := conn.publish("rokocr/scan/result", []byte(payload)); err != nil {
+		t.Fatalf("publish(): %v", err)
+	}
+	if err := conn.close(); err != nil {
+		t.Fatalf("close(): %v", err)
+	}
+	<-done
+
+	if got.protocol != "MQTT" || got.level != 4 {
+		t.Errorf("protocol = %v level %v, want MQTT level 4", got.protocol, got.level)
+	}
+	// clean session, user & password
+	if got.flags != 0xc2 {
+		t.Errorf("connect flags = %08b, want 11000010", got.flags)
+	}
+	if len(got.clientID) == 0 || len(got.clientID) > mqttMaxClientID || !strings.HasPrefix(got.clientID, "rokocr-") {
+		t.Errorf("client id = %q", got.clientID)
+	}
+	if got.user != "scanner" || got.password != "secret" {
+		t.Errorf("credentials = %v:%v", got.user, got.password)
+	}
+	if got.topic != "rokocr/scan/result" || got.payload != payload {
+		t.Errorf("published %q to %v", got.payload, got.topic)
+	}
+	if !got.disconnected {
+		t.Errorf("no DISCONNECT before closing")
+	}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	addr, _ := fakeBroker(t, func(conn net.Conn) {
+		_, _, _ = readMQTTPacket(bufio.NewReader(conn))
+		// bad user name or password
+		_, _ = conn.Write([]byte{mqttConnAck, 2, 0, 4})
+	})
+
+	u, _ := url.Parse("mqtt://scanner:wrong@" + addr)
+	if _, err := dialMQTT(u); err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("dialMQTT() = %v, want not authorized", err)
+	}
+}
+
+func TestMQTTClientID(t *testing.T) {
+	nonce := [2]byte{0xab, 0xcd}
+	tests := []struct {
+		hostname string
+		pid      int
+		want     string
+	}{
+		{"box", 42, "rokocr-42-abcd-box"},
+		{"", 42, "rokocr-42-abcd"},
+		{"scanner-7d9f8b6c4-x2k9z", 1234, "rokocr-1234-abcd-scanne"},
+		{"scanner-7d9f8b6c4-x2k9z", 4194304, "rokocr-4194304-abcd-sca"},
+	}
+	for _, tt := range tests {
+		got := mqttClientID(tt.hostname, tt.pid, nonce)
+		if got != tt.want {
+			t.Errorf("mqttClientID(%q, %v) = %q, want %q", tt.hostname, tt.pid, got, tt.want)
+		}
+		if len(got) > mqttMaxClientID {
+			t.Errorf("mqttClientID(%q, %v) is %d characters long", tt.hostname, tt.pid, len(got))
+		}
+	}
+
+	// the pid survives long hostnames, clients of one host don't share the id
+	a := mqttClientID("a-very-long-hostname-of-a-kubernetes-pod", 100, nonce)
+	b := mqttClientID("a-very-long-hostname-of-a-kubernetes-pod", 101, nonce)
+	if a == b {
+		t.Errorf("clients of different processes share id %q", a)
+	}
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsConn - publishes with the NATS text protocol, answering PINGs of the server so it keeps the connection
+type natsConn struct {
+	conn net.Conn
+	// mu - PUB & PONG are written from different goroutines
+	mu sync.Mutex
+}
+
+func dialNATS(u *url.URL) (transport, error) {
+	host := u.Host
+	if len(u.Port()) == 0 {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := natsHandshake(conn, reader, u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &natsConn{conn: conn}
+	go c.read(reader)
+	return c, nil
+}
+
+func natsHandshake(conn net.Conn, reader *bufio.Reader, u *url.URL) error {
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no INFO: %w", err)
+	}
+	if strings.Contains(info, `"tls_required":true`) {
+		return errors.New("server requires TLS, which isn't supported")
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "rokocr", "lang": "go"}
+	if user := u.User; user != nil {
+		if password, ok := user.Password(); ok {
+			options["user"], options["pass"] = user.Username(), password
+		} else {
+			options["auth_token"] = user.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	// PONG confirms CONNECT was accepted, -ERR comes first otherwise
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("no PONG: %w", err)
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+	}
+}
+
+// read - answers PINGs, until the connection is closed
+func (c *natsConn) read(reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(line) == "PING" {
+			_ = c.write([]byte("PONG\r\n"))
+		}
+	}
+}
+
+func (c *natsConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+func (c *natsConn) publish(subject string, payload []byte) error {
+	msg := append([]byte(fmt.Sprintf("PUB %s %d\r\n", subject, len(payload))), payload...)
+	return c.write(append(msg, "\r\n"...))
+}
+
+func (c *natsConn) close() error {
+	return c.conn.Close()
+}
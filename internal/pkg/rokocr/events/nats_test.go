@@ -0,0 +1,136 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNATSPublish(t *testing.T) {
+	var options map[string]interface{}
+	var subject, payload string
+	var ponged bool
+
+	addr, done := fakeBroker(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		_, _ = fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":1048576}\r\n")
+
+		line, _ := r.ReadString('\n')
+		connect, ok := strings.CutPrefix(strings.TrimSpace(line), "CONNECT ")
+		if !ok || json.Unmarshal([]byte(connect), &options) != nil {
+			t.Errorf("expected CONNECT, got %q", line)
+			return
+		}
+		if line, _ := r.ReadString('\n'); strings.TrimSpace(line) != "PING" {
+			t.Errorf("expected PING, got %q", line)
+			return
+		}
+		_, _ = fmt.Fprintf(conn, "PONG\r\n")
+
+		// the server pings idle clients, they have to answer
+		_, _ = fmt.Fprintf(conn, "PING\r\n")
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		// PONG & PUB come in any order
+		for !ponged || len(subject) == 0 {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Errorf("read: %v", err)
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PONG" {
+				ponged = true
+				continue
+			}
+
+			var size int
+			if _, err := fmt.Sscanf(line, "PUB %s %d", &subject, &size); err != nil {
+				t.Errorf("expected PUB, got %q", line)
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				t.Errorf("payload: %v", err)
+			}
+			payload = string(data[:size])
+		}
+	})
+
+	u, _ := url.Parse("nats://scanner:secret@" + addr)
+	conn, err := dialNATS(u)
+	if err != nil {
+		t.Fatalf("dialNATS(): %v", err)
+	}
+	defer conn.close()
+
+	if err := conn.publish("rokocr.scan.result", []byte(`{"type":"scan.result"}`)); err != nil {
+		t.Fatalf("publish(): %v", err)
+	}
+	<-done
+
+	if options["user"] != "scanner" || options["pass"] != "secret" || options["verbose"] != false {
+		t.Errorf("CONNECT options = %v", options)
+	}
+	if !ponged {
+		t.Errorf("PING of the server wasn't answered")
+	}
+	if subject != "rokocr.scan.result" || payload != `{"type":"scan.result"}` {
+		t.Errorf("published %q to %v", payload, subject)
+	}
+}
+
+func TestNATSToken(t *testing.T) {
+	var options map[string]interface{}
+	addr, _ := fakeBroker(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		_, _ = fmt.Fprintf(conn, "INFO {}\r\n")
+		line, _ := r.ReadString('\n')
+		_ = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &options)
+		_, _ = r.ReadString('\n')
+		_, _ = fmt.Fprintf(conn, "PONG\r\n")
+	})
+
+	u, _ := url.Parse("nats://s3cr3t@" + addr)
+	conn, err := dialNATS(u)
+	if err != nil {
+		t.Fatalf("dialNATS(): %v", err)
+	}
+	conn.close()
+
+	if options["auth_token"] != "s3cr3t" || options["user"] != nil {
+		t.Errorf("CONNECT options = %v, want auth_token", options)
+	}
+}
+
+func TestNATSConnectRejected(t *testing.T) {
+	addr, _ := fakeBroker(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		_, _ = fmt.Fprintf(conn, "INFO {\"auth_required\":true}\r\n")
+		_, _ = r.ReadString('\n')
+		_, _ = r.ReadString('\n')
+		_, _ = fmt.Fprintf(conn, "-ERR 'Authorization Violation'\r\n")
+	})
+
+	u, _ := url.Parse("nats://scanner:wrong@" + addr)
+	if _, err := dialNATS(u); err == nil || err.Error() != "Authorization Violation" {
+		t.Errorf("dialNATS() = %v, want Authorization Violation", err)
+	}
+}
+
+func TestNATSRequiresTLS(t *testing.T) {
+	addr, _ := fakeBroker(t, func(conn net.Conn) {
+		_, _ = fmt.Fprintf(conn, "INFO {\"tls_required\":true}\r\n")
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+	})
+
+	u, _ := url.Parse("nats://" + addr)
+	if _, err := dialNATS(u); err == nil || !strings.Contains(err.Error(), "TLS") {
+		t.Errorf("dialNATS() = %v, want TLS error", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Package fieldcache - recognized fields by perceptual hash of their crop, so re-running a scan (e.g. after fixing
+// one template) or overlapping captures of the same screen skip OCR of crops which were already read.
+package fieldcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"sync/atomic"
+	"time"
+
+	"github.com/corona10/goimagehash"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	bolt "go.etcd.io/bbolt"
+)
+
+var fieldsBucket = []byte("fields")
+
+// hashSize - side of the perception hash (16x16 - 256 bits), small text differences (a single digit) still change it
+const hashSize = 16
+
+// Stats - lookups since the cache was opened
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// HitRate - share of lookups (0-1) which skipped OCR
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache - fields in an embedded (bbolt) database, safe for concurrent use
+type Cache struct {
+	db *bolt.DB
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func Open(path string) (*Cache, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can't open field cache %v: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(fieldsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Cache{db: db}, nil
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Key - key of the crop recognized with settings (anything changing the result - languages, psm, preprocessing,
+// engine, ...), JSON encoded into the key
+func Key(crop image.Image, settings interface{}) (string, error) {
+	hash, err := goimagehash.ExtPerceptionHash(crop, hashSize, hashSize)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(data)
+	size := crop.Bounds().Size()
+	return fmt.Sprintf("%s:%dx%d:%s", hex.EncodeToString(sum[:8]), size.X, size.Y, hash.ToString()), nil
+}
+
+// Get - cached field of the key, counted as hit or miss
+func (c *Cache) Get(key string) (schema.FieldResult, bool) {
+	var field schema.FieldResult
+	found := false
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(fieldsBucket).Get([]byte(key)); data != nil {
+			found = json.Unmarshal(data, &field) == nil
+		}
+		return nil
+	})
+
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return field, found
+}
+
+func (c *Cache) Put(key string, field schema.FieldResult) error {
+	data, err := json.Marshal(field)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(fieldsBucket).Put([]byte(key), data)
+	})
+}
+
+// Len - how many fields are cached
+func (c *Cache) Len() int {
+	n := 0
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(fieldsBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
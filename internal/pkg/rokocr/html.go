@@ -0,0 +1,162 @@
+package rokocr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// thumbnails are only scaled down, to fit this box
+const (
+	thumbnailMaxWidth  = 240
+	thumbnailMaxHeight = 48
+)
+
+// HTMLCrops - crops of the fields the result was read from (see OCRTemplate.ResultCrops), nil if not available
+type HTMLCrops func(r schema.OCRResult) map[string]image.Image
+
+type htmlCell struct {
+	Value      string
+	Thumbnail  template.URL
+	Confidence float64
+	Text       string
+	// Suspicious - low confidence, or the value fails validation (Problems)
+	Suspicious bool
+	Problems   []string
+}
+
+type htmlRow struct {
+	Filename string
+	Cells    []htmlCell
+	Error    string
+}
+
+type htmlReport struct {
+	Title      string
+	Generated  string
+	Columns    []schema.OCRTableField
+	Rows       []htmlRow
+	Suspicious int
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"suspicious": htmlRowSuspicious,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }} - {{ .Generated }}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; background: #fafafa; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 6px; vertical-align: top; text-align: left; }
+th { background: #d9d9d9; position: sticky; top: 0; }
+td img { display: block; margin-top: 4px; }
+td.suspicious { background: #ffe0b2; }
+td .meta { color: #777; font-size: 0.8em; }
+tr.failed td { background: #ffcdd2; }
+label { display: block; margin: 0.5em 0; }
+body.only-suspicious tr.clean { display: none; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<p>{{ len .Rows }} rows, {{ .Suspicious }} with suspicious values, generated {{ .Generated }}</p>
+<label><input type="checkbox" onchange="document.body.classList.toggle('only-suspicious', this.checked)"> Only rows with suspicious values</label>
+<table>
+<thead><tr><th>Filename</th>{{ range .Columns }}<th>{{ .Title }}</th>{{ end }}</tr></thead>
+<tbody>
+{{- range .Rows }}
+<tr class="{{ if .Error }}failed{{ else if suspicious . }}suspicious{{ else }}clean{{ end }}">
+<td>{{ .Filename }}{{ if .Error }}<div class="meta">{{ .Error }}</div>{{ end }}</td>
+{{- range .Cells }}
+<td{{ if .Suspicious }} class="suspicious"{{ end }}{{ if .Problems }} title="{{ range .Problems }}{{ . }}&#10;{{ end }}"{{ end }}>{{ .Value }}
+{{- if .Thumbnail }}<img src="{{ .Thumbnail }}" alt="crop">{{ end }}
+{{- if .Text }}<div class="meta">{{ .Text }} ({{ printf "%.0f" .Confidence }}%)</div>{{ end }}</td>
+{{- end }}
+</tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// WriteHTMLReport - standalone HTML page with the results table, every value next to a thumbnail of the crop
+// it was read from, so suspicious values (low confidence or failing validation, highlighted) can be checked
+// without opening the screenshots. Columns like WriteCSV.
+func WriteHTMLReport(data []schema.OCRResult, template schema.OCRTemplate, crops HTMLCrops, w io.Writer) error {
+	report := htmlReport{
+		Title:     template.Title,
+		Generated: time.Now().Format(time.RFC1123),
+		Columns:   ExportColumns(template, data),
+	}
+
+	for _, r := range data {
+		var thumbnails map[string]image.Image
+		if crops != nil {
+			thumbnails = crops(r)
+		}
+		problems := template.ValidateResult(r)
+
+		row := htmlRow{Filename: r.Filename, Error: r.Error}
+		for _, x := range report.Columns {
+			cell := htmlCell{Value: schema.FormatValue(r.Data[x.Field]), Problems: problems[x.Field]}
+			if f, ok := r.Fields[x.Field]; ok {
+				cell.Text = strings.TrimSpace(f.Text)
+				cell.Confidence = f.Confidence
+				cell.Suspicious = f.LowConfidence
+			}
+			cell.Suspicious = cell.Suspicious || len(cell.Problems) > 0
+			if img, ok := thumbnails[x.Field]; ok {
+				if uri, err := thumbnailURI(img); err == nil {
+					cell.Thumbnail = uri
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		if htmlRowSuspicious(row) {
+			report.Suspicious++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
+
+func htmlRowSuspicious(row htmlRow) bool {
+	for _, c := range row.Cells {
+		if c.Suspicious {
+			return true
+		}
+	}
+	return false
+}
+
+// thumbnailURI - crop scaled down to the thumbnail box, as PNG data URI
+func thumbnailURI(img image.Image) (template.URL, error) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w > thumbnailMaxWidth {
+		w, h = thumbnailMaxWidth, h*thumbnailMaxWidth/w
+	}
+	if h > thumbnailMaxHeight {
+		w, h = w*thumbnailMaxHeight/h, thumbnailMaxHeight
+	}
+	if (w != img.Bounds().Dx() || h != img.Bounds().Dy()) && w > 0 && h > 0 {
+		img = imgutils.ResizeImage(img, w, h)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
@@ -0,0 +1,50 @@
+package rokocr
+
+import (
+	"sort"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// Ranked - result & its value of the ranked field
+type Ranked struct {
+	Result schema.OCRResult
+	Value  float64
+}
+
+// Leaderboard - results with numeric valueField, biggest value first. Governors present several times (e.g. on two
+// overlapping screenshots) are ranked once, by their biggest value.
+func Leaderboard(data []schema.OCRResult, template schema.OCRTemplate, keyField, valueField string) []Ranked {
+	var ranked []Ranked
+	seen := make(map[string]int)
+	for _, r := range data {
+		v, err := numericValue(r.Data[valueField])
+		if err != nil {
+			continue
+		}
+
+		key := template.KeyOf(r, keyField)
+		if i, ok := seen[key]; ok && len(key) > 0 {
+			if v > ranked[i].Value {
+				ranked[i] = Ranked{Result: r, Value: v}
+			}
+			continue
+		}
+		seen[key] = len(ranked)
+		ranked = append(ranked, Ranked{Result: r, Value: v})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Value > ranked[j].Value })
+	return ranked
+}
+
+// NumericColumns - table columns of int & float fields, the ones worth ranking governors by
+func NumericColumns(template schema.OCRTemplate) []schema.OCRTableField {
+	var columns []schema.OCRTableField
+	for _, c := range tableColumns(template) {
+		if s, ok := template.OCRSchema[c.Field]; ok && (s.Type == schema.TypeInt || s.Type == schema.TypeFloat) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,63 @@
+package ocrengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// cliEngine - runs tesseract binary, slower than bindings (traineddata is loaded for every crop), but needs no cgo
+type cliEngine struct {
+	binary string
+	slots  semaphore
+}
+
+func newCLI(binary string, size int) (Engine, error) {
+	if len(binary) == 0 {
+		binary = "tesseract"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("tesseract binary not found: %w", err)
+	}
+	return &cliEngine{binary: path, slots: make(semaphore, size)}, nil
+}
+
+func (e *cliEngine) args(imageFileName string, s schema.OCRSchema, tessdata string) []string {
+	args := []string{imageFileName, "stdout", "-l", strings.Join(languages(s), "+"), "--psm", fmt.Sprint(s.PSM)}
+	if len(tessdata) > 0 {
+		args = append(args, "--tessdata-dir", tessdata)
+	}
+	if s.OEM > 0 {
+		args = append(args, "--oem", fmt.Sprint(s.OEM))
+	}
+	if chars := whitelist(s); len(chars) > 0 {
+		args = append(args, "-c", "tessedit_char_whitelist="+chars)
+	}
+	return append(args, "tsv")
+}
+
+func (e *cliEngine) Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, tessdata string) (string, float64, error) {
+	if err := e.slots.acquire(ctx); err != nil {
+		return "", 0, err
+	}
+	defer e.slots.release()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.binary, e.args(imageFileName, s, tessdata)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", 0, fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTSV(stdout.String())
+}
+
+func (e *cliEngine) Close() error {
+	return nil
+}
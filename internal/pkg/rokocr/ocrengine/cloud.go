@@ -0,0 +1,268 @@
+package ocrengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// cloudLanguages - tesseract traineddata names => BCP-47 language hints of cloud engines
+var cloudLanguages = map[string]string{
+	"eng":     "en",
+	"rus":     "ru",
+	"ukr":     "uk",
+	"fra":     "fr",
+	"deu":     "de",
+	"spa":     "es",
+	"por":     "pt",
+	"ita":     "it",
+	"pol":     "pl",
+	"tur":     "tr",
+	"ara":     "ar",
+	"vie":     "vi",
+	"tha":     "th",
+	"ind":     "id",
+	"jpn":     "ja",
+	"kor":     "ko",
+	"chi_sim": "zh-Hans",
+	"chi_tra": "zh-Hant",
+}
+
+func languageHints(s schema.OCRSchema) []string {
+	var hints []string
+	for _, l := range s.Languages {
+		if hint, ok := cloudLanguages[l]; ok {
+			hints = append(hints, hint)
+		}
+	}
+	return hints
+}
+
+// googleEngine - Google Cloud Vision document text detection, authenticated with an API key
+type googleEngine struct {
+	url    string
+	key    string
+	client *http.Client
+	slots  semaphore
+}
+
+type googleRequest struct {
+	Requests []googleImageRequest `json:"requests"`
+}
+
+type googleImageRequest struct {
+	Image struct {
+		Content string `json:"content"`
+	} `json:"image"`
+	Features []struct {
+		Type string `json:"type"`
+	} `json:"features"`
+	ImageContext struct {
+		LanguageHints []string `json:"languageHints,omitempty"`
+	} `json:"imageContext"`
+}
+
+type googleResponse struct {
+	Responses []struct {
+		FullTextAnnotation struct {
+			Text  string `json:"text"`
+			Pages []struct {
+				Blocks []struct {
+					Paragraphs []struct {
+						Words []struct {
+							Confidence float64 `json:"confidence"`
+						} `json:"words"`
+					} `json:"paragraphs"`
+				} `json:"blocks"`
+			} `json:"pages"`
+		} `json:"fullTextAnnotation"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"responses"`
+}
+
+func newGoogle(apiKey string, size int) (Engine, error) {
+	if len(apiKey) == 0 {
+		return nil, errors.New("google engine needs an API key of Cloud Vision")
+	}
+	return &googleEngine{
+		url:    "https://vision.googleapis.com/v1/images:annotate",
+		key:    apiKey,
+		client: newHTTPClient(),
+		slots:  make(semaphore, size),
+	}, nil
+}
+
+func (e *googleEngine) Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, _ string) (string, float64, error) {
+	if err := e.slots.acquire(ctx); err != nil {
+		return "", 0, err
+	}
+	defer e.slots.release()
+
+	content, err := os.ReadFile(imageFileName)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var r googleImageRequest
+	r.Image.Content = base64.StdEncoding.EncodeToString(content)
+	r.Features = append(r.Features, struct {
+		Type string `json:"type"`
+	}{Type: "DOCUMENT_TEXT_DETECTION"})
+	r.ImageContext.LanguageHints = languageHints(s)
+
+	body, err := json.Marshal(googleRequest{Requests: []googleImageRequest{r}})
+	if err != nil {
+		return "", 0, err
+	}
+
+	// key in the header, not the query, keeps it out of errors & proxy logs
+	b, err := post(ctx, e.client, e.url, "application/json", bytes.NewReader(body), http.Header{"X-Goog-Api-Key": {e.key}})
+	if err != nil {
+		return "", 0, err
+	}
+
+	var result googleResponse
+	if err := json.Unmarshal(b, &result); err != nil {
+		return "", 0, fmt.Errorf("invalid cloud vision response: %w", err)
+	}
+	if len(result.Responses) == 0 {
+		return "", 0, nil
+	}
+	if e := result.Responses[0].Error; e != nil {
+		return "", 0, fmt.Errorf("cloud vision error %d: %s", e.Code, e.Message)
+	}
+
+	sum, count := 0.0, 0
+	annotation := result.Responses[0].FullTextAnnotation
+	for _, p := range annotation.Pages {
+		for _, b := range p.Blocks {
+			for _, par := range b.Paragraphs {
+				for _, w := range par.Words {
+					sum = sum + w.Confidence
+					count++
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return annotation.Text, 0, nil
+	}
+	return annotation.Text, 100 * sum / float64(count), nil
+}
+
+func (e *googleEngine) Close() error {
+	return nil
+}
+
+// azureMinSize - images smaller than this (in any dimension) are rejected by Azure, crops are upscaled
+const azureMinSize = 50
+
+// azureEngine - Azure AI Vision (Image Analysis 4.0) read feature
+type azureEngine struct {
+	url    string
+	key    string
+	client *http.Client
+	slots  semaphore
+}
+
+type azureResponse struct {
+	ReadResult struct {
+		Blocks []struct {
+			Lines []struct {
+				Text  string `json:"text"`
+				Words []struct {
+					Confidence float64 `json:"confidence"`
+				} `json:"words"`
+			} `json:"lines"`
+		} `json:"blocks"`
+	} `json:"readResult"`
+}
+
+func newAzure(endpoint, key string, size int) (Engine, error) {
+	if !strings.HasPrefix(endpoint, "https://") || len(key) == 0 {
+		return nil, errors.New("azure engine needs endpoint (https://<resource>.cognitiveservices.azure.com) and key of AI Vision resource")
+	}
+	return &azureEngine{
+		url:    strings.TrimSuffix(endpoint, "/") + "/computervision/imageanalysis:analyze?api-version=2023-10-01&features=read",
+		key:    key,
+		client: newHTTPClient(),
+		slots:  make(semaphore, size),
+	}, nil
+}
+
+// azureImage - PNG of the crop, upscaled to be at least azureMinSize
+func azureImage(imageFileName string) ([]byte, error) {
+	img, err := imgutils.ReadImageFile(imageFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("empty image %v", imageFileName)
+	}
+	if b.Dx() < azureMinSize || b.Dy() < azureMinSize {
+		factor := math.Ceil(float64(azureMinSize) / float64(min(b.Dx(), b.Dy())))
+		img = imgutils.Upscale(img, factor)
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func (e *azureEngine) Recognize(ctx context.Context, imageFileName string, _ schema.OCRSchema, _ string) (string, float64, error) {
+	if err := e.slots.acquire(ctx); err != nil {
+		return "", 0, err
+	}
+	defer e.slots.release()
+
+	content, err := azureImage(imageFileName)
+	if err != nil {
+		return "", 0, err
+	}
+
+	b, err := post(ctx, e.client, e.url, "application/octet-stream", bytes.NewReader(content), http.Header{"Ocp-Apim-Subscription-Key": {e.key}})
+	if err != nil {
+		return "", 0, err
+	}
+
+	var result azureResponse
+	if err := json.Unmarshal(b, &result); err != nil {
+		return "", 0, fmt.Errorf("invalid azure vision response: %w", err)
+	}
+
+	var lines []string
+	sum, count := 0.0, 0
+	for _, block := range result.ReadResult.Blocks {
+		for _, line := range block.Lines {
+			lines = append(lines, line.Text)
+			for _, w := range line.Words {
+				sum = sum + w.Confidence
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return strings.Join(lines, "\n"), 0, nil
+	}
+	return strings.Join(lines, "\n") + "\n", 100 * sum / float64(count), nil
+}
+
+func (e *azureEngine) Close() error {
+	return nil
+}
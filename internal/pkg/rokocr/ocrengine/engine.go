@@ -0,0 +1,108 @@
+package ocrengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// supported engines
+const (
+	// Gosseract - libtesseract bindings, needs cgo
+	Gosseract = "gosseract"
+	// CLI - runs tesseract binary for every crop, works without cgo (e.g. on Windows)
+	CLI = "cli"
+	// Remote - posts crops to a tesseract server (https://github.com/hertzg/tesseract-server)
+	Remote = "remote"
+	// Google - Google Cloud Vision, better with CJK names, paid per request
+	Google = "google"
+	// Azure - Azure AI Vision read, paid per request
+	Azure = "azure"
+)
+
+// Engine - recognizes text of a single (already cropped & preprocessed) image
+type Engine interface {
+	// Recognize - returns text & average word confidence (0-100)
+	Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, tessdata string) (string, float64, error)
+	// Close - releases resources, engine must not be used afterwards
+	Close() error
+}
+
+type Config struct {
+	// Name - one of Gosseract, CLI or Remote, empty - DefaultName
+	Name string
+	// Binary - path of tesseract binary (CLI)
+	Binary string
+	// URL - address of tesseract server (Remote)
+	URL string
+	// GoogleAPIKey - Cloud Vision API key (Google)
+	GoogleAPIKey string
+	// AzureEndpoint & AzureKey - AI Vision resource (Azure)
+	AzureEndpoint string
+	AzureKey      string
+	// Size - how many crops are recognized concurrently, 0 or 1 - serial
+	Size int
+}
+
+func DefaultConfig() Config {
+	return Config{Name: DefaultName, Binary: "tesseract"}
+}
+
+// Names - supported engine names
+func Names() []string {
+	return []string{Gosseract, CLI, Remote, Google, Azure}
+}
+
+// New - creates the engine described by config
+func New(cfg Config) (Engine, error) {
+	if cfg.Size < 1 {
+		cfg.Size = 1
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Name)) {
+	case "":
+		cfg.Name = DefaultName
+		return New(cfg)
+	case Gosseract:
+		return newGosseract(cfg.Size)
+	case CLI:
+		return newCLI(cfg.Binary, cfg.Size)
+	case Remote:
+		return newRemote(cfg.URL, cfg.Size)
+	case Google:
+		return newGoogle(cfg.GoogleAPIKey, cfg.Size)
+	case Azure:
+		return newAzure(cfg.AzureEndpoint, cfg.AzureKey, cfg.Size)
+	default:
+		return nil, fmt.Errorf("unknown OCR engine %q, expected one of: %v", cfg.Name, strings.Join(Names(), ", "))
+	}
+}
+
+func languages(s schema.OCRSchema) []string {
+	if len(s.Languages) > 0 {
+		return s.Languages
+	}
+	return []string{"eng"}
+}
+
+func whitelist(s schema.OCRSchema) string {
+	return s.AllowList.Chars()
+}
+
+// semaphore - bounds concurrency of engines which don't have a pool of their own
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
@@ -0,0 +1,111 @@
+//go:build cgo
+
+package ocrengine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/otiai10/gosseract/v2"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// DefaultName - engine used when none is configured
+const DefaultName = Gosseract
+
+// gosseractEngine - bounded pool of tesseract clients. Creating a client (and loading traineddata) is expensive,
+// so workers reuse them; get blocks while all clients are busy, which also bounds the OCR concurrency.
+type gosseractEngine struct {
+	slots chan struct{}
+	idle  chan *gosseract.Client
+
+	// closed - clients still in use when the engine is closed are closed once they are put back
+	mu     sync.Mutex
+	closed bool
+}
+
+func newGosseract(size int) (Engine, error) {
+	return &gosseractEngine{
+		slots: make(chan struct{}, size),
+		idle:  make(chan *gosseract.Client, size),
+	}, nil
+}
+
+func (p *gosseractEngine) get(ctx context.Context) (*gosseract.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case c := <-p.idle:
+		return c, nil
+	default:
+		return gosseract.NewClient(), nil
+	}
+}
+
+func (p *gosseractEngine) put(c *gosseract.Client) {
+	p.mu.Lock()
+	if p.closed {
+		_ = c.Close()
+	} else {
+		p.idle <- c
+	}
+	p.mu.Unlock()
+	<-p.slots
+}
+
+func (p *gosseractEngine) Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, tessdata string) (string, float64, error) {
+	client, err := p.get(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	defer p.put(client)
+
+	// client is reused between calls, so every setting has to be (re)set here
+	_ = client.SetTessdataPrefix(tessdata)
+	_ = client.SetLanguage(languages(s)...)
+	_ = client.SetPageSegMode(gosseract.PageSegMode(s.PSM))
+	_ = client.SetImage(imageFileName)
+	_ = client.SetWhitelist(whitelist(s))
+
+	text, err := client.Text()
+	if err != nil {
+		return "", 0, err
+	}
+
+	return text, wordConfidence(client), nil
+}
+
+func wordConfidence(client *gosseract.Client) float64 {
+	boxes, err := client.GetBoundingBoxes(gosseract.RIL_WORD)
+	if err != nil || len(boxes) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, b := range boxes {
+		sum = sum + b.Confidence
+	}
+	return sum / float64(len(boxes))
+}
+
+// Close - closes idle clients, busy ones are closed when their recognition is done
+func (p *gosseractEngine) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for {
+		select {
+		case c := <-p.idle:
+			_ = c.Close()
+		default:
+			return nil
+		}
+	}
+}
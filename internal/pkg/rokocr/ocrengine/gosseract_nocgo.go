@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package ocrengine
+
+import "errors"
+
+// DefaultName - engine used when none is configured, bindings aren't available without cgo
+const DefaultName = CLI
+
+func newGosseract(size int) (Engine, error) {
+	return nil, errors.New("gosseract engine isn't available, binary was built without cgo (use cli or remote engine)")
+}
@@ -0,0 +1,62 @@
+package ocrengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// post - sends the request, transport failures, 429 & 5xx are transient (retried by recognition)
+func post(ctx context.Context, client *http.Client, url, contentType string, body io.Reader, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redact(urlErr.URL)
+		}
+		return nil, retryutils.Retryable(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, retryutils.Retryable(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("POST %v: %v", redact(url), strings.TrimSpace(string(b)))}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, retryutils.Retryable(err)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// redact - hides query (api keys) from errors
+func redact(url string) string {
+	if i := strings.Index(url, "?"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
@@ -0,0 +1,28 @@
+package ocrengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostRedactsKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	url := server.URL + "/v1/images:annotate?key=secret"
+
+	_, err := post(context.Background(), newHTTPClient(), url, "application/json", strings.NewReader("{}"), nil)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got %v, want error without the key", err)
+	}
+
+	// transport errors carry the url too
+	server.Close()
+	_, err = post(context.Background(), newHTTPClient(), url, "application/json", strings.NewReader("{}"), nil)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got %v, want error without the key", err)
+	}
+}
@@ -0,0 +1,121 @@
+package ocrengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// remoteEngine - tesseract server, so the machine running the scanner doesn't need tesseract at all.
+// Server uses its own traineddata, tessdata directory is ignored.
+type remoteEngine struct {
+	url    string
+	client *http.Client
+	slots  semaphore
+}
+
+type remoteOptions struct {
+	Languages    []string          `json:"languages"`
+	PSM          int               `json:"pageSegmentationMethod"`
+	OEM          *int              `json:"ocrEngineMode,omitempty"`
+	ConfigParams map[string]string `json:"configParams,omitempty"`
+}
+
+type remoteResponse struct {
+	Data struct {
+		Stdout string `json:"stdout"`
+		Stderr string `json:"stderr"`
+	} `json:"data"`
+}
+
+func newRemote(url string, size int) (Engine, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid tesseract server URL %q, expected http(s)://host:port", url)
+	}
+	return &remoteEngine{
+		url:    strings.TrimSuffix(url, "/") + "/tesseract",
+		client: newHTTPClient(),
+		slots:  make(semaphore, size),
+	}, nil
+}
+
+func (e *remoteEngine) body(imageFileName string, s schema.OCRSchema) (*bytes.Buffer, string, error) {
+	opts := remoteOptions{
+		Languages: languages(s),
+		PSM:       s.PSM,
+		// tsv output, for word confidences
+		ConfigParams: map[string]string{"tessedit_create_tsv": "1"},
+	}
+	if s.OEM > 0 {
+		opts.OEM = &s.OEM
+	}
+	if chars := whitelist(s); len(chars) > 0 {
+		opts.ConfigParams["tessedit_char_whitelist"] = chars
+	}
+
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	image, err := os.ReadFile(imageFileName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("options", string(options)); err != nil {
+		return nil, "", err
+	}
+	part, err := w.CreateFormFile("file", filepath.Base(imageFileName))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(image); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, w.FormDataContentType(), nil
+}
+
+func (e *remoteEngine) Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, _ string) (string, float64, error) {
+	if err := e.slots.acquire(ctx); err != nil {
+		return "", 0, err
+	}
+	defer e.slots.release()
+
+	body, contentType, err := e.body(imageFileName, s)
+	if err != nil {
+		return "", 0, err
+	}
+
+	b, err := post(ctx, e.client, e.url, contentType, body, nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var result remoteResponse
+	if err := json.Unmarshal(b, &result); err != nil {
+		return "", 0, fmt.Errorf("invalid tesseract server response: %w", err)
+	}
+	if len(result.Data.Stdout) == 0 && len(result.Data.Stderr) > 0 {
+		return "", 0, errors.New(strings.TrimSpace(result.Data.Stderr))
+	}
+	return parseTSV(result.Data.Stdout)
+}
+
+func (e *remoteEngine) Close() error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package ocrengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTSV - text & average word confidence from tesseract TSV output
+// (level page_num block_num par_num line_num word_num left top width height conf text)
+func parseTSV(out string) (string, float64, error) {
+	var lines []string
+	var words []string
+	lastLine := ""
+	sum, count := 0.0, 0
+
+	for i, row := range strings.Split(strings.ReplaceAll(out, "\r\n", "\n"), "\n") {
+		cols := strings.Split(row, "\t")
+		if i == 0 || len(cols) < 12 || cols[0] != "5" {
+			continue // header & non-word rows
+		}
+
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("unexpected tesseract output: %q", row)
+		}
+		if conf < 0 {
+			continue
+		}
+
+		if line := strings.Join(cols[1:5], "."); line != lastLine {
+			if len(words) > 0 {
+				lines = append(lines, strings.Join(words, " "))
+			}
+			words = nil
+			lastLine = line
+		}
+		words = append(words, cols[11])
+		sum = sum + conf
+		count++
+	}
+	if len(words) > 0 {
+		lines = append(lines, strings.Join(words, " "))
+	}
+
+	if count == 0 {
+		return "", 0, nil
+	}
+	return strings.Join(lines, "\n") + "\n", sum / float64(count), nil
+}
@@ -68,7 +68,7 @@ func HOHScan(original image.Image, tessdataDir string) HOHResult {
 			OEM:       1,
 			PSM:       7,
 			Languages: []string{"eng"},
-			AllowList: []interface{}{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", ","},
+			AllowList: ocrschema.AllowList{"digits", ","},
 		}, tessdataDir)
 
 		value, _ := strconv.Atoi(strings.ReplaceAll(text, ",", ""))
@@ -0,0 +1,102 @@
+package pdfpages
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Extension - PDF files are rasterized into pages, instead of being decoded as an image
+const Extension = ".pdf"
+
+// Options - how pages are rasterized, zero value is usable
+type Options struct {
+	// PDFToPPM - path to pdftoppm binary (poppler-utils), used for rasterizing
+	PDFToPPM string
+	// DPI - resolution of rasterized pages
+	DPI int
+}
+
+func DefaultOptions() Options {
+	return Options{PDFToPPM: "pdftoppm", DPI: 150}
+}
+
+// IsPDF - file is a PDF (by extension)
+func IsPDF(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), Extension)
+}
+
+// Pages - rasterizes every page of the PDF
+func Pages(ctx context.Context, file string, opts Options) ([]image.Image, error) {
+	return rasterize(ctx, file, opts)
+}
+
+// Page - rasterizes a single (1-based) page of the PDF
+func Page(ctx context.Context, file string, page int, opts Options) (image.Image, error) {
+	n := fmt.Sprintf("%d", page)
+	pages, err := rasterize(ctx, file, opts, "-f", n, "-l", n)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%v has no page %v", filepath.Base(file), page)
+	}
+	return pages[0], nil
+}
+
+func rasterize(ctx context.Context, file string, opts Options, extra ...string) ([]image.Image, error) {
+	binary, dpi := opts.PDFToPPM, opts.DPI
+	if len(binary) == 0 {
+		binary = DefaultOptions().PDFToPPM
+	}
+	if dpi <= 0 {
+		dpi = DefaultOptions().DPI
+	}
+
+	args := append([]string{"-png", "-r", fmt.Sprintf("%d", dpi)}, extra...)
+	// without output root, pages are written to stdout one after another
+	cmd := exec.CommandContext(ctx, binary, append(args, file)...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("can't start pdftoppm: %v", err)
+	}
+
+	// png decoder stops at IEND chunk, so pages can be read one after another
+	var pages []image.Image
+	r := bufio.NewReader(stdout)
+	for {
+		if _, err := r.Peek(1); err != nil {
+			break
+		}
+		img, err := png.Decode(r)
+		if err != nil {
+			_, _ = io.Copy(io.Discard, r)
+			_ = cmd.Wait()
+			return nil, fmt.Errorf("can't decode page %v of %v: %v", len(pages)+1, filepath.Base(file), err)
+		}
+		pages = append(pages, img)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("pdftoppm: %v", strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("pdftoppm: %v", err)
+	}
+	return pages, nil
+}
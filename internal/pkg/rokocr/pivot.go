@@ -0,0 +1,92 @@
+package rokocr
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+	log "github.com/sirupsen/logrus"
+)
+
+type PivotRow struct {
+	Group string
+	Sum   float64
+	Count int
+}
+
+func (r PivotRow) Average() float64 {
+	if r.Count == 0 {
+		return 0
+	}
+	return r.Sum / float64(r.Count)
+}
+
+// ResultRows - flattens results into rows of strings (filename is included as "filename")
+func ResultRows(data []schema.OCRResult) []map[string]string {
+	var rows []map[string]string
+	for _, r := range data {
+		row := map[string]string{"filename": r.Filename}
+		for k, v := range r.Data {
+			row[k] = schema.FormatValue(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func PivotSum(rows []map[string]string, groupBy, valueField string) map[string]float64 {
+	result := make(map[string]float64)
+	for _, r := range Pivot(rows, groupBy, valueField) {
+		result[r.Group] = r.Sum
+	}
+	return result
+}
+
+// Pivot - groups rows by groupBy and sums numeric valueField, sorted by group
+func Pivot(rows []map[string]string, groupBy, valueField string) []PivotRow {
+	groups := make(map[string]*PivotRow)
+
+	for _, row := range rows {
+		value, err := stringutils.ParseNumber(row[valueField])
+		if err != nil {
+			log.Warnf("[pivot] skipping %v=%q (%v): %v", groupBy, row[groupBy], valueField, err)
+			continue
+		}
+
+		g, ok := groups[row[groupBy]]
+		if !ok {
+			g = &PivotRow{Group: row[groupBy]}
+			groups[row[groupBy]] = g
+		}
+		g.Sum = g.Sum + value
+		g.Count = g.Count + 1
+	}
+
+	var result []PivotRow
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Group < result[j].Group
+	})
+
+	return result
+}
+
+func WritePivotCSV(rows []PivotRow, groupBy, valueField string, w io.Writer) {
+	table := csv.NewWriter(w)
+	_ = table.Write([]string{groupBy, "sum_" + valueField, "count", "avg_" + valueField})
+	for _, r := range rows {
+		_ = table.Write([]string{
+			r.Group,
+			strconv.FormatFloat(r.Sum, 'f', -1, 64),
+			strconv.Itoa(r.Count),
+			strconv.FormatFloat(r.Average(), 'f', 2, 64),
+		})
+	}
+	table.Flush()
+}
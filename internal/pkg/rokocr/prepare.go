@@ -1,6 +1,7 @@
 package rokocr
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -9,6 +10,10 @@ import (
 	"github.com/rokmonster/ocr/templates"
 	"github.com/sirupsen/logrus"
 
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tessdata"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
 
 	"github.com/rokmonster/ocr/internal/pkg/config"
@@ -73,6 +78,9 @@ func Prepare(flags config.CommonConfiguration) {
 	fileutils.Mkdirs(flags.MediaDirectory)
 	fileutils.Mkdirs(flags.TemplatesDirectory)
 
+	if err := tesseractutils.SetDefaultEngine(flags.OCREngine); err != nil {
+		logrus.Fatalf("Can't use OCR engine %q: %v", flags.OCREngine.Name, err)
+	}
 }
 
 func PreloadTemplates(flags config.CommonConfiguration) {
@@ -89,23 +97,54 @@ func PreloadTemplates(flags config.CommonConfiguration) {
 	}
 }
 
+// baseLanguages - downloaded up front, so templates made with the template maker work out of the box
+var baseLanguages = []string{
+	"eng",     // English
+	"rus",     // Russian
+	"fra",     // French
+	"spa",     // Spanish
+	"chi_tra", // Chinese Traditional
+	"chi_sim", // Chinese Simplified
+	"jpn",     // Japan
+	"ita",     // Italian
+	"kor",     // Korean
+}
+
+func tessdataManager(flags config.CommonConfiguration) *tessdata.Manager {
+	m, err := tessdata.New(flags.TessdataDirectory, flags.TessdataVariant, flags.TessdataDownload)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return m
+}
+
 func DownloadTesseractData(flags config.CommonConfiguration) {
-	langFiles := []string{
-		"eng",     // English
-		"rus",     // Russian
-		"fra",     // French
-		"spa",     // Spanish
-		"chi_tra", // Chinese Traditional
-		"chi_sim", // Chinese Simplified
-		"jpn",     // Japan
-		"ita",     // Italian
-		"kor",     // Korean
+	if !usesTessdata(flags.OCREngine) || !flags.TessdataDownload {
+		return
+	}
+	if err := tessdataManager(flags).Ensure(context.Background(), baseLanguages); err != nil {
+		logrus.Errorf("Failed to download language models: %v", err)
 	}
+}
 
-	for _, lang := range langFiles {
-		path := filepath.Join(flags.TessdataDirectory, fmt.Sprintf("%v.traineddata", lang))
-		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-			_ = fileutils.Download(path, fmt.Sprintf("https://raw.githubusercontent.com/tesseract-ocr/tessdata/main/%v.traineddata", lang))
-		}
+// EnsureTemplateLanguages - downloads models of languages the templates use (defaults - of fields without own lang),
+// and tells which are still missing, custom models included
+func EnsureTemplateLanguages(flags config.CommonConfiguration, templates []schema.OCRTemplate, defaults []string) error {
+	if !usesTessdata(flags.OCREngine) {
+		return nil
+	}
+	manager := tessdataManager(flags)
+	return errors.Join(
+		manager.Ensure(context.Background(), tessdata.TemplateLanguages(templates, defaults)),
+		manager.Custom(tessdata.TemplateModels(templates)),
+	)
+}
+
+// usesTessdata - cloud engines (and remote tesseract server) have their own models
+func usesTessdata(cfg ocrengine.Config) bool {
+	switch cfg.Name {
+	case "", ocrengine.Gosseract, ocrengine.CLI:
+		return true
 	}
+	return false
 }
@@ -0,0 +1,107 @@
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// ReviewFolder - where screenshots failing the check are put, so they can be looked at (and re-taken)
+const ReviewFolder = "review"
+
+// ErrLowQuality - screenshot failed the check and was rejected before OCR
+var ErrLowQuality = errors.New("low quality screenshot")
+
+// Options - thresholds of the check, zero value of a threshold disables that check
+type Options struct {
+	// MinSharpness - Laplacian variance below which the screenshot is blurry
+	MinSharpness float64
+	// MaxFlat - share of the screen without any detail above which it's partially loaded
+	MaxFlat float64
+	// MaxLetterbox - share of the screen covered by black bars above which it's letterboxed
+	MaxLetterbox float64
+	// MaxAspectDeviation - relative difference of aspect ratio to the template's one
+	MaxAspectDeviation float64
+	// Reject - failing screenshots aren't recognized at all, otherwise they are only flagged
+	Reject bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MinSharpness:       20,
+		MaxFlat:            0.5,
+		MaxLetterbox:       0.05,
+		MaxAspectDeviation: 0.05,
+	}
+}
+
+// Report - measurements of the screenshot & issues found
+type Report struct {
+	Sharpness float64
+	Flat      float64
+	Letterbox float64
+	Aspect    float64
+	Issues    []string
+}
+
+func (r Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Err - ErrLowQuality with the issues, nil if there are none
+func (r Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrLowQuality, strings.Join(r.Issues, ", "))
+}
+
+// Check - analyses the screenshot, the aspect ratio is compared to width x height (of the template, 0 - not checked)
+func Check(img image.Image, width, height int, opts Options) Report {
+	b := img.Bounds()
+	var r Report
+	if b.Empty() {
+		r.Issues = append(r.Issues, "empty image")
+		return r
+	}
+
+	content := imgutils.DarkBorders(img, 16)
+	r.Letterbox = 1 - float64(content.Dx()*content.Dy())/float64(b.Dx()*b.Dy())
+	if opts.MaxLetterbox > 0 && r.Letterbox > opts.MaxLetterbox {
+		r.Issues = append(r.Issues, fmt.Sprintf("letterboxed (%.0f%% black bars)", 100*r.Letterbox))
+	}
+	if content.Empty() {
+		r.Issues = append(r.Issues, "blank image")
+		return r
+	}
+
+	// bars are reported on their own, rest is measured only on the content
+	inner, err := imgutils.CropImage(img, content)
+	if err != nil {
+		inner = img
+	}
+
+	r.Sharpness = imgutils.Sharpness(inner)
+	if opts.MinSharpness > 0 && r.Sharpness < opts.MinSharpness {
+		r.Issues = append(r.Issues, fmt.Sprintf("blurry (sharpness %.1f)", r.Sharpness))
+	}
+
+	r.Flat = imgutils.FlatFraction(inner, 8, 2)
+	if opts.MaxFlat > 0 && r.Flat > opts.MaxFlat {
+		r.Issues = append(r.Issues, fmt.Sprintf("partially loaded (%.0f%% blank)", 100*r.Flat))
+	}
+
+	r.Aspect = float64(b.Dx()) / float64(b.Dy())
+	if width > 0 && height > 0 && opts.MaxAspectDeviation > 0 {
+		expected := float64(width) / float64(height)
+		if math.Abs(r.Aspect-expected)/expected > opts.MaxAspectDeviation {
+			r.Issues = append(r.Issues, fmt.Sprintf("aspect ratio %.2f doesn't match template's %.2f", r.Aspect, expected))
+		}
+	}
+
+	return r
+}
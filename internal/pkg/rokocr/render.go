@@ -0,0 +1,196 @@
+package rokocr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Masterminds/sprig/v3"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// templateExtensions - suffixes stripped off output template file names, the rest is the extension of the output
+var templateExtensions = []string{".tmpl", ".gotmpl", ".tpl"}
+
+// TemplatedResults - what output templates are executed with
+type TemplatedResults struct {
+	// Title - title of the OCR template results were recognized with
+	Title     string
+	Columns   []schema.OCRTableField
+	Rows      []TemplatedRow
+	Generated time.Time
+}
+
+// TemplatedRow - one result, Values are formatted in the order of Columns
+type TemplatedRow struct {
+	Filename string
+	Data     map[string]interface{}
+	Values   []string
+	// LowConfidence - fields which are unreliable, by field name
+	LowConfidence map[string]bool
+}
+
+// Value - formatted value of the field, empty if the row doesn't have it
+func (r TemplatedRow) Value(field string) string {
+	return schema.FormatValue(r.Data[field])
+}
+
+// ParseOutputTemplate - Go text/template with sprig functions, and helpers for tables in chat & forums:
+// sortBy (rows biggest value of a field first), thousands (1234567 => 1,234,567), padLeft / padRight (align columns
+// of code blocks) and md (escape Markdown)
+func ParseOutputTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(sprig.TxtFuncMap()).Funcs(template.FuncMap{
+		"sortBy":    sortRowsBy,
+		"thousands": thousands,
+		"padLeft":   padLeft,
+		"padRight":  padRight,
+		"md":        escapeMarkdown,
+	}).Parse(text)
+}
+
+// OutputTemplateExtension - extension of the output rendered by the template file (leaderboard.md.tmpl => .md),
+// .txt if it has none
+func OutputTemplateExtension(path string) string {
+	base := filepath.Base(path)
+	for _, ext := range templateExtensions {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if ext := filepath.Ext(base); len(ext) > 0 {
+		return ext
+	}
+	return ".txt"
+}
+
+// WriteTemplated - renders results through the output template, columns like WriteCSV
+func WriteTemplated(data []schema.OCRResult, template schema.OCRTemplate, tmpl *template.Template, w io.Writer) error {
+	results := TemplatedResults{
+		Title:     template.Title,
+		Columns:   ExportColumns(template, data),
+		Generated: time.Now(),
+	}
+
+	for _, row := range data {
+		r := TemplatedRow{
+			Filename:      row.Filename,
+			Data:          row.Data,
+			LowConfidence: map[string]bool{},
+		}
+		for _, x := range results.Columns {
+			r.Values = append(r.Values, schema.FormatValue(row.Data[x.Field]))
+		}
+		for k, f := range row.Fields {
+			if f.LowConfidence {
+				r.LowConfidence[k] = true
+			}
+		}
+		results.Rows = append(results.Rows, r)
+	}
+
+	return tmpl.Execute(w, results)
+}
+
+// sortRowsBy - copy of rows, biggest value of the field first (numbers by value, the rest alphabetically)
+func sortRowsBy(field string, rows []TemplatedRow) []TemplatedRow {
+	sorted := append([]TemplatedRow(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, aok := toFloat(sorted[i].Data[field])
+		b, bok := toFloat(sorted[j].Data[field])
+		if aok && bok {
+			return a > b
+		}
+		if aok != bok {
+			// rows missing the value go last
+			return aok
+		}
+		return sorted[i].Value(field) > sorted[j].Value(field)
+	})
+	return sorted
+}
+
+// thousands - number with digits grouped by commas, other values are formatted as they are
+func thousands(v interface{}) string {
+	s := schema.FormatValue(v)
+	if _, ok := toFloat(v); !ok {
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return s
+		}
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if hasFraction {
+		b.WriteString("." + fraction)
+	}
+	return sign + b.String()
+}
+
+func padLeft(width int, v interface{}) string {
+	s := schema.FormatValue(v)
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
+
+func padRight(width int, v interface{}) string {
+	s := schema.FormatValue(v)
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "|", `\|`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "~", `\~`,
+)
+
+// escapeMarkdown - governor names often contain characters which are Markdown (or Discord) formatting
+func escapeMarkdown(v interface{}) string {
+	return markdownEscaper.Replace(schema.FormatValue(v))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// ParseOutputTemplateFile - output template from a file, see ParseOutputTemplate
+func ParseOutputTemplateFile(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := ParseOutputTemplate(filepath.Base(path), string(text))
+	if err != nil {
+		return nil, fmt.Errorf("output template %v: %w", path, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,183 @@
+package rokocr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/fieldcache"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/quality"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+)
+
+// status of an input file in the run report
+const (
+	ReportRecognized = "recognized"
+	ReportFailed     = "failed"
+)
+
+// failure reasons, so automation can tell what to retry (e.g. re-take low quality screenshots)
+const (
+	FailureNoTemplateMatch = "no_template_match"
+	FailureLowQuality      = "low_quality"
+	FailureError           = "error"
+)
+
+// FailureReason - machine readable reason of a failed file
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, quality.ErrLowQuality):
+		return FailureLowQuality
+	case errors.Is(err, tesseractutils.ErrNoTemplateMatch):
+		return FailureNoTemplateMatch
+	}
+	return FailureError
+}
+
+type ReportField struct {
+	Value         interface{} `json:"value"`
+	Text          string      `json:"text"`
+	Confidence    float64     `json:"confidence"`
+	LowConfidence bool        `json:"low_confidence,omitempty"`
+	// Retries & Variant - alternative variants tried because of low confidence, and the kept one
+	Retries int    `json:"retries,omitempty"`
+	Variant string `json:"variant,omitempty"`
+	// Invalid - validation rules of the field the value breaks
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// ReportResult - single result of a file (stitched screenshots, PDFs & ranking lists have several)
+type ReportResult struct {
+	Panel    int                    `json:"panel,omitempty"`
+	Page     int                    `json:"page,omitempty"`
+	Row      int                    `json:"row,omitempty"`
+	Template string                 `json:"template"`
+	Quality  []string               `json:"quality,omitempty"`
+	Fields   map[string]ReportField `json:"fields"`
+	Took     time.Duration          `json:"duration"`
+}
+
+type ReportFile struct {
+	File    string         `json:"file"`
+	Status  string         `json:"status"`
+	Reason  string         `json:"reason,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Results []ReportResult `json:"results,omitempty"`
+	Took    time.Duration  `json:"duration"`
+}
+
+type ReportSummary struct {
+	Files      int            `json:"files"`
+	Recognized int            `json:"recognized"`
+	Failed     int            `json:"failed"`
+	Results    int            `json:"results"`
+	Failures   map[string]int `json:"failures,omitempty"`
+}
+
+// RunReport - machine readable account of a scan: every input file, the template it matched,
+// recognized values, timing & failure reasons. Safe for concurrent use.
+type RunReport struct {
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Source   string        `json:"source"`
+	Files    []ReportFile  `json:"files"`
+	Summary  ReportSummary `json:"summary"`
+	// FieldCache - lookups of the field cache, if enabled
+	FieldCache *fieldcache.Stats `json:"field_cache,omitempty"`
+
+	mu    sync.Mutex
+	index map[string]int
+}
+
+func NewRunReport(source string) *RunReport {
+	return &RunReport{Started: time.Now(), Source: source, Files: []ReportFile{}, index: make(map[string]int)}
+}
+
+func (r *RunReport) file(name string) *ReportFile {
+	i, ok := r.index[name]
+	if !ok {
+		i = len(r.Files)
+		r.index[name] = i
+		r.Files = append(r.Files, ReportFile{File: name})
+	}
+	return &r.Files[i]
+}
+
+// Failed - file which couldn't be recognized
+func (r *RunReport) Failed(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.file(name)
+	f.Status = ReportFailed
+	f.Reason = FailureReason(err)
+	f.Error = err.Error()
+}
+
+// Add - result recognized from the file, with the template it matched
+func (r *RunReport) Add(result schema.OCRResult, template schema.OCRTemplate) {
+	invalid := template.ValidateResult(result)
+
+	fields := make(map[string]ReportField, len(result.Data))
+	for k, v := range result.Data {
+		fr := result.Fields[k]
+		fields[k] = ReportField{
+			Value:         v,
+			Text:          fr.Text,
+			Confidence:    fr.Confidence,
+			LowConfidence: fr.LowConfidence,
+			Retries:       fr.Retries,
+			Variant:       fr.Variant,
+			Invalid:       invalid[k],
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.file(result.Filename)
+	f.Status = ReportRecognized
+	f.Took += result.Took
+	f.Results = append(f.Results, ReportResult{
+		Panel:    result.Panel,
+		Page:     result.Page,
+		Row:      result.Row,
+		Template: template.Title,
+		Quality:  result.Quality,
+		Fields:   fields,
+		Took:     result.Took,
+	})
+}
+
+// Finish - marks the end of the run & sums up the files
+func (r *RunReport) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Finished = time.Now()
+	r.Summary = ReportSummary{Files: len(r.Files)}
+	for _, f := range r.Files {
+		r.Summary.Results += len(f.Results)
+		if f.Status == ReportRecognized {
+			r.Summary.Recognized++
+			continue
+		}
+		r.Summary.Failed++
+		if r.Summary.Failures == nil {
+			r.Summary.Failures = make(map[string]int)
+		}
+		r.Summary.Failures[f.Reason]++
+	}
+}
+
+func (r *RunReport) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,130 @@
+package resultsdb
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// TemplateSummary - stats of a template summed over all runs, and of its latest run against the earlier ones
+type TemplateSummary struct {
+	Total   TemplateStats
+	Latest  TemplateStats
+	Earlier TemplateStats
+}
+
+// Summarize - per template summaries of runs (oldest first, see Stats), sorted by template title
+func Summarize(runs []RunStats) []TemplateSummary {
+	byTemplate := make(map[string][]TemplateStats)
+	for _, run := range runs {
+		for title, t := range run.Templates {
+			byTemplate[title] = append(byTemplate[title], t)
+		}
+	}
+
+	summaries := make([]TemplateSummary, 0, len(byTemplate))
+	for title, list := range byTemplate {
+		s := TemplateSummary{Latest: list[len(list)-1]}
+		s.Total.Template, s.Earlier.Template = title, title
+		for i, t := range list {
+			s.Total.Add(t)
+			if i < len(list)-1 {
+				s.Earlier.Add(t)
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Total.Template < summaries[j].Total.Template })
+	return summaries
+}
+
+// failedChange & confidenceChange - of the latest run against the earlier ones, failure rate in percentage points
+func (s TemplateSummary) failedChange() float64 {
+	return (s.Latest.FailureRate() - s.Earlier.FailureRate()) * 100
+}
+
+func (s TemplateSummary) confidenceChange() float64 {
+	return s.Latest.AvgConfidence() - s.Earlier.AvgConfidence()
+}
+
+// Degraded - failure rate of the latest run jumped by 10 points, or its confidence dropped by 5 (e.g. after a game update)
+func (s TemplateSummary) Degraded() bool {
+	return s.Earlier.Runs > 0 && (s.failedChange() >= 10 || s.confidenceChange() <= -5)
+}
+
+// Trend - change of failure rate & confidence of the latest run against the earlier ones, "-" if there is just one
+func (s TemplateSummary) Trend() string {
+	if s.Earlier.Runs == 0 {
+		return "-"
+	}
+	trend := fmt.Sprintf("failed %+.1f%%, confidence %+.1f", s.failedChange(), s.confidenceChange())
+	if s.Degraded() {
+		trend += " (degraded)"
+	}
+	return trend
+}
+
+// WriteStats - table of template summaries
+func WriteStats(w io.Writer, summaries []TemplateSummary) {
+	table := tablewriter.NewWriter(w)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader([]string{"Template", "Scans", "Last scan", "Results", "Failed", "Confidence", "Low confidence", "Latest vs. earlier"})
+	for _, s := range summaries {
+		table.Append([]string{
+			s.Total.Template, fmt.Sprint(s.Total.Runs), s.Total.Last.Format("2006-01-02 15:04"), fmt.Sprint(s.Total.Results),
+			fmt.Sprintf("%.1f%%", s.Total.FailureRate()*100), fmt.Sprintf("%.1f", s.Total.AvgConfidence()),
+			fmt.Sprintf("%.1f%%", s.Total.LowConfidenceRate()*100), s.Trend(),
+		})
+	}
+	table.Render()
+}
+
+// HistoryOf - History of the governor, value is normalized like keyField of the template (if it has one).
+// The key looked up is returned too.
+func (s *Store) HistoryOf(template schema.OCRTemplate, keyField, value string) (string, []HistoryEntry, error) {
+	key := strings.TrimSpace(value)
+	if f, ok := template.OCRSchema[keyField]; ok {
+		key = f.NormalizeKey(value)
+	}
+	history, err := s.History(key)
+	return key, history, err
+}
+
+// HistoryFields - columns of the history: fields of the template, or all recognized fields (sorted) without one
+func HistoryFields(template schema.OCRTemplate, history []HistoryEntry) []string {
+	if len(template.OCRSchema) > 0 {
+		return template.ColumnKeys()
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, h := range history {
+		for k := range h.Result.Data {
+			if !seen[k] {
+				seen[k] = true
+				fields = append(fields, k)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// WriteHistory - table of the history, a row per run
+func WriteHistory(w io.Writer, history []HistoryEntry, fields []string) {
+	table := tablewriter.NewWriter(w)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader(append([]string{"Scan", "Date", "Filename"}, fields...))
+	for _, h := range history {
+		row := []string{fmt.Sprint(h.Run.ID), h.Run.Started.Format("2006-01-02 15:04"), h.Result.Filename}
+		for _, f := range fields {
+			row = append(row, schema.FormatValue(h.Result.Data[f]))
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
@@ -0,0 +1,137 @@
+package resultsdb
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func killsTemplate() schema.OCRTemplate {
+	return schema.OCRTemplate{
+		Title: "Kills",
+		OCRSchema: map[string]schema.OCRSchema{
+			"id":    {Type: schema.TypeInt},
+			"kills": {Type: schema.TypeInt},
+		},
+	}
+}
+
+func kills(file string, id, kills int64, confidence float64) schema.OCRResult {
+	return schema.OCRResult{
+		Filename: file,
+		Data:     map[string]interface{}{"id": id, "kills": kills},
+		Fields:   map[string]schema.FieldResult{"kills": {Value: kills, Confidence: confidence}},
+	}
+}
+
+// scan - records a finished run of the template
+func scan(t *testing.T, store *Store, template schema.OCRTemplate, results ...schema.OCRResult) {
+	t.Helper()
+	run, err := store.StartRun(template, "media", "id", schema.ScanTags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddResults(&run, template, results...); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishRun(&run); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	return openAt(t, filepath.Join(t.TempDir(), "results.db"))
+}
+
+func TestSummarize(t *testing.T) {
+	store := openTestStore(t)
+	template := killsTemplate()
+	scan(t, store, template, kills("1.png", 1, 100, 90), kills("2.png", 2, 200, 90))
+	scan(t, store, template, kills("3.png", 1, 150, 80), schema.OCRResult{Filename: "4.png", Error: "no rows"})
+	scan(t, store, schema.OCRTemplate{Title: "Power"}, kills("5.png", 1, 0, 95))
+
+	runs, err := store.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries := Summarize(runs)
+	if len(summaries) != 2 || summaries[0].Total.Template != "Kills" || summaries[1].Total.Template != "Power" {
+		t.Fatalf("got %+v, want summaries of Kills & Power", summaries)
+	}
+
+	k := summaries[0]
+	if k.Total.Runs != 2 || k.Total.Results != 4 || k.Total.Failed != 1 || k.Earlier.Runs != 1 || k.Latest.Failed != 1 {
+		t.Errorf("Kills: total %+v, earlier %+v, latest %+v", k.Total, k.Earlier, k.Latest)
+	}
+	// 0% => 50% failed, confidence 90 => 80
+	if !k.Degraded() {
+		t.Errorf("Kills isn't degraded")
+	}
+	if want := "failed +50.0%, confidence -10.0 (degraded)"; k.Trend() != want {
+		t.Errorf("Kills trend = %q, want %q", k.Trend(), want)
+	}
+
+	// nothing to compare a single run against
+	if p := summaries[1]; p.Degraded() || p.Trend() != "-" {
+		t.Errorf("Power: degraded %v, trend %q, want false & \"-\"", p.Degraded(), p.Trend())
+	}
+
+	var out bytes.Buffer
+	WriteStats(&out, summaries)
+	for _, want := range []string{"Kills", "Power", "25.0%", "(degraded)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stats table has no %q:\n%v", want, out.String())
+		}
+	}
+}
+
+func TestTrendSteady(t *testing.T) {
+	steady := TemplateSummary{
+		Latest:  TemplateStats{Runs: 1, Results: 10, Failed: 1, Fields: 10, Confidence: 880},
+		Earlier: TemplateStats{Runs: 3, Results: 30, Failed: 3, Fields: 30, Confidence: 2700},
+	}
+	if steady.Degraded() {
+		t.Errorf("steady template is degraded: %v", steady.Trend())
+	}
+	if want := "failed +0.0%, confidence -2.0"; steady.Trend() != want {
+		t.Errorf("trend = %q, want %q", steady.Trend(), want)
+	}
+}
+
+func TestHistoryOf(t *testing.T) {
+	store := openTestStore(t)
+	template := killsTemplate()
+	scan(t, store, template, kills("1.png", 1, 100, 90), kills("2.png", 2, 200, 90))
+	scan(t, store, template, kills("3.png", 1, 150, 80))
+
+	key, history, err := store.HistoryOf(template, "id", " 1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "1" || len(history) != 2 {
+		t.Fatalf("got key %q & %d entries, want \"1\" & 2", key, len(history))
+	}
+	if history[0].Result.Filename != "1.png" || history[1].Result.Filename != "3.png" {
+		t.Errorf("history = %v, %v, want 1.png, 3.png (oldest first)", history[0].Result.Filename, history[1].Result.Filename)
+	}
+
+	// without a template, all recognized fields are the columns
+	if fields := HistoryFields(schema.OCRTemplate{}, history); strings.Join(fields, ",") != "id,kills" {
+		t.Errorf("fields = %v, want id, kills", fields)
+	}
+
+	var out bytes.Buffer
+	WriteHistory(&out, history, HistoryFields(template, history))
+	for _, want := range []string{"1.png", "3.png", "150"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("history table has no %q:\n%v", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "2.png") {
+		t.Errorf("history table has another governor:\n%v", out.String())
+	}
+}
@@ -0,0 +1,134 @@
+package resultsdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// NoMatch - template of screenshots no template matched
+const NoMatch = "(no match)"
+
+// TemplateStats - how screenshots of a template were recognized, in one run or summed over several
+type TemplateStats struct {
+	Template string    `json:"template"`
+	Runs     int       `json:"runs"`
+	Last     time.Time `json:"last"`
+	Results  int       `json:"results"`
+	// Failed - results with an error (unreadable screenshot, no rows, rejected by quality check, ...)
+	Failed int `json:"failed"`
+	// Fields - recognized fields, Confidence is the sum of their confidence
+	Fields        int     `json:"fields"`
+	Confidence    float64 `json:"confidence"`
+	LowConfidence int     `json:"low_confidence"`
+}
+
+// FailureRate - share of failed results
+func (s TemplateStats) FailureRate() float64 {
+	if s.Results == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Results)
+}
+
+// AvgConfidence - average confidence of recognized fields
+func (s TemplateStats) AvgConfidence() float64 {
+	if s.Fields == 0 {
+		return 0
+	}
+	return s.Confidence / float64(s.Fields)
+}
+
+// LowConfidenceRate - share of fields read with low confidence
+func (s TemplateStats) LowConfidenceRate() float64 {
+	if s.Fields == 0 {
+		return 0
+	}
+	return float64(s.LowConfidence) / float64(s.Fields)
+}
+
+// Add - sums stats of another run
+func (s *TemplateStats) Add(o TemplateStats) {
+	s.Runs += o.Runs
+	if o.Last.After(s.Last) {
+		s.Last = o.Last
+	}
+	s.Results += o.Results
+	s.Failed += o.Failed
+	s.Fields += o.Fields
+	s.Confidence += o.Confidence
+	s.LowConfidence += o.LowConfidence
+}
+
+func (s *TemplateStats) addResult(r schema.OCRResult) {
+	s.Results++
+	if len(r.Error) > 0 {
+		s.Failed++
+		return
+	}
+	for _, f := range r.Fields {
+		s.Fields++
+		s.Confidence += f.Confidence
+		if f.LowConfidence {
+			s.LowConfidence++
+		}
+	}
+}
+
+// RunStats - stats of templates used in a run, by title
+type RunStats struct {
+	Run       Run                      `json:"run"`
+	Templates map[string]TemplateStats `json:"templates"`
+}
+
+// Stats - per template stats of every run, oldest first. Results are counted to the template they were recognized
+// with (or the one of the run), screenshots no template matched to NoMatch.
+func (s *Store) Stats() ([]RunStats, error) {
+	rows, err := s.db.Query(`SELECT ` + runColumns + `, results.result FROM runs LEFT JOIN results ON results.run_id = runs.id
+		ORDER BY runs.id, results.seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []RunStats
+	for rows.Next() {
+		var result sql.NullString
+		run, err := scanRun(rows, &result)
+		if err != nil {
+			return nil, err
+		}
+		if len(stats) == 0 || stats[len(stats)-1].Run.ID != run.ID {
+			stats = append(stats, RunStats{Run: run, Templates: make(map[string]TemplateStats)})
+		}
+		if !result.Valid {
+			continue
+		}
+
+		var r schema.OCRResult
+		if err := json.Unmarshal([]byte(result.String), &r); err != nil {
+			return nil, err
+		}
+		current := &stats[len(stats)-1]
+		template := resultTemplate(run, r)
+		t := current.Templates[template]
+		t.Template, t.Runs, t.Last = template, 1, run.Started
+		t.addResult(r)
+		current.Templates[template] = t
+	}
+	return stats, rows.Err()
+}
+
+// resultTemplate - title of the template the result was recognized with
+func resultTemplate(run Run, r schema.OCRResult) string {
+	switch {
+	case len(r.Template) > 0:
+		return r.Template
+	case r.Match != nil || len(run.Template) == 0:
+		// templates were ranked, and none matched
+		return NoMatch
+	}
+	return run.Template
+}
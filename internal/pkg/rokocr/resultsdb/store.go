@@ -0,0 +1,287 @@
+package resultsdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	_ "modernc.org/sqlite"
+)
+
+// schemaSQL - runs, and results of every run; key is the normalized value of the run's key field (e.g. governor id).
+// Results are kept as JSON too, columns are there for querying the file with SQL tools.
+const schemaSQL = `
+CREATE TABLE IF NOT EXISTS runs (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	started   TEXT NOT NULL,
+	finished  TEXT NOT NULL DEFAULT '',
+	template  TEXT NOT NULL,
+	source    TEXT NOT NULL DEFAULT '',
+	key_field TEXT NOT NULL DEFAULT '',
+	kingdom   TEXT NOT NULL DEFAULT '',
+	alliance  TEXT NOT NULL DEFAULT '',
+	scan_date TEXT NOT NULL DEFAULT '',
+	results   INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS results (
+	run_id   INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+	seq      INTEGER NOT NULL,
+	key      TEXT,
+	filename TEXT NOT NULL,
+	template TEXT NOT NULL DEFAULT '',
+	error    TEXT NOT NULL DEFAULT '',
+	result   TEXT NOT NULL,
+	PRIMARY KEY (run_id, seq)
+);
+CREATE INDEX IF NOT EXISTS results_key ON results(key);
+`
+
+// Run - single scan
+type Run struct {
+	ID       uint64    `json:"id"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Template string    `json:"template"`
+	// Source - what was scanned (media dir, video, device)
+	Source string `json:"source,omitempty"`
+	// KeyField - field results are indexed by (e.g. governor id), for History
+	KeyField string `json:"key_field,omitempty"`
+	// Tags - kingdom, alliance & date the scan was tagged with
+	Tags    *schema.ScanTags `json:"tags,omitempty"`
+	Results int              `json:"results"`
+}
+
+// Store - every scan run & its per-file results, in an embedded SQLite database,
+// so trends can be tracked across weeks without keeping folders of CSV files
+type Store struct {
+	db *sql.DB
+}
+
+func Open(path string) (*Store, error) {
+	// WAL - readers (e.g. the server's bot) don't wait for a scan recording into the same file
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("can't open results database %v: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schemaSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't open results database %v: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// StartRun - records a new run, results are added with AddResults
+func (s *Store) StartRun(template schema.OCRTemplate, source, keyField string, tags schema.ScanTags) (Run, error) {
+	run := Run{Started: time.Now(), Template: template.Title, Source: source, KeyField: keyField}
+	if !tags.Empty() {
+		run.Tags = &tags
+	}
+	res, err := s.db.Exec(`INSERT INTO runs (started, template, source, key_field, kingdom, alliance, scan_date) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		formatTime(run.Started), run.Template, run.Source, run.KeyField, tags.Kingdom, tags.Alliance, tags.Date)
+	if err != nil {
+		return run, err
+	}
+	id, err := res.LastInsertId()
+	run.ID = uint64(id)
+	return run, err
+}
+
+// AddResults - stores results of the run, and indexes them by run key field
+func (s *Store) AddResults(run *Run, template schema.OCRTemplate, results ...schema.OCRResult) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM results WHERE run_id = ?`, run.ID).Scan(&seq); err != nil {
+		return err
+	}
+
+	added := 0
+	for _, r := range results {
+		buf, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		var key interface{}
+		if k := template.KeyOf(r, run.KeyField); len(run.KeyField) > 0 && len(k) > 0 {
+			key = k
+		}
+		seq++
+		_, err = tx.Exec(`INSERT INTO results (run_id, seq, key, filename, template, error, result) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			run.ID, seq, key, r.Filename, r.Template, r.Error, string(buf))
+		if err != nil {
+			return err
+		}
+		added++
+	}
+
+	res, err := tx.Exec(`UPDATE runs SET results = results + ? WHERE id = ?`, added, run.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("unknown run %d", run.ID)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	run.Results += added
+	return nil
+}
+
+// FinishRun - marks the run as completed
+func (s *Store) FinishRun(run *Run) error {
+	run.Finished = time.Now()
+	_, err := s.db.Exec(`UPDATE runs SET finished = ? WHERE id = ?`, formatTime(run.Finished), run.ID)
+	return err
+}
+
+const runColumns = `runs.id, runs.started, runs.finished, runs.template, runs.source, runs.key_field, runs.kingdom, runs.alliance, runs.scan_date, runs.results`
+
+// Runs - all runs, latest first
+func (s *Store) Runs() ([]Run, error) {
+	rows, err := s.db.Query(`SELECT ` + runColumns + ` FROM runs ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Run - run by id
+func (s *Store) Run(id uint64) (Run, bool, error) {
+	run, err := scanRun(s.db.QueryRow(`SELECT `+runColumns+` FROM runs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return run, false, nil
+	}
+	return run, err == nil, err
+}
+
+// Results - results of the run, in the order they were added
+func (s *Store) Results(id uint64) ([]schema.OCRResult, error) {
+	if _, found, err := s.Run(id); err != nil || !found {
+		if err == nil {
+			err = fmt.Errorf("unknown run %d", id)
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT result FROM results WHERE run_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []schema.OCRResult
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// HistoryEntry - result of a governor in a single run
+type HistoryEntry struct {
+	Run    Run              `json:"run"`
+	Result schema.OCRResult `json:"result"`
+}
+
+// History - results of the governor (normalized key, see OCRTemplate.KeyOf) across all runs, oldest first
+func (s *Store) History(key string) ([]HistoryEntry, error) {
+	rows, err := s.db.Query(`SELECT `+runColumns+`, results.result FROM results JOIN runs ON runs.id = results.run_id
+		WHERE results.key = ? ORDER BY runs.id, results.seq`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var result string
+		if entry.Run, err = scanRun(rows, &result); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(result), &entry.Result); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRun - run of runColumns, followed by extra columns
+func scanRun(row scanner, extra ...interface{}) (Run, error) {
+	var run Run
+	var started, finished string
+	var tags schema.ScanTags
+	dest := append([]interface{}{&run.ID, &started, &finished, &run.Template, &run.Source, &run.KeyField,
+		&tags.Kingdom, &tags.Alliance, &tags.Date, &run.Results}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return run, err
+	}
+
+	var err error
+	if run.Started, err = parseTime(started); err != nil {
+		return run, err
+	}
+	if run.Finished, err = parseTime(finished); err != nil {
+		return run, err
+	}
+	if !tags.Empty() {
+		run.Tags = &tags
+	}
+	return run, nil
+}
+
+func scanResult(row scanner) (schema.OCRResult, error) {
+	var r schema.OCRResult
+	var result string
+	if err := row.Scan(&result); err != nil {
+		return r, err
+	}
+	return r, json.Unmarshal([]byte(result), &r)
+}
+
+// timeFormat - RFC 3339 with fixed width fraction, with the zone time was recorded in
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t time.Time) string {
+	return t.Format(timeFormat)
+}
+
+// parseTime - of formatTime, empty is zero time (run not finished)
+func parseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(timeFormat, s)
+}
@@ -0,0 +1,107 @@
+package resultsdb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func TestStoreRuns(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "results.db")
+	store, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := killsTemplate()
+	tags := schema.ScanTags{Kingdom: "1234", Date: "2026-10-01"}
+
+	run, err := store.StartRun(template, "media", "id", tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddResults(&run, template, kills("1.png", 1, 100, 90)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddResults(&run, template, kills("2.png", 2, 200, 90), schema.OCRResult{Filename: "3.png", Error: "no rows"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.FinishRun(&run); err != nil {
+		t.Fatal(err)
+	}
+	scan(t, store, template, kills("4.png", 1, 150, 80))
+
+	unknown := Run{ID: 99}
+	if err := store.AddResults(&unknown, template, kills("5.png", 5, 1, 90)); err == nil {
+		t.Errorf("results added to an unknown run")
+	}
+	store.Close()
+
+	// everything is in the file
+	store = openAt(t, name)
+	runs, err := store.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID <= runs[1].ID {
+		t.Fatalf("got %+v, want 2 runs, latest first", runs)
+	}
+	got := runs[1]
+	if got.ID != run.ID || got.Template != "Kills" || got.Source != "media" || got.KeyField != "id" || got.Results != 3 ||
+		got.Tags == nil || *got.Tags != tags || !got.Started.Equal(run.Started) || !got.Finished.Equal(run.Finished) {
+		t.Errorf("got %+v, want %+v", got, run)
+	}
+	if runs[0].Tags != nil {
+		t.Errorf("untagged run has tags %+v", runs[0].Tags)
+	}
+
+	results, err := store.Results(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Filename != "1.png" || results[2].Error != "no rows" {
+		t.Errorf("got %+v, want 1.png, 2.png & 3.png in order", results)
+	}
+	if _, err := store.Results(99); err == nil {
+		t.Errorf("results of an unknown run")
+	}
+	if _, found, err := store.Run(99); found || err != nil {
+		t.Errorf("unknown run: found %v, %v", found, err)
+	}
+}
+
+// TestStoreSQL - the file is a plain SQLite database, results can be queried without the tools
+func TestStoreSQL(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "results.db")
+	store := openAt(t, name)
+	scan(t, store, killsTemplate(), kills("1.png", 1, 100, 90), kills("2.png", 2, 200, 90))
+	scan(t, store, killsTemplate(), kills("3.png", 1, 150, 80))
+
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var scans int
+	var last string
+	err = db.QueryRow(`SELECT COUNT(*), MAX(filename) FROM results JOIN runs ON runs.id = results.run_id
+		WHERE results.key = '1' AND runs.template = 'Kills'`).Scan(&scans, &last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scans != 2 || last != "3.png" {
+		t.Errorf("got %d scans, last %v, want 2 & 3.png", scans, last)
+	}
+}
+
+func openAt(t *testing.T, name string) *Store {
+	t.Helper()
+	store, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
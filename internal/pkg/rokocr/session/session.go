@@ -0,0 +1,162 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	log "github.com/sirupsen/logrus"
+)
+
+// Folder - sub-folder of output dir with session journals
+const Folder = "sessions"
+
+// Entry - processed file, with its results or error
+type Entry struct {
+	File    string             `json:"file"`
+	Results []schema.OCRResult `json:"results,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Time    time.Time          `json:"time"`
+}
+
+// Session - journal of a scan run. Every processed file is appended (and synced) right away,
+// so re-running with the same ID skips files done before a crash or interrupt.
+type Session struct {
+	ID      string
+	path    string
+	mu      sync.Mutex
+	fd      *os.File
+	entries []Entry
+	done    map[string]bool
+}
+
+// NewID - id of a new session
+func NewID() string {
+	return time.Now().Format("20060102-150405")
+}
+
+// Open - opens the session journal in dir, entries of an existing one are loaded
+func Open(dir, id string) (*Session, error) {
+	if len(id) == 0 || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	s := &Session{ID: id, path: filepath.Join(dir, id+".jsonl"), done: make(map[string]bool)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.fd = fd
+	return s, nil
+}
+
+// load - reads entries of the journal. The last line is cut off if the process was killed while writing it,
+// so the next entry isn't appended onto it.
+func (s *Session) load() error {
+	fd, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	reader := bufio.NewReader(fd)
+	var size int64
+	for line := 1; ; line++ {
+		buf, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(buf) == 0 {
+				return nil
+			}
+			log.Warnf("Session %v: dropping unfinished entry on line %d", s.ID, line)
+			return fd.Truncate(size)
+		}
+		if err != nil {
+			return err
+		}
+		size += int64(len(buf))
+
+		var e Entry
+		if err := json.Unmarshal(buf, &e); err != nil {
+			log.Warnf("Session %v: ignoring broken entry on line %d: %v", s.ID, line, err)
+			continue
+		}
+		s.entries = append(s.entries, e)
+		s.done[e.File] = true
+	}
+}
+
+// Resumed - how many files were processed before
+func (s *Session) Resumed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Done - file was already processed in this session
+func (s *Session) Done(file string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done[file]
+}
+
+// Record - appends processed file to the journal
+func (s *Session) Record(file string, results []schema.OCRResult, err error) error {
+	e := Entry{File: file, Results: results, Time: time.Now()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	buf, jsonErr := json.Marshal(e)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.fd.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+	s.entries = append(s.entries, e)
+	s.done[file] = true
+	return s.fd.Sync()
+}
+
+// Results - results of all processed files, in the order they were processed
+func (s *Session) Results() []schema.OCRResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []schema.OCRResult
+	for _, e := range s.entries {
+		results = append(results, e.Results...)
+	}
+	return results
+}
+
+func (s *Session) Close() error {
+	return s.fd.Close()
+}
+
+// Remove - closes & deletes the journal, once results are safely written elsewhere
+func (s *Session) Remove() error {
+	_ = s.fd.Close()
+	return os.Remove(s.path)
+}
@@ -0,0 +1,66 @@
+package session
+
+import (
+	"os"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func record(t *testing.T, s *Session, file string) {
+	t.Helper()
+	if err := s.Record(file, []schema.OCRResult{{Filename: file}}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumeAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(t, s, "1.png")
+	record(t, s, "2.png")
+	s.Close()
+
+	// killed while writing the entry of 3.png
+	fd, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.WriteString(`{"file":"3.png","resu`)
+	fd.Close()
+
+	s, err = Open(dir, "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Resumed() != 2 || !s.Done("2.png") || s.Done("3.png") {
+		t.Fatalf("resumed %d, want 1.png & 2.png done", s.Resumed())
+	}
+	record(t, s, "3.png")
+	s.Close()
+
+	// the entry recorded after resuming isn't lost in the broken line
+	s, err = Open(dir, "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Resumed() != 3 || !s.Done("3.png") {
+		t.Errorf("resumed %d, want 3 with 3.png done", s.Resumed())
+	}
+	results := s.Results()
+	if len(results) != 3 || results[2].Filename != "3.png" {
+		t.Errorf("got %+v, want results of 1.png, 2.png & 3.png", results)
+	}
+}
+
+func TestOpenInvalidID(t *testing.T) {
+	for _, id := range []string{"", "../x", "a/b"} {
+		if _, err := Open(t.TempDir(), id); err == nil {
+			t.Errorf("%q: opened", id)
+		}
+	}
+}
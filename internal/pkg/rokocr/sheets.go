@@ -0,0 +1,214 @@
+package rokocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/google"
+)
+
+const sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets/"
+
+// SheetsOptions - where to push results. Credentials are a service-account JSON key,
+// the spreadsheet has to be shared with the service-account e-mail.
+type SheetsOptions struct {
+	CredentialsFile string
+	SpreadsheetID   string
+	Sheet           string
+	// KeyField - if set, rows with the same key (e.g. governor id) are updated instead of appended
+	KeyField string
+
+	Retry retryutils.Options
+}
+
+type SheetsExporter struct {
+	client  *http.Client
+	opts    SheetsOptions
+	baseURL string
+}
+
+func NewSheetsExporter(ctx context.Context, opts SheetsOptions) (*SheetsExporter, error) {
+	credentials, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read credentials: %v", err)
+	}
+
+	conf, err := google.JWTConfigFromJSON(credentials, "https://www.googleapis.com/auth/spreadsheets")
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials: %v", err)
+	}
+
+	if len(opts.Sheet) == 0 {
+		opts.Sheet = "Sheet1"
+	}
+
+	return &SheetsExporter{
+		client:  conf.Client(ctx),
+		opts:    opts,
+		baseURL: sheetsAPI + url.PathEscape(opts.SpreadsheetID),
+	}, nil
+}
+
+// Push - writes results to the sheet, header row is created if sheet is empty
+func (e *SheetsExporter) Push(ctx context.Context, data []schema.OCRResult, template schema.OCRTemplate) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	columns := ExportColumns(template, data)
+	headers := []interface{}{"Filename"}
+	keyColumn := -1
+	for i, x := range columns {
+		headers = append(headers, x.Title)
+		if x.Field == e.opts.KeyField {
+			keyColumn = i + 1
+		}
+	}
+	if len(e.opts.KeyField) > 0 && keyColumn < 0 {
+		return fmt.Errorf("key field %q is not a column of template %v table", e.opts.KeyField, template.Title)
+	}
+
+	existing, err := e.values(ctx)
+	if err != nil {
+		return err
+	}
+
+	var appendRows [][]interface{}
+	if len(existing) == 0 {
+		appendRows = append(appendRows, headers)
+	}
+
+	// key => 1-based row number in the sheet
+	rowsByKey := make(map[string]int)
+	if keyColumn >= 0 {
+		s := template.OCRSchema[e.opts.KeyField]
+		for i, row := range existing {
+			if i > 0 && keyColumn < len(row) {
+				rowsByKey[s.NormalizeKey(row[keyColumn])] = i + 1
+			}
+		}
+	}
+
+	var updates []sheetsValueRange
+	// rows of this batch, which are not in the sheet yet => index in appendRows
+	appended := make(map[string]int)
+	for _, r := range data {
+		row := []interface{}{r.Filename}
+		for _, x := range columns {
+			row = append(row, schema.FormatValue(r.Data[x.Field]))
+		}
+
+		key := ""
+		if keyColumn >= 0 {
+			key = template.KeyOf(r, e.opts.KeyField)
+		}
+
+		if len(key) > 0 {
+			if n, ok := rowsByKey[key]; ok {
+				updates = append(updates, sheetsValueRange{
+					Range:  sheetRange(e.opts.Sheet, fmt.Sprintf("A%d", n)),
+					Values: [][]interface{}{row},
+				})
+				continue
+			}
+			if idx, ok := appended[key]; ok {
+				appendRows[idx] = row
+				continue
+			}
+			appended[key] = len(appendRows)
+		}
+		appendRows = append(appendRows, row)
+	}
+
+	if len(updates) > 0 {
+		body := map[string]interface{}{"valueInputOption": "USER_ENTERED", "data": updates}
+		if err := e.call(ctx, http.MethodPost, e.baseURL+"/values:batchUpdate", body, nil); err != nil {
+			return fmt.Errorf("can't update rows: %v", err)
+		}
+	}
+
+	if len(appendRows) > 0 {
+		endpoint := fmt.Sprintf("%s/values/%s:append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS", e.baseURL, url.PathEscape(sheetRange(e.opts.Sheet, "A1")))
+		if err := e.call(ctx, http.MethodPost, endpoint, sheetsValueRange{Values: appendRows}, nil); err != nil {
+			return fmt.Errorf("can't append rows: %v", err)
+		}
+	}
+
+	log.Debugf("[sheets] %v rows updated, %v appended", len(updates), len(appendRows))
+	return nil
+}
+
+// sheetRange - A1 notation of cell in the sheet, whole sheet if cell is empty. Name is always quoted,
+// names with spaces, "!" or looking like a cell (e.g. "KvK1") don't parse otherwise.
+func sheetRange(sheet, cell string) string {
+	r := "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+	if len(cell) > 0 {
+		r += "!" + cell
+	}
+	return r
+}
+
+type sheetsValueRange struct {
+	Range  string          `json:"range,omitempty"`
+	Values [][]interface{} `json:"values"`
+}
+
+func (e *SheetsExporter) values(ctx context.Context) ([][]interface{}, error) {
+	var result sheetsValueRange
+	// unformatted, so keys compare the same way as recognized values
+	endpoint := fmt.Sprintf("%s/values/%s?valueRenderOption=UNFORMATTED_VALUE", e.baseURL, url.PathEscape(sheetRange(e.opts.Sheet, "")))
+	if err := e.call(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return nil, fmt.Errorf("can't read sheet: %v", err)
+	}
+	return result.Values, nil
+}
+
+func (e *SheetsExporter) call(ctx context.Context, method, endpoint string, body, out interface{}) error {
+	return retryutils.Do(ctx, e.opts.Retry, func(ctx context.Context) error {
+		var reader io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			reader = bytes.NewReader(b)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode/100 != 2 {
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				// quota exceeded, worth waiting
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+
+		if out != nil {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	})
+}
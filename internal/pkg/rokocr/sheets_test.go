@@ -0,0 +1,71 @@
+package rokocr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+func TestSheetRange(t *testing.T) {
+	tests := []struct {
+		sheet, cell, want string
+	}{
+		{"Sheet1", "A1", "'Sheet1'!A1"},
+		{"KvK 3 (kills)", "A12", "'KvK 3 (kills)'!A12"},
+		{"Bob's kills", "A1", "'Bob''s kills'!A1"},
+		{"Bob's kills", "", "'Bob''s kills'"},
+	}
+	for _, tt := range tests {
+		if got := sheetRange(tt.sheet, tt.cell); got != tt.want {
+			t.Errorf("sheetRange(%q, %q) = %v, want %v", tt.sheet, tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestSheetsPushQuotesSheet(t *testing.T) {
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet:
+			ranges = append(ranges, strings.TrimPrefix(r.URL.Path, "/values/"))
+			json.NewEncoder(w).Encode(sheetsValueRange{Values: [][]interface{}{{"Filename", "id"}, {"1.png", "1"}}})
+			return
+		case strings.HasSuffix(r.URL.Path, "/values:batchUpdate"):
+			var body struct {
+				Data []sheetsValueRange `json:"data"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, d := range body.Data {
+				ranges = append(ranges, d.Range)
+			}
+		default:
+			ranges = append(ranges, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/values/"), ":append"))
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	e := &SheetsExporter{client: server.Client(), opts: SheetsOptions{Sheet: "Bob's kills", KeyField: "id"}, baseURL: server.URL}
+	template := schema.OCRTemplate{OCRSchema: map[string]schema.OCRSchema{"id": {Type: schema.TypeInt}}}
+	data := []schema.OCRResult{
+		{Filename: "1.png", Data: map[string]interface{}{"id": int64(1)}},
+		{Filename: "2.png", Data: map[string]interface{}{"id": int64(2)}},
+	}
+	if err := e.Push(context.Background(), data, template); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"'Bob''s kills'", "'Bob''s kills'!A2", "'Bob''s kills'!A1"}
+	if strings.Join(ranges, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got ranges %q, want %q", ranges, want)
+	}
+}
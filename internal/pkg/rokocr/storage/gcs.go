@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const gcsAPI = "https://storage.googleapis.com"
+
+// GCS - bucket of Google Cloud Storage, credentials are Application Default Credentials
+// (GOOGLE_APPLICATION_CREDENTIALS service-account key, or the metadata server when running on GCP)
+type GCS struct {
+	bucket string
+	prefix string
+	client *http.Client
+}
+
+func NewGCS(ctx context.Context, bucket, prefix string) (*GCS, error) {
+	if len(bucket) == 0 {
+		return nil, errors.New("gcs: bucket is empty")
+	}
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, fmt.Errorf("gcs: no credentials: %w", err)
+	}
+	return &GCS{bucket: bucket, prefix: prefix, client: oauth2.NewClient(ctx, creds.TokenSource)}, nil
+}
+
+func (g *GCS) objectKey(key string) string {
+	return strings.TrimPrefix(Key(g.prefix, key), "/")
+}
+
+func (g *GCS) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gcsAPI, url.PathEscape(g.bucket), url.PathEscape(g.objectKey(key)))
+}
+
+func (g *GCS) Put(ctx context.Context, key string, r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", gcsAPI, url.PathEscape(g.bucket), url.QueryEscape(g.objectKey(key)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(body))
+	resp, err := g.do(req, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (g *GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.do(req, key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, g.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.do(req, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	full := g.objectKey(prefix)
+	if len(prefix) == 0 || strings.HasSuffix(prefix, "/") {
+		full = strings.TrimPrefix(full+"/", "/")
+	}
+
+	var keys []string
+	query := url.Values{"prefix": {full}, "fields": {"items(name),nextPageToken"}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", gcsAPI, url.PathEscape(g.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := g.do(req, prefix)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gcs: can't decode list: %w", err)
+		}
+
+		for _, item := range result.Items {
+			keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(item.Name, g.prefix), "/"))
+		}
+		if len(result.NextPageToken) == 0 {
+			break
+		}
+		query.Set("pageToken", result.NextPageToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (g *GCS) String() string {
+	return "gs://" + Key(g.bucket, g.prefix)
+}
+
+func (g *GCS) do(req *http.Request, key string) (*http.Response, error) {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("gcs: %v: %w", key, ErrNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("gcs: %v %v: %v %s", req.Method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
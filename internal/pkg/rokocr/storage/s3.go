@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options - bucket of S3 (or S3 compatible storage, e.g. MinIO, R2). Credentials are read from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY & AWS_SESSION_TOKEN.
+type S3Options struct {
+	Bucket string
+	Prefix string
+	// Region - default AWS_REGION, or us-east-1
+	Region string
+	// Endpoint - S3 compatible storage (e.g. http://minio:9000), addressed path-style
+	Endpoint string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+type S3 struct {
+	opts   S3Options
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(opts S3Options) (*S3, error) {
+	if len(opts.Bucket) == 0 {
+		return nil, errors.New("s3: bucket is empty")
+	}
+	if len(opts.Region) == 0 {
+		opts.Region = os.Getenv("AWS_REGION")
+	}
+	if len(opts.Region) == 0 {
+		opts.Region = "us-east-1"
+	}
+	if len(opts.AccessKey) == 0 {
+		opts.AccessKey, opts.SecretKey, opts.SessionToken = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if len(opts.AccessKey) == 0 || len(opts.SecretKey) == 0 {
+		return nil, errors.New("s3: no credentials, set AWS_ACCESS_KEY_ID & AWS_SECRET_ACCESS_KEY")
+	}
+
+	// virtual-hosted style on AWS, path-style on custom endpoints. Names with dots are path-style too,
+	// a bucket.with.dots.s3... host doesn't match the *.s3... TLS certificate.
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", opts.Bucket, opts.Region)
+	if strings.Contains(opts.Bucket, ".") {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com/%s/", opts.Region, opts.Bucket)
+	}
+	if len(opts.Endpoint) > 0 {
+		endpoint = strings.TrimSuffix(opts.Endpoint, "/") + "/" + opts.Bucket + "/"
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+
+	return &S3{opts: opts, base: base, client: &http.Client{Timeout: 5 * time.Minute}, now: time.Now}, nil
+}
+
+func (s *S3) objectKey(key string) string {
+	return strings.TrimPrefix(Key(s.opts.Prefix, key), "/")
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectKey(key), nil, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectKey(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.objectKey(key), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {s.objectKey(prefix)}}
+	if len(prefix) == 0 || strings.HasSuffix(prefix, "/") {
+		// path.Join drops the trailing slash
+		query.Set("prefix", strings.TrimPrefix(s.objectKey(prefix)+"/", "/"))
+	}
+
+	var keys []string
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: can't decode list: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(strings.TrimPrefix(c.Key, s.opts.Prefix), "/"))
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3) String() string {
+	return "s3://" + Key(s.opts.Bucket, s.opts.Prefix)
+}
+
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.base
+	u.Path += key
+	// segments escaped the way SigV4 canonical uri expects
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = awsEscape(segments[i])
+	}
+	u.RawPath = s.base.EscapedPath() + strings.Join(segments, "/")
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3: %v: %w", key, ErrNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3: %v %v: %v %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign - AWS Signature Version 4 of the request, all its headers are signed
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if len(s.opts.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+
+	var names []string
+	headers := make(map[string]string)
+	for name, values := range req.Header {
+		n := strings.ToLower(name)
+		names = append(names, n)
+		headers[n] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, n := range names {
+		canonicalHeaders.WriteString(n + ":" + headers[n] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature))
+	// Host header is taken from the url
+	req.Header.Del("Host")
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape - URI encoding of SigV4, only unreserved characters are kept
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
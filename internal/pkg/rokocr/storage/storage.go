@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound - no object with the key
+var ErrNotFound = errors.New("not found")
+
+// Storage - where uploaded screenshots & generated reports are kept. Keys are slash separated paths
+// (e.g. job_12/screenshot.png), relative to the root of the storage.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List - keys starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+	// String - location of the storage, for logs (e.g. s3://bucket/prefix)
+	String() string
+}
+
+// Open - storage by location: s3://bucket[/prefix][?region=...&endpoint=...], gs://bucket[/prefix],
+// or local directory (path or file://path)
+func Open(ctx context.Context, location string) (Storage, error) {
+	u, err := url.Parse(location)
+	if err != nil || len(u.Scheme) <= 1 {
+		// not an url (or windows drive letter)
+		return NewLocal(location), nil
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		return NewLocal(filepath.FromSlash(u.Host + u.Path)), nil
+	case "s3":
+		return NewS3(S3Options{
+			Bucket:   u.Host,
+			Prefix:   prefix,
+			Region:   u.Query().Get("region"),
+			Endpoint: u.Query().Get("endpoint"),
+		})
+	case "gs":
+		return NewGCS(ctx, u.Host, prefix)
+	}
+	return nil, fmt.Errorf("unsupported storage %q, expected s3://, gs:// or local directory", location)
+}
+
+// Key - joins parts of a key
+func Key(parts ...string) string {
+	return path.Join(parts...)
+}
+
+// PutFile - uploads the local file as key
+func PutFile(ctx context.Context, s Storage, key, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(ctx, key, f)
+}
+
+// DeletePrefix - deletes every object starting with prefix
+func DeletePrefix(ctx context.Context, s Storage, prefix string) error {
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch - local directory with the objects under prefix, for tools reading files from disk (e.g. recognition).
+// Remote objects are downloaded into a temporary directory, removed by cleanup.
+func Fetch(ctx context.Context, s Storage, prefix, tmpdir string) (dir string, cleanup func(), err error) {
+	if local, ok := s.(*Local); ok {
+		return local.path(prefix), func() {}, nil
+	}
+
+	dir, err = os.MkdirTemp(tmpdir, "rokocr-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { _ = os.RemoveAll(dir) }
+
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	for _, k := range keys {
+		if err := download(ctx, s, k, filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(k, strings.TrimSuffix(prefix, "/")+"/")))); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("can't download %v: %w", k, err)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+func download(ctx context.Context, s Storage, key, file string) error {
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
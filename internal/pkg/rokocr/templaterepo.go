@@ -0,0 +1,372 @@
+package rokocr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+// TemplateIndexName - file listing templates of a repository
+const TemplateIndexName = "index.json"
+
+// TemplateIndexEntry - single template in a repository, file is relative to the index
+type TemplateIndexEntry struct {
+	Name      string   `json:"name"`
+	File      string   `json:"file"`
+	SHA256    string   `json:"sha256"`
+	Title     string   `json:"title"`
+	Version   string   `json:"version,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	Languages []string `json:"languages,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+type TemplateIndex struct {
+	Templates []TemplateIndexEntry `json:"templates"`
+}
+
+// TemplateRepository - remote index of community templates (plain http(s) URL of index.json,
+// or "github:owner/repo[@ref][/dir]" for a GitHub repo)
+type TemplateRepository struct {
+	IndexURL string
+	Retry    retryutils.Options
+	client   *http.Client
+}
+
+func NewTemplateRepository(index string, retry retryutils.Options) (*TemplateRepository, error) {
+	u, err := resolveIndexURL(index)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateRepository{IndexURL: u, Retry: retry, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func resolveIndexURL(index string) (string, error) {
+	if rest, ok := strings.CutPrefix(index, "github:"); ok {
+		parts := strings.SplitN(rest, "/", 3)
+		if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return "", fmt.Errorf("invalid github repository %q, expected github:owner/repo[@ref][/dir]", index)
+		}
+		repo, ref, found := strings.Cut(parts[1], "@")
+		if !found {
+			ref = "main"
+		}
+		dir := ""
+		if len(parts) == 3 {
+			dir = parts[2]
+		}
+		return "https://raw.githubusercontent.com/" + path.Join(parts[0], repo, ref, dir, TemplateIndexName), nil
+	}
+
+	u, err := url.Parse(index)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid template index %q, expected http(s) URL or github:owner/repo", index)
+	}
+	return index, nil
+}
+
+func (r *TemplateRepository) get(ctx context.Context, rawURL string) ([]byte, error) {
+	var body []byte
+	err := retryutils.Do(ctx, r.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return retryutils.Retryable(err)
+		}
+		defer resp.Body.Close()
+
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return retryutils.Retryable(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("GET %v", rawURL)}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+		return nil
+	})
+	return body, err
+}
+
+// Index - fetches the list of available templates
+func (r *TemplateRepository) Index(ctx context.Context) (TemplateIndex, error) {
+	var index TemplateIndex
+
+	body, err := r.get(ctx, r.IndexURL)
+	if err != nil {
+		return index, err
+	}
+
+	if err := json.Unmarshal(body, &index); err != nil {
+		return index, fmt.Errorf("invalid template index: %w", err)
+	}
+	return index, nil
+}
+
+// Install - downloads the template with the base templates, includes & chained templates it needs (they have to be
+// in the index too), verifies their checksums & that the template loads, and only then writes them into dir, at their
+// paths relative to the index. Returns paths of the written files, the template first; files already in dir with the
+// same content aren't written again.
+func (r *TemplateRepository) Install(ctx context.Context, index TemplateIndex, entry TemplateIndexEntry, dir string) ([]string, error) {
+	staging, err := os.MkdirTemp("", "rok-template-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	s := &templateStaging{repo: r, index: index, dir: staging, staged: make(map[string]bool)}
+	if err := s.stage(ctx, entry); err != nil {
+		return nil, err
+	}
+	name := filepath.Join(staging, filepath.FromSlash(s.files[0]))
+
+	// chain is only known once bases are merged in, and it's relative to the template
+	data, _, err := schema.ReadTemplateJSON(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %v: %w", entry.Name, err)
+	}
+	var template schema.OCRTemplate
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("invalid template %v: %w", entry.Name, err)
+	}
+	for _, c := range template.Chain {
+		if err := s.stageRef(ctx, s.files[0], c); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := schema.LoadTemplate(name); err != nil {
+		return nil, fmt.Errorf("template %v doesn't load: %w", entry.Name, err)
+	}
+
+	var written []string
+	for _, f := range s.files {
+		body, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(f)))
+		if err != nil {
+			return written, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f))
+		if existing, err := os.ReadFile(target); err == nil && bytes.Equal(existing, body) && f != s.files[0] {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(target, body, 0644); err != nil {
+			return written, err
+		}
+		written = append(written, target)
+	}
+	return written, nil
+}
+
+// templateStaging - files of a template being installed, downloaded into dir
+type templateStaging struct {
+	repo  *TemplateRepository
+	index TemplateIndex
+	dir   string
+	// files - paths relative to the index, in the order they were staged; staged - the same as a set
+	files  []string
+	staged map[string]bool
+}
+
+// stage - downloads the entry & verifies its checksum, then stages the templates it extends & includes
+func (s *templateStaging) stage(ctx context.Context, entry TemplateIndexEntry) error {
+	file, err := indexPath(entry.File)
+	if err != nil {
+		return fmt.Errorf("invalid file of %v: %w", entry.Name, err)
+	}
+	if s.staged[file] {
+		return nil
+	}
+	s.staged[file] = true
+
+	base, err := url.Parse(s.repo.IndexURL)
+	if err != nil {
+		return err
+	}
+	ref, err := url.Parse(file)
+	if err != nil {
+		return fmt.Errorf("invalid file of %v: %w", entry.Name, err)
+	}
+
+	body, err := s.repo.get(ctx, base.ResolveReference(ref).String())
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), entry.SHA256) {
+		return fmt.Errorf("checksum mismatch of %v: expected %v, got %x", entry.Name, entry.SHA256, sum)
+	}
+
+	name := filepath.Join(s.dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, body, 0644); err != nil {
+		return err
+	}
+	s.files = append(s.files, file)
+
+	parents, err := schema.TemplateJSONParents(body)
+	if err != nil {
+		return fmt.Errorf("invalid template %v: %w", entry.Name, err)
+	}
+	for _, p := range parents {
+		if err := s.stageRef(ctx, file, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stageRef - stages the index entry of a file referenced by another one (relative to it)
+func (s *templateStaging) stageRef(ctx context.Context, from, ref string) error {
+	if filepath.IsAbs(ref) {
+		return fmt.Errorf("%v references %v, only relative paths can be installed", from, ref)
+	}
+	file := path.Join(path.Dir(from), filepath.ToSlash(ref))
+	for _, e := range s.index.Templates {
+		if f, err := indexPath(e.File); err == nil && f == file {
+			return s.stage(ctx, e)
+		}
+	}
+	return fmt.Errorf("%v references %v, which isn't in the index", from, file)
+}
+
+// indexPath - clean path of a file relative to the index, it can't point outside of it
+func indexPath(file string) (string, error) {
+	p := path.Clean(filepath.ToSlash(file))
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return "", fmt.Errorf("%v is outside of the repository", file)
+	}
+	return p, nil
+}
+
+// Find - entry by name
+func (index TemplateIndex) Find(name string) (TemplateIndexEntry, bool) {
+	for _, e := range index.Templates {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return TemplateIndexEntry{}, false
+}
+
+// Search - entries having every word of the query in name, title, author, tags, languages or resolution (e.g. "1920x1080")
+func (index TemplateIndex) Search(query string) []TemplateIndexEntry {
+	words := strings.Fields(strings.ToLower(query))
+
+	var result []TemplateIndexEntry
+	for _, e := range index.Templates {
+		haystack := strings.ToLower(strings.Join(append([]string{
+			e.Name, e.Title, e.Author, fmt.Sprintf("%vx%v", e.Width, e.Height),
+		}, append(e.Tags, e.Languages...)...), " "))
+
+		matches := true
+		for _, w := range words {
+			if !strings.Contains(haystack, w) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// BuildTemplateIndex - index of all JSON templates in dir & its sub-folders (base templates, includes), for publishing
+// a repository. Entries are described by the merged template, so variants list what they inherit.
+func BuildTemplateIndex(dir string) (TemplateIndex, error) {
+	var index TemplateIndex
+
+	err := filepath.WalkDir(dir, func(f string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if f != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if filepath.Ext(f) != ".json" || rel == TemplateIndexName {
+			return nil
+		}
+
+		body, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		merged, _, err := schema.ReadTemplateJSON(f)
+		if err != nil {
+			return fmt.Errorf("%v: %w", rel, err)
+		}
+
+		var template schema.OCRTemplate
+		if err := json.Unmarshal(merged, &template); err != nil {
+			return fmt.Errorf("%v: %w", rel, err)
+		}
+
+		languages := make(map[string]bool)
+		for _, field := range template.OCRSchema {
+			for _, l := range field.Languages {
+				languages[l] = true
+			}
+		}
+
+		entry := TemplateIndexEntry{
+			Name:    strings.TrimSuffix(rel, ".json"),
+			File:    rel,
+			SHA256:  fmt.Sprintf("%x", sha256.Sum256(body)),
+			Title:   template.Title,
+			Version: template.Version,
+			Author:  template.Author,
+			Width:   template.Width,
+			Height:  template.Height,
+		}
+		for l := range languages {
+			entry.Languages = append(entry.Languages, l)
+		}
+		sort.Strings(entry.Languages)
+
+		index.Templates = append(index.Templates, entry)
+		return nil
+	})
+
+	return index, err
+}
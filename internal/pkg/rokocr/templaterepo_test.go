@@ -0,0 +1,199 @@
+package rokocr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+// templateRepo - repository with a variant extending an abstract base in a sub-folder, and including a partial
+func templateRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"base/profile.json": `{
+			"abstract": true,
+			"title": "Governor Profile",
+			"width": 1920,
+			"height": 1080,
+			"ocr_schema": {"power": {"crop": {"x": 800, "y": 300, "w": 200, "h": 40}, "type": "int"}}
+		}`,
+		"partials/kills.json": `{"ocr_schema": {"kills": {"crop": {"x": 800, "y": 400, "w": 200, "h": 40}, "type": "int"}}}`,
+		"profile_de.json": `{
+			"extends": "base/profile.json",
+			"include": ["partials/kills.json"],
+			"title": "Governor Profile (DE)"
+		}`,
+	}
+	for name, body := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// serveRepo - serves the repository dir with the index
+func serveRepo(t *testing.T, dir string, index TemplateIndex) *TemplateRepository {
+	t.Helper()
+	body, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(dir, TemplateIndexName), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+
+	repo, err := NewTemplateRepository(server.URL+"/"+TemplateIndexName, retryutils.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestBuildTemplateIndex(t *testing.T) {
+	index, err := BuildTemplateIndex(templateRepo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Templates) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(index.Templates), index.Templates)
+	}
+	// the variant is described by the merged template
+	e, ok := index.Find("profile_de")
+	if !ok || e.File != "profile_de.json" || e.Title != "Governor Profile (DE)" || e.Width != 1920 || e.Height != 1080 {
+		t.Errorf("got %+v, want profile_de.json 1920x1080", e)
+	}
+	if e, ok := index.Find("base/profile"); !ok || e.File != "base/profile.json" {
+		t.Errorf("got %+v, want base/profile.json", e)
+	}
+}
+
+func TestInstallWithBase(t *testing.T) {
+	dir := templateRepo(t)
+	index, err := BuildTemplateIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := serveRepo(t, dir, index)
+	entry, _ := index.Find("profile_de")
+
+	target := t.TempDir()
+	installed, err := repo.Install(context.Background(), index, entry, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"profile_de.json", "base/profile.json", "partials/kills.json"}
+	if len(installed) != len(want) {
+		t.Fatalf("installed %v, want %v", installed, want)
+	}
+	for i, f := range want {
+		if installed[i] != filepath.Join(target, filepath.FromSlash(f)) {
+			t.Errorf("installed[%d] = %v, want %v", i, installed[i], f)
+		}
+	}
+
+	template, err := schema.LoadTemplate(installed[0])
+	if err != nil {
+		t.Fatalf("installed template doesn't load: %v", err)
+	}
+	if _, ok := template.OCRSchema["kills"]; !ok || template.Abstract {
+		t.Errorf("installed template isn't merged: %+v", template)
+	}
+
+	// bases already there aren't written again
+	installed, err = repo.Install(context.Background(), index, entry, target)
+	if err != nil || len(installed) != 1 {
+		t.Errorf("reinstalled %v (%v), want just the template", installed, err)
+	}
+}
+
+func TestInstallVerifiesBeforeWriting(t *testing.T) {
+	dir := templateRepo(t)
+	index, err := BuildTemplateIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := index.Find("profile_de")
+
+	tests := []struct {
+		name  string
+		index func(TemplateIndex) TemplateIndex
+		want  string
+	}{
+		{"base missing in index", func(index TemplateIndex) TemplateIndex {
+			var missing TemplateIndex
+			for _, e := range index.Templates {
+				if e.Name != "base/profile" {
+					missing.Templates = append(missing.Templates, e)
+				}
+			}
+			return missing
+		}, "isn't in the index"},
+		{"base checksum mismatch", func(index TemplateIndex) TemplateIndex {
+			var broken TemplateIndex
+			for _, e := range index.Templates {
+				if e.Name == "base/profile" {
+					e.SHA256 = strings.Repeat("0", 64)
+				}
+				broken.Templates = append(broken.Templates, e)
+			}
+			return broken
+		}, "checksum mismatch of base/profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := tt.index(index)
+			repo := serveRepo(t, dir, index)
+			target := t.TempDir()
+
+			if _, err := repo.Install(context.Background(), index, entry, target); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error %q", err, tt.want)
+			}
+			if files, _ := os.ReadDir(target); len(files) > 0 {
+				t.Errorf("%v written, want nothing", files[0].Name())
+			}
+		})
+	}
+}
+
+func TestInstallTemplateWhichDoesntLoad(t *testing.T) {
+	dir := t.TempDir()
+	body := `{"title": "Broken", "fingerprint": "not a hash"}`
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := BuildTemplateIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := serveRepo(t, dir, index)
+	target := t.TempDir()
+
+	if _, err := repo.Install(context.Background(), index, index.Templates[0], target); err == nil || !strings.Contains(err.Error(), "doesn't load") {
+		t.Errorf("got %v, want error of a template which doesn't load", err)
+	}
+	if files, _ := os.ReadDir(target); len(files) > 0 {
+		t.Errorf("%v written, want nothing", files[0].Name())
+	}
+}
+
+func TestInstallOutsideOfRepository(t *testing.T) {
+	index := TemplateIndex{Templates: []TemplateIndexEntry{{Name: "evil", File: "../evil.json"}}}
+	repo := serveRepo(t, t.TempDir(), index)
+	if _, err := repo.Install(context.Background(), index, index.Templates[0], t.TempDir()); err == nil || !strings.Contains(err.Error(), "outside of the repository") {
+		t.Errorf("got %v, want error of a file outside of the repository", err)
+	}
+}
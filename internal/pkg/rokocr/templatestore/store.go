@@ -0,0 +1,202 @@
+package templatestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	log "github.com/sirupsen/logrus"
+)
+
+// Settle - changes are picked up only after the directory wasn't written for this long
+// (editors save files in several steps)
+const Settle = 500 * time.Millisecond
+
+type entry struct {
+	modified time.Time
+	size     int64
+	template schema.OCRTemplate
+	err      error
+	// loaded - when the file was loaded, base templates & includes changed since then reload it too
+	loaded time.Time
+}
+
+// sourcesChanged - a base template or include of the entry was written after it was loaded
+func (e entry) sourcesChanged() bool {
+	for _, f := range e.template.Sources {
+		info, err := os.Stat(f)
+		if err != nil || info.ModTime().After(e.loaded) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store - templates of a directory, kept up to date with added, changed & removed files (see Watch)
+type Store struct {
+	dir string
+
+	mu        sync.RWMutex
+	files     map[string]entry
+	templates []schema.OCRTemplate
+	onReload  []func(templates []schema.OCRTemplate)
+}
+
+// New - loads all templates of the directory
+func New(dir string) *Store {
+	s := &Store{dir: dir, files: make(map[string]entry)}
+	s.Reload()
+	return s
+}
+
+// Templates - currently loaded templates, in stable order
+func (s *Store) Templates() []schema.OCRTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]schema.OCRTemplate(nil), s.templates...)
+}
+
+func loader(f string) func(string) (schema.OCRTemplate, error) {
+	switch filepath.Ext(f) {
+	case ".json":
+		return schema.LoadTemplate
+	case schema.TemplateBinaryExt:
+		return schema.LoadTemplateBinary
+	}
+	return nil
+}
+
+// OnReload - fn is called with the templates once they are loaded (right away) and after every reload
+func (s *Store) OnReload(fn func(templates []schema.OCRTemplate)) {
+	s.mu.Lock()
+	s.onReload = append(s.onReload, fn)
+	s.mu.Unlock()
+	fn(s.Templates())
+}
+
+// Reload - re-reads added & changed template files, and drops removed ones. Templates failing to load
+// or validate are logged, only once per change of the file.
+func (s *Store) Reload() {
+	s.reload()
+
+	s.mu.RLock()
+	hooks := append([]func([]schema.OCRTemplate){}, s.onReload...)
+	s.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(s.Templates())
+	}
+}
+
+func (s *Store) reload() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Errorf("Failed to read templates: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make(map[string]entry, len(entries))
+	for _, e := range entries {
+		f := filepath.Join(s.dir, e.Name())
+		load := loader(f)
+		if e.IsDir() || load == nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		if old, ok := s.files[f]; ok && old.modified.Equal(info.ModTime()) && old.size == info.Size() && !old.sourcesChanged() {
+			files[f] = old
+			continue
+		}
+
+		_, known := s.files[f]
+		loaded := entry{modified: info.ModTime(), size: info.Size(), loaded: time.Now()}
+		loaded.template, loaded.err = load(f)
+		files[f] = loaded
+
+		if loaded.err != nil {
+			log.Errorf("Failed to load template: %v => %v", e.Name(), loaded.err)
+			continue
+		}
+		if loaded.template.Abstract {
+			log.Debugf("Skipped abstract template: %v", e.Name())
+			continue
+		}
+		if errs, ok := loaded.template.Validate().(schema.ValidationErrors); ok {
+			for _, ve := range errs {
+				log.Warnf("[%v] %v", e.Name(), ve)
+			}
+		}
+		if known {
+			log.Infof("Reloaded template: %v => %v", e.Name(), loaded.template.Title)
+		} else {
+			log.Debugf("Loaded template: %v => %v", e.Name(), loaded.template.Title)
+		}
+	}
+
+	for f := range s.files {
+		if _, ok := files[f]; !ok {
+			log.Infof("Removed template: %v", filepath.Base(f))
+		}
+	}
+
+	var templates []schema.OCRTemplate
+	for _, e := range files {
+		if e.err == nil && !e.template.Abstract {
+			templates = append(templates, e.template)
+		}
+	}
+	schema.SortTemplates(templates)
+
+	s.files = files
+	s.templates = templates
+}
+
+// Watch - reloads templates whenever the directory changes, until ctx is cancelled
+func (s *Store) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(s.dir); err != nil {
+		return fmt.Errorf("can't watch %v: %w", s.dir, err)
+	}
+
+	// fires once the burst of events settles
+	reload := time.NewTimer(Settle)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if loader(ev.Name) != nil {
+				reload.Reset(Settle)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("Watching %v: %v", s.dir, err)
+		case <-reload.C:
+			s.Reload()
+		}
+	}
+}
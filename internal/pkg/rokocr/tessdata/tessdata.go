@@ -0,0 +1,293 @@
+// Package tessdata - managed directory of tesseract language models (traineddata). Models used by templates are
+// downloaded on demand, in the configured variant, and recorded in a manifest, so switching the variant re-downloads
+// them.
+package tessdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	log "github.com/sirupsen/logrus"
+)
+
+// model variants, see https://tesseract-ocr.github.io/tessdoc/Data-Files.html
+const (
+	// Standard - tessdata repository, legacy & LSTM engines (default, the server always shipped these)
+	Standard = "standard"
+	// Fast - integer LSTM models, fastest & smallest, a bit less accurate
+	Fast = "fast"
+	// Best - float LSTM models, most accurate, slowest
+	Best = "best"
+)
+
+// Variants - known model variants
+var Variants = []string{Standard, Fast, Best}
+
+var repositories = map[string]string{
+	Standard: "tessdata",
+	Fast:     "tessdata_fast",
+	Best:     "tessdata_best",
+}
+
+// BaseURL - download URL of models, %s are the repository & language
+var BaseURL = "https://raw.githubusercontent.com/tesseract-ocr/%s/main/%s.traineddata"
+
+// ManifestFile - records which variant every downloaded model is, in the tessdata dir
+const ManifestFile = "tessdata.json"
+
+// ErrMissing - languages aren't installed, and downloading is disabled
+var ErrMissing = errors.New("missing tesseract language models")
+
+// Model - downloaded language model
+type Model struct {
+	Variant    string    `json:"variant"`
+	Size       int64     `json:"size"`
+	Downloaded time.Time `json:"downloaded"`
+}
+
+type manifest struct {
+	Models map[string]Model `json:"models"`
+}
+
+// Manager - tessdata dir, see Ensure. Models put into the dir by hand (not in the manifest) are left alone.
+type Manager struct {
+	dir      string
+	variant  string
+	download bool
+	client   *http.Client
+
+	mu sync.Mutex
+}
+
+// New - manager of the dir, missing models are downloaded in the variant unless download is false
+func New(dir, variant string, download bool) (*Manager, error) {
+	if len(variant) == 0 {
+		variant = Standard
+	}
+	if _, ok := repositories[variant]; !ok {
+		return nil, fmt.Errorf("unknown tessdata variant %q, expected one of: %s", variant, strings.Join(Variants, ", "))
+	}
+	return &Manager{dir: dir, variant: variant, download: download, client: &http.Client{Timeout: 10 * time.Minute}}, nil
+}
+
+// Path - traineddata file of the language
+func (m *Manager) Path(lang string) string {
+	return filepath.Join(m.dir, lang+".traineddata")
+}
+
+// Installed - languages with a model in the dir, by variant ("" - put there by hand)
+func (m *Manager) Installed() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	installed := make(map[string]string)
+	files, _ := filepath.Glob(filepath.Join(m.dir, "*.traineddata"))
+	models := m.readManifest().Models
+	for _, f := range files {
+		lang := strings.TrimSuffix(filepath.Base(f), ".traineddata")
+		installed[lang] = models[lang].Variant
+	}
+	return installed
+}
+
+// Missing - languages which have to be downloaded: not installed yet, or downloaded in another variant
+func (m *Manager) Missing(languages []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.missing(m.readManifest(), languages)
+}
+
+func (m *Manager) missing(man manifest, languages []string) []string {
+	var missing []string
+	for _, lang := range unique(languages) {
+		if _, err := os.Stat(m.Path(lang)); err != nil {
+			missing = append(missing, lang)
+			continue
+		}
+		if model, ok := man.Models[lang]; ok && model.Variant != m.variant {
+			missing = append(missing, lang)
+		}
+	}
+	return missing
+}
+
+// Ensure - downloads models of the languages which are missing (see Missing). With downloading disabled the error
+// wraps ErrMissing and names the languages, instead of tesseract failing on the first field using them.
+func (m *Manager) Ensure(ctx context.Context, languages []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	man := m.readManifest()
+	missing := m.missing(man, languages)
+	if len(missing) == 0 {
+		return nil
+	}
+	if !m.download {
+		// models of another variant still work
+		var absent []string
+		for _, lang := range missing {
+			if _, err := os.Stat(m.Path(lang)); err != nil {
+				absent = append(absent, lang)
+			}
+		}
+		if len(absent) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%w in %v: %s", ErrMissing, m.dir, strings.Join(absent, ", "))
+	}
+
+	if err := os.MkdirAll(m.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, lang := range missing {
+		size, err := m.fetch(ctx, lang)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", lang, err))
+			continue
+		}
+		man.Models[lang] = Model{Variant: m.variant, Size: size, Downloaded: time.Now().UTC()}
+	}
+
+	if err := m.writeManifest(man); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// fetch - downloads the model next to the target first, so an interrupted download doesn't leave a broken model
+func (m *Manager) fetch(ctx context.Context, lang string) (int64, error) {
+	url := fmt.Sprintf(BaseURL, repositories[m.variant], lang)
+	log.Infof("Downloading %v model of %v: %v", m.variant, lang, url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, fmt.Errorf("no %v model of language %q", m.variant, lang)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("download failed: %v", resp.Status)
+	}
+
+	tmp := m.Path(lang) + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return size, os.Rename(tmp, m.Path(lang))
+}
+
+func (m *Manager) readManifest() manifest {
+	man := manifest{Models: make(map[string]Model)}
+	data, err := os.ReadFile(filepath.Join(m.dir, ManifestFile))
+	if err != nil {
+		return man
+	}
+	if err := json.Unmarshal(data, &man); err != nil {
+		log.Warnf("Ignoring broken %v: %v", ManifestFile, err)
+	}
+	if man.Models == nil {
+		man.Models = make(map[string]Model)
+	}
+	return man
+}
+
+func (m *Manager) writeManifest(man manifest) error {
+	data, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, ManifestFile), data, 0644)
+}
+
+// Custom - checks custom models (see TemplateModels) are in the dir, they can't be downloaded. The error wraps
+// ErrMissing and names the models.
+func (m *Manager) Custom(models []string) error {
+	var absent []string
+	for _, model := range unique(models) {
+		if _, err := os.Stat(m.Path(model)); err != nil {
+			absent = append(absent, model+".traineddata")
+		}
+	}
+	if len(absent) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w in %v, custom models have to be put there by hand: %s", ErrMissing, m.dir, strings.Join(absent, ", "))
+}
+
+// TemplateLanguages - languages fields of the templates are recognized with (lang & lang_fallback), fields without
+// own lang use defaults (english if none). Fields with a custom model only need their fallbacks.
+func TemplateLanguages(templates []schema.OCRTemplate, defaults []string) []string {
+	if len(defaults) == 0 {
+		defaults = []string{"eng"}
+	}
+
+	var languages []string
+	for _, t := range templates {
+		for _, s := range t.OCRSchema {
+			if len(s.Languages) == 0 {
+				s.Languages = defaults
+			}
+			chain := s.LanguageChain()
+			if len(s.Model) > 0 {
+				chain = chain[1:]
+			}
+			for _, l := range chain {
+				languages = append(languages, l...)
+			}
+		}
+	}
+	return unique(languages)
+}
+
+// TemplateModels - custom models (`model`) fields of the templates are recognized with
+func TemplateModels(templates []schema.OCRTemplate) []string {
+	var models []string
+	for _, t := range templates {
+		for _, s := range t.OCRSchema {
+			models = append(models, s.Model)
+		}
+	}
+	return unique(models)
+}
+
+func unique(languages []string) []string {
+	seen := make(map[string]bool)
+	var list []string
+	for _, l := range languages {
+		if l = strings.TrimSpace(l); len(l) > 0 && !seen[l] {
+			seen[l] = true
+			list = append(list, l)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
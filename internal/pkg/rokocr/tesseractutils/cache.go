@@ -0,0 +1,73 @@
+package tesseractutils
+
+import (
+	"image"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/fieldcache"
+)
+
+// cacheSettings - everything besides the crop which changes how a field is recognized. Pattern & type aren't part
+// of it, value of a cached field is post-processed again.
+type cacheSettings struct {
+	Languages     []string         `json:"lang"`
+	Fallback      []string         `json:"fallback,omitempty"`
+	OEM           int              `json:"oem"`
+	PSM           int              `json:"psm"`
+	AllowList     schema.AllowList `json:"allowlist,omitempty"`
+	Preprocess    []string         `json:"preprocess,omitempty"`
+	Vote          int              `json:"vote,omitempty"`
+	MinConfidence float64          `json:"min_confidence,omitempty"`
+	Engine        string           `json:"engine"`
+}
+
+// cacheKey - key of the (not yet preprocessed) crop of the field, empty if the field isn't cached
+func (o Options) cacheKey(crop image.Image, s schema.OCRSchema) string {
+	if o.Cache == nil || o.WantAlternatives > 0 {
+		return ""
+	}
+
+	engine := s.Engine
+	if len(engine) == 0 {
+		engine = o.engineConfig().Name
+	}
+	key, err := fieldcache.Key(crop, cacheSettings{
+		Languages:     s.Languages,
+		Fallback:      s.LanguageFallback,
+		OEM:           s.OEM,
+		PSM:           s.PSM,
+		AllowList:     s.AllowList,
+		Preprocess:    s.Preprocess,
+		Vote:          s.Vote,
+		MinConfidence: o.minConfidence(s),
+		Engine:        engine,
+	})
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// cachedField - field recognized from the same crop before, with the value post-processed by current pattern & type
+func (o Options) cachedField(key string, s schema.OCRSchema) (schema.FieldResult, bool) {
+	if len(key) == 0 {
+		return schema.FieldResult{}, false
+	}
+	field, ok := o.Cache.Get(key)
+	if !ok {
+		return field, false
+	}
+	field.Crop = s.Crop.Pixels()
+	field.Value, field.Transforms = s.PostProcess(field.Text)
+	return field, true
+}
+
+// cacheField - failed recognitions (nothing read, or cancelled) aren't cached, so they're retried next time
+func (o Options) cacheField(name, n, key string, field schema.FieldResult) {
+	if len(key) == 0 || o.cancelled() || field.Confidence <= 0 {
+		return
+	}
+	if err := o.Cache.Put(key, field); err != nil {
+		o.logger(name, n).Warnf("Failed to cache '%s': %v", n, err)
+	}
+}
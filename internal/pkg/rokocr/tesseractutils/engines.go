@@ -0,0 +1,57 @@
+package tesseractutils
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+)
+
+// errEnginesClosed - field of a cancelled batch asked for an engine after the batch released them
+var errEnginesClosed = errors.New("OCR engines are closed")
+
+// engineSet - engines shared by a batch. Fields can pick their own engine, so they are created on first use.
+type engineSet struct {
+	cfg     ocrengine.Config
+	mu      sync.Mutex
+	engines map[string]ocrengine.Engine
+}
+
+func newEngineSet(cfg ocrengine.Config) *engineSet {
+	return &engineSet{cfg: cfg, engines: make(map[string]ocrengine.Engine)}
+}
+
+// get - engine by name, empty - the configured one
+func (set *engineSet) get(name string) (ocrengine.Engine, error) {
+	cfg := set.cfg
+	if len(name) > 0 {
+		cfg.Name = name
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.engines == nil {
+		return nil, errEnginesClosed
+	}
+	if engine, ok := set.engines[cfg.Name]; ok {
+		return engine, nil
+	}
+	engine, err := ocrengine.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	set.engines[cfg.Name] = engine
+	return engine, nil
+}
+
+// Close - closes all engines, set must not be used afterwards
+func (set *engineSet) Close() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for _, engine := range set.engines {
+		_ = engine.Close()
+	}
+	set.engines = nil
+}
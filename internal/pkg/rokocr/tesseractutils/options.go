@@ -0,0 +1,235 @@
+package tesseractutils
+
+import (
+	"context"
+	"image"
+	"os"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/fieldcache"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/pdfpages"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/quality"
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	"github.com/sirupsen/logrus"
+)
+
+// Options - knobs for recognition, zero value is usable
+type Options struct {
+	TmpDirectory      string
+	TessdataDirectory string
+	Files             fileutils.ListOptions
+
+	// WantAlternatives - how many candidate interpretations to keep per field (0 - none)
+	WantAlternatives int
+
+	// MinConfidence - default for fields without own min_confidence (0 - disabled).
+	// Fields below it are retried with alternative preprocessing, and flagged if still below.
+	MinConfidence float64
+
+	// Languages - default for fields without own lang (nil - engine default)
+	Languages []string
+
+	// Jobs - how many files (and fields of a file) are recognized concurrently, 0 or 1 - serial
+	Jobs int
+
+	// OnFailure - called (from recognition goroutine) for every file which couldn't be recognized,
+	// e.g. it doesn't match the template
+	OnFailure func(file string, err error)
+
+	// Skip - files of a batch not to recognize (e.g. already done by a resumed session)
+	Skip func(file string) bool
+
+	// OnProcessed - called (from recognition goroutine, in file order) once a file of a batch is done,
+	// before its results are emitted
+	OnProcessed func(file string, results []schema.OCRResult, err error)
+
+	// OnField - called (from recognition goroutine) once a field is recognized, with how long its crop, preprocessing,
+	// OCR & retries took
+	OnField func(template, field string, took time.Duration)
+
+	// MaxRetries & Backoff - retries of transient recognition failures
+	MaxRetries int
+	Backoff    time.Duration
+
+	// CropBorders - black bars & notch insets are cropped off screenshots before template matching
+	CropBorders bool
+
+	// PDF - how pages of PDF files are rasterized
+	PDF pdfpages.Options
+
+	// Quality - pre-check of screenshots before OCR (nil - disabled), failing ones are rejected
+	// with quality.ErrLowQuality or flagged (see OCRResult.Quality)
+	Quality *quality.Options
+
+	// Engine - OCR engine to use, zero value - the default one (see SetDefaultEngine)
+	Engine ocrengine.Config
+
+	// Cache - fields by crop hash (nil - disabled), crops read before aren't recognized again.
+	// Not used when WantAlternatives is set.
+	Cache *fieldcache.Cache
+
+	// Tags - kingdom, alliance & date of the scan, added to every result (see schema.ScanTags)
+	Tags schema.ScanTags
+
+	// entry - log entry of the recognized file & template, set by parseScaled
+	entry *logrus.Entry
+
+	// engines - shared engines, set up by batch functions so their clients are reused between files
+	engines *engineSet
+
+	// ctx - cancels recognition, see WithContext
+	ctx context.Context
+}
+
+func DefaultOptions(tessdata string) Options {
+	return Options{
+		TmpDirectory:      os.TempDir(),
+		TessdataDirectory: tessdata,
+		Files:             fileutils.DefaultImageListOptions(),
+		CropBorders:       true,
+		PDF:               pdfpages.DefaultOptions(),
+	}
+}
+
+// WithContext - options of recognition cancelled with ctx. Files which weren't done once it's cancelled are left out
+// of the results (and OnProcessed / OnFailure), engines are still released.
+func (o Options) WithContext(ctx context.Context) Options {
+	o.ctx = ctx
+	return o
+}
+
+// Context - context of the recognition, context.Background() unless set with WithContext
+func (o Options) Context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// cancelled - recognition was cancelled, results from now on are incomplete
+func (o Options) cancelled() bool {
+	return o.ctx != nil && o.ctx.Err() != nil
+}
+
+func (o Options) tmpDirectory() string {
+	if len(o.TmpDirectory) == 0 {
+		return os.TempDir()
+	}
+	return o.TmpDirectory
+}
+
+func (o Options) minConfidence(s schema.OCRSchema) float64 {
+	if s.MinConfidence > 0 {
+		return s.MinConfidence
+	}
+	return o.MinConfidence
+}
+
+// languages - field with default languages filled in
+func (o Options) languages(s schema.OCRSchema) schema.OCRSchema {
+	if len(s.Model) > 0 {
+		s.Languages = []string{s.Model}
+	} else if len(s.Languages) == 0 {
+		s.Languages = o.Languages
+	}
+	return s
+}
+
+// logger - log entry with file, template (once known) & field (if given) of the recognition
+func (o Options) logger(name, field string) *logrus.Entry {
+	entry := o.entry
+	if entry == nil {
+		entry = logutils.File(name)
+	}
+	if len(field) > 0 {
+		return entry.WithField(logutils.FieldKey, field)
+	}
+	return entry
+}
+
+func (o Options) jobs() int {
+	if o.Jobs < 1 {
+		return 1
+	}
+	return o.Jobs
+}
+
+func (o Options) engineConfig() ocrengine.Config {
+	cfg := o.Engine
+	if len(cfg.Name) == 0 {
+		cfg = defaultEngine
+	}
+	cfg.Size = o.jobs()
+	return cfg
+}
+
+// Shared - options with engines shared between calls (e.g. of a long living scanner), instead of set up per file.
+// Engines are released by the returned function.
+func (o Options) Shared() (Options, func()) {
+	return o.withEngine()
+}
+
+// withEngine - returns options with engines sized to Jobs (if it doesn't have them yet),
+// and a function to release them once the batch is done
+func (o Options) withEngine() (Options, func()) {
+	if o.engines != nil {
+		return o, func() {}
+	}
+	o.engines = newEngineSet(o.engineConfig())
+	return o, o.engines.Close
+}
+
+func (o Options) parseText(ctx context.Context, imageFileName string, s schema.OCRSchema) (string, float64, error) {
+	if o.engines != nil {
+		engine, err := o.engines.get(s.Engine)
+		if err != nil {
+			return "", 0, err
+		}
+		return engine.Recognize(ctx, imageFileName, s, o.TessdataDirectory)
+	}
+
+	cfg := o.engineConfig()
+	cfg.Size = 1
+	if len(s.Engine) > 0 {
+		cfg.Name = s.Engine
+	}
+	engine, err := ocrengine.New(cfg)
+	if err != nil {
+		return "", 0, err
+	}
+	defer engine.Close()
+	return engine.Recognize(ctx, imageFileName, s, o.TessdataDirectory)
+}
+
+func (o Options) retry() retryutils.Options {
+	return retryutils.Options{MaxRetries: o.MaxRetries, Backoff: o.Backoff}
+}
+
+// checkQuality - issues of the screenshot to flag its result with, or an error if it's rejected
+func (o Options) checkQuality(name string, img image.Image, template schema.OCRTemplate) ([]string, error) {
+	if o.Quality == nil {
+		return nil, nil
+	}
+
+	report := quality.Check(img, template.Width, template.Height, *o.Quality)
+	if report.OK() {
+		return nil, nil
+	}
+	if o.Quality.Reject {
+		return report.Issues, report.Err()
+	}
+	logutils.Recognition(name, template.Title).Warn(report.Err())
+	return report.Issues, nil
+}
+
+func (o Options) prepareImage(img image.Image) image.Image {
+	if o.CropBorders {
+		return imgutils.CropBorders(img)
+	}
+	return img
+}
@@ -1,43 +1,340 @@
 package tesseractutils
 
 import (
+	"context"
 	"image"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	imgutils2 "github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
 
-	log "github.com/sirupsen/logrus"
-
 	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
 )
 
 func ParseImage(name string, img image.Image, template schema.OCRTemplate, tmpdir, tessdata string) schema.OCRResult {
-	log.Debugf("[%s] Processing with template: %s", filepath.Base(name), template.Title)
+	opts := DefaultOptions(tessdata)
+	opts.TmpDirectory = tmpdir
+	return ParseImageWithOptions(name, img, template, opts)
+}
+
+func ParseImageWithOptions(name string, img image.Image, template schema.OCRTemplate, opts Options) schema.OCRResult {
+	logutils.Recognition(name, template.Title).Debugf("Processing with template: %s", template.Title)
+	return parseScaled(name, img, template, scaledTemplate(name, img, template), opts)
+}
+
+// ParseRowsWithOptions - like ParseImageWithOptions, but ranking lists (templates with rows) produce a result
+// for every detected row. Rows with no recognized field are left out. Fields of chained templates are merged into every result.
+func ParseRowsWithOptions(name string, img image.Image, template schema.OCRTemplate, opts Options) []schema.OCRResult {
+	return parseChained(name, img, template, listRows(name, img, template, opts), opts)
+}
+
+// parseChained - fields of chained templates (which apply to the image) are recognized once, and merged into every result
+func parseChained(name string, img image.Image, template schema.OCRTemplate, results []schema.OCRResult, opts Options) []schema.OCRResult {
+	if len(results) == 0 {
+		return results
+	}
+	for _, c := range template.Chained {
+		if !c.AppliesTo(img) {
+			logutils.Recognition(name, template.Title).Debugf("Chained template %s doesn't match, skipped", c.Title)
+			continue
+		}
+		chained := ParseImageWithOptions(name, img, c, opts)
+		for i := range results {
+			schema.MergeChained(&results[i], chained)
+		}
+	}
+	return results
+}
+
+// listRows - result of every row of a ranking list, other screenshots have just one
+func listRows(name string, img image.Image, template schema.OCRTemplate, opts Options) []schema.OCRResult {
+	if template.Rows == nil {
+		return []schema.OCRResult{ParseImageWithOptions(name, img, template, opts)}
+	}
+
+	logutils.Recognition(name, template.Title).Debugf("Processing rows with template: %s", template.Title)
+	scaled := scaledTemplate(name, img, template)
+
+	var results []schema.OCRResult
+	for i, offset := range template.RowOffsets(img) {
+		result := parseScaled(name, img, template, scaled.Moved(image.Pt(0, offset)), opts)
+		if emptyResult(result, template) {
+			logutils.Recognition(name, template.Title).Debugf("row %v is empty", i+1)
+			continue
+		}
+		result.Row = i + 1
+		results = append(results, result)
+	}
+	return results
+}
+
+func emptyResult(r schema.OCRResult, template schema.OCRTemplate) bool {
+	for k := range template.OCRSchema {
+		if v := r.Data[k]; v != nil && len(strings.TrimSpace(schema.FormatValue(v))) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scaledTemplate - crops are scaled to the image resolution (instead of resizing the image), so text is read in
+// native quality, and follow anchors of the template
+func scaledTemplate(name string, img image.Image, template schema.OCRTemplate) schema.OCRTemplate {
+	scaled := template
+	if template.Width != img.Bounds().Dx() || template.Height != img.Bounds().Dy() {
+		logutils.Recognition(name, template.Title).Debugf("Need to scale: Original -> %v,%v, Template -> %v, %v", img.Bounds().Dx(), img.Bounds().Dy(), template.Width, template.Height)
+		scaled = template.ScaledTo(img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if len(template.Anchors) > 0 {
+		scaled = scaled.MoveAnchored(template.LocateAnchors(img))
+	}
+	return scaled
+}
+
+// parseScaled - recognizes fields of the template, at crops of the scaled one
+func parseScaled(name string, img image.Image, template, scaled schema.OCRTemplate, opts Options) schema.OCRResult {
 	start := time.Now()
+	opts.entry = logutils.Recognition(name, template.Title)
 
 	results := make(map[string]interface{})
+	fields := make(map[string]schema.FieldResult)
 
-	if template.Width != img.Bounds().Dx() || template.Height != img.Bounds().Dy() {
-		log.Debugf("[%s] Need to resize: Original -> %v,%v, Template -> %v, %v", filepath.Base(name), img.Bounds().Dx(), img.Bounds().Dy(), template.Width, template.Height)
-		img = imgutils2.ResizeImage(img, template.Width, template.Height)
+	var mu sync.Mutex
+	parse := func(n string, s schema.OCRSchema) {
+		fieldStart := time.Now()
+		field := parseField(name, n, s, scaled.OCRSchema[n].Crop, img, opts)
+		if opts.OnField != nil {
+			opts.OnField(template.Title, n, time.Since(fieldStart))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		results[n] = field.Value
+		fields[n] = field
 	}
 
+	// fields run concurrently only with a shared engine, which bounds the number of tesseract instances
+	var wg sync.WaitGroup
 	for n, s := range template.OCRSchema {
-		imgNew, _ := imgutils2.CropImage(img, image.Rect(s.Crop.X, s.Crop.Y, s.Crop.X+s.Crop.W, s.Crop.Y+s.Crop.H))
-		croppedName := filepath.Join(tmpdir, n+"_"+stringutils.Random(12)+"_"+filepath.Base(name))
-		imgutils2.WritePNGImage(imgNew, croppedName)
-		text, _ := ParseText(croppedName, s, tessdata)
-		_ = os.Remove(croppedName) // delete the temp file
-		log.Debugf("[%s] Extracted '%s' => %v", filepath.Base(name), n, text)
-		results[n] = text
+		if opts.cancelled() {
+			break
+		}
+		if opts.engines != nil && opts.jobs() > 1 {
+			wg.Add(1)
+			go func(n string, s schema.OCRSchema) {
+				defer wg.Done()
+				parse(n, s)
+			}(n, s)
+		} else {
+			parse(n, s)
+		}
 	}
+	wg.Wait()
 
-	return schema.OCRResult{
+	data, errs := template.ComputeFields(results)
+	for k, err := range errs {
+		opts.logger(name, k).Warnf("Failed to compute '%s': %v", k, err)
+	}
+	for k, msg := range template.FailedChecks(data) {
+		opts.logger(name, "").WithField("check", k).Warnf("Failed check '%s': %v", k, msg)
+	}
+
+	result := schema.OCRResult{
 		Filename: filepath.Base(name),
-		Data:     results,
+		Data:     data,
+		Fields:   fields,
 		Took:     time.Since(start),
 	}
+	opts.Tags.Apply(&result)
+	return result
+}
+
+// parseField - crops (with already scaled crop), preprocesses & recognizes a single field
+func parseField(name, n string, s schema.OCRSchema, crop *schema.OCRCrop, img image.Image, opts Options) schema.FieldResult {
+	s = opts.languages(s)
+	imgNew, _ := imgutils2.CropImage(img, crop.CropRectangle().Add(img.Bounds().Min))
+	key := opts.cacheKey(imgNew, s)
+	if field, ok := opts.cachedField(key, s); ok {
+		opts.logger(name, n).Debugf("Cached '%s' => %v (conf: %.1f)", n, field.Value, field.Confidence)
+		return field
+	}
+	imgNew, err := s.PreprocessImage(imgNew)
+	if err != nil {
+		opts.logger(name, n).Warnf("Failed to preprocess '%s': %v", n, err)
+	}
+	preprocess := append([]string{}, s.Preprocess...)
+	croppedName := filepath.Join(opts.tmpDirectory(), n+"_"+stringutils.Random(12)+"_"+filepath.Base(name))
+	imgutils2.WritePNGImage(imgNew, croppedName)
+	defer os.Remove(croppedName) // delete the temp file
+	text, confidence, languages := recognizeLanguages(name, n, croppedName, s, opts)
+	if len(s.LanguageFallback) == 0 {
+		languages = nil // nothing was picked, no need to record it
+	} else {
+		// retries & alternatives stay with the winning language
+		s.Languages, s.LanguageFallback = languages, nil
+	}
+
+	var voted vote
+	if s.Vote > 1 {
+		voted = voteDigits(name, n, img, crop, s, opts, ballot{text: text, confidence: confidence})
+		text, confidence = voted.text, voted.confidence
+	}
+
+	lowConfidence := false
+	var retry retried
+	if minConfidence := opts.minConfidence(s); minConfidence > 0 && confidence < minConfidence {
+		if voted.votes == 0 {
+			// voting already went through jittered variants
+			opts.logger(name, n).Debugf("'%s' confidence %.1f is below %.1f, retrying", n, confidence, minConfidence)
+			retry = retryLowConfidence(name, n, imgNew, s, opts, text, confidence)
+			text, confidence = retry.text, retry.confidence
+			preprocess = append(preprocess, retry.preprocess...)
+		}
+		if confidence < minConfidence {
+			opts.logger(name, n).Warnf("Low confidence of '%s' => %v (conf: %.1f)", n, text, confidence)
+			lowConfidence = true
+		}
+	}
+
+	value, transforms := s.PostProcess(text)
+	field := schema.FieldResult{
+		Crop:          s.Crop.Pixels(),
+		Preprocess:    preprocess,
+		Languages:     languages,
+		Text:          text,
+		Confidence:    confidence,
+		Transforms:    transforms,
+		Value:         value,
+		LowConfidence: lowConfidence,
+		Retries:       retry.attempts,
+		Variant:       retry.variant,
+		Votes:         voted.votes,
+		Agreement:     voted.agreement,
+	}
+	if opts.WantAlternatives > 0 {
+		field.Alternatives = opts.parseTextAlternatives(croppedName, s, opts.WantAlternatives)
+	}
+	opts.logger(name, n).Debugf("Extracted '%s' => %v (conf: %.1f)", n, value, confidence)
+	opts.cacheField(name, n, key, field)
+	return field
+}
+
+// recognizeLanguages - recognizes the crop with every language of the chain, and keeps the most confident result.
+// Stops early once the result is confident enough.
+func recognizeLanguages(name, field, file string, s schema.OCRSchema, opts Options) (string, float64, []string) {
+	chain := s.LanguageChain()
+	text, confidence := recognizeFile(name, field, file, s, opts)
+	languages := chain[0]
+
+	minConfidence := opts.minConfidence(s)
+	for _, l := range chain[1:] {
+		if minConfidence > 0 && confidence >= minConfidence {
+			break
+		}
+		variant := s
+		variant.Languages = l
+		t, c := recognizeFile(name, field, file, variant, opts)
+		opts.logger(name, field).Debugf("'%s' with %s => %v (conf: %.1f)", field, strings.Join(l, "+"), strings.TrimSpace(t), c)
+		if c > confidence {
+			text, confidence, languages = t, c, l
+		}
+	}
+	return text, confidence, languages
+}
+
+func recognizeFile(name, field, file string, s schema.OCRSchema, opts Options) (string, float64) {
+	var text string
+	var confidence float64
+	err := retryutils.Do(opts.Context(), opts.retry(), func(ctx context.Context) (err error) {
+		text, confidence, err = opts.parseText(ctx, file, s)
+		return err
+	})
+	if err != nil && !opts.cancelled() {
+		opts.logger(name, field).Warnf("Failed to recognize '%s': %v", field, err)
+	}
+	return text, confidence
+}
+
+// lowConfidenceVariant - alternative preprocessing and/or page segmentation mode, tried when field confidence is too low
+type lowConfidenceVariant struct {
+	name  string
+	apply func(img image.Image) image.Image
+	// psm - page segmentation mode to use instead of field's one (0 - keep)
+	psm int
+}
+
+// lowConfidenceVariants - in the order they are tried
+var lowConfidenceVariants = []lowConfidenceVariant{
+	{name: "upscale", apply: func(img image.Image) image.Image { return imgutils2.Upscale(img, 2) }},
+	{name: "binarize", apply: func(img image.Image) image.Image { return imgutils2.Binarize(img) }},
+	{name: "binarize,upscale", apply: func(img image.Image) image.Image { return imgutils2.Upscale(imgutils2.Binarize(img), 2) }},
+	{name: "grayscale,invert", apply: func(img image.Image) image.Image { return imgutils2.Invert(imgutils2.Grayscale(img)) }},
+	{name: "grayscale,invert,upscale", apply: func(img image.Image) image.Image {
+		return imgutils2.Upscale(imgutils2.Invert(imgutils2.Grayscale(img)), 2)
+	}},
+	// single line, single word, block of text
+	{name: "psm 7", psm: 7},
+	{name: "psm 8", psm: 8},
+	{name: "psm 6", psm: 6},
+}
+
+// retried - outcome of retryLowConfidence
+type retried struct {
+	text       string
+	confidence float64
+	// preprocess - extra preprocessing of the kept result
+	preprocess []string
+	// variant - name of the kept variant, empty if none beat the original
+	variant string
+	// attempts - how many variants were tried
+	attempts int
+}
+
+// retryLowConfidence - runs the crop through alternative preprocessing & page segmentation modes, and keeps
+// the most confident result. Stops once the result is confident enough.
+func retryLowConfidence(name, field string, img image.Image, s schema.OCRSchema, opts Options, text string, confidence float64) retried {
+	best := retried{text: text, confidence: confidence}
+	minConfidence := opts.minConfidence(s)
+
+	for _, v := range lowConfidenceVariants {
+		if best.confidence >= minConfidence {
+			break
+		}
+
+		variant := s
+		if v.psm > 0 {
+			if v.psm == s.PSM {
+				continue
+			}
+			variant.PSM = v.psm
+		}
+		crop := img
+		if v.apply != nil {
+			crop = v.apply(img)
+		}
+
+		file := filepath.Join(opts.tmpDirectory(), field+"_"+stringutils.Random(12)+"_"+filepath.Base(name))
+		if err := imgutils2.WritePNGImage(crop, file); err != nil {
+			continue
+		}
+		t, c := recognizeFile(name, field, file, variant, opts)
+		_ = os.Remove(file)
+		best.attempts++
+		opts.logger(name, field).Debugf("'%s' with %s => %v (conf: %.1f)", field, v.name, strings.TrimSpace(t), c)
+
+		if c > best.confidence {
+			best.text, best.confidence, best.variant = t, c, v.name
+			best.preprocess = nil
+			if v.apply != nil {
+				best.preprocess = strings.Split(v.name, ",")
+			}
+		}
+	}
+
+	return best
 }
@@ -0,0 +1,80 @@
+package tesseractutils
+
+import (
+	"context"
+	"sync"
+)
+
+// parallelOrdered - runs fn for 0..count-1 with a bounded number of workers, and emits results in index order
+func parallelOrdered[T any](ctx context.Context, count, workers int, fn func(i int) T) <-chan T {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type done struct {
+		index  int
+		result T
+	}
+
+	indexes := make(chan int)
+	results := make(chan done)
+	out := make(chan T)
+
+	go func() {
+		defer close(indexes)
+		for i := 0; i < count; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				select {
+				case results <- done{index: i, result: fn(i)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(out)
+		pending := make(map[int]T)
+		next := 0
+		for d := range results {
+			pending[d.index] = d.result
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					// out is closed only once fn isn't running anymore, so callers can release what it uses
+					for range results {
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
@@ -1,43 +1,112 @@
 package tesseractutils
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"image"
 	"path/filepath"
 
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/pdfpages"
 	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
 	"github.com/sirupsen/logrus"
 
 	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
 )
 
+// ErrNoTemplateMatch - image (or none of its panels) matches the template
+var ErrNoTemplateMatch = errors.New("image doesn't match the template")
+
 func RunRecognitionChan(mediaDir, tessData string, template schema.OCRTemplate, force bool) <-chan schema.OCRResult {
+	return RunRecognitionChanWithOptions(mediaDir, template, force, DefaultOptions(tessData))
+}
+
+func RunRecognitionChanWithOptions(mediaDir string, template schema.OCRTemplate, force bool, opts Options) <-chan schema.OCRResult {
 
 	out := make(chan schema.OCRResult)
 	go func() {
+		defer close(out)
+
 		dir, _ := filepath.Abs(mediaDir)
-		files := fileutils.GetFilesInDirectory(dir)
+		files := fileutils.ListFiles(dir, opts.Files)
+		if opts.Skip != nil {
+			var pending []string
+			for _, f := range files {
+				if !opts.Skip(f) {
+					pending = append(pending, f)
+				}
+			}
+			if skipped := len(files) - len(pending); skipped > 0 {
+				logrus.Infof("Skipping %v already processed files", skipped)
+			}
+			files = pending
+		}
 		total := len(files)
 
-		for index, f := range files {
-			result, err := ParseSingleFile(f, tessData, template, force)
-			if err != nil {
-				logrus.Errorf("[%04d/%04d] %v - %v", index, total, filepath.Base(f), err)
+		opts, release := opts.withEngine()
+		defer release()
+
+		type parsed struct {
+			results []schema.OCRResult
+			err     error
+		}
+
+		// files are parsed concurrently, but results still come out in file order
+		index := 0
+		ctx := opts.Context()
+		for p := range parallelOrdered(ctx, total, opts.jobs(), func(i int) parsed {
+			results, err := ParseFileWithOptions(files[i], template, force, opts)
+			return parsed{results: results, err: err}
+		}) {
+			if ctx.Err() != nil {
+				// file may have been cut short, it's left for the next run
+				logrus.Infof("Recognition cancelled after %v of %v files", index, total)
+				return
+			}
+			f := files[index]
+			index++
+
+			if p.err != nil {
+				logutils.File(f).Errorf("[%04d/%04d] %v - %v", index, total, filepath.Base(f), p.err)
+				if opts.OnProcessed != nil {
+					opts.OnProcessed(f, nil, p.err)
+				}
+				if opts.OnFailure != nil {
+					opts.OnFailure(f, p.err)
+				}
 				continue
 			}
-			out <- *result
+			for i := range p.results {
+				// keep sub-directory in the name, so files from different folders don't clash
+				if rel, err := filepath.Rel(dir, f); err == nil {
+					p.results[i].Filename = rel
+				}
+			}
+			if opts.OnProcessed != nil {
+				opts.OnProcessed(f, p.results, nil)
+			}
+			for _, result := range p.results {
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
-		close(out)
 	}()
 
 	return out
 }
 
 func RunRecognition(mediaDir, tessData string, template schema.OCRTemplate, force bool) []schema.OCRResult {
+	return RunRecognitionWithOptions(mediaDir, template, force, DefaultOptions(tessData))
+}
+
+func RunRecognitionWithOptions(mediaDir string, template schema.OCRTemplate, force bool, opts Options) []schema.OCRResult {
 	var data []schema.OCRResult
 
-	for elem := range RunRecognitionChan(mediaDir, tessData, template, force) {
+	for elem := range RunRecognitionChanWithOptions(mediaDir, template, force, opts) {
 		data = append(data, elem)
 	}
 
@@ -45,15 +114,116 @@ func RunRecognition(mediaDir, tessData string, template schema.OCRTemplate, forc
 }
 
 func ParseSingleFile(f, tessData string, template schema.OCRTemplate, force bool) (*schema.OCRResult, error) {
+	return ParseSingleFileWithOptions(f, template, force, DefaultOptions(tessData))
+}
+
+func ParseSingleFileWithOptions(f string, template schema.OCRTemplate, force bool, opts Options) (*schema.OCRResult, error) {
+	img, err := imgutils.ReadImageFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("cant read file: %v", err)
+	}
+	img = opts.prepareImage(img)
+
+	return parseImage(f, img, template, force, opts)
+}
+
+func parseImage(f string, img image.Image, template schema.OCRTemplate, force bool, opts Options) (*schema.OCRResult, error) {
+	results, err := parseRows(f, img, template, force, opts)
+	if err != nil {
+		return nil, err
+	}
+	// first row of a ranking list
+	return &results[0], nil
+}
+
+// parseRows - every row of a ranking list is a result, other screenshots have just one
+func parseRows(f string, img image.Image, template schema.OCRTemplate, force bool, opts Options) ([]schema.OCRResult, error) {
+	issues, err := opts.checkQuality(f, img, template)
+	if err != nil {
+		return nil, err
+	}
+
+	if !template.Matches(img) && !force {
+		return nil, fmt.Errorf("%w: Template: %s @ %s", ErrNoTemplateMatch, template.Title, template.Version)
+	}
+
+	results := ParseRowsWithOptions(f, img, template, opts)
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no rows found in the list: Template: %s @ %s", template.Title, template.Version)
+	}
+	for i := range results {
+		results[i].Quality = issues
+	}
+	return results, nil
+}
+
+// ParseFileWithOptions - like ParseSingleFileWithOptions, but stitched screenshots are split
+// into panels, and each matching panel produces a separate result. PDFs are rasterized, and each page
+// is parsed as a screenshot.
+func ParseFileWithOptions(f string, template schema.OCRTemplate, force bool, opts Options) ([]schema.OCRResult, error) {
+	if pdfpages.IsPDF(f) {
+		return parsePDF(f, template, force, opts)
+	}
+
 	img, err := imgutils.ReadImageFile(f)
 	if err != nil {
 		return nil, fmt.Errorf("cant read file: %v", err)
 	}
+	return parsePanels(f, opts.prepareImage(img), template, force, opts)
+}
+
+func parsePDF(f string, template schema.OCRTemplate, force bool, opts Options) ([]schema.OCRResult, error) {
+	pages, err := pdfpages.Pages(opts.Context(), f, opts.PDF)
+	if err != nil {
+		return nil, fmt.Errorf("cant read file: %v", err)
+	}
+
+	logutils.File(f).Debugf("PDF with %v pages", len(pages))
+
+	var results []schema.OCRResult
+	for i, page := range pages {
+		parsed, err := parsePanels(f, opts.prepareImage(page), template, force, opts)
+		if err != nil {
+			logutils.File(f).WithField("page", i+1).Debugf("page %v: %v", i+1, err)
+			continue
+		}
+		for _, result := range parsed {
+			result.Page = i + 1
+			results = append(results, result)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w (none of %v pages): Template: %s @ %s", ErrNoTemplateMatch, len(pages), template.Title, template.Version)
+	}
+
+	return results, nil
+}
+
+func parsePanels(f string, img image.Image, template schema.OCRTemplate, force bool, opts Options) ([]schema.OCRResult, error) {
+	panels := template.Panels(img)
+	if len(panels) == 1 {
+		return parseRows(f, img, template, force, opts)
+	}
+
+	logutils.File(f).Debugf("Stitched screenshot, splitting into %v panels", len(panels))
+
+	var results []schema.OCRResult
+	for i, panel := range panels {
+		parsed, err := parseRows(f, panel, template, force, opts)
+		if err != nil {
+			logutils.File(f).WithField("panel", i+1).Debugf("panel %v: %v", i+1, err)
+			continue
+		}
+		for _, result := range parsed {
+			result.Panel = i + 1
+			results = append(results, result)
+		}
+	}
 
-	if template.Matches(img) || force {
-		result := ParseImage(f, img, template, os.TempDir(), tessData)
-		return &result, nil
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w (none of %v panels): Template: %s @ %s", ErrNoTemplateMatch, len(panels), template.Title, template.Version)
 	}
 
-	return nil, fmt.Errorf("image doesn't match the template: Template: %s @ %s", template.Title, template.Version)
+	return results, nil
 }
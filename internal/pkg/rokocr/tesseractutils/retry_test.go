@@ -0,0 +1,80 @@
+package tesseractutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+// fakeEngine - fails with errs one by one, then recognizes "12345"
+type fakeEngine struct {
+	errs  []error
+	calls int
+}
+
+func (e *fakeEngine) Recognize(ctx context.Context, imageFileName string, s schema.OCRSchema, tessdata string) (string, float64, error) {
+	e.calls++
+	if len(e.errs) > 0 {
+		err := e.errs[0]
+		e.errs = e.errs[1:]
+		return "", 0, err
+	}
+	return "12345", 90, nil
+}
+
+func (e *fakeEngine) Close() error {
+	return nil
+}
+
+func withFakeEngine(engine *fakeEngine, maxRetries int) Options {
+	opts := Options{Engine: ocrengine.Config{Name: "fake"}, MaxRetries: maxRetries, Backoff: time.Millisecond}
+	opts.engines = newEngineSet(opts.engineConfig())
+	opts.engines.engines["fake"] = engine
+	return opts
+}
+
+func TestRecognizeRetriesTransientFailures(t *testing.T) {
+	engine := &fakeEngine{errs: []error{
+		&retryutils.StatusError{StatusCode: 503, Message: "busy"},
+		retryutils.Retryable(errors.New("connection reset")),
+	}}
+
+	text, confidence := recognizeFile("a.png", "power", "crop.png", schema.OCRSchema{}, withFakeEngine(engine, 3))
+	if text != "12345" || confidence != 90 {
+		t.Errorf("recognizeFile() = %q, %v, want 12345, 90", text, confidence)
+	}
+	if engine.calls != 3 {
+		t.Errorf("engine called %v times, want 3", engine.calls)
+	}
+}
+
+func TestRecognizeDoesNotRetryPermanentFailures(t *testing.T) {
+	engine := &fakeEngine{errs: []error{
+		&retryutils.StatusError{StatusCode: 400, Message: "unsupported image"},
+	}}
+
+	text, _ := recognizeFile("a.png", "power", "crop.png", schema.OCRSchema{}, withFakeEngine(engine, 3))
+	if text != "" {
+		t.Errorf("recognizeFile() = %q, want nothing", text)
+	}
+	if engine.calls != 1 {
+		t.Errorf("engine called %v times, want 1", engine.calls)
+	}
+}
+
+func TestRecognizeGivesUpAfterMaxRetries(t *testing.T) {
+	busy := &retryutils.StatusError{StatusCode: 502, Message: "bad gateway"}
+	engine := &fakeEngine{errs: []error{busy, busy, busy, busy}}
+
+	if text, _ := recognizeFile("a.png", "power", "crop.png", schema.OCRSchema{}, withFakeEngine(engine, 2)); text != "" {
+		t.Errorf("recognizeFile() = %q, want nothing", text)
+	}
+	if engine.calls != 3 {
+		t.Errorf("engine called %v times, want 3", engine.calls)
+	}
+}
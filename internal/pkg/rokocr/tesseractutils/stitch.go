@@ -0,0 +1,40 @@
+package tesseractutils
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+)
+
+// ParseStitchedWithOptions - files are screenshots of one ranking list scrolled down (in order). They are stitched
+// into one tall image (see OCRTemplate.Stitched), and every row of it is a result. Returns the stitched image as well.
+func ParseStitchedWithOptions(name string, files []string, template schema.OCRTemplate, force bool, opts Options) (image.Image, []schema.OCRResult, error) {
+	var images []image.Image
+	for _, f := range files {
+		img, err := imgutils.ReadImageFile(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cant read file: %v", err)
+		}
+		img = opts.prepareImage(img)
+		if !force && !template.Matches(img) {
+			return nil, nil, fmt.Errorf("%w: %s, Template: %s @ %s", ErrNoTemplateMatch, filepath.Base(f), template.Title, template.Version)
+		}
+		images = append(images, img)
+	}
+
+	stitched, scaled, err := template.Stitched(images)
+	if err != nil {
+		return nil, nil, err
+	}
+	logutils.Recognition(name, template.Title).Debugf("Stitched %v screenshots into %v", len(images), stitched.Bounds().Size())
+
+	results := ParseRowsWithOptions(name, stitched, scaled, opts)
+	for i := range results {
+		results[i].Filename = name
+	}
+	return stitched, results, nil
+}
@@ -0,0 +1,190 @@
+package tesseractutils
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/logutils"
+)
+
+type NamedImage struct {
+	Name  string
+	Image image.Image
+}
+
+func ProcessStream(ctx context.Context, in <-chan NamedImage, templates []schema.OCRTemplate, workers int) <-chan schema.OCRResult {
+	return ProcessStreamWithOptions(ctx, in, templates, workers, DefaultOptions("./tessdata"))
+}
+
+// ProcessStreamWithOptions - OCRs images from `in` with a pool of workers. Results are emitted in the
+// same order images came in (every row of a ranking list is a result); channel is closed when `in` is closed or ctx is cancelled.
+// Failures (e.g. no template matches) are reported via OCRResult.Error.
+func ProcessStreamWithOptions(ctx context.Context, in <-chan NamedImage, templates []schema.OCRTemplate, workers int, opts Options) <-chan schema.OCRResult {
+	if workers < 1 {
+		workers = 1
+	}
+	if opts.Jobs < workers {
+		opts.Jobs = workers
+	}
+
+	type job struct {
+		seq int
+		img NamedImage
+	}
+
+	type done struct {
+		seq     int
+		results []schema.OCRResult
+	}
+
+	opts = opts.WithContext(ctx)
+	opts, release := opts.withEngine()
+
+	jobs := make(chan job)
+	results := make(chan done)
+	out := make(chan schema.OCRResult)
+
+	// feeder
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case img, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{seq: seq, img: img}:
+					seq++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				select {
+				case results <- done{seq: j.seq, results: processNamedImage(j.img, templates, opts)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// engines are released once no worker uses them, even if ctx is cancelled mid-image
+	go func() {
+		wg.Wait()
+		release()
+		close(results)
+	}()
+
+	// re-assemble in input order
+	go func() {
+		defer close(out)
+		pending := make(map[int][]schema.OCRResult)
+		next := 0
+		for d := range results {
+			pending[d.seq] = d.results
+			for {
+				rs, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if ctx.Err() != nil {
+					// image may have been cut short
+					return
+				}
+				for _, r := range rs {
+					select {
+					case out <- r:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// ProcessImageWithOptions - OCRs a single image with the best matching of templates, like ProcessStreamWithOptions does.
+// Failures (e.g. no template matches) are reported via OCRResult.Error.
+func ProcessImageWithOptions(img NamedImage, templates []schema.OCRTemplate, opts Options) []schema.OCRResult {
+	return processNamedImage(img, templates, opts)
+}
+
+func processNamedImage(img NamedImage, templates []schema.OCRTemplate, opts Options) []schema.OCRResult {
+	start := time.Now()
+
+	if len(templates) == 0 {
+		return []schema.OCRResult{{Filename: img.Name, Data: map[string]interface{}{}, Error: "no templates"}}
+	}
+
+	img.Image = opts.prepareImage(img.Image)
+	scores := schema.RankTemplates(img.Image, templates)
+	match := schema.NewTemplateMatch(scores)
+	template := scores[0].Template
+	if !scores[0].Matches {
+		return []schema.OCRResult{{
+			Filename: img.Name,
+			Data:     map[string]interface{}{},
+			Took:     time.Since(start),
+			Match:    match,
+			Error:    fmt.Sprintf("no template matches the image (closest: %s @ %s)", template.Title, template.Version),
+		}}
+	}
+
+	if len(match.RunnerUp) > 0 {
+		logutils.Recognition(img.Name, template.Title).Debugf("Picked %s (distance: %v), runner-up: %s (distance: %v)", template.Title, match.Distance, match.RunnerUp, match.RunnerUpDistance)
+	}
+
+	issues, err := opts.checkQuality(img.Name, img.Image, template)
+	if err != nil {
+		return []schema.OCRResult{{
+			Filename: img.Name,
+			Data:     map[string]interface{}{},
+			Took:     time.Since(start),
+			Template: template.Title,
+			Match:    match,
+			Quality:  issues,
+			Error:    err.Error(),
+		}}
+	}
+
+	results := ParseRowsWithOptions(img.Name, img.Image, template, opts)
+	if len(results) == 0 {
+		return []schema.OCRResult{{
+			Filename: img.Name,
+			Data:     map[string]interface{}{},
+			Took:     time.Since(start),
+			Template: template.Title,
+			Match:    match,
+			Quality:  issues,
+			Error:    "no rows found in the list",
+		}}
+	}
+	for i := range results {
+		results[i].Filename = img.Name
+		results[i].Quality = issues
+		results[i].Template = template.Title
+		results[i].Match = match
+	}
+	return results
+}
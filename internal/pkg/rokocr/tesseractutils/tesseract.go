@@ -1,44 +1,80 @@
 package tesseractutils
 
 import (
-	"fmt"
+	"context"
+	"sort"
 	"strings"
 
-	"github.com/otiai10/gosseract/v2"
 	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
-	log "github.com/sirupsen/logrus"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/ocrengine"
 )
 
-func ParseText(imageFileName string, schema schema.OCRSchema, tessdata string) (string, error) {
-	client := gosseract.NewClient()
+// defaultEngine - engine of Options without own engine, and of ParseText
+var defaultEngine = ocrengine.DefaultConfig()
 
-	_ = client.SetTessdataPrefix(tessdata)
-	if len(schema.Languages) > 0 {
-		_ = client.SetLanguage(schema.Languages...)
-	} else {
-		_ = client.SetLanguage("eng")
+// SetDefaultEngine - selects the engine used when Options don't name one, fails if it can't be created
+// (e.g. tesseract binary isn't installed). Meant to be called once at startup.
+func SetDefaultEngine(cfg ocrengine.Config) error {
+	engine, err := ocrengine.New(cfg)
+	if err != nil {
+		return err
 	}
-	_ = client.SetPageSegMode(gosseract.PageSegMode(schema.PSM))
+	_ = engine.Close()
+	defaultEngine = cfg
+	return nil
+}
+
+func ParseText(imageFileName string, schema schema.OCRSchema, tessdata string) (string, error) {
+	text, _, err := ParseTextWithConfidence(imageFileName, schema, tessdata)
+	return text, err
+}
 
-	defer client.Close()
+// ParseTextWithConfidence - same as ParseText, but also returns average word confidence (0-100)
+func ParseTextWithConfidence(imageFileName string, schema schema.OCRSchema, tessdata string) (string, float64, error) {
+	return DefaultOptions(tessdata).parseText(context.Background(), imageFileName, schema)
+}
 
-	_ = client.SetImage(imageFileName)
+// alternative page segmentation modes, tried when caller asks for candidate interpretations
+var alternativePSM = []int{7, 8, 13, 6}
 
-	if len(schema.AllowList) > 0 {
-		var whitelistedCharacters []string
+// ParseTextAlternatives - runs the crop through several segmentation modes, and returns up to n
+// unique interpretations, best confidence first. engines don't expose LSTM choices, so this is
+// the closest we get to "top-N hypotheses".
+func ParseTextAlternatives(imageFileName string, s schema.OCRSchema, tessdata string, n int) []schema.FieldAlternative {
+	return DefaultOptions(tessdata).parseTextAlternatives(imageFileName, s, n)
+}
 
-		for _, x := range schema.AllowList {
-			whitelistedCharacters = append(whitelistedCharacters, fmt.Sprintf("%v", x))
+func (o Options) parseTextAlternatives(imageFileName string, s schema.OCRSchema, n int) []schema.FieldAlternative {
+	best := make(map[string]float64)
+
+	for _, psm := range alternativePSM {
+		variant := s
+		variant.PSM = psm
+		text, confidence, err := o.parseText(o.Context(), imageFileName, variant)
+		text = strings.TrimSpace(text)
+		if err != nil || len(text) == 0 {
+			continue
+		}
+		if c, ok := best[text]; !ok || confidence > c {
+			best[text] = confidence
 		}
+	}
 
-		whitelist := strings.Join(whitelistedCharacters, "")
-		_ = client.SetWhitelist(whitelist)
+	var result []schema.FieldAlternative
+	for text, confidence := range best {
+		result = append(result, schema.FieldAlternative{Text: text, Confidence: confidence})
 	}
 
-	text, err := client.Text()
-	if err != nil {
-		log.Fatalf("Error: %s", err)
-		return "", err
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Confidence == result[j].Confidence {
+			return result[i].Text < result[j].Text
+		}
+		return result[i].Confidence > result[j].Confidence
+	})
+
+	if len(result) > n {
+		result = result[:n]
 	}
-	return text, nil
+
+	return result
 }
@@ -0,0 +1,189 @@
+package tesseractutils
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	imgutils2 "github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
+)
+
+// jitter - slightly different crop & preprocessing of a vote, so recognition errors don't repeat
+type jitter struct {
+	dx, dy int
+	apply  func(img image.Image) image.Image
+}
+
+// jitters - variants of the votes after the first one (field recognized as is), used in order & repeated
+var jitters = []jitter{
+	{dx: 1},
+	{dx: -1},
+	{dy: 1},
+	{dy: -1},
+	{apply: func(img image.Image) image.Image { return imgutils2.Upscale(img, 2) }},
+	{apply: func(img image.Image) image.Image { return imgutils2.Binarize(img) }},
+	{dx: 1, dy: 1, apply: func(img image.Image) image.Image { return imgutils2.Upscale(img, 3) }},
+	{dx: -1, dy: -1, apply: func(img image.Image) image.Image { return imgutils2.Upscale(imgutils2.Binarize(img), 2) }},
+	{dx: 2, apply: func(img image.Image) image.Image { return imgutils2.Denoise(img) }},
+	{dx: -2, apply: func(img image.Image) image.Image { return imgutils2.Contrast(img, 1.5) }},
+}
+
+type ballot struct {
+	text       string
+	confidence float64
+}
+
+// vote - outcome of voteDigits
+type vote struct {
+	text       string
+	confidence float64
+	votes      int
+	agreement  float64
+}
+
+// voteDigits - recognizes the field s.Vote times (first one is the already recognized ballot) with jittered crop &
+// preprocessing, and picks every digit by majority. Only readings with the most common count of digits take part,
+// ties are decided by confidence. Text around the digits (separators, units) is kept from the most confident reading.
+func voteDigits(name, field string, img image.Image, crop *schema.OCRCrop, s schema.OCRSchema, opts Options, first ballot) vote {
+	ballots := []ballot{first}
+	rect := crop.CropRectangle().Add(img.Bounds().Min)
+
+	for i := 0; i < s.Vote-1; i++ {
+		j := jitters[i%len(jitters)]
+		r := rect.Add(image.Pt(j.dx, j.dy)).Intersect(img.Bounds())
+		if r.Empty() {
+			continue
+		}
+
+		cropped, err := imgutils2.CropImage(img, r)
+		if err != nil {
+			continue
+		}
+		cropped, _ = s.PreprocessImage(cropped)
+		if j.apply != nil {
+			cropped = j.apply(cropped)
+		}
+
+		file := filepath.Join(opts.tmpDirectory(), field+"_"+stringutils.Random(12)+"_"+filepath.Base(name))
+		if err := imgutils2.WritePNGImage(cropped, file); err != nil {
+			continue
+		}
+		t, c := recognizeFile(name, field, file, s, opts)
+		_ = os.Remove(file)
+		ballots = append(ballots, ballot{text: t, confidence: c})
+	}
+
+	result := voteBallots(ballots)
+	opts.logger(name, field).Debugf("'%s' voted from %d readings => %v (agreement: %.2f)", field, result.votes, strings.TrimSpace(result.text), result.agreement)
+	return result
+}
+
+func digits(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func voteBallots(ballots []ballot) vote {
+	// most common count of digits, ties by summed confidence, then by the longer reading (not by map order)
+	counts := make(map[int]int)
+	weights := make(map[int]float64)
+	for _, b := range ballots {
+		n := len(digits(b.text))
+		counts[n]++
+		weights[n] += b.confidence
+	}
+	lengths := make([]int, 0, len(counts))
+	for n := range counts {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+	length := -1
+	for _, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		c := counts[n]
+		if length < 0 || c > counts[length] || (c == counts[length] && weights[n] >= weights[length]) {
+			length = n
+		}
+	}
+	if length < 0 {
+		// no digits anywhere, nothing to vote on
+		return vote{text: ballots[0].text, confidence: ballots[0].confidence, votes: len(ballots)}
+	}
+
+	var voters []ballot
+	shape := -1
+	for _, b := range ballots {
+		if len(digits(b.text)) != length {
+			continue
+		}
+		voters = append(voters, b)
+		if shape < 0 || b.confidence > voters[shape].confidence {
+			shape = len(voters) - 1
+		}
+	}
+
+	voted := make([]byte, length)
+	for i := 0; i < length; i++ {
+		var count [10]int
+		var weight [10]float64
+		for _, b := range voters {
+			d := digits(b.text)[i] - '0'
+			count[d]++
+			weight[d] += b.confidence
+		}
+		best := 0
+		for d := 1; d < 10; d++ {
+			if count[d] > count[best] || (count[d] == count[best] && weight[d] > weight[best]) {
+				best = d
+			}
+		}
+		voted[i] = byte('0' + best)
+	}
+
+	// voted digits in place of the digits of the most confident reading
+	var text strings.Builder
+	i := 0
+	for _, r := range voters[shape].text {
+		if r >= '0' && r <= '9' {
+			text.WriteByte(voted[i])
+			i++
+			continue
+		}
+		text.WriteRune(r)
+	}
+
+	// confidence of readings agreeing with the vote, or of all readings voting if none reads it exactly
+	agree, confidence := 0, 0.0
+	for _, b := range voters {
+		if digits(b.text) == string(voted) {
+			agree++
+			confidence += b.confidence
+		}
+	}
+	if agree > 0 {
+		confidence /= float64(agree)
+	} else {
+		for _, b := range voters {
+			confidence += b.confidence
+		}
+		confidence /= float64(len(voters))
+	}
+
+	return vote{
+		text:       text.String(),
+		confidence: confidence,
+		votes:      len(ballots),
+		agreement:  float64(agree) / float64(len(ballots)),
+	}
+}
@@ -0,0 +1,30 @@
+package tesseractutils
+
+import "testing"
+
+func TestVoteBallots(t *testing.T) {
+	tests := []struct {
+		name    string
+		ballots []ballot
+		want    string
+	}{
+		{"majority of digits", []ballot{{"1,234,567", 80}, {"1,284,567", 85}, {"1,234,567", 70}}, "1,234,567"},
+		{"most common length", []ballot{{"12345", 90}, {"1234", 95}, {"12346", 80}}, "12345"},
+		{"length tie by confidence", []ballot{{"1234", 95}, {"12345", 80}}, "1234"},
+		// an exact tie of count & confidence goes to the longer reading, whatever the order of ballots
+		{"exact length tie", []ballot{{"1234", 90}, {"12345", 90}}, "12345"},
+		{"exact length tie reversed", []ballot{{"12345", 90}, {"1234", 90}}, "12345"},
+		{"no digits", []ballot{{"abc", 50}, {"abd", 60}}, "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// map iteration order differs between runs, the vote mustn't
+			for i := 0; i < 50; i++ {
+				if got := voteBallots(tt.ballots); got.text != tt.want {
+					t.Fatalf("run %d: got %q, want %q", i, got.text, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Package training - ground truth for fine-tuning tesseract LSTM models (e.g. on the game font) with tesstrain.
+// Every sample is a crop of a single text line (PNG) and its label (<name>.gt.txt), box files are generated from them.
+package training
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// LabelExt - label of crop.png is crop.gt.txt, as tesstrain expects
+const LabelExt = ".gt.txt"
+
+// Sample - labeled crop
+type Sample struct {
+	Image string
+	Label string
+}
+
+// Name - crop file name without extension, ground truth files of the sample are named by it
+func (s Sample) Name() string {
+	return strings.TrimSuffix(filepath.Base(s.Image), filepath.Ext(s.Image))
+}
+
+// LabelFile - label file of the crop
+func LabelFile(crop string) string {
+	return strings.TrimSuffix(crop, filepath.Ext(crop)) + LabelExt
+}
+
+// FindSamples - PNG crops of the dir with a non-empty label. Crops named <screenshot>_<field>.png (see rok-scanner -crops)
+// can be limited to fields, all crops are used if none given. Crops without label are returned separately.
+func FindSamples(dir string, fields []string) (samples []Sample, unlabeled []string, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		if !matchesField(f, fields) {
+			continue
+		}
+		data, err := os.ReadFile(LabelFile(f))
+		label := strings.TrimSpace(string(data))
+		if err != nil || len(label) == 0 {
+			unlabeled = append(unlabeled, f)
+			continue
+		}
+		samples = append(samples, Sample{Image: f, Label: label})
+	}
+	return samples, unlabeled, nil
+}
+
+func matchesField(file string, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for _, f := range fields {
+		if strings.HasSuffix(name, "_"+f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Correction - field of a result corrected by a reviewer (see OCRTemplate.Correct), Label is the corrected text
+type Correction struct {
+	Result schema.OCRResult
+	Field  string
+	Label  string
+}
+
+// Name - sample name of the correction: <screenshot>[_p<page>][_<panel>][_r<row>]_<field>
+func (c Correction) Name() string {
+	name := strings.TrimSuffix(filepath.Base(c.Result.Filename), filepath.Ext(c.Result.Filename))
+	if c.Result.Page > 0 {
+		name = fmt.Sprintf("%s_p%d", name, c.Result.Page)
+	}
+	if c.Result.Panel > 0 {
+		name = fmt.Sprintf("%s_%d", name, c.Result.Panel)
+	}
+	if c.Result.Row > 0 {
+		name = fmt.Sprintf("%s_r%d", name, c.Result.Row)
+	}
+	return name + "_" + c.Field
+}
+
+// Corrections - corrected fields of the results, limited to fields if given. Records merged of several screenshots
+// are left out, their crops can't be told apart.
+func Corrections(results []schema.OCRResult, fields []string) []Correction {
+	var corrections []Correction
+	for _, r := range results {
+		if len(r.Parts) > 1 {
+			continue
+		}
+		keys := make([]string, 0, len(r.Fields))
+		for k := range r.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			f := r.Fields[k]
+			label := strings.TrimSpace(f.Text)
+			if !f.Corrected || len(label) == 0 || !wantedField(k, fields) {
+				continue
+			}
+			corrections = append(corrections, Correction{Result: r, Field: k, Label: label})
+		}
+	}
+	return corrections
+}
+
+func wantedField(field string, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// LineBox - tesseract box file of a single line image: every character spans the whole image (LSTM training doesn't need
+// character positions), tab marks the end of the line
+func LineBox(label string, width, height int) string {
+	var sb strings.Builder
+	for _, c := range label {
+		fmt.Fprintf(&sb, "%c 0 0 %d %d 0\n", c, width, height)
+	}
+	fmt.Fprintf(&sb, "\t %d %d %d %d 0\n", width, height, width+1, height+1)
+	return sb.String()
+}
+
+// WriteGroundTruth - writes crop, label & box file of every sample into dir (tesstrain GROUND_TRUTH_DIR)
+func WriteGroundTruth(samples []Sample, dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		img, err := imgutils.ReadImageFile(s.Image)
+		if err != nil {
+			return fmt.Errorf("%v: %w", filepath.Base(s.Image), err)
+		}
+		if err := writeSample(s, img, dir); err != nil {
+			return fmt.Errorf("%v: %w", filepath.Base(s.Image), err)
+		}
+	}
+	return nil
+}
+
+func writeSample(s Sample, img image.Image, dir string) error {
+	base := filepath.Join(dir, s.Name())
+	if err := copyFile(s.Image, base+".png"); err != nil {
+		return err
+	}
+	return writeLabels(base, s.Label, img)
+}
+
+// AddSample - writes the crop (as <name>.png) with its label & box file into dir, e.g. a field corrected by a reviewer
+func AddSample(dir, name string, crop image.Image, label string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	base := filepath.Join(dir, name)
+	if err := imgutils.WritePNGImage(crop, base+".png"); err != nil {
+		return err
+	}
+	return writeLabels(base, label, crop)
+}
+
+func writeLabels(base, label string, img image.Image) error {
+	if err := os.WriteFile(base+LabelExt, []byte(label+"\n"), 0644); err != nil {
+		return err
+	}
+	size := img.Bounds().Size()
+	return os.WriteFile(base+".box", []byte(LineBox(label, size.X, size.Y)), 0644)
+}
+
+func absPath(p string) string {
+	a, _ := filepath.Abs(p)
+	return a
+}
+
+func copyFile(from, to string) error {
+	// ground truth written into the crops dir itself
+	if absPath(from) == absPath(to) {
+		return nil
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
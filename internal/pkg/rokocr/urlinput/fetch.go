@@ -0,0 +1,129 @@
+package urlinput
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+// MaxSize - larger downloads are refused, screenshots are a few MB at most
+const MaxSize = 64 << 20
+
+var client = &http.Client{Timeout: 60 * time.Second}
+
+// IsURL - input is a http(s) link (e.g. Discord CDN attachment), not a local file
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+// FileName - local name of the downloaded file, prefixed with its index, as attachment names often repeat
+// (e.g. Discord names every pasted screenshot "image.png"). Query (signed CDN params) is dropped.
+func FileName(rawURL string, index int) string {
+	name := "download"
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	return fmt.Sprintf("url_%03d_%s", index, name)
+}
+
+// Fetch - downloads the link into dir as name, returns path of the downloaded file.
+// If name has no known image extension, one matching the content type is added.
+func Fetch(ctx context.Context, rawURL, dir, name string, retry retryutils.Options) (string, error) {
+	if !IsURL(rawURL) {
+		return "", fmt.Errorf("invalid url %q, expected http(s) link", rawURL)
+	}
+	return fetch(ctx, client, rawURL, dir, name, retry)
+}
+
+func fetch(ctx context.Context, client *http.Client, rawURL, dir, name string, retry retryutils.Options) (string, error) {
+	var body []byte
+	var contentType string
+	err := retryutils.Do(ctx, retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", "rokmonster-ocr")
+
+		resp, err := client.Do(req)
+		if errors.Is(err, ErrNotAllowed) {
+			return err
+		}
+		if err != nil {
+			return retryutils.Retryable(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("GET %v", redact(rawURL))}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+
+		body, err = io.ReadAll(io.LimitReader(resp.Body, MaxSize+1))
+		if err != nil {
+			return retryutils.Retryable(err)
+		}
+		if len(body) > MaxSize {
+			return fmt.Errorf("%v is larger than %v MB", redact(rawURL), MaxSize>>20)
+		}
+		contentType = resp.Header.Get("Content-Type")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !fileutils.DefaultImageListOptions().Accepts(name) {
+		name = name + extension(contentType, body)
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(name))
+	return target, os.WriteFile(target, body, 0644)
+}
+
+func extension(contentType string, body []byte) string {
+	if len(contentType) == 0 || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = http.DetectContentType(body)
+	}
+	media, _, _ := mime.ParseMediaType(contentType)
+	switch media {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/heic", "image/heif":
+		return ".heic"
+	}
+	if exts, err := mime.ExtensionsByType(media); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".png"
+}
+
+// redact - signed CDN params are not logged
+func redact(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		u.RawQuery = ""
+		return u.String()
+	}
+	return rawURL
+}
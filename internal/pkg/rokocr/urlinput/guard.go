@@ -0,0 +1,129 @@
+package urlinput
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+// DefaultHosts - where links given to the server usually point to, Discord attachments
+var DefaultHosts = []string{"cdn.discordapp.com", "media.discordapp.net"}
+
+// ErrNotAllowed - link (or its redirect) points to a host which isn't allowed, or to a private address
+var ErrNotAllowed = errors.New("url not allowed")
+
+// Guard - restricts downloads of untrusted links (given to the server by anyone): only links to Hosts are
+// fetched, and never from loopback, private or link-local addresses, even if a host resolves to one
+type Guard struct {
+	// hosts - allowed host names, lower case, subdomains aren't included
+	hosts  []string
+	client *http.Client
+}
+
+func NewGuard(hosts []string) *Guard {
+	g := &Guard{}
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); len(h) > 0 {
+			g.hosts = append(g.hosts, h)
+		}
+	}
+	g.client = g.newClient(client.Timeout)
+	return g
+}
+
+// Check - link is http(s) and points to an allowed host
+func (g *Guard) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid url %q, expected http(s) link", redact(rawURL))
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range g.hosts {
+		if host == h {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %v isn't one of %v", ErrNotAllowed, host, strings.Join(g.hosts, ", "))
+}
+
+// newClient - http client checking every redirect, and refusing connections to non-public addresses.
+// Addresses are checked as dialed, so DNS changing between the check & the request doesn't matter.
+func (g *Guard) newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// no proxy from environment, the address dialed would be the proxy's
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return g.Check(req.URL.String())
+		},
+	}
+}
+
+// checkAddress - ip:port is a public address
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %v isn't an ip address", ErrNotAllowed, host)
+	}
+	if !Public(ip) {
+		return fmt.Errorf("%w: %v is not a public address", ErrNotAllowed, ip)
+	}
+	return nil
+}
+
+// nonPublicNets - not reachable from the internet either, though not covered by net.IP checks
+var nonPublicNets = []*net.IPNet{
+	// "this network" (RFC 1122), 0.0.0.0 & the rest of 0/8 reach the local host on Linux
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	// carrier-grade NAT (RFC 6598)
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	// NAT64 (RFC 6052), 64:ff9b::7f00:1 is 127.0.0.1 behind a NAT64 gateway
+	{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)},
+}
+
+// Public - ip is reachable from the internet: not loopback, private, link-local (cloud metadata) or multicast
+func Public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch - like the package level Fetch, only links the guard allows are downloaded
+func (g *Guard) Fetch(ctx context.Context, rawURL, dir, name string, retry retryutils.Options) (string, error) {
+	if err := g.Check(rawURL); err != nil {
+		return "", err
+	}
+	return fetch(ctx, g.client, rawURL, dir, name, retry)
+}
@@ -0,0 +1,70 @@
+package urlinput
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+)
+
+func TestGuardCheck(t *testing.T) {
+	g := NewGuard([]string{"cdn.discordapp.com", " Media.Discordapp.NET "})
+
+	for link, allowed := range map[string]bool{
+		"https://cdn.discordapp.com/attachments/1/2/image.png": true,
+		"https://media.discordapp.net/a.png?ex=1":              true,
+		"https://evil.cdn.discordapp.com/a.png":                false,
+		"http://169.254.169.254/latest/meta-data/":             false,
+		"ftp://cdn.discordapp.com/a.png":                       false,
+		"not a link":                                           false,
+	} {
+		if err := g.Check(link); (err == nil) != allowed {
+			t.Errorf("Check(%q) = %v, allowed %v", link, err, allowed)
+		}
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"1.1.1.1":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"192.168.0.1":        false,
+		"172.16.5.5":         false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"0.1.2.3":            false,
+		"100.127.255.255":    false,
+		"100.128.0.1":        true,
+		"64:ff9b::7f00:1":    false,
+		"64:ff9b::a9fe:a9fe": false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"::ffff:127.0.0.1":   false,
+	} {
+		if got := Public(net.ParseIP(ip)); got != public {
+			t.Errorf("Public(%v) = %v, want %v", ip, got, public)
+		}
+	}
+}
+
+func TestGuardRefusesPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	// the host is allowed, but it's loopback
+	g := NewGuard([]string{"127.0.0.1"})
+	_, err := g.Fetch(context.Background(), server.URL+"/a.png", t.TempDir(), "a.png", retryutils.Options{MaxRetries: 3})
+	if !errors.Is(err, ErrNotAllowed) {
+		t.Fatalf("expected ErrNotAllowed, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package videoframes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/corona10/goimagehash"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Options - how frames are pulled out of a recording
+type Options struct {
+	// FFmpeg - path to ffmpeg binary, used for decoding (any format / device ffmpeg understands)
+	FFmpeg string
+	// FPS - frames sampled per second of video
+	FPS float64
+	// StableFrames - how many consecutive (almost) identical frames make a screen "stable", not scrolling
+	StableFrames int
+	// MaxMotion - max hash distance between consecutive frames, to consider them identical
+	MaxMotion int
+	// MinChange - min hash distance from the previous emitted screen, to consider it a new one
+	MinChange int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		FFmpeg:       "ffmpeg",
+		FPS:          4,
+		StableFrames: 2,
+		MaxMotion:    2,
+		MinChange:    6,
+	}
+}
+
+// Frames - decodes video (file, url, or "-" for stdin, e.g. `scrcpy --record=-`) into frames, with ffmpeg.
+// Channel is closed when video ends, ffmpeg fails or ctx is cancelled; error (if any) is sent to errs.
+func Frames(ctx context.Context, input string, opts Options) (<-chan image.Image, <-chan error) {
+	out := make(chan image.Image)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errs)
+
+		args := []string{"-hide_banner", "-loglevel", "error", "-i", input,
+			"-vf", fmt.Sprintf("fps=%v", opts.FPS), "-f", "image2pipe", "-vcodec", "png", "-"}
+		cmd := exec.CommandContext(ctx, opts.FFmpeg, args...)
+		if input == "-" {
+			cmd.Stdin = os.Stdin
+		}
+
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			errs <- err
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			errs <- fmt.Errorf("can't start ffmpeg: %v", err)
+			return
+		}
+
+		// png decoder stops at IEND chunk, so frames can be read one after another
+		r := bufio.NewReader(stdout)
+		for {
+			img, err := png.Decode(r)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+					log.Debugf("[video] decode: %v", err)
+				}
+				break
+			}
+
+			select {
+			case out <- img:
+			case <-ctx.Done():
+				_ = cmd.Wait()
+				return
+			}
+		}
+
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			errs <- fmt.Errorf("ffmpeg: %v %v", err, strings.TrimSpace(stderr.String()))
+		}
+	}()
+
+	return out, errs
+}
+
+// UniqueScreens - filters frames down to stable (not scrolling / animating) and unique screens.
+// A screen is emitted once it stays the same for StableFrames frames, and it's different from the last emitted one.
+func UniqueScreens(frames <-chan image.Image, opts Options, prefix string) <-chan tesseractutils.NamedImage {
+	out := make(chan tesseractutils.NamedImage)
+
+	go func() {
+		defer close(out)
+
+		var previous, emitted *goimagehash.ImageHash
+		stable, index, count := 0, 0, 0
+
+		for img := range frames {
+			index++
+			hash, err := goimagehash.DifferenceHash(img)
+			if err != nil {
+				continue
+			}
+
+			if previous != nil {
+				if d, err := previous.Distance(hash); err == nil && d <= opts.MaxMotion {
+					stable++
+				} else {
+					stable = 0
+				}
+			}
+			previous = hash
+
+			if stable+1 < opts.StableFrames {
+				continue
+			}
+
+			if emitted != nil {
+				if d, err := emitted.Distance(hash); err == nil && d < opts.MinChange {
+					// same screen we already have
+					continue
+				}
+			}
+
+			emitted = hash
+			count++
+			log.Debugf("[video] frame %v is a new screen (%v)", index, count)
+			out <- tesseractutils.NamedImage{Name: fmt.Sprintf("%s_%04d.png", prefix, count), Image: img}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,126 @@
+package watchfolder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	log "github.com/sirupsen/logrus"
+)
+
+// sub-folders where processed files are moved to
+const (
+	DoneFolder   = "done"
+	FailedFolder = "failed"
+)
+
+type Options struct {
+	Files fileutils.ListOptions
+	// file is picked up only after it wasn't written for this long (cloud sync writes files in chunks)
+	Settle time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{Files: fileutils.DefaultImageListOptions(), Settle: 2 * time.Second}
+}
+
+// Watch - emits files already in the directory, then every new file as it arrives (once it settles).
+// Channel is closed when ctx is cancelled.
+func Watch(ctx context.Context, dir string, opts Options) (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("can't watch %v: %w", dir, err)
+	}
+
+	// only top level, so done & failed sub-folders are never picked up again
+	opts.Files.Recursive = false
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer watcher.Close()
+
+		emit := func(f string) bool {
+			select {
+			case out <- f:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, f := range fileutils.ListFiles(dir, opts.Files) {
+			if !emit(f) {
+				return
+			}
+		}
+
+		// last write of not yet settled files
+		pending := make(map[string]time.Time)
+		ticker := time.NewTicker(opts.Settle / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+					delete(pending, ev.Name)
+					continue
+				}
+				if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+					if st, err := os.Stat(ev.Name); err == nil && !st.IsDir() && opts.Files.Accepts(ev.Name) {
+						pending[ev.Name] = time.Now()
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warnf("Watching %v: %v", dir, err)
+			case now := <-ticker.C:
+				for f, last := range pending {
+					if now.Sub(last) < opts.Settle {
+						continue
+					}
+					delete(pending, f)
+					if !emit(f) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Move - moves the file into the sub-folder (DoneFolder or FailedFolder) next to it,
+// a timestamp is added to the name if file with same name was already processed
+func Move(file, folder string) (string, error) {
+	dir := filepath.Join(filepath.Dir(file), folder)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, filepath.Base(file))
+	if _, err := os.Stat(target); err == nil {
+		ext := filepath.Ext(file)
+		target = filepath.Join(dir, fmt.Sprintf("%s_%v%s", filepath.Base(file[:len(file)-len(ext)]), time.Now().UnixNano(), ext))
+	}
+
+	return target, os.Rename(file, target)
+}
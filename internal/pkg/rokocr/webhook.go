@@ -0,0 +1,186 @@
+package rokocr
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// webhookIdle - rows of a file are emitted one after another, a file is complete once no row came for this long
+	webhookIdle = time.Second
+	// WebhookSignatureHeader - "sha256=<hex HMAC of the body>", when the sink has a secret
+	WebhookSignatureHeader = "X-RokOCR-Signature"
+)
+
+// WebhookFile - JSON posted to the webhook once a file is done
+type WebhookFile struct {
+	File     string    `json:"file"`
+	Template string    `json:"template,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Time     time.Time `json:"time"`
+	// Rows - data of every row read from the file (one, unless the template has rows), for simple integrations
+	Rows []map[string]interface{} `json:"rows"`
+	// Results - everything known about the rows: confidence of fields, quality issues, ...
+	Results []schema.OCRResult `json:"results"`
+	// Error - why the file (or some of its rows) couldn't be read
+	Error string `json:"error,omitempty"`
+}
+
+type WebhookOptions struct {
+	URL string
+	// Secret - if set, bodies are signed with it (see WebhookSignatureHeader)
+	Secret string
+	// Source - what is scanned (media dir, video, device), added to every file
+	Source string
+	Retry  retryutils.Options
+}
+
+type webhookResult struct {
+	result   schema.OCRResult
+	template string
+}
+
+// WebhookSink - POSTs results of every file to a URL as the file is done (Zapier, Google Apps Script, dashboards, ...).
+// Files are posted in order from a goroutine, so a slow endpoint doesn't hold the scan up. Failed posts are logged,
+// the scan goes on.
+type WebhookSink struct {
+	opts   WebhookOptions
+	client *http.Client
+	in     chan webhookResult
+	done   chan struct{}
+	posted atomic.Int64
+	failed atomic.Int64
+}
+
+func NewWebhookSink(opts WebhookOptions) *WebhookSink {
+	w := &WebhookSink{
+		opts:   opts,
+		client: &http.Client{Timeout: 30 * time.Second},
+		in:     make(chan webhookResult, 1000),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add - queues a result, template is the title of the template it was recognized with
+func (w *WebhookSink) Add(r schema.OCRResult, template string) {
+	w.in <- webhookResult{result: r, template: template}
+}
+
+// Close - posts files still queued, and waits for them
+func (w *WebhookSink) Close() {
+	close(w.in)
+	<-w.done
+	log.Infof("Posted %v files to webhook (%v failed)", w.posted.Load(), w.failed.Load())
+}
+
+func (w *WebhookSink) run() {
+	defer close(w.done)
+
+	var pending []webhookResult
+	flush := func() {
+		if len(pending) > 0 {
+			w.post(pending)
+			pending = nil
+		}
+	}
+
+	for {
+		var idle <-chan time.Time
+		if len(pending) > 0 {
+			idle = time.After(webhookIdle)
+		}
+
+		select {
+		case r, ok := <-w.in:
+			if !ok {
+				flush()
+				return
+			}
+			if len(pending) > 0 && pending[0].result.Filename != r.result.Filename {
+				flush()
+			}
+			pending = append(pending, r)
+		case <-idle:
+			flush()
+		}
+	}
+}
+
+func (w *WebhookSink) post(results []webhookResult) {
+	file := WebhookFile{
+		File:     results[0].result.Filename,
+		Template: results[0].template,
+		Source:   w.opts.Source,
+		Time:     time.Now().UTC(),
+		Rows:     []map[string]interface{}{},
+	}
+	var errs []string
+	for _, x := range results {
+		file.Results = append(file.Results, x.result)
+		if len(x.result.Error) > 0 {
+			errs = append(errs, x.result.Error)
+		} else {
+			file.Rows = append(file.Rows, x.result.Data)
+		}
+	}
+	file.Error = strings.Join(errs, "; ")
+
+	if err := w.Post(context.Background(), file); err != nil {
+		w.failed.Add(1)
+		log.Errorf("Failed to post %v to webhook: %v", file.File, err)
+		return
+	}
+	w.posted.Add(1)
+}
+
+// Post - posts the file to the webhook, transient failures (timeouts, 5xx, 429) are retried
+func (w *WebhookSink) Post(ctx context.Context, file WebhookFile) error {
+	body, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	return retryutils.Do(ctx, w.opts.Retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(w.opts.Secret) > 0 {
+			mac := hmac.New(sha256.New, []byte(w.opts.Secret))
+			mac.Write(body)
+			req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package rokocr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	schema "github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Results"
+
+// WriteXLSX - writes results as Excel workbook, columns come from the template Table (Bold & Color are honored).
+// Header row has an autofilter and stays frozen while scrolling.
+func WriteXLSX(data []schema.OCRResult, template schema.OCRTemplate, w io.Writer) error {
+	columns := ExportColumns(template, data)
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), xlsxSheet); err != nil {
+		return err
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#D9D9D9"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := []string{"Filename"}
+	for _, x := range columns {
+		headers = append(headers, x.Title)
+	}
+
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		_ = f.SetCellValue(xlsxSheet, cell, h)
+		widths[i] = len(h)
+	}
+
+	last, _ := excelize.CoordinatesToCellName(len(headers), 1)
+	if err := f.SetCellStyle(xlsxSheet, "A1", last, headerStyle); err != nil {
+		return err
+	}
+
+	for r, row := range data {
+		values := []interface{}{row.Filename}
+		for _, x := range columns {
+			values = append(values, row.Data[x.Field])
+		}
+
+		for i, v := range values {
+			cell, _ := excelize.CoordinatesToCellName(i+1, r+2)
+			if err := f.SetCellValue(xlsxSheet, cell, v); err != nil {
+				return err
+			}
+			if l := len(schema.FormatValue(v)); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+
+	for i, x := range columns {
+		style, err := columnStyle(f, x)
+		if err != nil {
+			return fmt.Errorf("column %v: %v", x.Title, err)
+		}
+		if style == 0 || len(data) == 0 {
+			continue
+		}
+		from, _ := excelize.CoordinatesToCellName(i+2, 2)
+		to, _ := excelize.CoordinatesToCellName(i+2, len(data)+1)
+		if err := f.SetCellStyle(xlsxSheet, from, to, style); err != nil {
+			return err
+		}
+	}
+
+	for i, width := range widths {
+		name, _ := excelize.ColumnNumberToName(i + 1)
+		_ = f.SetColWidth(xlsxSheet, name, name, float64(min(width, 60)+2))
+	}
+
+	lastCell, _ := excelize.CoordinatesToCellName(len(headers), len(data)+1)
+	if err := f.AutoFilter(xlsxSheet, "A1:"+lastCell, nil); err != nil {
+		return err
+	}
+
+	if err := f.SetPanes(xlsxSheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+// columnStyle - excel style of the column cells, 0 if column has no formatting
+func columnStyle(f *excelize.File, x schema.OCRTableField) (int, error) {
+	if !x.Bold && len(strings.TrimSpace(x.Color)) == 0 {
+		return 0, nil
+	}
+
+	font := &excelize.Font{Bold: x.Bold}
+	if len(strings.TrimSpace(x.Color)) > 0 {
+		c, err := imgutils.ParseColor(x.Color)
+		if err != nil {
+			return 0, err
+		}
+		// table colors are picked for dark terminals, white text would be invisible on a white sheet
+		if c.R != 0xff || c.G != 0xff || c.B != 0xff {
+			font.Color = fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+		}
+	}
+	if !font.Bold && len(font.Color) == 0 {
+		return 0, nil
+	}
+
+	return f.NewStyle(&excelize.Style{Font: font})
+}
@@ -1,10 +1,58 @@
 package fileutils
 
 import (
+	"io/fs"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
 )
 
+// DefaultImageExtensions - images, and PDFs (their pages are rasterized)
+var DefaultImageExtensions = []string{".png", ".jpg", ".jpeg", ".webp", ".heic", ".heif", ".pdf"}
+
+type ListOptions struct {
+	Recursive  bool
+	Extensions []string
+}
+
+// DefaultImageListOptions - top level only, accepting files with DefaultImageExtensions
+func DefaultImageListOptions() ListOptions {
+	return ListOptions{Extensions: DefaultImageExtensions}
+}
+
+// ParseExtensions - converts "png,.JPG" into []string{".png", ".jpg"}
+func ParseExtensions(s string) []string {
+	var result []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if len(e) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// Accepts - file name has one of accepted extensions (or no extensions are set)
+func (o ListOptions) Accepts(name string) bool {
+	if len(o.Extensions) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range o.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
 func GetFilesInDirectory(directory string) []string {
 	var files []string
 
@@ -18,3 +66,38 @@ func GetFilesInDirectory(directory string) []string {
 
 	return files
 }
+
+// ListFiles - lists files in the directory (optionally recursive), skipping hidden ones and the ones with unaccepted extension
+func ListFiles(directory string, opts ListOptions) []string {
+	var files []string
+
+	_ = filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("Skipping %v: %v", path, err)
+			return nil
+		}
+
+		// hidden files (.DS_Store, macOS "._IMG_1.png" resource forks) and folders (.git) are never screenshots
+		hidden := path != directory && strings.HasPrefix(d.Name(), ".")
+		if d.IsDir() {
+			if path != directory && (!opts.Recursive || hidden) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden {
+			log.Debugf("Skipping %v: hidden", path)
+			return nil
+		}
+
+		if !opts.Accepts(d.Name()) {
+			log.Debugf("Skipping %v: not an accepted extension", path)
+			return nil
+		}
+
+		files = append(files, path)
+		return nil
+	})
+
+	return files
+}
@@ -0,0 +1,101 @@
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// messyTree - media folder as people have it: nested folders, thumbnails caches, notes, hidden files
+func messyTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.png",
+		"B.JPG",
+		"c.jpeg",
+		"notes.txt",
+		"Thumbs.db",
+		".DS_Store",
+		"._a.png",
+		"kvk/d.webp",
+		"kvk/E.PNG",
+		"kvk/readme.txt",
+		"kvk/day2/f.pdf",
+		"kvk/day2/Thumbs.db",
+		".git/g.png",
+	} {
+		f := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func relative(t *testing.T, dir string, files []string) []string {
+	t.Helper()
+	var result []string
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, filepath.ToSlash(rel))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestListFiles(t *testing.T) {
+	dir := messyTree(t)
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{
+			name: "top level images",
+			opts: DefaultImageListOptions(),
+			want: []string{"B.JPG", "a.png", "c.jpeg"},
+		},
+		{
+			name: "recursive images",
+			opts: ListOptions{Recursive: true, Extensions: DefaultImageExtensions},
+			want: []string{"B.JPG", "a.png", "c.jpeg", "kvk/E.PNG", "kvk/d.webp", "kvk/day2/f.pdf"},
+		},
+		{
+			name: "recursive, custom extensions",
+			opts: ListOptions{Recursive: true, Extensions: ParseExtensions("PNG, .txt")},
+			want: []string{"a.png", "kvk/E.PNG", "kvk/readme.txt", "notes.txt"},
+		},
+		{
+			name: "no extensions accepts everything but hidden files",
+			opts: ListOptions{},
+			want: []string{"B.JPG", "Thumbs.db", "a.png", "c.jpeg", "notes.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := relative(t, dir, ListFiles(dir, tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	got := ParseExtensions(" png,.JPG,, webp ")
+	want := []string{".png", ".jpg", ".webp"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExtensions() = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package fileutils
 
 import (
+	"io"
 	"os"
 
 	log "github.com/sirupsen/logrus"
@@ -17,3 +18,22 @@ func WriteFile(data []byte, name string) error {
 	_, err = fd.Write(data)
 	return err
 }
+
+// CopyFile - copies src to dst, overwriting it
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
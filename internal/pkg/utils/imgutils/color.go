@@ -0,0 +1,64 @@
+package imgutils
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// NamedColors - basic CSS color keywords
+var NamedColors = map[string]color.RGBA{
+	"black":   {0x00, 0x00, 0x00, 0xff},
+	"silver":  {0xc0, 0xc0, 0xc0, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"grey":    {0x80, 0x80, 0x80, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"maroon":  {0x80, 0x00, 0x00, 0xff},
+	"red":     {0xff, 0x00, 0x00, 0xff},
+	"purple":  {0x80, 0x00, 0x80, 0xff},
+	"fuchsia": {0xff, 0x00, 0xff, 0xff},
+	"magenta": {0xff, 0x00, 0xff, 0xff},
+	"green":   {0x00, 0x80, 0x00, 0xff},
+	"lime":    {0x00, 0xff, 0x00, 0xff},
+	"olive":   {0x80, 0x80, 0x00, 0xff},
+	"yellow":  {0xff, 0xff, 0x00, 0xff},
+	"navy":    {0x00, 0x00, 0x80, 0xff},
+	"blue":    {0x00, 0x00, 0xff, 0xff},
+	"teal":    {0x00, 0x80, 0x80, 0xff},
+	"aqua":    {0x00, 0xff, 0xff, 0xff},
+	"cyan":    {0x00, 0xff, 0xff, 0xff},
+	"orange":  {0xff, 0xa5, 0x00, 0xff},
+	"gold":    {0xff, 0xd7, 0x00, 0xff},
+	"pink":    {0xff, 0xc0, 0xcb, 0xff},
+	"brown":   {0xa5, 0x2a, 0x2a, 0xff},
+}
+
+// ParseColor - parses #RRGGBB, #RGB or a named color
+func ParseColor(s string) (color.RGBA, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+
+	if c, ok := NamedColors[v]; ok {
+		return c, nil
+	}
+
+	if !strings.HasPrefix(v, "#") {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: expected #RRGGBB, #RGB or a color name", s)
+	}
+
+	hex := v[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: expected #RRGGBB or #RGB", s)
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: not a hex value", s)
+	}
+
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}, nil
+}
@@ -0,0 +1,39 @@
+package imgutils
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.RGBA
+	}{
+		{"#ff8000", color.RGBA{0xff, 0x80, 0x00, 0xff}},
+		{"#FF8000", color.RGBA{0xff, 0x80, 0x00, 0xff}},
+		{"#f80", color.RGBA{0xff, 0x88, 0x00, 0xff}},
+		{" #000 ", color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{"red", color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{"Gold", color.RGBA{0xff, 0xd7, 0x00, 0xff}},
+		{"grey", color.RGBA{0x80, 0x80, 0x80, 0xff}},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if err != nil {
+			t.Errorf("ParseColor(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseColorInvalid(t *testing.T) {
+	for _, in := range []string{"", "ff8000", "#", "#ff80", "#ff80001", "#gg8000", "#+f8000", "reddish"} {
+		if c, err := ParseColor(in); err == nil {
+			t.Errorf("ParseColor(%q) = %v, want error", in, c)
+		}
+	}
+}
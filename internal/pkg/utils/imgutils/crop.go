@@ -21,3 +21,24 @@ func CropImage(img image.Image, crop image.Rectangle) (image.Image, error) {
 
 	return simg.SubImage(crop), nil
 }
+
+// MinContent - borders are cropped only if the rest covers at least this share of the image,
+// otherwise it's a (mostly) dark screen, not a letterboxed one
+const MinContent = 0.5
+
+// CropBorders - crops uniform dark borders & notch insets (see DarkBorders) off the image,
+// so screenshots of phones with other aspect ratio still match 16:9 templates
+func CropBorders(src image.Image) image.Image {
+	b := src.Bounds()
+	content := DarkBorders(src, 16)
+	if content == b || content.Dx()*content.Dy() < int(MinContent*float64(b.Dx()*b.Dy())) {
+		return src
+	}
+
+	cropped, err := CropImage(src, content)
+	if err != nil {
+		return src
+	}
+	// crops are relative to 0,0 - so the cropped image can't keep parent coordinates
+	return CloneRGBA(cropped)
+}
@@ -0,0 +1,57 @@
+package imgutils
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// CloneRGBA - returns a mutable copy of the image, so we can draw on it without touching the source.
+func CloneRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// DrawRectangle - draws an outline of the rectangle with given thickness.
+func DrawRectangle(dst draw.Image, r image.Rectangle, c color.Color, thickness int) {
+	u := image.NewUniform(c)
+	for i := 0; i < thickness; i++ {
+		draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y+i, r.Max.X, r.Min.Y+i+1), u, image.Point{}, draw.Over)
+		draw.Draw(dst, image.Rect(r.Min.X, r.Max.Y-i-1, r.Max.X, r.Max.Y-i), u, image.Point{}, draw.Over)
+		draw.Draw(dst, image.Rect(r.Min.X+i, r.Min.Y, r.Min.X+i+1, r.Max.Y), u, image.Point{}, draw.Over)
+		draw.Draw(dst, image.Rect(r.Max.X-i-1, r.Min.Y, r.Max.X-i, r.Max.Y), u, image.Point{}, draw.Over)
+	}
+}
+
+// DrawLabel - writes text with a solid background at x,y (top-left corner).
+// basic font is tiny (7x13), so scale is an integer upscale factor of it.
+func DrawLabel(dst draw.Image, x, y int, text string, scale int, fg, bg color.Color) image.Rectangle {
+	if scale < 1 {
+		scale = 1
+	}
+
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil() + 4
+	height := face.Height + 2
+
+	label := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(label, label.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	d := &font.Drawer{
+		Dst:  label,
+		Src:  image.NewUniform(fg),
+		Face: face,
+		Dot:  fixed.P(2, face.Ascent+1),
+	}
+	d.DrawString(text)
+
+	target := image.Rect(x, y, x+width*scale, y+height*scale)
+	draw.NearestNeighbor.Scale(dst, target, label, label.Bounds(), draw.Over, nil)
+	return target
+}
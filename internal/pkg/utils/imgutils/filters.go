@@ -0,0 +1,155 @@
+package imgutils
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// Grayscale - converts image to 8-bit grayscale
+func Grayscale(src image.Image) *image.Gray {
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// Invert - negative of the image, tesseract reads dark text on light background much better
+func Invert(src image.Image) *image.RGBA {
+	dst := CloneRGBA(src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = 255 - dst.Pix[i]
+		dst.Pix[i+1] = 255 - dst.Pix[i+1]
+		dst.Pix[i+2] = 255 - dst.Pix[i+2]
+	}
+	return dst
+}
+
+// Upscale - enlarges image with smooth (Catmull-Rom) interpolation, useful for tiny text
+func Upscale(src image.Image, factor float64) image.Image {
+	b := src.Bounds()
+	w, h := int(float64(b.Dx())*factor), int(float64(b.Dy())*factor)
+	if w < 1 || h < 1 {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Rect, src, b, draw.Src, nil)
+	return dst
+}
+
+// Luminance - perceived brightness of the color (0-255)
+func Luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+}
+
+// Threshold - black & white image, pixels brighter than level become white
+func Threshold(src image.Image, level uint8) *image.Gray {
+	dst := Grayscale(src)
+	for i, p := range dst.Pix {
+		if p > level {
+			dst.Pix[i] = 255
+		} else {
+			dst.Pix[i] = 0
+		}
+	}
+	return dst
+}
+
+// Binarize - black & white image, split at the level best separating dark & light pixels (Otsu's method)
+func Binarize(src image.Image) *image.Gray {
+	gray := Grayscale(src)
+
+	var histogram [256]int
+	for _, p := range gray.Pix {
+		histogram[p]++
+	}
+
+	total := len(gray.Pix)
+	sum := 0.0
+	for i, n := range histogram {
+		sum += float64(i * n)
+	}
+
+	level, best := uint8(127), 0.0
+	sumDark, dark := 0.0, 0
+	for i, n := range histogram {
+		dark += n
+		if dark == 0 {
+			continue
+		}
+		light := total - dark
+		if light == 0 {
+			break
+		}
+		sumDark += float64(i * n)
+		meanDark, meanLight := sumDark/float64(dark), (sum-sumDark)/float64(light)
+		// between-class variance
+		if v := float64(dark) * float64(light) * (meanDark - meanLight) * (meanDark - meanLight); v > best {
+			level, best = uint8(i), v
+		}
+	}
+
+	for i, p := range gray.Pix {
+		if p > level {
+			gray.Pix[i] = 255
+		} else {
+			gray.Pix[i] = 0
+		}
+	}
+	return gray
+}
+
+// Contrast - scales distance of every channel from the middle gray by factor (>1 more contrast)
+func Contrast(src image.Image, factor float64) *image.RGBA {
+	dst := CloneRGBA(src)
+	for i := 0; i < len(dst.Pix); i++ {
+		if i%4 == 3 {
+			// alpha
+			continue
+		}
+		v := (float64(dst.Pix[i])-128)*factor + 128
+		if v < 0 {
+			v = 0
+		} else if v > 255 {
+			v = 255
+		}
+		dst.Pix[i] = uint8(v)
+	}
+	return dst
+}
+
+// Denoise - 3x3 median filter on grayscale image, removes speckles around the glyphs
+func Denoise(src image.Image) *image.Gray {
+	gray := Grayscale(src)
+	b := gray.Bounds()
+	dst := image.NewGray(b)
+
+	window := make([]uint8, 0, 9)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			window = window[:0]
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					p := image.Pt(x+dx, y+dy)
+					if p.In(b) {
+						window = append(window, gray.GrayAt(p.X, p.Y).Y)
+					}
+				}
+			}
+			sortBytes(window)
+			dst.SetGray(x, y, color.Gray{Y: window[len(window)/2]})
+		}
+	}
+	return dst
+}
+
+func sortBytes(b []uint8) {
+	// insertion sort, window is tiny
+	for i := 1; i < len(b); i++ {
+		for j := i; j > 0 && b[j] < b[j-1]; j-- {
+			b[j], b[j-1] = b[j-1], b[j]
+		}
+	}
+}
@@ -7,6 +7,11 @@ import (
 	_ "image/png"
 	"io"
 	"os"
+
+	// iPhone screenshots, decoded by libheif compiled to wasm (no cgo needed)
+	_ "github.com/gen2brain/heic"
+	// Android & Discord exports
+	_ "golang.org/x/image/webp"
 )
 
 func ReadImageFile(filename string) (image.Image, error) {
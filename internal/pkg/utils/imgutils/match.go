@@ -0,0 +1,215 @@
+package imgutils
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// grayValues - luminance of every pixel, row by row
+func grayValues(src image.Image) ([]float64, int, int) {
+	gray := Grayscale(src)
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	values := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		row := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		for x, p := range row {
+			values[y*w+x] = float64(p)
+		}
+	}
+	return values, w, h
+}
+
+// scoreMap - normalized cross-correlation of tpl at every position inside area, row by row, along with
+// the size of the map & top-left corner of its first position. NaN where src is flat.
+func scoreMap(src, tpl image.Image, area image.Rectangle) ([]float64, int, int, image.Point) {
+	if area.Empty() {
+		area = src.Bounds()
+	}
+	area = area.Intersect(src.Bounds())
+
+	tw, th := tpl.Bounds().Dx(), tpl.Bounds().Dy()
+	if tw == 0 || th == 0 || area.Dx() < tw || area.Dy() < th {
+		return nil, 0, 0, area.Min
+	}
+
+	region, err := CropImage(src, area)
+	if err != nil {
+		return nil, 0, 0, area.Min
+	}
+	s, sw, sh := grayValues(region)
+	t, _, _ := grayValues(tpl)
+
+	// template with zero mean, so the cross term doesn't depend on window mean
+	n := float64(tw * th)
+	mean := 0.0
+	for _, v := range t {
+		mean += v
+	}
+	mean /= n
+	tvar := 0.0
+	for i := range t {
+		t[i] -= mean
+		tvar += t[i] * t[i]
+	}
+	if tvar == 0 {
+		// flat template matches everything equally
+		return nil, 0, 0, area.Min
+	}
+
+	// integral images of src & src^2, for window variance in constant time
+	iw := sw + 1
+	sum := make([]float64, iw*(sh+1))
+	sq := make([]float64, iw*(sh+1))
+	for y := 0; y < sh; y++ {
+		rs, rq := 0.0, 0.0
+		for x := 0; x < sw; x++ {
+			v := s[y*sw+x]
+			rs += v
+			rq += v * v
+			sum[(y+1)*iw+x+1] = sum[y*iw+x+1] + rs
+			sq[(y+1)*iw+x+1] = sq[y*iw+x+1] + rq
+		}
+	}
+	window := func(a []float64, x, y int) float64 {
+		return a[(y+th)*iw+x+tw] - a[y*iw+x+tw] - a[(y+th)*iw+x] + a[y*iw+x]
+	}
+
+	mw, mh := sw-tw+1, sh-th+1
+	scores := make([]float64, mw*mh)
+	for y := 0; y < mh; y++ {
+		for x := 0; x < mw; x++ {
+			ws := window(sum, x, y)
+			wvar := window(sq, x, y) - ws*ws/n
+			if wvar <= 0 {
+				scores[y*mw+x] = math.NaN()
+				continue
+			}
+
+			cross := 0.0
+			for ty := 0; ty < th; ty++ {
+				row := s[(y+ty)*sw+x : (y+ty)*sw+x+tw]
+				trow := t[ty*tw : ty*tw+tw]
+				for tx, v := range row {
+					cross += v * trow[tx]
+				}
+			}
+			scores[y*mw+x] = cross / math.Sqrt(wvar*tvar)
+		}
+	}
+	return scores, mw, mh, area.Min
+}
+
+// MatchTemplate - finds the position where tpl looks most like the src, using normalized cross-correlation of luminance
+// (insensitive to brightness & contrast changes). Only positions with tpl fully inside area are tried, an empty area
+// means whole src. Returns top-left corner of the best match (in src coordinates) and its score, from -1 to 1 (perfect match).
+func MatchTemplate(src, tpl image.Image, area image.Rectangle) (image.Point, float64) {
+	scores, mw, _, origin := scoreMap(src, tpl, area)
+
+	best, score := -1, math.Inf(-1)
+	for i, c := range scores {
+		if c > score {
+			best, score = i, c
+		}
+	}
+	if best < 0 {
+		return origin, 0
+	}
+	return origin.Add(image.Pt(best%mw, best/mw)), score
+}
+
+// Match - position & score of a match (see MatchTemplate)
+type Match struct {
+	At    image.Point
+	Score float64
+}
+
+// MatchTemplateAll - every position where tpl matches with at least minScore (e.g. the same icon in every row of a list),
+// best matches first. Matches overlapping a better one are left out.
+func MatchTemplateAll(src, tpl image.Image, area image.Rectangle, minScore float64) []Match {
+	scores, mw, _, origin := scoreMap(src, tpl, area)
+
+	var candidates []Match
+	for i, c := range scores {
+		if c >= minScore {
+			candidates = append(candidates, Match{At: image.Pt(i%mw, i/mw), Score: c})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	tw, th := tpl.Bounds().Dx(), tpl.Bounds().Dy()
+	var matches []Match
+	for _, c := range candidates {
+		overlaps := false
+		for _, m := range matches {
+			if abs(c.At.X-m.At.X) < tw && abs(c.At.Y-m.At.Y) < th {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			matches = append(matches, c)
+		}
+	}
+
+	for i := range matches {
+		matches[i].At = origin.Add(matches[i].At)
+	}
+	return matches
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// LineContrast - how much brighter or darker than the rows around it a row of pixels has to be to be a separator line
+const LineContrast = 20
+
+// HorizontalLines - y coordinates of separator lines inside area (an empty area means whole src): rows of pixels
+// evenly brighter or darker than the rows 2 pixels above & below. Lines up to 3 pixels thick are reported once.
+func HorizontalLines(src image.Image, area image.Rectangle) []int {
+	if area.Empty() {
+		area = src.Bounds()
+	}
+	area = area.Intersect(src.Bounds())
+	region, err := CropImage(src, area)
+	if err != nil {
+		return nil
+	}
+	v, w, h := grayValues(region)
+	if w == 0 || h < 5 {
+		return nil
+	}
+
+	means := make([]float64, h)
+	uniform := make([]bool, h)
+	for y := 0; y < h; y++ {
+		row := v[y*w : y*w+w]
+		sum, sq := 0.0, 0.0
+		for _, p := range row {
+			sum += p
+			sq += p * p
+		}
+		means[y] = sum / float64(w)
+		uniform[y] = math.Sqrt(math.Max(0, sq/float64(w)-means[y]*means[y])) < LineContrast/2
+	}
+
+	line := func(y int) bool {
+		if y < 2 || y >= h-2 || !uniform[y] {
+			return false
+		}
+		above, below := means[y]-means[y-2], means[y]-means[y+2]
+		return (above >= LineContrast && below >= LineContrast) || (above <= -LineContrast && below <= -LineContrast)
+	}
+
+	var lines []int
+	for y := 0; y < h; y++ {
+		if line(y) && !line(y+1) {
+			lines = append(lines, area.Min.Y+y)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,105 @@
+package imgutils
+
+import (
+	"image"
+	"math"
+)
+
+// Sharpness - variance of the Laplacian of the grayscale image, low values mean a blurry image
+func Sharpness(src image.Image) float64 {
+	gray := Grayscale(src)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	var sum, sumSq float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*gray.Stride + x
+			v := float64(gray.Pix[i-1]) + float64(gray.Pix[i+1]) + float64(gray.Pix[i-gray.Stride]) + float64(gray.Pix[i+gray.Stride]) - 4*float64(gray.Pix[i])
+			sum += v
+			sumSq += v * v
+		}
+	}
+
+	n := float64((w - 2) * (h - 2))
+	mean := sum / n
+	return sumSq/n - mean*mean
+}
+
+// FlatFraction - share of tiles (image split into grid x grid) with (almost) no variation in brightness,
+// e.g. parts of the screen which weren't loaded yet
+func FlatFraction(src image.Image, grid int, maxDeviation float64) float64 {
+	gray := Grayscale(src)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if grid < 1 || w < grid || h < grid {
+		return 0
+	}
+
+	flat := 0
+	for ty := 0; ty < grid; ty++ {
+		for tx := 0; tx < grid; tx++ {
+			var sum, sumSq float64
+			x0, x1 := tx*w/grid, (tx+1)*w/grid
+			y0, y1 := ty*h/grid, (ty+1)*h/grid
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					v := float64(gray.Pix[y*gray.Stride+x])
+					sum += v
+					sumSq += v * v
+				}
+			}
+			n := float64((x1 - x0) * (y1 - y0))
+			mean := sum / n
+			if math.Sqrt(math.Max(sumSq/n-mean*mean, 0)) <= maxDeviation {
+				flat++
+			}
+		}
+	}
+
+	return float64(flat) / float64(grid*grid)
+}
+
+// BorderTolerance - share of brighter pixels a row/column along the edge can have, and still be a border
+// (camera notch outline, status bar icons or gesture bar drawn over the black inset)
+const BorderTolerance = 0.02
+
+// DarkBorders - bounds of the image without uniform dark rows & columns along its edges (letterboxing,
+// notch insets), pixels darker than level count as dark
+func DarkBorders(src image.Image, level uint8) image.Rectangle {
+	gray := Grayscale(src)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+
+	dark := func(x0, y0, x1, y1 int) bool {
+		bright, allowed := 0, int(BorderTolerance*float64((x1-x0)*(y1-y0)))
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if gray.Pix[y*gray.Stride+x] > level {
+					bright++
+					if bright > allowed {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	top, bottom, left, right := 0, h, 0, w
+	for top < bottom && dark(0, top, w, top+1) {
+		top++
+	}
+	for bottom > top && dark(0, bottom-1, w, bottom) {
+		bottom--
+	}
+	for left < right && dark(left, top, left+1, bottom) {
+		left++
+	}
+	for right > left && dark(right-1, top, right, bottom) {
+		right--
+	}
+
+	b := src.Bounds()
+	return image.Rect(b.Min.X+left, b.Min.Y+top, b.Min.X+right, b.Min.Y+bottom)
+}
@@ -0,0 +1,50 @@
+package imgutils
+
+import (
+	"image"
+	"math"
+)
+
+// StackedThreshold - image has to be at least this much taller (relative to expected aspect ratio) to be treated as stacked
+const StackedThreshold = 1.5
+
+// IsStacked - checks if image looks like several screens stitched vertically
+func IsStacked(img image.Image, w, h int) bool {
+	b := img.Bounds()
+	if w <= 0 || h <= 0 || b.Dx() == 0 {
+		return false
+	}
+
+	expected := float64(h) / float64(w)
+	actual := float64(b.Dy()) / float64(b.Dx())
+
+	return actual/expected >= StackedThreshold
+}
+
+// SplitStacked - slices vertically stitched image into panels of (roughly) panelHeight.
+// Number of panels is rounded, so a few pixels of extra/missing height don't produce a sliver.
+func SplitStacked(img image.Image, panelHeight int) []image.Image {
+	b := img.Bounds()
+	if panelHeight <= 0 {
+		return []image.Image{img}
+	}
+
+	n := int(math.Round(float64(b.Dy()) / float64(panelHeight)))
+	if n <= 1 {
+		return []image.Image{img}
+	}
+
+	var panels []image.Image
+	step := b.Dy() / n
+	for i := 0; i < n; i++ {
+		r := image.Rect(b.Min.X, b.Min.Y+i*step, b.Max.X, b.Min.Y+(i+1)*step)
+		panel, err := CropImage(img, r)
+		if err != nil {
+			return []image.Image{img}
+		}
+		// crops are relative to 0,0 - so panels can't keep parent coordinates
+		panels = append(panels, CloneRGBA(panel))
+	}
+
+	return panels
+}
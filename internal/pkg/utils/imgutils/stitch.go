@@ -0,0 +1,66 @@
+package imgutils
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ScrollOffset - how many pixels the content of area moved up between prev & next screenshot of a scrolled list,
+// found by matching the top of next's area in prev's area. Returns the offset with its match score (see MatchTemplate).
+func ScrollOffset(prev, next image.Image, area image.Rectangle) (int, float64) {
+	area = area.Intersect(prev.Bounds()).Intersect(next.Bounds())
+	strip := area.Dy() / 4
+	if strip < 16 {
+		strip = area.Dy()
+	}
+
+	top, err := CropImage(next, image.Rect(area.Min.X, area.Min.Y, area.Max.X, area.Min.Y+strip))
+	if err != nil {
+		return 0, 0
+	}
+	at, score := MatchTemplate(prev, top, area)
+	return at.Y - area.Min.Y, score
+}
+
+// StitchVertical - merges screenshots of a list scrolled down (in order) into one tall image: part above area from
+// the first screenshot, the list (area) of every screenshot placed by its scroll offset, and part below area from the last.
+// Consecutive screenshots have to overlap, with a match score of at least minScore. Returns the image & top of every
+// screenshot's area in it.
+func StitchVertical(images []image.Image, area image.Rectangle, minScore float64) (image.Image, []int, error) {
+	if len(images) == 0 {
+		return nil, nil, fmt.Errorf("nothing to stitch")
+	}
+
+	bounds := images[0].Bounds()
+	for i, img := range images {
+		if img.Bounds().Size() != bounds.Size() {
+			return nil, nil, fmt.Errorf("screenshot %d is %v, expected %v like the first one", i+1, img.Bounds().Size(), bounds.Size())
+		}
+	}
+	area = area.Intersect(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if area.Empty() {
+		return nil, nil, fmt.Errorf("list area is outside the screenshots")
+	}
+
+	tops := []int{area.Min.Y}
+	for i := 1; i < len(images); i++ {
+		offset, score := ScrollOffset(images[i-1], images[i], area.Add(images[i-1].Bounds().Min))
+		if score < minScore {
+			return nil, nil, fmt.Errorf("screenshots %d & %d don't overlap (best score: %.2f)", i, i+1, score)
+		}
+		tops = append(tops, tops[i-1]+offset)
+	}
+
+	last := tops[len(tops)-1]
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+last-area.Min.Y))
+	draw.Draw(dst, image.Rect(0, 0, bounds.Dx(), area.Min.Y), images[0], bounds.Min, draw.Src)
+	for i, img := range images {
+		r := image.Rect(0, tops[i], bounds.Dx(), tops[i]+area.Dy())
+		draw.Draw(dst, r, img, image.Pt(bounds.Min.X, bounds.Min.Y+area.Min.Y), draw.Src)
+	}
+	footer := image.Rect(0, last+area.Dy(), bounds.Dx(), dst.Bounds().Dy())
+	draw.Draw(dst, footer, images[len(images)-1], image.Pt(bounds.Min.X, bounds.Min.Y+area.Max.Y), draw.Src)
+
+	return dst, tops, nil
+}
@@ -0,0 +1,50 @@
+package logutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// structured fields of log lines, so lines of one file (or template, field) can be filtered out of a large run
+const (
+	FileKey     = "file"
+	TemplateKey = "template"
+	FieldKey    = "field"
+)
+
+// log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SetFormat - text (default, human readable) or json (one object per line, for log tooling)
+func SetFormat(format string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format %q, expected %v or %v", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// File - log entry of an input file (only base name is logged)
+func File(name string) *log.Entry {
+	return log.WithField(FileKey, filepath.Base(name))
+}
+
+// Template - log entry of a template (by title)
+func Template(title string) *log.Entry {
+	return log.WithField(TemplateKey, title)
+}
+
+// Recognition - log entry of an input file recognized with the template
+func Recognition(name, template string) *log.Entry {
+	return File(name).WithField(TemplateKey, template)
+}
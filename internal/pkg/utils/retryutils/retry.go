@@ -0,0 +1,90 @@
+package retryutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Options struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// StatusError - error returned by remote backends, 5xx are considered transient
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("remote error %d: %s", e.StatusCode, e.Message)
+}
+
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable - marks error as transient, so Do will try again
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable - only timeouts, 5xx and explicitly marked errors are retried,
+// everything else (e.g. unsupported image) is permanent
+func IsRetryable(err error) bool {
+	var marked *retryableError
+	if errors.As(err, &marked) {
+		return true
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled)
+}
+
+// Do - runs fn, retrying transient failures with exponential backoff, until ctx is done
+func Do(ctx context.Context, opts Options, fn func(ctx context.Context) error) error {
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+
+		if attempt >= opts.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		log.Debugf("attempt %v/%v failed: %v, retrying in %v", attempt+1, opts.MaxRetries+1, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = backoff * 2
+	}
+}
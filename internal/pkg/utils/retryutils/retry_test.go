@@ -0,0 +1,63 @@
+package retryutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	permanent := errors.New("unsupported image")
+	transient := &StatusError{StatusCode: 503, Message: "unavailable"}
+
+	tests := []struct {
+		name  string
+		errs  []error
+		want  error
+		calls int
+	}{
+		{"success", nil, nil, 1},
+		{"fails then succeeds", []error{transient, Retryable(errors.New("reset"))}, nil, 3},
+		{"permanent error isn't retried", []error{permanent}, permanent, 1},
+		{"4xx isn't retried", []error{&StatusError{StatusCode: 404}}, &StatusError{StatusCode: 404}, 1},
+		{"timeout is retried", []error{context.DeadlineExceeded}, nil, 2},
+		{"gives up after max retries", []error{transient, transient, transient, transient}, transient, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.errs
+			calls := 0
+			err := Do(context.Background(), Options{MaxRetries: 3, Backoff: time.Millisecond}, func(ctx context.Context) error {
+				calls++
+				if len(errs) == 0 {
+					return nil
+				}
+				err := errs[0]
+				errs = errs[1:]
+				return err
+			})
+
+			if calls != tt.calls {
+				t.Errorf("called %v times, want %v", calls, tt.calls)
+			}
+			if (err == nil) != (tt.want == nil) || (err != nil && err.Error() != tt.want.Error()) {
+				t.Errorf("Do() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Options{MaxRetries: 10, Backoff: time.Hour}, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return Retryable(errors.New("reset"))
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do() = %v after %v calls, want context.Canceled after 1", err, calls)
+	}
+}
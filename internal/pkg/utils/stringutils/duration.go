@@ -0,0 +1,62 @@
+package stringutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationUnits = regexp.MustCompile(`(\d+)\s*([dhms])`)
+
+// ParseDuration - parses durations as shown in game: "1d 02:03:04", "02:03:04", "12:34", "1d 5h 30m", "45s"
+func ParseDuration(s string) (time.Duration, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if len(v) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	var total time.Duration
+
+	// day prefix of clock format, e.g. "1d 02:03:04"
+	if strings.Contains(v, ":") {
+		if idx := strings.Index(v, "d"); idx > 0 {
+			days, err := strconv.Atoi(strings.TrimSpace(v[:idx]))
+			if err != nil {
+				return 0, fmt.Errorf("not a duration: %q", s)
+			}
+			total += time.Duration(days) * 24 * time.Hour
+			v = strings.TrimSpace(v[idx+1:])
+		}
+
+		parts := strings.Split(v, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("not a duration: %q", s)
+		}
+
+		var clock time.Duration
+		for _, p := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return 0, fmt.Errorf("not a duration: %q", s)
+			}
+			clock = clock*60 + time.Duration(n)
+		}
+		// hh:mm:ss or mm:ss
+		return total + clock*time.Second, nil
+	}
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour, "m": time.Minute, "s": time.Second}
+	matches := durationUnits.FindAllStringSubmatch(v, -1)
+	if len(matches) == 0 || len(strings.TrimSpace(durationUnits.ReplaceAllString(v, ""))) > 0 {
+		return 0, fmt.Errorf("not a duration: %q", s)
+	}
+
+	for _, m := range matches {
+		n, _ := strconv.Atoi(m[1])
+		total += time.Duration(n) * units[m[2]]
+	}
+
+	return total, nil
+}
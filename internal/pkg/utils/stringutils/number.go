@@ -0,0 +1,81 @@
+package stringutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var numberSuffixes = map[byte]float64{
+	'k': 1e3,
+	'm': 1e6,
+	'b': 1e9,
+}
+
+// ParseNumber - parses numbers as shown in game, e.g. "1,234,567", "1.234.567", "12.5M", "3B".
+// Decimal separator is guessed, see ParseNumberWithDecimal
+func ParseNumber(s string) (float64, error) {
+	return ParseNumberWithDecimal(s, "")
+}
+
+// ParseNumberWithDecimal - same as ParseNumber, with explicit decimal separator ("." or ",").
+// With empty separator it's guessed: if both "," and "." are present - the last one is decimal,
+// a separator repeated more than once or followed by exactly 3 digits (without suffix) is a thousand separator.
+func ParseNumberWithDecimal(s, decimal string) (float64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.NewReplacer(" ", "", "\u00a0", "", "'", "").Replace(v)
+
+	if len(v) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	multiplier := 1.0
+	if m, ok := numberSuffixes[v[len(v)-1]]; ok {
+		multiplier = m
+		v = v[:len(v)-1]
+	}
+
+	if len(decimal) == 0 {
+		decimal = guessDecimalSeparator(v, multiplier != 1.0)
+	}
+
+	switch decimal {
+	case ",":
+		v = strings.ReplaceAll(v, ".", "")
+		v = strings.ReplaceAll(v, ",", ".")
+	default:
+		v = strings.ReplaceAll(v, ",", "")
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %q", s)
+	}
+
+	return f * multiplier, nil
+}
+
+func guessDecimalSeparator(v string, hasSuffix bool) string {
+	lastComma, lastDot := strings.LastIndex(v, ","), strings.LastIndex(v, ".")
+
+	switch {
+	case lastComma >= 0 && lastDot >= 0:
+		if lastComma > lastDot {
+			return ","
+		}
+		return "."
+	case lastDot >= 0:
+		if strings.Count(v, ".") > 1 || (!hasSuffix && len(v)-lastDot-1 == 3) {
+			// 1.234.567 or 1.234 => thousands
+			return ","
+		}
+		return "."
+	case lastComma >= 0:
+		if strings.Count(v, ",") > 1 || (!hasSuffix && len(v)-lastComma-1 == 3) {
+			return "."
+		}
+		return ","
+	}
+
+	return "."
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
 	"github.com/rokmonster/ocr/internal/pkg/rokocr/opencvutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
 	"github.com/sirupsen/logrus"
@@ -45,3 +46,57 @@ func (controller *APIController) ScanHOH(c *gin.Context) {
 
 	c.JSON(http.StatusOK, opencvutils.HOHScan(img, controller.tessdataDir))
 }
+
+// Fingerprint - POST /api/fingerprint, hashes of the uploaded image (form file "image") in the format templates expect.
+// Optional form values: crop ("x,y,w,h" in image pixels) & hash_algo (all algorithms by default). Borders are cropped
+// off the image first, as recognition does.
+func (controller *APIController) Fingerprint(c *gin.Context) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "image is missing"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	img, err := imgutils.ReadImage(f)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	img = imgutils.CropBorders(img)
+
+	var crop *ocrschema.OCRCrop
+	if s := c.PostForm("crop"); len(s) > 0 {
+		if crop, err = ocrschema.ParseCrop(s); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	algorithms := ocrschema.HashAlgorithms
+	if algo := c.PostForm("hash_algo"); len(algo) > 0 {
+		algorithms = []string{algo}
+	}
+
+	fingerprints := make(map[string]string)
+	for _, algo := range algorithms {
+		hash, err := ocrschema.Fingerprint(img, crop, algo)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fingerprints[algo] = hash
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"width":        img.Bounds().Dx(),
+		"height":       img.Bounds().Dy(),
+		"crop":         crop,
+		"fingerprints": fingerprints,
+	})
+}
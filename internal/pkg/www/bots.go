@@ -0,0 +1,61 @@
+package www
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// screenshotReader - recognizes screenshots sent to chat bots, one at a time, so bots don't slow down jobs
+type screenshotReader struct {
+	templates   *templatestore.Store
+	tessdataDir string
+	mu          sync.Mutex
+}
+
+// botScan - rows read from a screenshot, and errors of rows (or of the whole screenshot) which couldn't be read
+type botScan struct {
+	Name     string
+	Template ocrschema.OCRTemplate
+	Rows     []ocrschema.OCRResult
+	Failed   []string
+}
+
+// read - recognizes the screenshot with loaded templates, fails if it isn't an image
+func (r *screenshotReader) read(name string, data []byte) (botScan, error) {
+	img, err := imgutils.ReadImage(bytes.NewReader(data))
+	if err != nil {
+		return botScan{}, err
+	}
+
+	r.mu.Lock()
+	results := tesseractutils.ProcessImageWithOptions(tesseractutils.NamedImage{Name: name, Image: img}, r.templates.Templates(), tesseractutils.DefaultOptions(r.tessdataDir))
+	r.mu.Unlock()
+
+	scan := botScan{Name: name}
+	for _, x := range results {
+		if len(x.Error) > 0 {
+			scan.Failed = append(scan.Failed, x.Error)
+		} else {
+			scan.Rows = append(scan.Rows, x)
+		}
+	}
+	if len(scan.Rows) > 0 {
+		scan.Template = r.template(scan.Rows[0].Template)
+	}
+	return scan, nil
+}
+
+// template - loaded template of the title, only the title is known if it was removed since
+func (r *screenshotReader) template(title string) ocrschema.OCRTemplate {
+	for _, t := range r.templates.Templates() {
+		if t.Title == title {
+			return t
+		}
+	}
+	return ocrschema.OCRTemplate{Title: title}
+}
@@ -0,0 +1,796 @@
+package www
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/resultsdb"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	discordAPI = "https://discord.com/api/v10"
+	// discordMaxScreenshots - attachments /scan takes
+	discordMaxScreenshots = 5
+	// discordMaxEmbeds - rows shown as embeds, more are sent as CSV files
+	discordMaxEmbeds = 5
+	// discordMaxFields - Discord limit of embed fields
+	discordMaxFields = 25
+	// discordMaxContent - Discord limit of message text
+	discordMaxContent = 2000
+	// discordLeaderboardSize - governors listed by /leaderboard
+	discordLeaderboardSize = 10
+	discordMaxDownload     = 25 << 20
+	// discordReplyTimeout - interaction tokens expire after 15 minutes, the reply can't be edited later
+	discordReplyTimeout = 14 * time.Minute
+
+	discordColor        = 0x2ecc71
+	discordColorWarning = 0xe67e22
+)
+
+// interaction, response & command option types of the Discord API
+const (
+	discordInteractionPing    = 1
+	discordInteractionCommand = 2
+
+	discordResponsePong     = 1
+	discordResponseMessage  = 4
+	discordResponseDeferred = 5
+
+	discordOptionString     = 3
+	discordOptionBoolean    = 5
+	discordOptionAttachment = 11
+
+	// discordEphemeral - message only the user who ran the command sees
+	discordEphemeral = 1 << 6
+)
+
+// DiscordBotOptions - Discord application the bot runs as, and the results database its commands query
+type DiscordBotOptions struct {
+	Token         string
+	ApplicationID string
+	// PublicKey - hex encoded key interactions are signed with
+	PublicKey string
+	// Guilds - servers which can use the bot, commands are registered in each of them. Any server (global commands) if empty.
+	Guilds []string
+	// ResultsDB - results database scans are recorded into, /leaderboard & /governor query it (see rok-scanner -db)
+	ResultsDB string
+	// ResultsKey - field results are indexed by (governor id)
+	ResultsKey string
+}
+
+// DiscordBot - slash commands of a Discord application: /scan recognizes attached screenshots and records them into
+// the results database, /leaderboard & /governor query it. Discord posts interactions to the server (see Interactions),
+// no gateway connection is needed.
+type DiscordBot struct {
+	opts      DiscordBotOptions
+	publicKey ed25519.PublicKey
+	guilds    map[string]bool
+	reader    *screenshotReader
+	retry     retryutils.Options
+	client    *http.Client
+	// db - results database is opened only while a command uses it, so rok-scanner can record into the same file
+	db sync.Mutex
+}
+
+func NewDiscordBot(opts DiscordBotOptions, templates *templatestore.Store, tessdata string) (*DiscordBot, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(opts.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Discord public key %q", opts.PublicKey)
+	}
+
+	guilds := make(map[string]bool)
+	for _, id := range opts.Guilds {
+		if id = strings.TrimSpace(id); len(id) > 0 {
+			guilds[id] = true
+		}
+	}
+
+	return &DiscordBot{
+		opts:      opts,
+		publicKey: key,
+		guilds:    guilds,
+		reader:    &screenshotReader{templates: templates, tessdataDir: tessdata},
+		retry:     retryutils.Options{MaxRetries: 3, Backoff: time.Second},
+		client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+type discordCommand struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Options     []discordCommandOption `json:"options,omitempty"`
+}
+
+type discordCommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+func discordCommands() []discordCommand {
+	scan := discordCommand{Name: "scan", Description: "Read stats from screenshots"}
+	for i := 1; i <= discordMaxScreenshots; i++ {
+		option := discordCommandOption{Type: discordOptionAttachment, Name: fmt.Sprintf("screenshot%d", i), Description: "Screenshot"}
+		if i == 1 {
+			option.Name, option.Required = "screenshot", true
+		}
+		scan.Options = append(scan.Options, option)
+	}
+	scan.Options = append(scan.Options, discordCommandOption{Type: discordOptionBoolean, Name: "csv", Description: "Reply with CSV file"})
+
+	return []discordCommand{scan, {
+		Name:        "leaderboard",
+		Description: "Top governors of the latest scan",
+		Options: []discordCommandOption{
+			{Type: discordOptionString, Name: "field", Description: "Field to rank by (default: first numeric column)"},
+			{Type: discordOptionString, Name: "template", Description: "Latest scan of this template (default: latest scan)"},
+		},
+	}, {
+		Name:        "governor",
+		Description: "Stats of a governor across scans",
+		Options: []discordCommandOption{
+			{Type: discordOptionString, Name: "id", Description: "Governor id", Required: true},
+		},
+	}}
+}
+
+// RegisterCommands - registers (overwrites) slash commands of the application, in -discord-guilds or globally.
+// Global commands may take up to an hour to show up, guild ones are available right away.
+func (b *DiscordBot) RegisterCommands(ctx context.Context) error {
+	body, err := json.Marshal(discordCommands())
+	if err != nil {
+		return err
+	}
+
+	paths := []string{fmt.Sprintf("/applications/%v/commands", b.opts.ApplicationID)}
+	if len(b.guilds) > 0 {
+		paths = nil
+		for guild := range b.guilds {
+			paths = append(paths, fmt.Sprintf("/applications/%v/guilds/%v/commands", b.opts.ApplicationID, guild))
+		}
+	}
+	for _, path := range paths {
+		if err := b.request(ctx, http.MethodPut, path, "application/json", body, true); err != nil {
+			return fmt.Errorf("failed to register commands: %w", err)
+		}
+	}
+	log.Infof("Discord bot: registered /scan, /leaderboard & /governor (%d guilds)", len(b.guilds))
+	return nil
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type discordAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	URL         string `json:"url"`
+}
+
+type discordInteraction struct {
+	Type    int    `json:"type"`
+	Token   string `json:"token"`
+	GuildID string `json:"guild_id"`
+	Member  *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"options"`
+		Resolved struct {
+			Attachments map[string]discordAttachment `json:"attachments"`
+		} `json:"resolved"`
+	} `json:"data"`
+}
+
+func (i discordInteraction) user() discordUser {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	if i.User != nil {
+		return *i.User
+	}
+	return discordUser{}
+}
+
+func (i discordInteraction) option(name string) interface{} {
+	for _, o := range i.Data.Options {
+		if o.Name == name {
+			return o.Value
+		}
+	}
+	return nil
+}
+
+func (i discordInteraction) stringOption(name string) string {
+	s, _ := i.option(name).(string)
+	return strings.TrimSpace(s)
+}
+
+// attachments - screenshots attached to /scan, in order of the options
+func (i discordInteraction) attachments() []discordAttachment {
+	var list []discordAttachment
+	for _, o := range i.Data.Options {
+		if id, ok := o.Value.(string); ok && strings.HasPrefix(o.Name, "screenshot") {
+			if a, ok := i.Data.Resolved.Attachments[id]; ok {
+				list = append(list, a)
+			}
+		}
+	}
+	return list
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+type discordMessage struct {
+	Content     string              `json:"content"`
+	Embeds      []discordEmbed      `json:"embeds"`
+	Flags       int                 `json:"flags,omitempty"`
+	Attachments []discordFileUpload `json:"attachments,omitempty"`
+	files       [][]byte
+}
+
+type discordFileUpload struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+}
+
+func (m *discordMessage) attach(name string, data []byte) {
+	m.Attachments = append(m.Attachments, discordFileUpload{ID: len(m.files), Filename: name})
+	m.files = append(m.files, data)
+}
+
+func discordError(format string, args ...interface{}) discordMessage {
+	return discordMessage{Content: fmt.Sprintf(format, args...), Flags: discordEphemeral}
+}
+
+// Interactions - POST /discord/interactions, the Interactions Endpoint URL of the application. Requests are verified with
+// the public key, /scan is answered once screenshots are recognized, other commands right away.
+func (b *DiscordBot) Interactions(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil || !b.verify(c.Request, body) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var i discordInteraction
+	if err := json.Unmarshal(body, &i); err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if i.Type == discordInteractionPing {
+		c.JSON(http.StatusOK, gin.H{"type": discordResponsePong})
+		return
+	}
+	if i.Type != discordInteractionCommand {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	respond := func(m discordMessage) {
+		c.JSON(http.StatusOK, gin.H{"type": discordResponseMessage, "data": m})
+	}
+	if len(b.guilds) > 0 && !b.guilds[i.GuildID] {
+		respond(discordError("This server can't use the bot."))
+		return
+	}
+
+	log.Infof("Discord bot: /%v by %v", i.Data.Name, i.user().Username)
+	switch i.Data.Name {
+	case "scan":
+		// recognition takes longer than the 3 seconds Discord waits for the response
+		c.JSON(http.StatusOK, gin.H{"type": discordResponseDeferred})
+		go b.scan(i)
+	case "leaderboard":
+		respond(b.leaderboard(i))
+	case "governor":
+		respond(b.governor(i))
+	default:
+		respond(discordError("Unknown command /%v", i.Data.Name))
+	}
+}
+
+func (b *DiscordBot) verify(r *http.Request, body []byte) bool {
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(b.publicKey, append([]byte(r.Header.Get("X-Signature-Timestamp")), body...), sig)
+}
+
+// scan - recognizes screenshots of /scan, records them into the results database & replies with the rows
+// (or CSV files, one per template)
+func (b *DiscordBot) scan(i discordInteraction) {
+	ctx, cancel := context.WithTimeout(context.Background(), discordReplyTimeout)
+	defer cancel()
+
+	var scans []botScan
+	var problems []string
+	for _, a := range i.attachments() {
+		data, err := b.download(ctx, a)
+		if err == nil {
+			var scan botScan
+			if scan, err = b.reader.read(a.Filename, data); err == nil {
+				scans = append(scans, scan)
+				if len(scan.Rows) == 0 {
+					problems = append(problems, fmt.Sprintf("`%v`: %v", a.Filename, strings.Join(scan.Failed, ", ")))
+				} else if len(scan.Failed) > 0 {
+					problems = append(problems, fmt.Sprintf("`%v`: %d rows couldn't be read", a.Filename, len(scan.Failed)))
+				}
+				continue
+			}
+		}
+		problems = append(problems, fmt.Sprintf("`%v`: %v", a.Filename, err))
+	}
+
+	if err := b.record(scans, i.user()); err != nil {
+		log.Warnf("Discord bot: scan wasn't recorded: %v", err)
+		problems = append(problems, fmt.Sprintf("Not saved for /leaderboard & /governor: %v", err))
+	}
+
+	reply := b.scanReply(scans, i.option("csv") == true)
+	if len(problems) > 0 {
+		reply.Content = strings.TrimSpace(reply.Content + "\n" + strings.Join(problems, "\n"))
+	}
+	if len(reply.Content) > discordMaxContent {
+		reply.Content = reply.Content[:discordMaxContent-1] + "…"
+	}
+
+	if err := b.editReply(ctx, i, reply); err != nil {
+		log.Warnf("Discord bot: failed to reply to /scan: %v", err)
+	}
+}
+
+func (b *DiscordBot) scanReply(scans []botScan, csv bool) discordMessage {
+	var rows []ocrschema.OCRResult
+	for _, s := range scans {
+		rows = append(rows, s.Rows...)
+	}
+	if len(rows) == 0 {
+		return discordMessage{Content: "No stats could be read from the screenshots."}
+	}
+
+	if !csv && len(rows) <= discordMaxEmbeds {
+		var reply discordMessage
+		for _, s := range scans {
+			for _, r := range s.Rows {
+				reply.Embeds = append(reply.Embeds, resultEmbed(s.Template, r, s.Name, nil))
+			}
+		}
+		return reply
+	}
+
+	// one CSV per template, columns differ
+	var order []string
+	byTemplate := make(map[string][]ocrschema.OCRResult)
+	templates := make(map[string]ocrschema.OCRTemplate)
+	for _, s := range scans {
+		title := s.Template.Title
+		if _, ok := templates[title]; !ok {
+			order = append(order, title)
+			templates[title] = s.Template
+		}
+		byTemplate[title] = append(byTemplate[title], s.Rows...)
+	}
+
+	reply := discordMessage{Content: fmt.Sprintf("Read %d rows from %d screenshots.", len(rows), len(scans))}
+	for _, title := range order {
+		var buf bytes.Buffer
+		rokocr.WriteCSV(byTemplate[title], templates[title], &buf)
+		reply.attach(csvFilename(title), buf.Bytes())
+	}
+	return reply
+}
+
+// resultEmbed - fields of the row, the ones read with low confidence are marked. Numeric fields show the change
+// since the previous result of the governor, if there's one.
+func resultEmbed(template ocrschema.OCRTemplate, r ocrschema.OCRResult, filename string, previous *ocrschema.OCRResult) discordEmbed {
+	embed := discordEmbed{Title: template.Title, Description: fmt.Sprintf("`%v`", filename), Color: discordColor}
+	for _, c := range resultColumns(template, r) {
+		value := strings.TrimSpace(ocrschema.FormatValue(r.Data[c.Field]))
+		if len(value) == 0 || len(embed.Fields) == discordMaxFields {
+			continue
+		}
+		if r.Fields[c.Field].LowConfidence {
+			value += " ⚠"
+			embed.Color = discordColorWarning
+		}
+		if previous != nil {
+			if change, ok := rokocr.Change(previous.Data[c.Field], r.Data[c.Field]); ok && change != 0 {
+				value += fmt.Sprintf(" (%v)", formatChange(change))
+			}
+		}
+		embed.Fields = append(embed.Fields, discordField{Name: c.Title, Value: value, Inline: true})
+	}
+	return embed
+}
+
+// resultColumns - export columns of the template, all fields by name if the template isn't loaded anymore
+func resultColumns(template ocrschema.OCRTemplate, r ocrschema.OCRResult) []ocrschema.OCRTableField {
+	if len(template.OCRSchema) > 0 {
+		return rokocr.ExportColumns(template, []ocrschema.OCRResult{r})
+	}
+
+	var keys []string
+	for k := range r.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var columns []ocrschema.OCRTableField
+	for _, k := range keys {
+		columns = append(columns, ocrschema.OCRTableField{Title: k, Field: k})
+	}
+	return columns
+}
+
+func csvFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, title)
+	if len(name) == 0 {
+		name = "results"
+	}
+	return name + ".csv"
+}
+
+// record - rows of every template are recorded as a run of the results database
+func (b *DiscordBot) record(scans []botScan, user discordUser) error {
+	var order []string
+	byTemplate := make(map[string]botScan)
+	for _, s := range scans {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		x, ok := byTemplate[s.Template.Title]
+		if !ok {
+			order = append(order, s.Template.Title)
+			x.Template = s.Template
+		}
+		x.Rows = append(x.Rows, s.Rows...)
+		byTemplate[s.Template.Title] = x
+	}
+	if len(order) == 0 || len(b.opts.ResultsDB) == 0 {
+		return nil
+	}
+
+	return b.withResults(func(store *resultsdb.Store) error {
+		for _, title := range order {
+			x := byTemplate[title]
+			run, err := store.StartRun(x.Template, "discord:"+user.Username, b.opts.ResultsKey, ocrschema.ScanTags{})
+			if err != nil {
+				return err
+			}
+			if err := store.AddResults(&run, x.Template, x.Rows...); err != nil {
+				return err
+			}
+			if err := store.FinishRun(&run); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *DiscordBot) withResults(fn func(store *resultsdb.Store) error) error {
+	if len(b.opts.ResultsDB) == 0 {
+		return errors.New("no results database (-results-db)")
+	}
+
+	b.db.Lock()
+	defer b.db.Unlock()
+	store, err := resultsdb.Open(b.opts.ResultsDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return fn(store)
+}
+
+// leaderboard - top governors of the latest scan (of the template), by the field or the first numeric column
+func (b *DiscordBot) leaderboard(i discordInteraction) discordMessage {
+	var reply discordMessage
+	err := b.withResults(func(store *resultsdb.Store) error {
+		runs, err := store.Runs()
+		if err != nil {
+			return err
+		}
+
+		var run *resultsdb.Run
+		for k := range runs {
+			if runs[k].Results > 0 && (len(i.stringOption("template")) == 0 || strings.EqualFold(runs[k].Template, i.stringOption("template"))) {
+				run = &runs[k]
+				break
+			}
+		}
+		if run == nil {
+			reply = discordError("No scans yet, run /scan first.")
+			return nil
+		}
+
+		template := b.reader.template(run.Template)
+		column, ok := leaderboardColumn(template, run.KeyField, i.stringOption("field"))
+		switch {
+		case !ok && len(i.stringOption("field")) == 0:
+			reply = discordError("%v has no numeric fields, pick one with the field option.", template.Title)
+			return nil
+		case !ok:
+			reply = discordError("Unknown field %q, fields of %v: %v", i.stringOption("field"), template.Title, strings.Join(template.ColumnKeys(), ", "))
+			return nil
+		}
+
+		results, err := store.Results(run.ID)
+		if err != nil {
+			return err
+		}
+		ranked := rokocr.Leaderboard(results, template, run.KeyField, column.Field)
+		if len(ranked) == 0 {
+			reply = discordError("No governor of the scan has %v.", column.Title)
+			return nil
+		}
+
+		var lines []string
+		for n, x := range ranked[:min(discordLeaderboardSize, len(ranked))] {
+			lines = append(lines, fmt.Sprintf("%d. %v — **%v**", n+1, governorLabel(template, x.Result, run.KeyField), ocrschema.FormatValue(x.Result.Data[column.Field])))
+		}
+		reply.Embeds = []discordEmbed{{
+			Title:       fmt.Sprintf("Top %v", column.Title),
+			Description: strings.Join(lines, "\n"),
+			Color:       discordColor,
+			Footer:      &discordFooter{Text: runLabel(*run)},
+		}}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Discord bot: /leaderboard failed: %v", err)
+		return discordError("Failed to read scans: %v", err)
+	}
+	return reply
+}
+
+// leaderboardColumn - column of the field (key or title), the first numeric one (but the key) by default
+func leaderboardColumn(template ocrschema.OCRTemplate, keyField, field string) (ocrschema.OCRTableField, bool) {
+	if len(field) == 0 {
+		for _, c := range rokocr.NumericColumns(template) {
+			if c.Field != keyField {
+				return c, true
+			}
+		}
+		return ocrschema.OCRTableField{}, false
+	}
+
+	for _, c := range rokocr.ExportColumns(template, nil) {
+		if strings.EqualFold(c.Field, field) || strings.EqualFold(c.Title, field) {
+			return c, true
+		}
+	}
+	// templates which were removed since have no columns
+	return ocrschema.OCRTableField{Title: field, Field: field}, len(template.OCRSchema) == 0
+}
+
+// governorLabel - name & key of the governor, name is the first text column which isn't the key
+func governorLabel(template ocrschema.OCRTemplate, r ocrschema.OCRResult, keyField string) string {
+	key := template.KeyOf(r, keyField)
+	for _, c := range rokocr.ExportColumns(template, nil) {
+		if s, ok := template.OCRSchema[c.Field]; ok && c.Field != keyField && (s.Type == "" || s.Type == ocrschema.TypeString) {
+			if name := strings.TrimSpace(ocrschema.FormatValue(r.Data[c.Field])); len(name) > 0 {
+				return fmt.Sprintf("%v (%v)", name, key)
+			}
+		}
+	}
+	return key
+}
+
+// runLabel - template & date of the scan, with kingdom & alliance it was tagged with
+func runLabel(run resultsdb.Run) string {
+	parts := []string{run.Template, run.Started.Format(ocrschema.ScanDateFormat)}
+	if run.Tags != nil {
+		if len(run.Tags.Date) > 0 {
+			parts[1] = run.Tags.Date
+		}
+		if len(run.Tags.Kingdom) > 0 {
+			parts = append(parts, "kingdom "+run.Tags.Kingdom)
+		}
+		if len(run.Tags.Alliance) > 0 {
+			parts = append(parts, run.Tags.Alliance)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// governor - latest stats of the governor, numeric ones with the change since the previous scan of the same template
+func (b *DiscordBot) governor(i discordInteraction) discordMessage {
+	id := i.stringOption("id")
+
+	var history []resultsdb.HistoryEntry
+	err := b.withResults(func(store *resultsdb.Store) error {
+		keys := []string{id}
+		for _, t := range b.reader.templates.Templates() {
+			if s, ok := t.OCRSchema[b.opts.ResultsKey]; ok {
+				keys = append(keys, s.NormalizeKey(id))
+			}
+		}
+		for _, key := range keys {
+			var err error
+			if history, err = store.History(key); err != nil || len(history) > 0 {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Discord bot: /governor failed: %v", err)
+		return discordError("Failed to read scans: %v", err)
+	}
+	if len(history) == 0 {
+		return discordError("Governor %v isn't in any scan.", id)
+	}
+
+	latest := history[len(history)-1]
+	var previous *resultsdb.HistoryEntry
+	for k := len(history) - 2; k >= 0; k-- {
+		if history[k].Run.Template == latest.Run.Template {
+			previous = &history[k]
+			break
+		}
+	}
+
+	template := b.reader.template(latest.Run.Template)
+	description := fmt.Sprintf("Scanned %d times", len(history))
+	var before *ocrschema.OCRResult
+	if previous != nil {
+		before = &previous.Result
+		description += fmt.Sprintf(", changes since %v", previous.Run.Started.Format(ocrschema.ScanDateFormat))
+	}
+
+	embed := resultEmbed(template, latest.Result, latest.Result.Filename, before)
+	embed.Title = governorLabel(template, latest.Result, latest.Run.KeyField)
+	embed.Description = description
+	embed.Footer = &discordFooter{Text: runLabel(latest.Run)}
+	return discordMessage{Embeds: []discordEmbed{embed}}
+}
+
+func formatChange(change float64) string {
+	if change > 0 {
+		return "+" + ocrschema.FormatValue(change)
+	}
+	return ocrschema.FormatValue(change)
+}
+
+func (b *DiscordBot) download(ctx context.Context, a discordAttachment) ([]byte, error) {
+	if a.Size > discordMaxDownload {
+		return nil, fmt.Errorf("file is bigger than %d MB", discordMaxDownload>>20)
+	}
+	if len(a.ContentType) > 0 && !strings.HasPrefix(a.ContentType, "image/") {
+		return nil, errors.New("not a screenshot, attach PNG or JPEG images")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &retryutils.StatusError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, discordMaxDownload))
+}
+
+// editReply - replaces "thinking..." of the deferred response, files are attached as multipart
+func (b *DiscordBot) editReply(ctx context.Context, i discordInteraction, m discordMessage) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/webhooks/%v/%v/messages/@original", b.opts.ApplicationID, i.Token)
+	if len(m.files) == 0 {
+		return b.request(ctx, http.MethodPatch, path, "application/json", payload, false)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("payload_json", string(payload))
+	for n, data := range m.files {
+		part, err := w.CreateFormFile(fmt.Sprintf("files[%d]", n), filepath.Base(m.Attachments[n].Filename))
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return b.request(ctx, http.MethodPatch, path, w.FormDataContentType(), body.Bytes(), false)
+}
+
+// request - Discord API request, with the bot token if auth is set
+func (b *DiscordBot) request(ctx context.Context, method, path, contentType string, body []byte, auth bool) error {
+	return retryutils.Do(ctx, b.retry, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, method, discordAPI+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		if auth {
+			req.Header.Set("Authorization", "Bot "+b.opts.Token)
+		}
+
+		resp, err := b.client.Do(req)
+		if err != nil {
+			// the error includes the URL, and the interaction token with it
+			return withoutURL(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			data, _ := io.ReadAll(resp.Body)
+			err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+			if resp.StatusCode == http.StatusTooManyRequests {
+				var limit struct {
+					RetryAfter float64 `json:"retry_after"`
+				}
+				if json.Unmarshal(data, &limit) == nil && limit.RetryAfter > 0 {
+					select {
+					case <-ctx.Done():
+					case <-time.After(time.Duration(limit.RetryAfter * float64(time.Second))):
+					}
+				}
+				return retryutils.Retryable(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,447 @@
+package www
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	rokocrv1 "github.com/rokmonster/ocr/api/rokocr/v1"
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/quality"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/storage"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// BasicAuthenticator - checks credentials of API clients, see oauth2Middleware.AuthenticateBasic
+type BasicAuthenticator interface {
+	AuthenticateBasic(name, password string) (middlewares.OAuthClientInfo, error)
+}
+
+// GRPCService - OCR-as-a-service of api/rokocr/v1, jobs are shared with the HTTP API
+type GRPCService struct {
+	rokocrv1.UnimplementedOCRServer
+
+	jobs      *JobsController
+	templates *templatestore.Store
+	auth      BasicAuthenticator
+}
+
+func NewGRPCService(jobs *JobsController, templates *templatestore.Store, auth BasicAuthenticator) *GRPCService {
+	return &GRPCService{jobs: jobs, templates: templates, auth: auth}
+}
+
+// NewGRPCServer - gRPC server with the service registered, calls are authenticated with basic auth metadata
+func NewGRPCServer(service *GRPCService, opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.MaxRecvMsgSize(32 << 20),
+		grpc.ChainUnaryInterceptor(service.unaryAuth),
+		grpc.ChainStreamInterceptor(service.streamAuth),
+	}, opts...)...)
+	rokocrv1.RegisterOCRServer(srv, service)
+	return srv
+}
+
+type grpcUserKey struct{}
+
+// authenticate - user of "authorization: Basic ..." metadata
+func (s *GRPCService) authenticate(ctx context.Context) (context.Context, error) {
+	var name, password string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			if encoded, ok := strings.CutPrefix(values[0], "Basic "); ok {
+				if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+					name, password, _ = strings.Cut(string(decoded), ":")
+				}
+			}
+		}
+	}
+
+	user, err := s.auth.AuthenticateBasic(name, password)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "sign in required, use basic auth of a local account")
+	}
+	return context.WithValue(ctx, grpcUserKey{}, user), nil
+}
+
+func (s *GRPCService) unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticatedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (s *GRPCService) streamAuth(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+func grpcUser(ctx context.Context) middlewares.OAuthClientInfo {
+	user, _ := ctx.Value(grpcUserKey{}).(middlewares.OAuthClientInfo)
+	return user
+}
+
+// grpcClient - who the call comes from, for rate limits & fair queueing: signed in user, or IP
+func grpcClient(ctx context.Context) string {
+	if key := grpcUser(ctx).Key(); len(key) > 0 {
+		return key
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func (s *GRPCService) ListTemplates(context.Context, *rokocrv1.ListTemplatesRequest) (*rokocrv1.ListTemplatesResponse, error) {
+	var response rokocrv1.ListTemplatesResponse
+	for _, t := range s.templates.Templates() {
+		template := &rokocrv1.Template{
+			Title:   t.Title,
+			Version: t.Version,
+			Author:  t.Author,
+			Width:   int32(t.Width),
+			Height:  int32(t.Height),
+		}
+		for name := range t.OCRSchema {
+			template.Fields = append(template.Fields, name)
+		}
+		response.Templates = append(response.Templates, template)
+	}
+	return &response, nil
+}
+
+func (s *GRPCService) Recognize(stream rokocrv1.OCR_RecognizeServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	templates := s.templates.Templates()
+	workers := 1
+	if options := first.GetOptions(); options != nil {
+		if templates, err = selectTemplates(templates, options.Templates); err != nil {
+			return err
+		}
+		workers = max(1, min(int(options.Workers), runtime.NumCPU()))
+	}
+
+	// next image is received only when a worker picks up the previous one
+	in := make(chan tesseractutils.NamedImage)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		msg := first
+		for {
+			if image := msg.GetImage(); image != nil {
+				img, err := imgutils.ReadImage(bytes.NewReader(image.Data))
+				if err != nil {
+					recvErr <- status.Errorf(codes.InvalidArgument, "can't decode %v: %v", image.Name, err)
+					cancel()
+					return
+				}
+				select {
+				case in <- tesseractutils.NamedImage{Name: image.Name, Image: img}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if msg, err = stream.Recv(); err != nil {
+				if !errors.Is(err, io.EOF) {
+					recvErr <- err
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+
+	opts := tesseractutils.DefaultOptions(s.jobs.tessdataDir)
+	for result := range tesseractutils.ProcessStreamWithOptions(ctx, in, templates, workers, opts) {
+		if len(result.Error) > 0 {
+			observeFailure(streamFailure(result))
+		} else {
+			observeResult(result.Template, result)
+		}
+		if err := stream.Send(toResult(result)); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// streamFailure - why an image of the stream failed (stream reports failures as OCRResult.Error)
+func streamFailure(r ocrschema.OCRResult) error {
+	if len(r.Quality) > 0 {
+		return fmt.Errorf("%w: %s", quality.ErrLowQuality, strings.Join(r.Quality, ", "))
+	}
+	return fmt.Errorf("%w: %v", tesseractutils.ErrNoTemplateMatch, r.Error)
+}
+
+// selectTemplates - templates with given titles, all of them if none is given
+func selectTemplates(templates []ocrschema.OCRTemplate, titles []string) ([]ocrschema.OCRTemplate, error) {
+	if len(titles) == 0 {
+		return templates, nil
+	}
+
+	var selected []ocrschema.OCRTemplate
+	for _, title := range titles {
+		found := false
+		for _, t := range templates {
+			if t.Title == title {
+				selected = append(selected, t)
+				found = true
+			}
+		}
+		if !found {
+			return nil, status.Errorf(codes.NotFound, "template %q not found", title)
+		}
+	}
+	return selected, nil
+}
+
+func (s *GRPCService) CreateJob(stream rokocrv1.OCR_CreateJobServer) error {
+	ctx := stream.Context()
+	user := grpcUser(ctx)
+
+	name := fmt.Sprintf("gRPC Job: %v", time.Now().Format("2006-01-02 15:04:05"))
+	priority := PriorityNormal
+
+	var id uint64
+	files := 0
+	fail := func(err error) error {
+		if id > 0 {
+			s.jobs.deleteJob(id)
+		}
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		if options := msg.GetOptions(); options != nil {
+			if id > 0 {
+				return fail(status.Error(codes.InvalidArgument, "options must be sent before images"))
+			}
+			if len(options.Name) > 0 {
+				name = options.Name
+			}
+			if priority, err = ParsePriority(options.Priority); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			priority = UserPriority(priority, user.Admin)
+			continue
+		}
+
+		image := msg.GetImage()
+		if image == nil {
+			continue
+		}
+		if id == 0 {
+			if id, err = s.jobs.createJob(name, user); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		key := storage.Key(jobMediaPrefix(id), filepath.Base(image.Name))
+		if err := s.jobs.storage.Put(ctx, key, bytes.NewReader(image.Data)); err != nil {
+			return fail(status.Errorf(codes.Internal, "failed to save %v: %v", image.Name, err))
+		}
+		files++
+	}
+
+	if id == 0 {
+		return status.Error(codes.InvalidArgument, "nothing to scan, no images were sent")
+	}
+
+	if err := s.jobs.enqueue(id, grpcClient(ctx), priority); err != nil {
+		var limited *RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			return fail(status.Error(codes.ResourceExhausted, err.Error()))
+		case errors.Is(err, ErrQueueFull), errors.Is(err, ErrQueueClosed):
+			return fail(status.Error(codes.Unavailable, err.Error()))
+		default:
+			return fail(status.Error(codes.Internal, err.Error()))
+		}
+	}
+	log.Infof("[Job: %04d] Created via gRPC with %v files", id, files)
+
+	return stream.SendAndClose(s.toJob(s.jobs.getJob(id)))
+}
+
+// accessibleJob - job of the id, if the user can see it
+func (s *GRPCService) accessibleJob(ctx context.Context, id uint64) (*OCRJob, error) {
+	job := s.jobs.getJob(id)
+	if job == nil || !job.Accessible(grpcUser(ctx)) {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	return job, nil
+}
+
+func (s *GRPCService) GetJob(ctx context.Context, req *rokocrv1.GetJobRequest) (*rokocrv1.Job, error) {
+	job, err := s.accessibleJob(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toJob(job), nil
+}
+
+func (s *GRPCService) WatchJob(req *rokocrv1.GetJobRequest, stream rokocrv1.OCR_WatchJobServer) error {
+	job, err := s.accessibleJob(stream.Context(), req.Id)
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := s.jobs.progress.subscribe(job.ID)
+	defer unsubscribe()
+
+	// job might have finished before we subscribed
+	if job.Finished() {
+		return stream.Send(&rokocrv1.JobProgress{Job: job.ID, State: job.State, Status: job.Status})
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case e := <-events:
+			if err := stream.Send(toProgress(e)); err != nil {
+				return err
+			}
+			if e.State == JobCompleted || e.State == JobFailed {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *GRPCService) toJob(job *OCRJob) *rokocrv1.Job {
+	response := &rokocrv1.Job{
+		Id:       job.ID,
+		Name:     job.Name,
+		State:    job.State,
+		Status:   job.Status,
+		Template: job.Template.Title,
+		Files:    int32(len(s.jobs.getJobFiles(job.ID))),
+	}
+	if job.State == JobQueued {
+		response.Position = int32(s.jobs.queue.Position(job.ID))
+	}
+	for _, r := range job.Results {
+		response.Results = append(response.Results, toResult(r))
+	}
+	return response
+}
+
+func toProgress(e ProgressEvent) *rokocrv1.JobProgress {
+	progress := &rokocrv1.JobProgress{
+		Job:    e.Job,
+		Index:  int32(e.Index),
+		Total:  int32(e.Total),
+		State:  e.State,
+		Status: e.Status,
+	}
+	switch {
+	case e.result != nil:
+		progress.Result = toResult(*e.result)
+	case len(e.Filename) > 0:
+		progress.Result = &rokocrv1.Result{Filename: e.Filename, Template: e.Template, Error: e.Error}
+	}
+	return progress
+}
+
+func toResult(r ocrschema.OCRResult) *rokocrv1.Result {
+	result := &rokocrv1.Result{
+		Filename: r.Filename,
+		Row:      int32(r.Row),
+		Panel:    int32(r.Panel),
+		Page:     int32(r.Page),
+		Template: r.Template,
+		Fields:   make(map[string]*rokocrv1.Field, len(r.Data)),
+		Took:     durationpb.New(r.Took),
+		Quality:  r.Quality,
+		Error:    r.Error,
+	}
+
+	// data holds the final values (computed fields too), fields - how they were recognized
+	for name, value := range r.Data {
+		field := toField(value)
+		if f, ok := r.Fields[name]; ok {
+			field.Raw = f.Text
+			field.Confidence = f.Confidence
+			field.LowConfidence = f.LowConfidence
+		}
+		result.Fields[name] = field
+	}
+	return result
+}
+
+func toField(value interface{}) *rokocrv1.Field {
+	switch v := value.(type) {
+	case nil:
+		return &rokocrv1.Field{}
+	case int:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Number{Number: int64(v)}}
+	case int64:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Number{Number: v}}
+	case float64:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Decimal{Decimal: v}}
+	case bool:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Flag{Flag: v}}
+	case string:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Text{Text: v}}
+	default:
+		return &rokocrv1.Field{Value: &rokocrv1.Field_Text{Text: ocrschema.FormatValue(v)}}
+	}
+}
@@ -0,0 +1,53 @@
+package www
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController - liveness & readiness probes (e.g. of Kubernetes or docker compose), no auth required
+type HealthController struct {
+	mu     sync.Mutex
+	checks map[string]func() error
+}
+
+func NewHealthController() *HealthController {
+	return &HealthController{checks: make(map[string]func() error)}
+}
+
+// AddCheck - readiness check, server isn't ready while it returns an error
+func (controller *HealthController) AddCheck(name string, check func() error) {
+	controller.mu.Lock()
+	defer controller.mu.Unlock()
+	controller.checks[name] = check
+}
+
+// Healthz - GET /healthz, process is alive (serving HTTP)
+func (controller *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz - GET /readyz, all checks pass; 503 with failed checks otherwise (e.g. while shutting down)
+func (controller *HealthController) Readyz(c *gin.Context) {
+	controller.mu.Lock()
+	checks := make(map[string]func() error, len(controller.checks))
+	for name, check := range controller.checks {
+		checks[name] = check
+	}
+	controller.mu.Unlock()
+
+	failed := gin.H{}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			failed[name] = err.Error()
+		}
+	}
+
+	if len(failed) > 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": failed})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,177 @@
+package www
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/storage"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/urlinput"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
+	log "github.com/sirupsen/logrus"
+)
+
+// CreateJobAPI - POST /api/jobs, multipart form with one or more "file" images and/or "url" links to download
+// (only of allowed hosts, e.g. Discord attachments), optional "name". Links are downloaded & recognized in background,
+// poll GET /api/jobs/:id for status & results.
+func (controller *JobsController) CreateJobAPI(c *gin.Context) {
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["file"]
+	} else if !errors.Is(err, http.ErrNotMultipart) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid multipart form: %v", err)})
+		return
+	}
+
+	links := c.PostFormArray("url")
+	for _, link := range links {
+		if err := controller.Links.Check(link); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if len(files) == 0 && len(links) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "nothing to scan, expected \"file\" or \"url\" form fields"})
+		return
+	}
+
+	priority, err := ParsePriority(c.PostForm("priority"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	priority = UserPriority(priority, middlewares.CurrentUser(c).Admin)
+
+	name := c.PostForm("name")
+	if len(name) == 0 {
+		name = fmt.Sprintf("API Job: %v", time.Now().Format("2006-01-02 15:04:05"))
+	}
+
+	id, err := controller.createJob(name, middlewares.CurrentUser(c))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, file := range files {
+		if _, err := controller.saveUpload(c.Request.Context(), id, file); err != nil {
+			controller.deleteJob(id)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save %v: %v", file.Filename, err)})
+			return
+		}
+	}
+
+	if len(links) > 0 {
+		// downloads are slow, the job is queued once they are done
+		_ = controller.updateJobState(id, JobPending, "Downloading")
+		go controller.fetchAndEnqueue(id, links, clientOf(c), priority)
+		log.Infof("[Job: %04d] Created via API with %v files, downloading %v links", id, len(files), len(links))
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":    id,
+			"state": JobPending,
+			"url":   fmt.Sprintf("/api/jobs/%v", id),
+		})
+		return
+	}
+
+	if err := controller.enqueue(id, clientOf(c), priority); err != nil {
+		controller.deleteJob(id)
+
+		var limited *RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrQueueFull), errors.Is(err, ErrQueueClosed):
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	log.Infof("[Job: %04d] Created via API with %v files", id, len(files))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":       id,
+		"state":    JobQueued,
+		"position": controller.queue.Position(id),
+		"url":      fmt.Sprintf("/api/jobs/%v", id),
+	})
+}
+
+// GetJobAPI - GET /api/jobs/:id, job state and results so far
+func (controller *JobsController) GetJobAPI(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 0, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job := controller.getJob(id)
+	if job == nil || !job.Accessible(middlewares.CurrentUser(c)) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	response := gin.H{
+		"id":       job.ID,
+		"name":     job.Name,
+		"state":    job.State,
+		"status":   job.Status,
+		"template": job.Template.Title,
+		"files":    len(controller.getJobFiles(job.ID)),
+		"results":  job.Results,
+	}
+	if job.State == JobQueued {
+		response["position"] = controller.queue.Position(job.ID)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// fetchAndEnqueue - downloads links of the job, and queues it. Failed downloads fail the job, with details
+// only in the log (responses of other servers aren't shown to the client).
+func (controller *JobsController) fetchAndEnqueue(id uint64, links []string, client, priority string) {
+	ctx, done := controller.startJob(id)
+	defer done()
+
+	if err := controller.fetchLinks(ctx, id, links); err != nil {
+		log.Warnf("[Job: %04d] Failed to download links: %v", id, err)
+		controller.finishJob(id, 0, JobFailed, "Failed to download linked screenshots")
+		return
+	}
+	if err := controller.enqueue(id, client, priority); err != nil {
+		log.Warnf("[Job: %04d] Failed to queue: %v", id, err)
+		controller.finishJob(id, 0, JobFailed, fmt.Sprintf("Failed to queue: %v", err))
+	}
+}
+
+// fetchLinks - downloads linked screenshots (through temporary directory) into storage of the job
+func (controller *JobsController) fetchLinks(ctx context.Context, id uint64, links []string) error {
+	tmp, err := os.MkdirTemp("", "rokocr-links-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for i, link := range links {
+		f, err := controller.Links.Fetch(ctx, link, tmp, urlinput.FileName(link, i+1), retryutils.Options{MaxRetries: 2, Backoff: time.Second})
+		if err != nil {
+			return err
+		}
+		if err := storage.PutFile(ctx, controller.storage, storage.Key(jobMediaPrefix(id), filepath.Base(f)), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,21 +2,32 @@ package www
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
 	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/events"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/storage"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
 	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
-	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/urlinput"
 	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
 	log "github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
@@ -24,26 +35,99 @@ import (
 
 type JobsController struct {
 	db          *bolt.DB
+	templates   *templatestore.Store
 	tessdataDir string
+	storage     storage.Storage
+	progress    *progressHub
+	upgrader    websocket.Upgrader
+	queue       *JobQueue
+
+	// TrainingDirectory - where reviewers can add corrected crops as training samples (empty - disabled)
+	TrainingDirectory string
+	// Events - job lifecycle & results are published to it (nil - disabled)
+	Events *events.Publisher
+	// Links - hosts links of API jobs may point to (default - Discord attachments)
+	Links *urlinput.Guard
+
+	// running - cancels recognition of running jobs, when they are deleted or the server can't wait for them
+	mu      sync.Mutex
+	running map[uint64]context.CancelFunc
 }
 
-func NewJobsController(db *bolt.DB, tessdata string) *JobsController {
-	return &JobsController{
+func NewJobsController(db *bolt.DB, templates *templatestore.Store, tessdata string, store storage.Storage, queue QueueOptions) *JobsController {
+	controller := &JobsController{
 		db:          db,
+		templates:   templates,
 		tessdataDir: tessdata,
+		storage:     store,
+		progress:    newProgressHub(),
+		running:     make(map[uint64]context.CancelFunc),
+		Links:       urlinput.NewGuard(urlinput.DefaultHosts),
 	}
+	controller.queue = NewJobQueue(queue, controller.runJob)
+	return controller
 }
 
+// job states, so API clients can tell when to stop polling (Status is human readable)
+const (
+	JobPending   = "pending"
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
 type OCRJob struct {
 	ID       uint64                `json:"id"`
 	Name     string                `json:"name"`
 	Results  []ocrschema.OCRResult `json:"results,omitempty"`
 	Status   string                `json:"status,omitempty"`
+	State    string                `json:"state,omitempty"`
 	Template ocrschema.OCRTemplate `json:"template,omitempty"`
+	// Client & Priority - who queued the job (user email or IP) and with what priority, kept for requeueing on restart
+	Client   string `json:"client,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	// Owner - user the job belongs to (see OAuthClientInfo.Key), only they & admins can see it, empty - created
+	// while auth was disabled (admins only). OwnerName - user's email or name, for display
+	Owner     string `json:"owner,omitempty"`
+	OwnerName string `json:"owner_name,omitempty"`
+	// Uploads - storage keys of uploaded files by SHA-256 of their content, so files uploaded again are skipped
+	Uploads map[string]string `json:"uploads,omitempty"`
+}
+
+// JobFile - uploaded file of a job
+type JobFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Duplicate - the same content was uploaded before (as Name), the file wasn't stored again
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// Accessible - job can be seen & changed by the user
+func (job *OCRJob) Accessible(user middlewares.OAuthClientInfo) bool {
+	return user.Admin || (len(job.Owner) > 0 && job.Owner == user.Key())
+}
+
+// Finished - job completed or failed (jobs created before State was introduced only have Status)
+func (job *OCRJob) Finished() bool {
+	if len(job.State) > 0 {
+		return job.State == JobCompleted || job.State == JobFailed
+	}
+	return strings.HasPrefix(job.Status, "Completed") || strings.HasPrefix(job.Status, "Failed")
+}
+
+// MediaPrefix - storage prefix of the uploaded screenshots
+func (job *OCRJob) MediaPrefix() string {
+	return jobMediaPrefix(job.ID)
+}
+
+// ReportsPrefix - storage prefix of the generated reports (results.csv & results.json)
+func (job *OCRJob) ReportsPrefix() string {
+	return fmt.Sprintf("reports/job_%v/", job.ID)
 }
 
-func (job *OCRJob) MediaDirectory() string {
-	return fmt.Sprintf("./media/job_%v", job.ID)
+func jobMediaPrefix(id uint64) string {
+	return fmt.Sprintf("job_%v/", id)
 }
 
 func (controller *JobsController) getJobs() []OCRJob {
@@ -71,15 +155,124 @@ func (controller *JobsController) getJobs() []OCRJob {
 	return jobs
 }
 
+// deleteJob - removes the job together with its screenshots & reports, recognition is stopped if it's running
 func (controller *JobsController) deleteJob(id uint64) {
+	controller.cancelJob(id)
+
+	job := OCRJob{ID: id}
+	for _, prefix := range []string{job.MediaPrefix(), job.ReportsPrefix()} {
+		if err := storage.DeletePrefix(context.Background(), controller.storage, prefix); err != nil {
+			log.Warnf("[Job: %04d] Can't delete %v%v: %v", id, controller.storage, prefix, err)
+		}
+	}
+
 	_ = controller.db.Update(func(t *bolt.Tx) error {
 		return t.Bucket([]byte("jobs")).Delete(itob(id))
 	})
 }
 
+// getJobFiles - storage keys of the uploaded screenshots
 func (controller *JobsController) getJobFiles(id uint64) []string {
-	job := controller.getJob(id)
-	return fileutils.GetFilesInDirectory(job.MediaDirectory())
+	files, err := controller.storage.List(context.Background(), jobMediaPrefix(id))
+	if err != nil {
+		log.Warnf("[Job: %04d] Can't list files: %v", id, err)
+	}
+	return files
+}
+
+// saveUpload - stores the uploaded screenshot as a file of the job. Content uploaded before isn't stored again,
+// and files of the same name (e.g. from different folders) are renamed instead of overwriting each other.
+func (controller *JobsController) saveUpload(ctx context.Context, id uint64, file *multipart.FileHeader) (JobFile, error) {
+	f, err := file.Open()
+	if err != nil {
+		return JobFile{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return JobFile{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return JobFile{}, err
+	}
+	upload := JobFile{SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	// file is reserved first, so concurrent uploads of the same content are stored once
+	var key string
+	err = controller.updateJob(id, func(job *OCRJob) *OCRJob {
+		if existing, ok := job.Uploads[upload.SHA256]; ok {
+			key, upload.Duplicate = existing, true
+			return job
+		}
+		if job.Uploads == nil {
+			job.Uploads = make(map[string]string)
+		}
+		key = uniqueUploadKey(job, filepath.Base(file.Filename))
+		job.Uploads[upload.SHA256] = key
+		return job
+	})
+	if err != nil {
+		return upload, err
+	}
+	upload.Name = path.Base(key)
+	if upload.Duplicate {
+		return upload, nil
+	}
+
+	if err := controller.storage.Put(ctx, key, f); err != nil {
+		_ = controller.updateJob(id, func(job *OCRJob) *OCRJob {
+			delete(job.Uploads, upload.SHA256)
+			return job
+		})
+		return upload, err
+	}
+	return upload, nil
+}
+
+// uniqueUploadKey - storage key of the file name, with a counter if another upload of the job has the name already
+func uniqueUploadKey(job *OCRJob, name string) string {
+	used := make(map[string]bool, len(job.Uploads))
+	for _, k := range job.Uploads {
+		used[k] = true
+	}
+
+	ext := filepath.Ext(name)
+	key := storage.Key(job.MediaPrefix(), name)
+	for i := 2; used[key]; i++ {
+		key = storage.Key(job.MediaPrefix(), fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	return key
+}
+
+// jobFiles - uploaded files of the job, with hashes of the ones uploaded since hashes are kept
+func (controller *JobsController) jobFiles(job *OCRJob) []JobFile {
+	hashes := make(map[string]string, len(job.Uploads))
+	for sum, key := range job.Uploads {
+		hashes[key] = sum
+	}
+
+	var files []JobFile
+	for _, key := range controller.getJobFiles(job.ID) {
+		files = append(files, JobFile{Name: path.Base(key), SHA256: hashes[key]})
+	}
+	return files
+}
+
+// saveReports - stores results of the finished job as results.csv & results.json
+func (controller *JobsController) saveReports(job *OCRJob, template ocrschema.OCRTemplate, data []ocrschema.OCRResult) {
+	ctx := context.Background()
+
+	csv := new(bytes.Buffer)
+	rokocr.WriteCSV(data, template, csv)
+	if err := controller.storage.Put(ctx, path.Join(job.ReportsPrefix(), "results.csv"), csv); err != nil {
+		log.Warnf("[Job: %04d] Can't store CSV report: %v", job.ID, err)
+	}
+
+	buf, _ := json.MarshalIndent(data, "", "  ")
+	if err := controller.storage.Put(ctx, path.Join(job.ReportsPrefix(), "results.json"), bytes.NewReader(buf)); err != nil {
+		log.Warnf("[Job: %04d] Can't store JSON report: %v", job.ID, err)
+	}
 }
 
 func (controller *JobsController) updateJob(id uint64, fn func(*OCRJob) *OCRJob) error {
@@ -112,6 +305,14 @@ func (controller *JobsController) updateJobStatus(id uint64, status string) erro
 	})
 }
 
+func (controller *JobsController) updateJobState(id uint64, state, status string) error {
+	return controller.updateJob(id, func(job *OCRJob) *OCRJob {
+		job.State = state
+		job.Status = status
+		return job
+	})
+}
+
 func (controller *JobsController) updateJobTemplate(id uint64, template ocrschema.OCRTemplate) error {
 	return controller.updateJob(id, func(job *OCRJob) *OCRJob {
 		job.Template = template
@@ -130,14 +331,36 @@ func (controller *JobsController) getJob(id uint64) *OCRJob {
 	var job *OCRJob
 
 	_ = controller.db.View(func(t *bolt.Tx) error {
-		bytes := t.Bucket([]byte("jobs")).Get(itob(id))
-		return json.Unmarshal(bytes, &job)
+		bucket := t.Bucket([]byte("jobs"))
+		if bucket == nil {
+			return fmt.Errorf("bucket not found")
+		}
+		return json.Unmarshal(bucket.Get(itob(id)), &job)
 	})
 
 	return job
 }
 
-func (controller *JobsController) createJob(jobName string) (uint64, error) {
+// userJobs - jobs the user can see, their own history (admins see all)
+func (controller *JobsController) userJobs(user middlewares.OAuthClientInfo) []OCRJob {
+	var jobs []OCRJob
+	for _, job := range controller.getJobs() {
+		if job.Accessible(user) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// displayName - how the owner of a job is shown
+func displayName(user middlewares.OAuthClientInfo) string {
+	if len(user.Email) > 0 {
+		return user.Email
+	}
+	return user.Name
+}
+
+func (controller *JobsController) createJob(jobName string, owner middlewares.OAuthClientInfo) (uint64, error) {
 	id := uint64(0)
 
 	err := controller.db.Update(func(t *bolt.Tx) error {
@@ -146,8 +369,11 @@ func (controller *JobsController) createJob(jobName string) (uint64, error) {
 		id, _ = bucket.NextSequence()
 
 		u := OCRJob{
-			Name: jobName,
-			ID:   id,
+			Name:      jobName,
+			ID:        id,
+			State:     JobPending,
+			Owner:     owner.Key(),
+			OwnerName: displayName(owner),
 		}
 
 		buf, err := json.Marshal(u)
@@ -170,13 +396,13 @@ func itob(v uint64) []byte {
 
 func (controller *JobsController) GetJobsList(c *gin.Context) {
 	c.HTML(http.StatusOK, "jobs.html", gin.H{
-		"jobs":     controller.getJobs(),
+		"jobs":     controller.userJobs(middlewares.CurrentUser(c)),
 		"userdata": c.MustGet(middlewares.AuthUserData),
 	})
 }
 
 func (controller *JobsController) CreateJobForm(c *gin.Context) {
-	id, err := controller.createJob(fmt.Sprintf("Job: %v", time.Now().Format("2006-01-02 15:04:05")))
+	id, err := controller.createJob(fmt.Sprintf("Job: %v", time.Now().Format("2006-01-02 15:04:05")), middlewares.CurrentUser(c))
 	if err == nil {
 		c.Redirect(http.StatusFound, fmt.Sprintf("/jobs/%v", id))
 	} else {
@@ -199,45 +425,299 @@ func (controller *JobsController) StartJobByID(c *gin.Context) {
 
 	// TODO: Edit job here
 	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
-	job := controller.getJob(id)
 
-	go func(job *OCRJob) {
-		log.Debugf("Processing job: %v", job)
+	if err := controller.enqueue(id, clientOf(c), PriorityNormal); err != nil {
+		_ = controller.updateJobStatus(id, fmt.Sprintf("Not started: %v", err))
+		c.Redirect(http.StatusFound, fmt.Sprintf("/jobs/%v", id))
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("/jobs/%v/results", id))
+}
+
+// clientOf - who the request comes from, for rate limits & fair queueing: signed in user, or IP
+func clientOf(c *gin.Context) string {
+	if key := middlewares.CurrentUser(c).Key(); len(key) > 0 {
+		return key
+	}
+	return c.ClientIP()
+}
+
+// RequireJobAccess - jobs of other users are not found, unless the user is admin (follows auth middleware)
+func (controller *JobsController) RequireJobAccess(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	if job := controller.getJob(id); job == nil || !job.Accessible(middlewares.CurrentUser(c)) {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"userdata": c.MustGet(middlewares.AuthUserData),
+			"code":     http.StatusNotFound,
+			"err":      "Job not found",
+		})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// enqueue - queues the job, persisted as queued so it survives a restart
+func (controller *JobsController) enqueue(id uint64, client, priority string) error {
+	files := len(controller.getJobFiles(id))
+	if err := controller.queue.Push(id, client, priority, files); err != nil {
+		return err
+	}
+	controller.Events.Publish(events.Event{Type: events.JobQueued, Job: id, Total: files, Status: "Queued"})
+	return controller.updateJob(id, func(job *OCRJob) *OCRJob {
+		job.State = JobQueued
+		job.Status = "Queued"
+		job.Client = client
+		job.Priority = priority
+		return job
+	})
+}
+
+// Resume - queues again jobs which were queued or running when the server stopped
+func (controller *JobsController) Resume() {
+	for _, job := range controller.getJobs() {
+		if job.State == JobQueued || job.State == JobRunning {
+			log.Infof("[Job: %04d] Queued again after restart", job.ID)
+			controller.queue.Requeue(job.ID, job.Client, job.Priority)
+		}
+	}
+}
+
+// Shutdown - waits for running jobs to finish (or ctx to be done), queued ones are picked up after restart,
+// as are running ones which didn't finish in time. Those are cancelled, so their OCR engines are released.
+func (controller *JobsController) Shutdown(ctx context.Context) error {
+	err := controller.queue.Drain(ctx)
+	if err == nil {
+		return nil
+	}
+
+	controller.mu.Lock()
+	for id, cancel := range controller.running {
+		log.Warnf("[Job: %04d] Cancelled, queued again after restart", id)
+		cancel()
+	}
+	controller.mu.Unlock()
 
-		index := 1
-		fileCount := len(controller.getJobFiles(job.ID))
+	// cancelled recognition stops after the field it's at
+	grace, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	_ = controller.queue.Drain(grace)
+	return err
+}
 
-		// clean results & update status
-		_ = controller.updateJobResults(job.ID, []ocrschema.OCRResult{})
-		_ = controller.updateJobStatus(job.ID, fmt.Sprintf("Processing: %v/%v", index, fileCount))
+// cancelGrace - how long Shutdown waits for cancelled jobs to stop
+const cancelGrace = 10 * time.Second
 
-		mediaDir := job.MediaDirectory()
+// startJob - context of the job's recognition, cancelled by cancelJob. Done must be called once the job stops.
+func (controller *JobsController) startJob(id uint64) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
 
-		templates := ocrschema.LoadTemplates("./templates")
-		if len(templates) > 0 {
-			log.Debugf("Loaded %v templates", len(templates))
-			template := ocrschema.FindTemplate(mediaDir, templates)
-			log.Infof("[Job: %04d] Picked template: %s by %s", job.ID, template.Title, template.Author)
-			_ = controller.updateJobTemplate(job.ID, template)
+	controller.mu.Lock()
+	controller.running[id] = cancel
+	controller.mu.Unlock()
 
-			var data []ocrschema.OCRResult
-			for elem := range tesseractutils.RunRecognitionChan(mediaDir, controller.tessdataDir, template, true) {
-				data = append(data, elem)
-				log.Printf("[Job: %04d][%04d/%04d] %v Took: %v ms", job.ID, index, fileCount, elem.Filename, elem.Took.Milliseconds())
-				index = index + 1
-				_ = controller.updateJobStatus(job.ID, fmt.Sprintf("Processing: %v/%v", index, fileCount))
-				_ = controller.updateJobResults(job.ID, data)
-			}
+	return ctx, func() {
+		controller.mu.Lock()
+		delete(controller.running, id)
+		controller.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelJob - stops recognition of the job, if it's running
+func (controller *JobsController) cancelJob(id uint64) {
+	controller.mu.Lock()
+	defer controller.mu.Unlock()
+
+	if cancel, ok := controller.running[id]; ok {
+		cancel()
+	}
+}
+
+// Ready - jobs are accepted
+func (controller *JobsController) Ready() error {
+	if controller.queue.Closed() {
+		return ErrQueueClosed
+	}
+	return nil
+}
+
+// runJob - job picked from the queue, unless it was deleted meanwhile
+func (controller *JobsController) runJob(id uint64) {
+	if job := controller.getJob(id); job != nil {
+		controller.processJob(job)
+	}
+}
+
+// processJob - recognizes all files uploaded for the job, status & results are saved as files are processed,
+// and every file is announced to progress websockets
+func (controller *JobsController) processJob(job *OCRJob) {
+	log.Debugf("Processing job: %v", job)
+
+	ctx, done := controller.startJob(job.ID)
+	defer done()
+
+	// failures are reported from recognition goroutine, so counter is shared
+	var processed atomic.Int64
+	fileCount := len(controller.getJobFiles(job.ID))
+
+	jobsRunning.Inc()
+	queueDepth.Add(float64(fileCount))
+	// ranking lists & stitched screenshots produce several results of a file, so the queue is counted in files
+	var dequeued atomic.Int64
+	dequeue := func(index int) {
+		if index <= fileCount {
+			dequeued.Add(1)
+			queueDepth.Dec()
+		}
+	}
+	defer func() {
+		jobsRunning.Dec()
+		// files which didn't come out of recognition aren't waiting anymore either
+		queueDepth.Sub(float64(int64(fileCount) - dequeued.Load()))
+	}()
+
+	// clean results & update status
+	_ = controller.updateJobResults(job.ID, []ocrschema.OCRResult{})
+	_ = controller.updateJobState(job.ID, JobRunning, fmt.Sprintf("Processing: %v/%v", 1, fileCount))
+	controller.Events.Publish(events.Event{Type: events.JobStarted, Job: job.ID, Total: fileCount, Status: "Processing"})
+
+	// recognition reads files from disk, remote storages are downloaded into temporary directory first
+	mediaDir, cleanup, err := storage.Fetch(ctx, controller.storage, job.MediaPrefix(), "")
+	if ctx.Err() != nil {
+		log.Warnf("[Job: %04d] Cancelled", job.ID)
+		return
+	}
+	if err != nil {
+		log.Errorf("[Job: %04d] Can't fetch files from %v: %v", job.ID, controller.storage, err)
+		controller.finishJob(job.ID, fileCount, JobFailed, fmt.Sprintf("Failed, can't fetch files: %v", err))
+		return
+	}
+	defer cleanup()
+
+	templates := controller.templates.Templates()
+	if len(templates) > 0 {
+		log.Debugf("Loaded %v templates", len(templates))
+		template := ocrschema.FindTemplate(mediaDir, templates)
+		log.Infof("[Job: %04d] Picked template: %s by %s", job.ID, template.Title, template.Author)
+		_ = controller.updateJobTemplate(job.ID, template)
+
+		opts := tesseractutils.DefaultOptions(controller.tessdataDir).WithContext(ctx)
+		opts.OnFailure = func(file string, err error) {
+			observeFailure(err)
+			index := int(processed.Add(1))
+			dequeue(index)
+			controller.progress.publish(ProgressEvent{
+				Job:      job.ID,
+				Index:    index,
+				Total:    fileCount,
+				Filename: filepath.Base(file),
+				Template: template.Title,
+				Error:    err.Error(),
+				State:    JobRunning,
+			})
+			controller.Events.Publish(events.Event{
+				Type:     events.JobResult,
+				Job:      job.ID,
+				Index:    index,
+				Total:    fileCount,
+				Template: template.Title,
+				Result:   &ocrschema.OCRResult{Filename: filepath.Base(file), Data: map[string]interface{}{}, Error: err.Error()},
+			})
+		}
 
+		var data []ocrschema.OCRResult
+		for elem := range tesseractutils.RunRecognitionChanWithOptions(mediaDir, template, true, opts) {
+			index := int(processed.Add(1))
+			data = append(data, elem)
+			observeResult(template.Title, elem)
+			dequeue(index)
+			log.Printf("[Job: %04d][%04d/%04d] %v Took: %v ms", job.ID, index, fileCount, elem.Filename, elem.Took.Milliseconds())
+			controller.progress.publish(resultEvent(job.ID, index, fileCount, template.Title, elem))
+			result := elem
+			controller.Events.Publish(events.Event{Type: events.JobResult, Job: job.ID, Index: index, Total: fileCount, Template: template.Title, Result: &result})
+			_ = controller.updateJobStatus(job.ID, fmt.Sprintf("Processing: %v/%v", index+1, fileCount))
 			_ = controller.updateJobResults(job.ID, data)
-			_ = controller.updateJobStatus(job.ID, fmt.Sprintf("Completed: %v files", len(data)))
-		} else {
-			log.Warnf("No compatible template found")
-			_ = controller.updateJobStatus(job.ID, "Failed, no template found")
 		}
-	}(job)
 
-	c.Redirect(http.StatusFound, fmt.Sprintf("/jobs/%v/results", id))
+		if ctx.Err() != nil {
+			// deleted, or the server is shutting down - job stays running, so it's queued again after restart
+			log.Warnf("[Job: %04d] Cancelled after %v of %v files", job.ID, processed.Load(), fileCount)
+			return
+		}
+
+		_ = controller.updateJobResults(job.ID, data)
+		controller.saveReports(job, template, data)
+		controller.finishJob(job.ID, fileCount, JobCompleted, fmt.Sprintf("Completed: %v files", len(data)))
+	} else {
+		log.Warnf("No compatible template found")
+		templateMatches.WithLabelValues(unmatched).Add(float64(fileCount))
+		imagesProcessed.WithLabelValues(rokocr.ReportFailed, rokocr.FailureNoTemplateMatch).Add(float64(fileCount))
+		controller.finishJob(job.ID, fileCount, JobFailed, "Failed, no template found")
+	}
+}
+
+func (controller *JobsController) finishJob(id uint64, total int, state, status string) {
+	_ = controller.updateJobState(id, state, status)
+	controller.progress.publish(ProgressEvent{Job: id, Index: total, Total: total, State: state, Status: status})
+
+	event := events.JobCompleted
+	if state == JobFailed {
+		event = events.JobFailed
+	}
+	controller.Events.Publish(events.Event{Type: event, Job: id, Index: total, Total: total, Status: status})
+}
+
+// JobProgressWebsocket - streams ProgressEvent's of the job while it runs
+func (controller *JobsController) JobProgressWebsocket(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	job := controller.getJob(id)
+	if job == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ws, err := controller.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Warnf("[Job: %04d] Progress websocket: %v", id, err)
+		return
+	}
+	defer ws.Close()
+
+	events, unsubscribe := controller.progress.subscribe(id)
+	defer unsubscribe()
+
+	// job might have finished before we subscribed
+	if job.Finished() {
+		_ = ws.WriteJSON(ProgressEvent{Job: id, State: job.State, Status: job.Status})
+		return
+	}
+
+	// reader is needed to notice the browser going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case e := <-events:
+			if err := ws.WriteJSON(e); err != nil {
+				return
+			}
+			if e.State == JobCompleted || e.State == JobFailed {
+				return
+			}
+		}
+	}
 }
 
 func (controller *JobsController) ExportJobAsCSV(c *gin.Context) {
@@ -263,18 +743,46 @@ func (controller *JobsController) ExportJobResultsHTML(c *gin.Context) {
 
 func (controller *JobsController) UploadFilesForJob(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
-	job := controller.getJob(id)
 
-	_ = os.MkdirAll(job.MediaDirectory(), os.ModePerm)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// move uploaded file
-	file, _ := c.FormFile("file")
-	dst := filepath.Join(job.MediaDirectory(), filepath.Base(file.Filename))
-	_ = c.SaveUploadedFile(file, dst)
+	upload, err := controller.saveUpload(c.Request.Context(), id, file)
+	if err != nil {
+		log.Errorf("[Job: %04d] Can't store %v: %v", id, file.Filename, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if upload.Duplicate {
+		log.Debugf("[Job: %04d] %v is the same as %v, skipped", id, file.Filename, upload.Name)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"userdata":    c.MustGet(middlewares.AuthUserData),
-		"destination": dst,
+		"destination": storage.Key(jobMediaPrefix(id), upload.Name),
+		"name":        upload.Name,
+		"sha256":      upload.SHA256,
+		"duplicate":   upload.Duplicate,
+	})
+}
+
+// ListJobFiles - GET /jobs/:id/files, uploaded files of the job with SHA-256 of their content, so the upload page
+// can skip files which are there already
+func (controller *JobsController) ListJobFiles(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	job := controller.getJob(id)
+	if job == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":    job.ID,
+		"state": job.State,
+		"files": controller.jobFiles(job),
 	})
 }
 
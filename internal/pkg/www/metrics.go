@@ -0,0 +1,79 @@
+package www
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+)
+
+// MetricsNamespace - prefix of the metrics, served on /metrics together with HTTP ones (see go-gin-prometheus)
+const MetricsNamespace = "rokmonster"
+
+var (
+	imagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "images_processed_total",
+		Help:      "Images processed by jobs, by status (recognized, failed) and failure reason",
+	}, []string{"status", "reason"})
+
+	templateMatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "template_matches_total",
+		Help:      "Images by template they matched, \"none\" for images no template matches",
+	}, []string{"template"})
+
+	fieldConfidence = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "field_confidence",
+		Help:      "Confidence (0-100) of recognized fields",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	}, []string{"template", "field"})
+
+	ocrDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "ocr_duration_seconds",
+		Help:      "Time to recognize all fields of an image",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"template"})
+
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "queue_depth",
+		Help:      "Images of running jobs, waiting to be recognized",
+	})
+
+	queuedJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "jobs_queued",
+		Help:      "Jobs waiting in the queue",
+	})
+
+	jobsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      "jobs_running",
+		Help:      "Jobs being processed",
+	})
+)
+
+// unmatched - template label of images no template matches
+const unmatched = "none"
+
+// observeResult - recognized image of a job
+func observeResult(template string, r ocrschema.OCRResult) {
+	imagesProcessed.WithLabelValues(rokocr.ReportRecognized, "").Inc()
+	templateMatches.WithLabelValues(template).Inc()
+	ocrDuration.WithLabelValues(template).Observe(r.Took.Seconds())
+	for field, f := range r.Fields {
+		fieldConfidence.WithLabelValues(template, field).Observe(f.Confidence)
+	}
+}
+
+// observeFailure - image of a job which couldn't be recognized
+func observeFailure(err error) {
+	reason := rokocr.FailureReason(err)
+	imagesProcessed.WithLabelValues(rokocr.ReportFailed, reason).Inc()
+	if reason == rokocr.FailureNoTemplateMatch {
+		templateMatches.WithLabelValues(unmatched).Inc()
+	}
+}
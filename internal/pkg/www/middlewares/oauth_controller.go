@@ -23,6 +23,18 @@ const (
 	AuthUserData = "rokmonster.dev/auth/userdata"
 )
 
+// sign in providers
+const (
+	ProviderGoogle  = "google"
+	ProviderDiscord = "discord"
+	ProviderLocal   = "local"
+)
+
+var discordEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://discord.com/oauth2/authorize",
+	TokenURL: "https://discord.com/api/oauth2/token",
+}
+
 func randToken() string {
 	b := make([]byte, 32)
 	rand.Read(b)
@@ -35,56 +47,150 @@ type OAuthCredentions struct {
 }
 
 type OAuthClientInfo struct {
-	ID      string `json:"sub"`
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Picture string `json:"picture"`
+	ID       string `json:"sub"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Picture  string `json:"picture"`
+	Provider string `json:"provider,omitempty"`
+	Admin    bool   `json:"admin,omitempty"`
+	// EmailVerified - whether the provider verified the email, unverified ones aren't identities
+	EmailVerified bool `json:"email_verified,omitempty"`
+}
+
+// Key - identity of the signed in user, jobs are owned by it (empty - auth is disabled)
+func (u OAuthClientInfo) Key() string {
+	if len(u.Provider) == 0 {
+		return ""
+	}
+	return u.Provider + ":" + u.ID
+}
+
+// AuthOptions - sign in providers, with none of them set up everyone has access (as admin)
+type AuthOptions struct {
+	// Domains - TLS domains, first one is used in OAuth redirect urls
+	Domains string
+
+	GoogleClientID string
+	GoogleSecret   string
+
+	DiscordClientID string
+	DiscordSecret   string
+
+	// Admins - OAuth users with admin role, as google:<verified email> or discord:<user id>
+	Admins []string
+
+	// Users - local accounts (see UserStore.Add), admin role is set per account
+	Users *UserStore
+}
+
+type oauthProvider struct {
+	conf        *oauth2.Config
+	userInfoURL string
+	parse       func(data []byte) (OAuthClientInfo, error)
 }
 
 type oauth2Middleware struct {
-	conf    *oauth2.Config
-	store   sessions.Store
-	enabled bool
+	providers map[string]*oauthProvider
+	users     *UserStore
+	admins    map[string]bool
+	store     sessions.Store
+	enabled   bool
 }
 
-func (ctrl *oauth2Middleware) authHandler(c *gin.Context) {
-	// Handle the exchange code to initiate a transport.
-	session := sessions.Default(c)
-	retrievedState := session.Get("state")
-	if retrievedState != c.Query("state") {
-		c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("invalid session state: %s", retrievedState))
-		return
-	}
+func (ctrl *oauth2Middleware) authHandler(provider string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := ctrl.providers[provider]
 
-	tok, err := ctrl.conf.Exchange(context.Background(), c.Query("code"))
-	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
-		return
-	}
+		// Handle the exchange code to initiate a transport.
+		session := sessions.Default(c)
+		retrievedState := session.Get("state")
+		if retrievedState != c.Query("state") {
+			c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("invalid session state: %s", retrievedState))
+			return
+		}
 
-	client := ctrl.conf.Client(context.Background(), tok)
-	userInfo, err := client.Get("https://www.googleapis.com/oauth2/v3/userinfo")
-	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, err)
-		return
+		tok, err := p.conf.Exchange(context.Background(), c.Query("code"))
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		client := p.conf.Client(context.Background(), tok)
+		userInfo, err := client.Get(p.userInfoURL)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		defer userInfo.Body.Close()
+		data, _ := ioutil.ReadAll(userInfo.Body)
+
+		clientDetails, err := p.parse(data)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		clientDetails.Provider = provider
+		if id := adminIdentity(clientDetails); len(id) > 0 {
+			clientDetails.Admin = ctrl.admins[id]
+		}
+
+		session.Set(AuthUserData, clientDetails)
+		session.Save()
+
+		c.Redirect(http.StatusFound, "/")
 	}
-	defer userInfo.Body.Close()
-	data, _ := ioutil.ReadAll(userInfo.Body)
+}
 
-	var clientDetails OAuthClientInfo
-	json.Unmarshal(data, &clientDetails)
+// adminIdentity - identity -admins are matched against, one the user can't change: verified email of Google
+// account, id of Discord account (names are editable, so anyone could take an admin's one)
+func adminIdentity(u OAuthClientInfo) string {
+	switch u.Provider {
+	case ProviderGoogle:
+		if u.EmailVerified && len(u.Email) > 0 {
+			return ProviderGoogle + ":" + strings.ToLower(u.Email)
+		}
+	case ProviderDiscord:
+		if len(u.ID) > 0 {
+			return ProviderDiscord + ":" + u.ID
+		}
+	}
+	return ""
+}
 
-	session.Set(AuthUserData, clientDetails)
-	session.Save()
+func parseGoogleUser(data []byte) (OAuthClientInfo, error) {
+	var info OAuthClientInfo
+	err := json.Unmarshal(data, &info)
+	return info, err
+}
 
-	c.Redirect(http.StatusFound, "/")
+func parseDiscordUser(data []byte) (OAuthClientInfo, error) {
+	var user struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+		Email      string `json:"email"`
+		Verified   bool   `json:"verified"`
+		Avatar     string `json:"avatar"`
+	}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return OAuthClientInfo{}, err
+	}
+	info := OAuthClientInfo{ID: user.ID, Name: user.Username}
+	// unverified email may belong to someone else
+	if user.Verified {
+		info.Email, info.EmailVerified = user.Email, true
+	}
+	if len(user.Avatar) > 0 {
+		info.Picture = fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", user.ID, user.Avatar)
+	}
+	return info, nil
 }
 
-func (ctrl *oauth2Middleware) GetLoginURL(state string) string {
-	return ctrl.conf.AuthCodeURL(state, oauth2.ApprovalForce)
+func (ctrl *oauth2Middleware) GetLoginURL(provider, state string) string {
+	return ctrl.providers[provider].conf.AuthCodeURL(state, oauth2.ApprovalForce)
 }
 
-func NewOAuth2Middleware(engine *gin.Engine, clientId, secret, domains string) *oauth2Middleware {
+func NewOAuth2Middleware(engine *gin.Engine, opts AuthOptions) *oauth2Middleware {
 	gob.Register(OAuthClientInfo{})
 
 	sessionStore := memstore.NewStore([]byte("Gisooshei6eitiQu2coe7ohze2phuuQu"))
@@ -98,49 +204,132 @@ func NewOAuth2Middleware(engine *gin.Engine, clientId, secret, domains string) *
 		ctx.Redirect(http.StatusFound, "/")
 	})
 
-	ctrl := &oauth2Middleware{conf: nil, enabled: false, store: sessionStore}
+	ctrl := &oauth2Middleware{
+		providers: make(map[string]*oauthProvider),
+		users:     opts.Users,
+		admins:    make(map[string]bool),
+		store:     sessionStore,
+	}
+	for _, admin := range opts.Admins {
+		if admin = strings.TrimSpace(admin); len(admin) == 0 {
+			continue
+		}
+		provider, id, ok := strings.Cut(admin, ":")
+		provider = strings.ToLower(provider)
+		if !ok || len(id) == 0 || (provider != ProviderGoogle && provider != ProviderDiscord) {
+			logrus.Warnf("Admin %q skipped, expected google:<email> or discord:<user id>", admin)
+			continue
+		}
+		if provider == ProviderGoogle {
+			id = strings.ToLower(id)
+		}
+		ctrl.admins[provider+":"+id] = true
+	}
 
-	tlsDomains := strings.Split(domains, ",")
-	if len(tlsDomains) > 0 && len(clientId) > 0 && len(secret) > 0 {
+	tlsDomains := strings.Split(opts.Domains, ",")
+	if len(tlsDomains[0]) > 0 && len(opts.GoogleClientID) > 0 && len(opts.GoogleSecret) > 0 {
 		redirectUrl := fmt.Sprintf("https://%s/oauth", tlsDomains[0])
-		logrus.Infof("Initiliazing OAuth2 with redirect url: %v", redirectUrl)
+		logrus.Infof("Initiliazing Google OAuth2 with redirect url: %v", redirectUrl)
 
-		ctrl = &oauth2Middleware{
-			enabled: true,
-			store:   sessionStore,
+		ctrl.providers[ProviderGoogle] = &oauthProvider{
 			conf: &oauth2.Config{
-				ClientID:     clientId,
-				ClientSecret: secret,
+				ClientID:     opts.GoogleClientID,
+				ClientSecret: opts.GoogleSecret,
 				RedirectURL:  redirectUrl,
 				Scopes: []string{
 					"https://www.googleapis.com/auth/userinfo.email",
 				},
 				Endpoint: google.Endpoint,
 			},
+			userInfoURL: "https://www.googleapis.com/oauth2/v3/userinfo",
+			parse:       parseGoogleUser,
 		}
+		engine.GET("/oauth", ctrl.authHandler(ProviderGoogle))
+	}
+
+	if len(tlsDomains[0]) > 0 && len(opts.DiscordClientID) > 0 && len(opts.DiscordSecret) > 0 {
+		redirectUrl := fmt.Sprintf("https://%s/oauth/discord", tlsDomains[0])
+		logrus.Infof("Initiliazing Discord OAuth2 with redirect url: %v", redirectUrl)
 
-		engine.GET("/oauth", ctrl.authHandler)
-		engine.GET("/login", ctrl.Login)
+		ctrl.providers[ProviderDiscord] = &oauthProvider{
+			conf: &oauth2.Config{
+				ClientID:     opts.DiscordClientID,
+				ClientSecret: opts.DiscordSecret,
+				RedirectURL:  redirectUrl,
+				Scopes:       []string{"identify", "email"},
+				Endpoint:     discordEndpoint,
+			},
+			userInfoURL: "https://discord.com/api/users/@me",
+			parse:       parseDiscordUser,
+		}
+		engine.GET("/oauth/discord", ctrl.authHandler(ProviderDiscord))
+	}
+
+	local := opts.Users != nil && opts.Users.HasUsers()
+	ctrl.enabled = len(ctrl.providers) > 0 || local
+
+	if ctrl.enabled {
+		engine.GET("/login", func(ctx *gin.Context) {
+			ctx.HTML(http.StatusOK, "auth.html", ctrl.loginPage(""))
+		})
+		engine.GET("/login/:provider", ctrl.Login)
+		if local {
+			engine.POST("/login", ctrl.LocalLogin)
+		}
 	} else {
-		logrus.Warn("No OAuth2 setup found")
+		logrus.Warn("No OAuth2 setup or local users found, everyone has access")
 	}
 
 	return ctrl
 }
 
+// loginPage - data of auth.html
+func (ctrl *oauth2Middleware) loginPage(err string) gin.H {
+	return gin.H{
+		ProviderGoogle:  ctrl.providers[ProviderGoogle] != nil,
+		ProviderDiscord: ctrl.providers[ProviderDiscord] != nil,
+		ProviderLocal:   ctrl.users != nil && ctrl.users.HasUsers(),
+		"error":         err,
+	}
+}
+
 func (ctrl *oauth2Middleware) Login(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+	if ctrl.providers[provider] == nil {
+		ctx.Redirect(http.StatusFound, "/login")
+		return
+	}
+
 	session := sessions.Default(ctx)
 
 	state := randToken()
 	session.Set("state", state)
 	session.Save()
-	ctx.Redirect(http.StatusFound, ctrl.GetLoginURL(state))
+	ctx.Redirect(http.StatusFound, ctrl.GetLoginURL(provider, state))
 }
 
+// LocalLogin - POST /login, user name & password of a local account
+func (ctrl *oauth2Middleware) LocalLogin(ctx *gin.Context) {
+	user, err := ctrl.users.Authenticate(ctx.PostForm("name"), ctx.PostForm("password"))
+	if err != nil {
+		logrus.Warnf("Failed login of %q from %v: %v", ctx.PostForm("name"), ctx.ClientIP(), err)
+		ctx.HTML(http.StatusUnauthorized, "auth.html", ctrl.loginPage(ErrInvalidLogin.Error()))
+		return
+	}
+
+	session := sessions.Default(ctx)
+	session.Set(AuthUserData, user.Info())
+	session.Save()
+	ctx.Redirect(http.StatusFound, "/")
+}
+
+// anonymous - user of requests when auth is disabled, everyone can do everything
+var anonymous = OAuthClientInfo{Admin: true}
+
 func (ctrl *oauth2Middleware) Middleware() func(ctx *gin.Context) {
 	if !ctrl.enabled {
 		return func(ctx *gin.Context) {
-			ctx.Set(AuthUserData, OAuthClientInfo{})
+			ctx.Set(AuthUserData, anonymous)
 			ctx.Next()
 		}
 	}
@@ -149,7 +338,7 @@ func (ctrl *oauth2Middleware) Middleware() func(ctx *gin.Context) {
 		session := sessions.Default(ctx)
 		data := session.Get(AuthUserData)
 		if data == nil {
-			ctx.HTML(200, "auth.html", gin.H{})
+			ctx.HTML(200, "auth.html", ctrl.loginPage(""))
 			ctx.Abort()
 		} else {
 			ctx.Set(AuthUserData, data)
@@ -157,3 +346,67 @@ func (ctrl *oauth2Middleware) Middleware() func(ctx *gin.Context) {
 		}
 	}
 }
+
+// APIMiddleware - like Middleware, but API clients (bots, scripts) sign in with HTTP basic auth of a local account
+func (ctrl *oauth2Middleware) APIMiddleware() func(ctx *gin.Context) {
+	if !ctrl.enabled {
+		return ctrl.Middleware()
+	}
+
+	return func(ctx *gin.Context) {
+		if data := sessions.Default(ctx).Get(AuthUserData); data != nil {
+			ctx.Set(AuthUserData, data)
+			ctx.Next()
+			return
+		}
+
+		if name, password, ok := ctx.Request.BasicAuth(); ok {
+			if user, err := ctrl.AuthenticateBasic(name, password); err == nil {
+				ctx.Set(AuthUserData, user)
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.Header("WWW-Authenticate", `Basic realm="rokmonster"`)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sign in required, use basic auth of a local account"})
+	}
+}
+
+// AuthenticateBasic - user of basic auth credentials (local account), for API clients outside of gin (e.g. gRPC).
+// When auth is disabled everyone is the anonymous admin
+func (ctrl *oauth2Middleware) AuthenticateBasic(name, password string) (OAuthClientInfo, error) {
+	if !ctrl.enabled {
+		return anonymous, nil
+	}
+	if ctrl.users == nil {
+		return OAuthClientInfo{}, ErrInvalidLogin
+	}
+	user, err := ctrl.users.Authenticate(name, password)
+	if err != nil {
+		return OAuthClientInfo{}, err
+	}
+	return user.Info(), nil
+}
+
+// AdminMiddleware - only users with admin role pass, must follow Middleware
+func (ctrl *oauth2Middleware) AdminMiddleware() func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		if !CurrentUser(ctx).Admin {
+			ctx.HTML(http.StatusForbidden, "error.html", gin.H{
+				"userdata": ctx.MustGet(AuthUserData),
+				"code":     http.StatusForbidden,
+				"err":      "Only admins can do this",
+			})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// CurrentUser - signed in user of the request (set by Middleware)
+func CurrentUser(ctx *gin.Context) OAuthClientInfo {
+	user, _ := ctx.Value(AuthUserData).(OAuthClientInfo)
+	return user
+}
@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var usersBucket = []byte("users")
+
+// ErrInvalidLogin - unknown user or wrong password
+var ErrInvalidLogin = errors.New("invalid user name or password")
+
+// LocalUser - account signing in with user name & password, stored in the server database
+type LocalUser struct {
+	Name         string `json:"name"`
+	PasswordHash []byte `json:"password_hash"`
+	Admin        bool   `json:"admin,omitempty"`
+}
+
+// Info - signed in user of the account
+func (u LocalUser) Info() OAuthClientInfo {
+	return OAuthClientInfo{ID: u.Name, Name: u.Name, Provider: ProviderLocal, Admin: u.Admin}
+}
+
+// UserStore - local accounts
+type UserStore struct {
+	db *bolt.DB
+}
+
+func NewUserStore(db *bolt.DB) *UserStore {
+	return &UserStore{db: db}
+}
+
+// Add - creates the account, or changes password & role of an existing one
+func (s *UserStore) Add(name, password string, admin bool) error {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return errors.New("user name is empty")
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(LocalUser{Name: name, PasswordHash: hash, Admin: admin})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(t *bolt.Tx) error {
+		bucket, err := t.CreateBucketIfNotExists(usersBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), buf)
+	})
+}
+
+// Authenticate - account with the name, if the password matches
+func (s *UserStore) Authenticate(name, password string) (LocalUser, error) {
+	var user LocalUser
+	err := s.db.View(func(t *bolt.Tx) error {
+		bucket := t.Bucket(usersBucket)
+		if bucket == nil {
+			return ErrInvalidLogin
+		}
+		data := bucket.Get([]byte(strings.TrimSpace(name)))
+		if data == nil {
+			return ErrInvalidLogin
+		}
+		if err := json.Unmarshal(data, &user); err != nil {
+			return fmt.Errorf("can't read user %v: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return user, err
+	}
+	if bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)) != nil {
+		return user, ErrInvalidLogin
+	}
+	return user, nil
+}
+
+// HasUsers - at least one local account exists
+func (s *UserStore) HasUsers() bool {
+	found := false
+	_ = s.db.View(func(t *bolt.Tx) error {
+		if bucket := t.Bucket(usersBucket); bucket != nil {
+			k, _ := bucket.Cursor().First()
+			found = k != nil
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,90 @@
+package www
+
+import (
+	"sync"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+)
+
+// ProgressEvent - sent to web UI for every processed file of a running job, and once when job finishes
+type ProgressEvent struct {
+	Job        uint64                 `json:"job"`
+	Index      int                    `json:"index"`
+	Total      int                    `json:"total"`
+	Filename   string                 `json:"filename,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Confidence float64                `json:"confidence,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	State      string                 `json:"state"`
+	Status     string                 `json:"status"`
+
+	// result - typed result of the file, for gRPC watchers
+	result *ocrschema.OCRResult
+}
+
+func resultEvent(job uint64, index, total int, template string, r ocrschema.OCRResult) ProgressEvent {
+	data := make(map[string]interface{}, len(r.Data))
+	for k, v := range r.Data {
+		data[k] = ocrschema.FormatValue(v)
+	}
+
+	return ProgressEvent{
+		Job:        job,
+		Index:      index,
+		Total:      total,
+		Filename:   r.Filename,
+		Template:   template,
+		Data:       data,
+		Confidence: r.Confidence(),
+		Error:      r.Error,
+		State:      JobRunning,
+		result:     &r,
+	}
+}
+
+// progressHub - fans out job progress to connected websockets. Slow subscribers miss events instead of
+// blocking the job, they still get the final state from job results.
+type progressHub struct {
+	mu          sync.Mutex
+	subscribers map[uint64]map[chan ProgressEvent]struct{}
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{subscribers: make(map[uint64]map[chan ProgressEvent]struct{})}
+}
+
+func (h *progressHub) subscribe(job uint64) (<-chan ProgressEvent, func()) {
+	ch := make(chan ProgressEvent, 64)
+
+	h.mu.Lock()
+	if h.subscribers[job] == nil {
+		h.subscribers[job] = make(map[chan ProgressEvent]struct{})
+	}
+	h.subscribers[job][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[job][ch]; ok {
+			delete(h.subscribers[job], ch)
+			close(ch)
+		}
+		if len(h.subscribers[job]) == 0 {
+			delete(h.subscribers, job)
+		}
+	}
+}
+
+func (h *progressHub) publish(e ProgressEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[e.Job] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
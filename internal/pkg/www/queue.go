@@ -0,0 +1,284 @@
+package www
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// job priorities, higher ones are picked first
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+var priorities = map[string]int{PriorityLow: -1, PriorityNormal: 0, PriorityHigh: 1}
+
+// ParsePriority - priority by name, empty - normal
+func ParsePriority(name string) (string, error) {
+	if len(name) == 0 {
+		return PriorityNormal, nil
+	}
+	if _, ok := priorities[name]; !ok {
+		return "", fmt.Errorf("invalid priority %q, expected %v, %v or %v", name, PriorityLow, PriorityNormal, PriorityHigh)
+	}
+	return name, nil
+}
+
+// UserPriority - priority the user queues at: high is reserved to admins, anyone else asking for it gets normal,
+// otherwise one client could jump ahead of all others
+func UserPriority(priority string, admin bool) string {
+	if priority == PriorityHigh && !admin {
+		return PriorityNormal
+	}
+	return priority
+}
+
+// ErrQueueFull - queue already holds QueueOptions.Size jobs
+var ErrQueueFull = errors.New("job queue is full, try again later")
+
+// ErrQueueClosed - server is shutting down, jobs are not queued anymore
+var ErrQueueClosed = errors.New("server is shutting down, try again later")
+
+// RateLimitError - client already queued QueueOptions.RateLimit images within the last hour
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many images queued, try again in %v", e.RetryAfter.Round(time.Second))
+}
+
+type QueueOptions struct {
+	// Size - how many jobs can wait in the queue (0 - unbounded)
+	Size int
+	// Workers - how many jobs are processed at once
+	Workers int
+	// RateLimit - images a single client can queue per hour (0 - unlimited)
+	RateLimit int
+}
+
+type queuedJob struct {
+	id       uint64
+	client   string
+	priority int
+	seq      uint64
+}
+
+// bucket - token bucket of a client, refilled by RateLimit tokens per hour
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// JobQueue - pending jobs, picked by priority, then round-robin between clients (so one client uploading
+// 1,000 screenshots doesn't starve everyone else), then in order they were queued. Jobs themselves are
+// persisted as queued, so they are queued again on restart (see JobsController.Resume).
+type JobQueue struct {
+	opts QueueOptions
+	run  func(id uint64)
+
+	mu      sync.Mutex
+	wake    *sync.Cond
+	jobs    []queuedJob
+	seq     uint64
+	turn    uint64
+	served  map[string]uint64
+	buckets map[string]*bucket
+	closed  bool
+	running sync.WaitGroup
+}
+
+// NewJobQueue - starts workers running queued jobs with run
+func NewJobQueue(opts QueueOptions, run func(id uint64)) *JobQueue {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	q := &JobQueue{opts: opts, run: run, served: make(map[string]uint64), buckets: make(map[string]*bucket)}
+	q.wake = sync.NewCond(&q.mu)
+	for i := 0; i < opts.Workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Push - queues the job of the client, with images it has to recognize (for rate limiting)
+func (q *JobQueue) Push(id uint64, client, priority string, images int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if q.opts.Size > 0 && len(q.jobs) >= q.opts.Size {
+		return ErrQueueFull
+	}
+	if err := q.take(client, images); err != nil {
+		return err
+	}
+	q.push(id, client, priority)
+	return nil
+}
+
+// Requeue - queues a job again (after restart), without limits
+func (q *JobQueue) Requeue(id uint64, client, priority string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.push(id, client, priority)
+}
+
+func (q *JobQueue) push(id uint64, client, priority string) {
+	q.seq++
+	q.jobs = append(q.jobs, queuedJob{id: id, client: client, priority: priorities[priority], seq: q.seq})
+	queuedJobs.Set(float64(len(q.jobs)))
+	q.wake.Signal()
+}
+
+// Drain - stops picking queued jobs and waits for running ones to finish (or ctx to be done). Jobs left
+// in the queue stay persisted as queued, and are queued again on the next start.
+func (q *JobQueue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.wake.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Closed - queue is drained, see Drain
+func (q *JobQueue) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Len - jobs waiting in the queue
+func (q *JobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// Position - 1-based position of the job in the queue, 0 if it's not queued
+func (q *JobQueue) Position(id uint64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := append([]queuedJob(nil), q.jobs...)
+	served := make(map[string]uint64, len(q.served))
+	for k, v := range q.served {
+		served[k] = v
+	}
+	turn := q.turn
+	for i := 1; len(pending) > 0; i++ {
+		next := pick(pending, served)
+		if pending[next].id == id {
+			return i
+		}
+		turn++
+		served[pending[next].client] = turn
+		pending = append(pending[:next], pending[next+1:]...)
+	}
+	return 0
+}
+
+// take - spends tokens of the client's bucket
+func (q *JobQueue) take(client string, images int) error {
+	if q.opts.RateLimit <= 0 {
+		return nil
+	}
+
+	limit := float64(q.opts.RateLimit)
+	perSecond := limit / time.Hour.Seconds()
+	now := time.Now()
+	q.pruneBuckets(now, limit, perSecond)
+
+	b, ok := q.buckets[client]
+	if !ok {
+		b = &bucket{tokens: limit, updated: now}
+		q.buckets[client] = b
+	}
+	b.tokens = math.Min(limit, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+
+	// a job larger than the whole limit is let in once the bucket is full
+	need := math.Min(float64(images), limit)
+	if b.tokens < need {
+		return &RateLimitError{RetryAfter: time.Duration((need - b.tokens) / perSecond * float64(time.Second))}
+	}
+	b.tokens -= float64(images)
+	return nil
+}
+
+// pruneBuckets - forgets buckets refilled to the limit, they are the same as a new one (so the map doesn't grow
+// with every client ever seen)
+func (q *JobQueue) pruneBuckets(now time.Time, limit, perSecond float64) {
+	for client, b := range q.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*perSecond >= limit {
+			delete(q.buckets, client)
+		}
+	}
+}
+
+// pick - index of the next job: highest priority, then client served longest ago, then oldest
+func pick(jobs []queuedJob, served map[string]uint64) int {
+	best := 0
+	for i, j := range jobs[1:] {
+		b := jobs[best]
+		switch {
+		case j.priority != b.priority:
+			if j.priority > b.priority {
+				best = i + 1
+			}
+		case j.client != b.client && served[j.client] != served[b.client]:
+			if served[j.client] < served[b.client] {
+				best = i + 1
+			}
+		case j.seq < b.seq:
+			best = i + 1
+		}
+	}
+	return best
+}
+
+func (q *JobQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 && !q.closed {
+			q.wake.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		next := pick(q.jobs, q.served)
+		job := q.jobs[next]
+		q.jobs = append(q.jobs[:next], q.jobs[next+1:]...)
+		q.turn++
+		q.served[job.client] = q.turn
+		queuedJobs.Set(float64(len(q.jobs)))
+		q.running.Add(1)
+		q.mu.Unlock()
+
+		log.Debugf("[Job: %04d] Picked from queue (client: %v)", job.id, job.client)
+		q.run(job.id)
+		q.running.Done()
+	}
+}
@@ -0,0 +1,52 @@
+package www
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		admin    bool
+		want     string
+	}{
+		{PriorityHigh, true, PriorityHigh},
+		{PriorityHigh, false, PriorityNormal},
+		{PriorityNormal, false, PriorityNormal},
+		{PriorityLow, false, PriorityLow},
+		{PriorityLow, true, PriorityLow},
+	}
+	for _, tt := range tests {
+		if got := UserPriority(tt.priority, tt.admin); got != tt.want {
+			t.Errorf("UserPriority(%v, admin %v) = %v, want %v", tt.priority, tt.admin, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitBucketsArePruned(t *testing.T) {
+	q := &JobQueue{opts: QueueOptions{RateLimit: 3600}, buckets: make(map[string]*bucket)}
+
+	if err := q.take("a", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.take("b", 3600); err != nil {
+		t.Fatal(err)
+	}
+	// a refills in 10 seconds, b would take an hour
+	q.buckets["a"].updated = q.buckets["a"].updated.Add(-11 * time.Second)
+
+	if err := q.take("c", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := q.buckets["a"]; ok {
+		t.Errorf("refilled bucket of a is kept")
+	}
+	if _, ok := q.buckets["b"]; !ok {
+		t.Errorf("bucket of b is gone, it's still empty")
+	}
+
+	if _, ok := q.take("b", 10).(*RateLimitError); !ok {
+		t.Errorf("b queued over its limit")
+	}
+}
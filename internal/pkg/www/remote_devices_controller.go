@@ -1,6 +1,7 @@
 package www
 
 import (
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
 	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
 	wsserver "github.com/rokmonster/ocr/internal/pkg/www/websocket/server"
 	"net/http"
@@ -23,17 +24,17 @@ type ServerClient struct {
 }
 
 type RemoteDevicesController struct {
-	clients      map[uuid.UUID]ServerClient
-	upgrader     websocket.Upgrader
-	templatesDir string
-	tessdataDir  string
+	clients     map[uuid.UUID]ServerClient
+	upgrader    websocket.Upgrader
+	templates   *templatestore.Store
+	tessdataDir string
 }
 
-func NewRemoteDevicesController(templates, tessdata string) *RemoteDevicesController {
+func NewRemoteDevicesController(templates *templatestore.Store, tessdata string) *RemoteDevicesController {
 	return &RemoteDevicesController{
-		clients:      make(map[uuid.UUID]ServerClient),
-		templatesDir: templates,
-		tessdataDir:  tessdata,
+		clients:     make(map[uuid.UUID]ServerClient),
+		templates:   templates,
+		tessdataDir: tessdata,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				// who care's about CORS?
@@ -100,7 +101,7 @@ func (controller *RemoteDevicesController) Websocket(c *gin.Context) {
 		sessionId := uuid.New()
 
 		// register handler && start the loop
-		handler := wsserver.NewRemoteServerWS(ws, sessionId.String(), controller.templatesDir, controller.tessdataDir)
+		handler := wsserver.NewRemoteServerWS(ws, sessionId.String(), controller.templates, controller.tessdataDir)
 		device := ServerClient{
 			Address: ws.RemoteAddr().String(),
 			Name:    deviceInfo.Serial,
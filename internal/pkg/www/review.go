@@ -0,0 +1,196 @@
+package www
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/storage"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/training"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReviewItem - field of a result a reviewer should look at: read with low confidence, or failing validation
+type ReviewItem struct {
+	// Result - index of the result in the job
+	Result     int
+	Filename   string
+	Row        int
+	Field      string
+	Value      string
+	Text       string
+	Confidence float64
+	Problems   []string
+	Corrected  bool
+}
+
+// reviewItems - fields to review, every recognized field with all
+func reviewItems(job *OCRJob, all bool) []ReviewItem {
+	var items []ReviewItem
+	for i, r := range job.Results {
+		if len(r.Error) > 0 {
+			continue
+		}
+		problems := job.Template.ValidateResult(r)
+		for _, k := range job.Template.FieldKeys() {
+			f := r.Fields[k]
+			if !all && !f.LowConfidence && len(problems[k]) == 0 {
+				continue
+			}
+			items = append(items, ReviewItem{
+				Result:     i,
+				Filename:   r.Filename,
+				Row:        r.Row,
+				Field:      k,
+				Value:      ocrschema.FormatValue(r.Data[k]),
+				Text:       strings.TrimSpace(f.Text),
+				Confidence: f.Confidence,
+				Problems:   problems[k],
+				Corrected:  f.Corrected,
+			})
+		}
+	}
+	return items
+}
+
+// resultCrops - crops of the fields the result was read from, out of the uploaded screenshot.
+// PDF pages & records merged of several screenshots have none.
+func (controller *JobsController) resultCrops(ctx context.Context, job *OCRJob, r ocrschema.OCRResult) (map[string]image.Image, error) {
+	if r.Page > 0 || len(r.Parts) > 1 {
+		return nil, fmt.Errorf("%v: no crops of pages & merged records", r.Filename)
+	}
+
+	f, err := controller.storage.Get(ctx, storage.Key(job.MediaPrefix(), r.Filename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := imgutils.ReadImage(f)
+	if err != nil {
+		return nil, err
+	}
+	// recognition crops borders off (see tesseractutils.DefaultOptions)
+	img = imgutils.CropBorders(img)
+	return job.Template.ResultCrops(job.Template.Panel(img, r), r), nil
+}
+
+// ReviewJob - fields of the job to review (?all=1 - every field), with their crops & a form for corrections
+func (controller *JobsController) ReviewJob(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	job := controller.getJob(id)
+	all := len(c.Query("all")) > 0
+
+	c.HTML(http.StatusOK, "job_review.html", gin.H{
+		"userdata": c.MustGet(middlewares.AuthUserData),
+		"job":      job,
+		"items":    reviewItems(job, all),
+		"all":      all,
+		"saved":    c.Query("saved"),
+		"training": len(controller.TrainingDirectory) > 0,
+	})
+}
+
+// ReviewCrop - PNG crop of a field of the result
+func (controller *JobsController) ReviewCrop(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	job := controller.getJob(id)
+
+	index, err := strconv.Atoi(c.Param("result"))
+	if err != nil || index < 0 || index >= len(job.Results) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	crops, err := controller.resultCrops(c.Request.Context(), job, job.Results[index])
+	if err != nil {
+		log.Debugf("[Job: %04d] No crop: %v", id, err)
+	}
+	crop, ok := crops[c.Param("field")]
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	c.Header("Content-Type", "image/png")
+	c.Header("Cache-Control", "private, max-age=3600")
+	if err := png.Encode(c.Writer, crop); err != nil {
+		log.Warnf("[Job: %04d] Can't write crop: %v", id, err)
+	}
+}
+
+// SaveReview - applies corrections (fix.<result>.<field> form values) to the results, reports are written again.
+// With training checked (and -training-dir set), corrected crops are added to the training corpus.
+func (controller *JobsController) SaveReview(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 0, 64)
+	job := controller.getJob(id)
+	if !job.Finished() {
+		c.Redirect(http.StatusFound, fmt.Sprintf("/jobs/%v/review", id))
+		return
+	}
+
+	_ = c.Request.ParseForm()
+	saveTraining := len(controller.TrainingDirectory) > 0 && c.PostForm("training") == "on"
+
+	corrected := 0
+	for name, values := range c.Request.PostForm {
+		if !strings.HasPrefix(name, "fix.") || len(values) == 0 {
+			continue
+		}
+		index, field, ok := strings.Cut(strings.TrimPrefix(name, "fix."), ".")
+		i, err := strconv.Atoi(index)
+		text := strings.TrimSpace(values[0])
+		if !ok || err != nil || i < 0 || i >= len(job.Results) || len(text) == 0 {
+			continue
+		}
+
+		r := &job.Results[i]
+		if f, ok := r.Fields[field]; ok && f.Text == text {
+			continue
+		}
+		if err := job.Template.Correct(r, field, text); err != nil {
+			log.Warnf("[Job: %04d] Can't correct %v of %v: %v", id, field, r.Filename, err)
+			continue
+		}
+		corrected++
+
+		if saveTraining {
+			controller.addTrainingSample(c.Request.Context(), job, *r, field, text)
+		}
+	}
+
+	if corrected > 0 {
+		log.Infof("[Job: %04d] %v fields corrected by %v", id, corrected, displayName(middlewares.CurrentUser(c)))
+		_ = controller.updateJobResults(id, job.Results)
+		controller.saveReports(job, job.Template, job.Results)
+	}
+
+	redirect := fmt.Sprintf("/jobs/%v/review?saved=%v", id, corrected)
+	if len(c.PostForm("all")) > 0 {
+		redirect += "&all=1"
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// addTrainingSample - crop of the corrected field & its text, named job<id>_<correction name>
+func (controller *JobsController) addTrainingSample(ctx context.Context, job *OCRJob, r ocrschema.OCRResult, field, text string) {
+	crops, err := controller.resultCrops(ctx, job, r)
+	crop, ok := crops[field]
+	if err != nil || !ok {
+		log.Warnf("[Job: %04d] No training sample of %v of %v: %v", job.ID, field, r.Filename, err)
+		return
+	}
+
+	name := fmt.Sprintf("job%d_%s", job.ID, training.Correction{Result: r, Field: field}.Name())
+	if err := training.AddSample(controller.TrainingDirectory, name, crop, text); err != nil {
+		log.Warnf("[Job: %04d] Can't add training sample: %v", job.ID, err)
+	}
+}
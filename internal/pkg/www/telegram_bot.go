@@ -0,0 +1,383 @@
+package www
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
+	"github.com/rokmonster/ocr/internal/pkg/utils/retryutils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org"
+	// telegramPollTimeout - long polling, getUpdates waits this long for new messages
+	telegramPollTimeout = 30 * time.Second
+	// telegramMaxRows - results of more rows (e.g. a kills ranking) are sent as CSV file
+	telegramMaxRows = 5
+	// telegramMaxMessage - Telegram limit of message text, longer replies are sent as CSV file
+	telegramMaxMessage = 4096
+	// telegramMaxDownload - bots can't download bigger files
+	telegramMaxDownload = 20 << 20
+)
+
+const telegramUsage = `Send me screenshots, I reply with the stats I read from them.
+
+Send them as files (📎 → File) if you can, Telegram compresses photos and small numbers get harder to read.
+Add "csv" to the caption to get a CSV file instead of a message, lists of more than %d rows are always sent as CSV.`
+
+// TelegramBot - recognizes screenshots sent to a Telegram bot and replies with the stats read from them,
+// as a message or CSV file. Screenshots are recognized one at a time, so the bot doesn't slow down jobs.
+type TelegramBot struct {
+	token  string
+	reader *screenshotReader
+	// chats - ids of chats which can use the bot, any chat if empty
+	chats  map[string]bool
+	retry  retryutils.Options
+	client *http.Client
+}
+
+func NewTelegramBot(token string, templates *templatestore.Store, tessdata string, chats []string) *TelegramBot {
+	allowed := make(map[string]bool)
+	for _, id := range chats {
+		if id = strings.TrimSpace(id); len(id) > 0 {
+			allowed[id] = true
+		}
+	}
+
+	return &TelegramBot{
+		token:  token,
+		reader: &screenshotReader{templates: templates, tessdataDir: tessdata},
+		chats:  allowed,
+		retry:  retryutils.Options{MaxRetries: 3, Backoff: time.Second},
+		client: &http.Client{Timeout: telegramPollTimeout + 30*time.Second},
+	}
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+	Text     string         `json:"text"`
+	Caption  string         `json:"caption"`
+	Photo    []telegramFile `json:"photo"`
+	Document *struct {
+		telegramFile
+		FileName string `json:"file_name"`
+		MimeType string `json:"mime_type"`
+	} `json:"document"`
+}
+
+type telegramFile struct {
+	FileID   string `json:"file_id"`
+	FileSize int    `json:"file_size"`
+	FilePath string `json:"file_path"`
+}
+
+// Run - polls messages sent to the bot until ctx is cancelled
+func (b *TelegramBot) Run(ctx context.Context) error {
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := b.call(ctx, "getMe", nil, &me); err != nil {
+		return fmt.Errorf("telegram bot: %w", err)
+	}
+	log.Infof("Telegram bot @%v is waiting for screenshots", me.Username)
+
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := b.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			log.Warnf("Telegram bot: failed to get messages: %v", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				b.handle(ctx, *u.Message)
+			}
+		}
+	}
+}
+
+func (b *TelegramBot) handle(ctx context.Context, m telegramMessage) {
+	chat := strconv.FormatInt(m.Chat.ID, 10)
+	image := m.image()
+	private := m.Chat.Type == "private"
+
+	// in groups, only screenshots & commands are for the bot
+	if image == nil && !private && !strings.HasPrefix(m.Text, "/") {
+		return
+	}
+
+	if len(b.chats) > 0 && !b.chats[chat] {
+		log.Warnf("Telegram bot: chat %v isn't allowed to use the bot", chat)
+		b.reply(ctx, m, fmt.Sprintf("This chat (id <code>%v</code>) isn't allowed to use the bot.", chat))
+		return
+	}
+
+	switch {
+	case image != nil:
+		b.recognize(ctx, m, *image)
+	case m.Document != nil:
+		b.reply(ctx, m, "That's not a screenshot, send PNG or JPEG images.")
+	case private || strings.HasPrefix(m.Text, "/start") || strings.HasPrefix(m.Text, "/help"):
+		b.reply(ctx, m, html.EscapeString(fmt.Sprintf(telegramUsage, telegramMaxRows)))
+	}
+}
+
+// image - the screenshot of the message: image document, or the biggest size of a photo
+func (m telegramMessage) image() *telegramFile {
+	if m.Document != nil {
+		if !strings.HasPrefix(m.Document.MimeType, "image/") {
+			return nil
+		}
+		return &m.Document.telegramFile
+	}
+	if len(m.Photo) == 0 {
+		return nil
+	}
+	return &m.Photo[len(m.Photo)-1]
+}
+
+// filename - name of the screenshot, photos have none
+func (m telegramMessage) filename() string {
+	if m.Document != nil && len(m.Document.FileName) > 0 {
+		return m.Document.FileName
+	}
+	return fmt.Sprintf("photo_%d.jpg", m.MessageID)
+}
+
+func (b *TelegramBot) recognize(ctx context.Context, m telegramMessage, file telegramFile) {
+	name := m.filename()
+	logger := log.WithField("chat", m.Chat.ID).WithField("file", name)
+	_ = b.call(ctx, "sendChatAction", map[string]interface{}{"chat_id": m.Chat.ID, "action": "typing"}, nil)
+
+	data, err := b.download(ctx, file)
+	if err != nil {
+		logger.Warnf("Telegram bot: failed to download the screenshot: %v", err)
+		b.reply(ctx, m, fmt.Sprintf("Failed to download the screenshot: %v", html.EscapeString(err.Error())))
+		return
+	}
+
+	scan, err := b.reader.read(name, data)
+	if err != nil {
+		b.reply(ctx, m, fmt.Sprintf("That's not a screenshot: %v", html.EscapeString(err.Error())))
+		return
+	}
+	rows, failed, template := scan.Rows, scan.Failed, scan.Template
+	logger.Infof("Telegram bot: %v rows recognized, %v failed", len(rows), len(failed))
+
+	if len(rows) == 0 {
+		b.reply(ctx, m, fmt.Sprintf("Couldn't read the screenshot: %v", html.EscapeString(strings.Join(failed, ", "))))
+		return
+	}
+
+	text := formatTelegramResults(template, rows, len(failed))
+	if len(rows) > telegramMaxRows || len(text) > telegramMaxMessage || strings.Contains(strings.ToLower(m.Caption), "csv") {
+		var csv bytes.Buffer
+		rokocr.WriteCSV(rows, template, &csv)
+		caption := fmt.Sprintf("%v: %d rows", template.Title, len(rows))
+		if len(failed) > 0 {
+			caption += fmt.Sprintf(", %d couldn't be read", len(failed))
+		}
+		csvName := strings.TrimSuffix(name, filepath.Ext(name)) + ".csv"
+		if err := b.sendDocument(ctx, m, csvName, csv.Bytes(), caption); err != nil {
+			logger.Warnf("Telegram bot: failed to send CSV: %v", err)
+		}
+		return
+	}
+	b.reply(ctx, m, text)
+}
+
+// formatTelegramResults - template title, then "Column: value" lines of every row, fields read with low
+// confidence are marked
+func formatTelegramResults(template ocrschema.OCRTemplate, rows []ocrschema.OCRResult, failed int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<b>%v</b>\n", html.EscapeString(template.Title))
+
+	columns := rokocr.ExportColumns(template, rows)
+	for i, r := range rows {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		for _, c := range columns {
+			value := strings.TrimSpace(ocrschema.FormatValue(r.Data[c.Field]))
+			if len(value) == 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "%v: <code>%v</code>", html.EscapeString(c.Title), html.EscapeString(value))
+			if r.Fields[c.Field].LowConfidence {
+				sb.WriteString(" ⚠")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(&sb, "\n%d rows couldn't be read", failed)
+	}
+	return sb.String()
+}
+
+func (b *TelegramBot) reply(ctx context.Context, m telegramMessage, text string) {
+	err := b.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":             m.Chat.ID,
+		"text":                text,
+		"parse_mode":          "HTML",
+		"reply_to_message_id": m.MessageID,
+	}, nil)
+	if err != nil {
+		log.Warnf("Telegram bot: failed to reply to chat %v: %v", m.Chat.ID, err)
+	}
+}
+
+func (b *TelegramBot) download(ctx context.Context, file telegramFile) ([]byte, error) {
+	if file.FileSize > telegramMaxDownload {
+		return nil, fmt.Errorf("file is bigger than %d MB", telegramMaxDownload>>20)
+	}
+	if err := b.call(ctx, "getFile", map[string]interface{}{"file_id": file.FileID}, &file); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%v/file/bot%v/%v", telegramAPI, b.token, file.FilePath), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &retryutils.StatusError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, telegramMaxDownload))
+}
+
+func (b *TelegramBot) sendDocument(ctx context.Context, m telegramMessage, name string, data []byte, caption string) error {
+	return retryutils.Do(ctx, b.retry, func(ctx context.Context) error {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		_ = w.WriteField("chat_id", strconv.FormatInt(m.Chat.ID, 10))
+		_ = w.WriteField("reply_to_message_id", strconv.FormatInt(m.MessageID, 10))
+		_ = w.WriteField("caption", caption)
+		part, err := w.CreateFormFile("document", name)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		return b.do(ctx, "sendDocument", w.FormDataContentType(), &body, nil)
+	})
+}
+
+// call - Bot API method with JSON params, result is decoded into out (if not nil)
+func (b *TelegramBot) call(ctx context.Context, method string, params map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	// long polling isn't retried, Run polls again anyway
+	if method == "getUpdates" {
+		return b.do(ctx, method, "application/json", bytes.NewReader(body), out)
+	}
+	return retryutils.Do(ctx, b.retry, func(ctx context.Context) error {
+		return b.do(ctx, method, "application/json", bytes.NewReader(body), out)
+	})
+}
+
+func (b *TelegramBot) do(ctx context.Context, method, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%v/bot%v/%v", telegramAPI, b.token, method), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v: %w", method, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	var r telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return &retryutils.StatusError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	if !r.OK {
+		err := &retryutils.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("%v: %v", method, r.Description)}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if r.Parameters.RetryAfter > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(r.Parameters.RetryAfter) * time.Second):
+				}
+			}
+			return retryutils.Retryable(err)
+		}
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, out)
+}
+
+// withoutURL - errors of the client include the URL, and the token with it
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
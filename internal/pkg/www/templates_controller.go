@@ -3,15 +3,18 @@ package www
 import (
 	"encoding/json"
 	"fmt"
+	"image"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rokmonster/ocr/internal/pkg/rokocr"
 	"github.com/rokmonster/ocr/internal/pkg/www/middlewares"
 
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
 	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
 	imgutils2 "github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
 	"github.com/rokmonster/ocr/internal/pkg/utils/stringutils"
@@ -32,13 +35,15 @@ type TemplateMakerSession struct {
 
 type TemplatesController struct {
 	sessions     map[string]TemplateMakerSession
+	templates    *templatestore.Store
 	templatesDir string
 	tessdataDir  string
 }
 
-func NewTemplatesController(templateDir, tessdataDir string) *TemplatesController {
+func NewTemplatesController(templates *templatestore.Store, templateDir, tessdataDir string) *TemplatesController {
 	return &TemplatesController{
 		sessions:     make(map[string]TemplateMakerSession),
+		templates:    templates,
 		templatesDir: templateDir,
 		tessdataDir:  tessdataDir,
 	}
@@ -90,23 +95,24 @@ func (controller *TemplatesController) buildTemplate(id string, threshold int, s
 	}
 
 	return &schema.OCRTemplate{
-		Title:       fmt.Sprintf("ROK OCR Monster Template [%s]", id),
-		Version:     "1",
-		Fingerprint: fmt.Sprintf("%x", hash.GetHash()),
-		Width:       img.Bounds().Dx(),
-		Height:      img.Bounds().Dy(),
-		Author:      "ROK OCR Template Maker",
-		Threshold:   threshold,
-		OCRSchema:   s.schema,
-		Table:       controller.makeTable(s.schema),
-		Checkpoints: s.checkpoints,
+		SchemaVersion: schema.CurrentSchemaVersion,
+		Title:         fmt.Sprintf("ROK OCR Monster Template [%s]", id),
+		Version:       "1",
+		Fingerprint:   fmt.Sprintf("%x", hash.GetHash()),
+		Width:         img.Bounds().Dx(),
+		Height:        img.Bounds().Dy(),
+		Author:        "ROK OCR Template Maker",
+		Threshold:     threshold,
+		OCRSchema:     s.schema,
+		Table:         controller.makeTable(s.schema),
+		Checkpoints:   s.checkpoints,
 	}, nil
 }
 
 func (controller *TemplatesController) ListTemplates(c *gin.Context) {
 	c.HTML(http.StatusOK, "templates.html", gin.H{
 		"userdata":  c.MustGet(middlewares.AuthUserData),
-		"templates": schema.LoadTemplates(controller.templatesDir),
+		"templates": controller.templates.Templates(),
 	})
 }
 
@@ -172,7 +178,7 @@ func (controller *TemplatesController) TestTemplateByID(c *gin.Context) {
 
 		c.JSON(http.StatusOK, gin.H{
 			"fingerprint": fmt.Sprintf("%x", template.Hash().GetHash()),
-			"results":     tesseractutils.ParseImage("test", img, *template, os.TempDir(), controller.tessdataDir).Data,
+			"results":     controller.parseImage(c, "test", img, *template).Data,
 		})
 		return
 	}
@@ -212,6 +218,31 @@ func (controller *TemplatesController) ExportTemplateByID(c *gin.Context) {
 	c.JSON(http.StatusNotFound, gin.H{})
 }
 
+// newAreaField - "number" & "text" are the original builder types, rest are ocrschema.FieldTypes
+func (controller *TemplatesController) newAreaField(fieldType string, crop *schema.OCRCrop) schema.OCRSchema {
+	switch fieldType {
+	case "number":
+		return schema.NewNumberField(crop)
+	case schema.TypeInt, schema.TypeFloat, schema.TypePercent:
+		field := schema.NewNumberField(crop)
+		field.Type = fieldType
+		switch fieldType {
+		case schema.TypeFloat:
+			field.AllowList = append(field.AllowList, ".", ",")
+		case schema.TypePercent:
+			field.AllowList = append(field.AllowList, ".", ",", "%")
+		}
+		return field
+	case schema.TypeDuration:
+		field := schema.NewNumberField(crop)
+		field.Type = fieldType
+		field.AllowList = append(field.AllowList, ":", "d", "h", "m", "s", " ")
+		return field
+	default:
+		return schema.NewTextField(crop, rokocr.AvailableLanguages(controller.tessdataDir)...)
+	}
+}
+
 func (controller *TemplatesController) AddAreaOnTemplate(c *gin.Context) {
 	if s, ok := controller.sessions[c.Param("session")]; ok {
 		var postData rokTemplateArea
@@ -219,26 +250,82 @@ func (controller *TemplatesController) AddAreaOnTemplate(c *gin.Context) {
 		_ = c.MustBindWith(&postData, binding.JSON)
 
 		if len(strings.TrimSpace(postData.Name)) > 0 {
-			if postData.Type == "number" {
-				s.schema[postData.Name] = schema.NewNumberField(&schema.OCRCrop{
-					X: postData.X,
-					Y: postData.Y,
-					W: postData.W,
-					H: postData.H,
-				})
-			} else {
-				s.schema[postData.Name] = schema.NewTextField(&schema.OCRCrop{
+			s.schema[postData.Name] = controller.newAreaField(postData.Type, &schema.OCRCrop{
+				X: postData.X,
+				Y: postData.Y,
+				W: postData.W,
+				H: postData.H,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"schema":      s.schema,
+			"checkpoints": s.checkpoints,
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{})
+}
+
+func (controller *TemplatesController) RemoveAreaFromTemplate(c *gin.Context) {
+	if s, ok := controller.sessions[c.Param("session")]; ok {
+		var postData struct {
+			Name string `json:"name" binding:"required"`
+		}
+
+		_ = c.MustBindWith(&postData, binding.JSON)
+		delete(s.schema, postData.Name)
+
+		c.JSON(http.StatusOK, gin.H{
+			"schema":      s.schema,
+			"checkpoints": s.checkpoints,
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{})
+}
+
+// PreviewArea - recognizes just the area being drawn, so user sees what OCR reads before adding it
+func (controller *TemplatesController) PreviewArea(c *gin.Context) {
+	if s, ok := controller.sessions[c.Param("session")]; ok {
+		var postData struct {
+			rokCropCoordinates
+			Type string `json:"type"`
+		}
+
+		if err := c.MustBindWith(&postData, binding.JSON); err != nil {
+			return
+		}
+
+		img, err := imgutils2.ReadImageFile(s.imagePath)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"err": err.Error(),
+			})
+			return
+		}
+
+		const name = "preview"
+		template := schema.OCRTemplate{
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+			OCRSchema: map[string]schema.OCRSchema{
+				name: controller.newAreaField(postData.Type, &schema.OCRCrop{
 					X: postData.X,
 					Y: postData.Y,
 					W: postData.W,
 					H: postData.H,
-				}, rokocr.AvailableLanguages(controller.tessdataDir)...)
-			}
+				}),
+			},
 		}
 
+		field := controller.parseImage(c, name, img, template).Fields[name]
 		c.JSON(http.StatusOK, gin.H{
-			"schema":      s.schema,
-			"checkpoints": s.checkpoints,
+			"text":       field.Text,
+			"value":      schema.FormatValue(field.Value),
+			"confidence": field.Confidence,
 		})
 		return
 	}
@@ -246,6 +333,31 @@ func (controller *TemplatesController) AddAreaOnTemplate(c *gin.Context) {
 	c.JSON(http.StatusNotFound, gin.H{})
 }
 
+// DownloadTemplateByID - same template as export, but offered as a download instead of saving to templates dir
+func (controller *TemplatesController) DownloadTemplateByID(c *gin.Context) {
+	if s, ok := controller.sessions[c.Param("session")]; ok {
+		threshold, err := strconv.Atoi(c.DefaultQuery("threshold", "1"))
+		if err != nil || threshold < 1 {
+			threshold = 1
+		}
+
+		template, err := controller.buildTemplate(time.Now().Format("20060102_150405"), threshold, s)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"err": err.Error(),
+			})
+			return
+		}
+
+		bytes, _ := json.MarshalIndent(template, "", "  ")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=builder_%s.json", c.Param("session")))
+		c.Data(http.StatusOK, "application/json", bytes)
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{})
+}
+
 func (controller *TemplatesController) AddCheckpointOnTemplate(c *gin.Context) {
 	sessionId := c.Param("session")
 	if s, ok := controller.sessions[sessionId]; ok {
@@ -282,3 +394,16 @@ func (controller *TemplatesController) AddCheckpointOnTemplate(c *gin.Context) {
 
 	c.JSON(http.StatusNotFound, gin.H{})
 }
+
+// parseImage - recognizes the image for a preview, stopped when the browser goes away
+func (controller *TemplatesController) parseImage(c *gin.Context, name string, img image.Image, template schema.OCRTemplate) schema.OCRResult {
+	opts := tesseractutils.DefaultOptions(controller.tessdataDir).WithContext(c.Request.Context())
+	return tesseractutils.ParseImageWithOptions(name, img, template, opts)
+}
+
+// TemplateJSONSchema - GET /schema/template.schema.json, JSON Schema of templates for editors. No auth required,
+// editors fetch it on their own.
+func TemplateJSONSchema(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, schema.TemplateJSONSchema())
+}
@@ -20,25 +20,26 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/olekukonko/tablewriter"
 	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/templatestore"
 	log "github.com/sirupsen/logrus"
 )
 
-func NewRemoteServerWS(socket *websocket.Conn, device, templates, tessdata string) *RemoteServerWS {
+func NewRemoteServerWS(socket *websocket.Conn, device string, templates *templatestore.Store, tessdata string) *RemoteServerWS {
 	return &RemoteServerWS{
-		results:      make([]ocrschema.OCRResult, 0),
-		templatesDir: templates,
-		tessdataDir:  tessdata,
-		device:       device,
-		socket:       socket,
+		results:     make([]ocrschema.OCRResult, 0),
+		templates:   templates,
+		tessdataDir: tessdata,
+		device:      device,
+		socket:      socket,
 	}
 }
 
 type RemoteServerWS struct {
-	socket       *websocket.Conn
-	device       string
-	templatesDir string
-	tessdataDir  string
-	results      []ocrschema.OCRResult
+	socket      *websocket.Conn
+	device      string
+	templates   *templatestore.Store
+	tessdataDir string
+	results     []ocrschema.OCRResult
 }
 
 func (c *RemoteServerWS) Disconnect() error {
@@ -69,9 +70,8 @@ func (c *RemoteServerWS) requestTap(x, y int) error {
 }
 
 func (c *RemoteServerWS) processImage(img image.Image) {
-	templates := ocrschema.LoadTemplates(c.templatesDir)
-	imageHash, _ := goimagehash.DifferenceHash(img)
-	t := ocrschema.PickTemplate(imageHash, templates)
+	templates := c.templates.Templates()
+	t := ocrschema.PickTemplateForImage(img, templates)
 	// TODO: Check if match???
 
 	fileName := fmt.Sprintf("remoteimage_%v.png", time.Now().Format("20060102_150405"))
@@ -111,7 +111,7 @@ func (c *RemoteServerWS) printAll() {
 
 		for _, x := range headers {
 			if value, ok := row.Data[x]; ok {
-				rowData = append(rowData, fmt.Sprintf("%v", value))
+				rowData = append(rowData, ocrschema.FormatValue(value))
 			} else {
 				rowData = append(rowData, "")
 			}
@@ -125,7 +125,7 @@ func (c *RemoteServerWS) printAll() {
 }
 
 func (c *RemoteServerWS) isScreenInteresting(w, h int, hash *goimagehash.ImageHash) bool {
-	templates := ocrschema.LoadTemplates(c.templatesDir)
+	templates := c.templates.Templates()
 	t := ocrschema.PickTemplate(hash, templates)
 	return t.Match(hash)
 }
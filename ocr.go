@@ -0,0 +1,142 @@
+// Package ocr - recognition of Rise of Kingdoms screenshots, for embedding into other Go projects.
+//
+// Templates describe a screen (fingerprint to match it, and crop areas of fields to read), a Scanner picks
+// the matching template of every image and reads its fields:
+//
+//	templates, err := ocr.LoadTemplates("./templates")
+//	if err != nil {
+//		log.Printf("some templates were skipped: %v", err)
+//	}
+//
+//	scanner := ocr.NewScanner(templates, ocr.Options{Tessdata: "./tessdata"})
+//	defer scanner.Close()
+//
+//	results, err := scanner.ScanFile(ctx, "screenshot.png")
+//	if errors.Is(err, ocr.ErrNoTemplateMatch) {
+//		...
+//	}
+//	fmt.Println(results[0].Data["power"])
+//
+// Results are exported with WriteCSV, WriteXLSX or WriteJSON.
+//
+// Packages under internal/ are free to change between releases, this package (and api/rokocr/v1) is the stable API:
+// its types are its own, and converted from the internal ones.
+package ocr
+
+import (
+	"errors"
+	"image"
+	"path/filepath"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/quality"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+)
+
+// Template - screen of the game and fields to read from it (JSON files made with rok-server template maker).
+// Loaded with LoadTemplate(s), the file format is described in docs/guides/creating-a-template.md.
+type Template struct {
+	t ocrschema.OCRTemplate
+}
+
+// Title - name of the screen, e.g. "Profile page (Pixel 5)"
+func (t Template) Title() string {
+	return t.t.Title
+}
+
+func (t Template) Version() string {
+	return t.t.Version
+}
+
+// Size - resolution the template was made for, screenshots of other resolutions are scaled
+func (t Template) Size() (width, height int) {
+	return t.t.Width, t.t.Height
+}
+
+// Columns - names of the fields in the order they're exported (template table)
+func (t Template) Columns() []string {
+	var columns []string
+	for _, c := range rokocr.ExportColumns(t.t, nil) {
+		columns = append(columns, c.Field)
+	}
+	return columns
+}
+
+// Result - fields read from an image (a ranking list produces a result per row). Data holds typed values by
+// field name, Fields - how they were recognized (text, confidence, crop)
+type Result struct {
+	Filename string `json:"filename"`
+	// Panel, Page & Row - 1-based panel of a stitched screenshot, page of a PDF & row of a ranking list, 0 if none
+	Panel    int                    `json:"panel,omitempty"`
+	Page     int                    `json:"page,omitempty"`
+	Row      int                    `json:"row,omitempty"`
+	Template string                 `json:"template,omitempty"`
+	Data     map[string]interface{} `json:"data"`
+	Fields   map[string]FieldResult `json:"fields,omitempty"`
+	// Quality - why the image is unreadable (blurry, letterboxed, ...), Error - why it failed
+	Quality []string `json:"quality,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// FieldResult - how a single field was recognized
+type FieldResult struct {
+	// Crop - area of the image the field was read from
+	Crop       image.Rectangle `json:"crop"`
+	Text       string          `json:"text"`
+	Confidence float64         `json:"confidence"`
+	Value      interface{}     `json:"value"`
+	// LowConfidence - confidence stayed below Options.MinConfidence, even after retries
+	LowConfidence bool `json:"low_confidence,omitempty"`
+}
+
+// Image - named image to scan, results are reported by the name
+type Image struct {
+	Name  string
+	Image image.Image
+}
+
+// EngineConfig - OCR engine: gosseract (libtesseract, default), cli (tesseract binary), remote (tesseract server),
+// google (Cloud Vision) or azure (AI Vision)
+type EngineConfig struct {
+	// Name - one of the engines above, empty - gosseract
+	Name string
+	// Binary - path of tesseract binary (cli)
+	Binary string
+	// URL - address of tesseract server (remote)
+	URL string
+	// GoogleAPIKey - Cloud Vision API key (google)
+	GoogleAPIKey string
+	// AzureEndpoint & AzureKey - AI Vision resource (azure)
+	AzureEndpoint string
+	AzureKey      string
+}
+
+var (
+	// ErrNoTemplateMatch - none of the templates matches the image
+	ErrNoTemplateMatch = tesseractutils.ErrNoTemplateMatch
+	// ErrLowQuality - image is too blurry (or otherwise unreadable) to be recognized
+	ErrLowQuality = quality.ErrLowQuality
+)
+
+// LoadTemplate - reads a JSON (or binary) template
+func LoadTemplate(file string) (Template, error) {
+	load := ocrschema.LoadTemplate
+	if filepath.Ext(file) == ocrschema.TemplateBinaryExt {
+		load = ocrschema.LoadTemplateBinary
+	}
+	t, err := load(file)
+	return Template{t: t}, err
+}
+
+// LoadTemplates - loads all templates of the directory. Templates which couldn't be loaded are skipped and
+// reported in the error, the rest is still returned.
+func LoadTemplates(dir string) ([]Template, error) {
+	templates, skipped := ocrschema.LoadTemplatesWithErrors(dir)
+
+	var errs []error
+	for _, e := range skipped {
+		errs = append(errs, e)
+	}
+	return publicTemplates(templates), errors.Join(errs...)
+}
@@ -0,0 +1,188 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rokmonster/ocr/internal/pkg/ocrschema"
+	"github.com/rokmonster/ocr/internal/pkg/rokocr/tesseractutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/fileutils"
+	"github.com/rokmonster/ocr/internal/pkg/utils/imgutils"
+)
+
+// Options - how images are recognized, zero value uses ./tessdata and the default engine
+type Options struct {
+	// Tessdata - directory of tesseract language models (downloaded by rok-scanner / rok-server on first run)
+	Tessdata string
+	// TmpDir - directory for crops of fields, default os.TempDir()
+	TmpDir string
+	// Workers - how many images (and fields of an image) are recognized at once, default 1
+	Workers int
+	// Languages - language models of fields without own lang, e.g. eng+chi_sim
+	Languages []string
+	// MinConfidence - fields below it (0-100) are retried with alternative preprocessing, and flagged if still below
+	MinConfidence float64
+	// Engine - OCR engine, zero value - gosseract
+	Engine EngineConfig
+}
+
+// Scanner - recognizes images with the best matching of its templates. Safe for concurrent use, Close releases
+// OCR engines once it's not needed anymore.
+type Scanner struct {
+	templates []Template
+	internal  []ocrschema.OCRTemplate
+	workers   int
+	opts      tesseractutils.Options
+	release   func()
+}
+
+func NewScanner(templates []Template, opts Options) *Scanner {
+	if len(opts.Tessdata) == 0 {
+		opts.Tessdata = "./tessdata"
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	o := tesseractutils.DefaultOptions(opts.Tessdata)
+	if len(opts.TmpDir) > 0 {
+		o.TmpDirectory = opts.TmpDir
+	}
+	o.Jobs = opts.Workers
+	o.Languages = opts.Languages
+	o.MinConfidence = opts.MinConfidence
+	o.Engine = opts.Engine.internal()
+
+	o, release := o.Shared()
+	return &Scanner{templates: templates, internal: internalTemplates(templates), workers: opts.Workers, opts: o, release: release}
+}
+
+// Close - releases OCR engines of the scanner
+func (s *Scanner) Close() {
+	s.release()
+}
+
+// Templates - templates the scanner picks from
+func (s *Scanner) Templates() []Template {
+	return s.templates
+}
+
+// Match - best matching template of the image, false if none of them really matches
+func (s *Scanner) Match(img image.Image) (Template, bool) {
+	t, ok := ocrschema.SelectTemplate(img, s.internal)
+	return Template{t: t}, ok
+}
+
+// Scan - recognizes the image, errors wrap ErrNoTemplateMatch or ErrLowQuality when the image can't be read.
+// Results of a failed image are still returned, with the closest template match. Once ctx is done recognition
+// stops, and ctx.Err() is returned.
+func (s *Scanner) Scan(ctx context.Context, name string, img image.Image) ([]Result, error) {
+	results := tesseractutils.ProcessImageWithOptions(tesseractutils.NamedImage{Name: name, Image: img}, s.internal, s.opts.WithContext(ctx))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 1 && len(results[0].Error) > 0 {
+		return publicResults(results), resultError(results[0])
+	}
+	return publicResults(results), nil
+}
+
+// ScanFile - recognizes PNG or JPEG file, results are named by the file name
+func (s *Scanner) ScanFile(ctx context.Context, file string) ([]Result, error) {
+	img, err := imgutils.ReadImageFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return s.Scan(ctx, filepath.Base(file), img)
+}
+
+// ScanDir - recognizes all images of the directory, every image with its own best matching template.
+// Images which couldn't be read have Result.Error set, the rest of the directory is still scanned.
+// Once ctx is done, results so far are returned with ctx.Err().
+func (s *Scanner) ScanDir(ctx context.Context, dir string) ([]Result, error) {
+	files := fileutils.ListFiles(dir, fileutils.DefaultImageListOptions())
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	in := make(chan Image)
+	read := make(chan struct{})
+	failed := make(map[string]error)
+	go func() {
+		defer close(read)
+		defer close(in)
+		for _, f := range files {
+			img, err := imgutils.ReadImageFile(f)
+			if err != nil {
+				failed[filepath.Base(f)] = err
+				continue
+			}
+			select {
+			case in <- Image{Name: filepath.Base(f), Image: img}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	byFile := make(map[string][]Result)
+	for r := range s.Stream(ctx, in) {
+		byFile[r.Filename] = append(byFile[r.Filename], r)
+	}
+	<-read
+
+	// failed files in their place, results of an image come in the order of the stream
+	var results []Result
+	for _, f := range files {
+		name := filepath.Base(f)
+		if err, ok := failed[name]; ok {
+			results = append(results, Result{Filename: name, Error: err.Error()})
+			continue
+		}
+		results = append(results, byFile[name]...)
+	}
+	return results, ctx.Err()
+}
+
+// Stream - recognizes images from in with Options.Workers at once, results come in the order images did.
+// Channel is closed once in is closed (or ctx is done), failed images have Result.Error set.
+func (s *Scanner) Stream(ctx context.Context, in <-chan Image) <-chan Result {
+	images := make(chan tesseractutils.NamedImage)
+	go func() {
+		defer close(images)
+		for img := range in {
+			select {
+			case images <- tesseractutils.NamedImage{Name: img.Name, Image: img.Image}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		for r := range tesseractutils.ProcessStreamWithOptions(ctx, images, s.internal, s.workers, s.opts) {
+			select {
+			case out <- publicResult(r):
+			case <-ctx.Done():
+				// drained, so recognition stops
+			}
+		}
+	}()
+	return out
+}
+
+// resultError - why the image failed, failures of a stream are only reported in Result.Error
+func resultError(r ocrschema.OCRResult) error {
+	if len(r.Quality) > 0 {
+		return fmt.Errorf("%w: %s", ErrLowQuality, strings.Join(r.Quality, ", "))
+	}
+	return fmt.Errorf("%w: %v", ErrNoTemplateMatch, r.Error)
+}
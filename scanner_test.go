@@ -0,0 +1,45 @@
+package ocr
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanDirUnreadableImage(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.png", "3.png"} {
+		fd, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(fd, image.NewGray(image.Rect(0, 0, 64, 64)))
+		fd.Close()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2.png"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// no templates, readable images fail to match without OCR
+	s := NewScanner(nil, Options{Tessdata: t.TempDir()})
+	defer s.Close()
+
+	results, err := s.ScanDir(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(results), results)
+	}
+	for i, want := range []string{"1.png", "2.png", "3.png"} {
+		if results[i].Filename != want || len(results[i].Error) == 0 {
+			t.Errorf("results[%d] = %+v, want failed %v", i, results[i], want)
+		}
+	}
+	if results[0].Error == results[1].Error {
+		t.Errorf("unreadable 2.png failed with %q, like an image without a template", results[1].Error)
+	}
+}